@@ -1,11 +1,27 @@
 package client
 
 import (
+	"github.com/couchbase/goprotostellar/genproto/admin_bucket_v1"
+	"github.com/couchbase/goprotostellar/genproto/admin_collection_v1"
+	"github.com/couchbase/goprotostellar/genproto/admin_query_v1"
+	"github.com/couchbase/goprotostellar/genproto/admin_search_v1"
+	"github.com/couchbase/goprotostellar/genproto/analytics_v1"
 	"github.com/couchbase/goprotostellar/genproto/kv_v1"
 	"github.com/couchbase/goprotostellar/genproto/query_v1"
+	"github.com/couchbase/goprotostellar/genproto/routing_v2"
+	"github.com/couchbase/goprotostellar/genproto/search_v1"
+	"github.com/couchbase/goprotostellar/genproto/transactions_v1"
 )
 
 type Conn interface {
 	KvV1() kv_v1.KvServiceClient
 	QueryV1() query_v1.QueryServiceClient
+	SearchV1() search_v1.SearchServiceClient
+	AnalyticsV1() analytics_v1.AnalyticsServiceClient
+	AdminBucketV1() admin_bucket_v1.BucketAdminServiceClient
+	AdminCollectionV1() admin_collection_v1.CollectionAdminServiceClient
+	AdminQueryV1() admin_query_v1.QueryAdminServiceClient
+	AdminSearchV1() admin_search_v1.SearchAdminServiceClient
+	TransactionsV1() transactions_v1.TransactionsServiceClient
+	RoutingV2() routing_v2.RoutingServiceClient
 }
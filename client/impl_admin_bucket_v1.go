@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+
+	"github.com/couchbase/goprotostellar/genproto/admin_bucket_v1"
+	"google.golang.org/grpc"
+)
+
+type routingImpl_AdminBucketV1 struct {
+	client *RoutingClient
+}
+
+// Verify that routingImpl_AdminBucketV1 implements BucketAdminServiceClient
+var _ admin_bucket_v1.BucketAdminServiceClient = (*routingImpl_AdminBucketV1)(nil)
+
+func (c *routingImpl_AdminBucketV1) ListBuckets(ctx context.Context, in *admin_bucket_v1.ListBucketsRequest, opts ...grpc.CallOption) (*admin_bucket_v1.ListBucketsResponse, error) {
+	return c.client.fetchConn().AdminBucketV1().ListBuckets(ctx, in, opts...)
+}
+
+func (c *routingImpl_AdminBucketV1) CreateBucket(ctx context.Context, in *admin_bucket_v1.CreateBucketRequest, opts ...grpc.CallOption) (*admin_bucket_v1.CreateBucketResponse, error) {
+	return c.client.fetchConnForBucket(in.BucketName).AdminBucketV1().CreateBucket(ctx, in, opts...)
+}
+
+func (c *routingImpl_AdminBucketV1) UpdateBucket(ctx context.Context, in *admin_bucket_v1.UpdateBucketRequest, opts ...grpc.CallOption) (*admin_bucket_v1.UpdateBucketResponse, error) {
+	return c.client.fetchConnForBucket(in.BucketName).AdminBucketV1().UpdateBucket(ctx, in, opts...)
+}
+
+func (c *routingImpl_AdminBucketV1) DeleteBucket(ctx context.Context, in *admin_bucket_v1.DeleteBucketRequest, opts ...grpc.CallOption) (*admin_bucket_v1.DeleteBucketResponse, error) {
+	return c.client.fetchConnForBucket(in.BucketName).AdminBucketV1().DeleteBucket(ctx, in, opts...)
+}
+
+func (c *routingImpl_AdminBucketV1) FlushBucket(ctx context.Context, in *admin_bucket_v1.FlushBucketRequest, opts ...grpc.CallOption) (*admin_bucket_v1.FlushBucketResponse, error) {
+	return c.client.fetchConnForBucket(in.BucketName).AdminBucketV1().FlushBucket(ctx, in, opts...)
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+
+	"github.com/couchbase/goprotostellar/genproto/admin_query_v1"
+	"google.golang.org/grpc"
+)
+
+type routingImpl_AdminQueryV1 struct {
+	client *RoutingClient
+}
+
+// Verify that routingImpl_AdminQueryV1 implements QueryAdminServiceClient
+var _ admin_query_v1.QueryAdminServiceClient = (*routingImpl_AdminQueryV1)(nil)
+
+func (c *routingImpl_AdminQueryV1) GetAllIndexes(ctx context.Context, in *admin_query_v1.GetAllIndexesRequest, opts ...grpc.CallOption) (*admin_query_v1.GetAllIndexesResponse, error) {
+	if in.BucketName != nil {
+		return c.client.fetchConnForBucket(*in.BucketName).AdminQueryV1().GetAllIndexes(ctx, in, opts...)
+	} else {
+		return c.client.fetchConn().AdminQueryV1().GetAllIndexes(ctx, in, opts...)
+	}
+}
+
+func (c *routingImpl_AdminQueryV1) CreatePrimaryIndex(ctx context.Context, in *admin_query_v1.CreatePrimaryIndexRequest, opts ...grpc.CallOption) (*admin_query_v1.CreatePrimaryIndexResponse, error) {
+	return c.client.fetchConnForBucket(in.BucketName).AdminQueryV1().CreatePrimaryIndex(ctx, in, opts...)
+}
+
+func (c *routingImpl_AdminQueryV1) CreateIndex(ctx context.Context, in *admin_query_v1.CreateIndexRequest, opts ...grpc.CallOption) (*admin_query_v1.CreateIndexResponse, error) {
+	return c.client.fetchConnForBucket(in.BucketName).AdminQueryV1().CreateIndex(ctx, in, opts...)
+}
+
+func (c *routingImpl_AdminQueryV1) DropPrimaryIndex(ctx context.Context, in *admin_query_v1.DropPrimaryIndexRequest, opts ...grpc.CallOption) (*admin_query_v1.DropPrimaryIndexResponse, error) {
+	return c.client.fetchConnForBucket(in.BucketName).AdminQueryV1().DropPrimaryIndex(ctx, in, opts...)
+}
+
+func (c *routingImpl_AdminQueryV1) DropIndex(ctx context.Context, in *admin_query_v1.DropIndexRequest, opts ...grpc.CallOption) (*admin_query_v1.DropIndexResponse, error) {
+	return c.client.fetchConnForBucket(in.BucketName).AdminQueryV1().DropIndex(ctx, in, opts...)
+}
+
+func (c *routingImpl_AdminQueryV1) BuildDeferredIndexes(ctx context.Context, in *admin_query_v1.BuildDeferredIndexesRequest, opts ...grpc.CallOption) (*admin_query_v1.BuildDeferredIndexesResponse, error) {
+	return c.client.fetchConnForBucket(in.BucketName).AdminQueryV1().BuildDeferredIndexes(ctx, in, opts...)
+}
+
+func (c *routingImpl_AdminQueryV1) WaitForIndexOnline(ctx context.Context, in *admin_query_v1.WaitForIndexOnlineRequest, opts ...grpc.CallOption) (*admin_query_v1.WaitForIndexOnlineResponse, error) {
+	return c.client.fetchConnForBucket(in.BucketName).AdminQueryV1().WaitForIndexOnline(ctx, in, opts...)
+}
@@ -0,0 +1,23 @@
+package client
+
+import (
+	"context"
+
+	"github.com/couchbase/goprotostellar/genproto/routing_v2"
+	"google.golang.org/grpc"
+)
+
+type routingImpl_RoutingV2 struct {
+	client *RoutingClient
+}
+
+// Verify that routingImpl_RoutingV2 implements RoutingServiceClient
+var _ routing_v2.RoutingServiceClient = (*routingImpl_RoutingV2)(nil)
+
+func (c *routingImpl_RoutingV2) WatchRouting(ctx context.Context, in *routing_v2.WatchRoutingRequest, opts ...grpc.CallOption) (routing_v2.RoutingService_WatchRoutingClient, error) {
+	if in.BucketName != nil {
+		return c.client.fetchConnForBucket(*in.BucketName).RoutingV2().WatchRouting(ctx, in, opts...)
+	} else {
+		return c.client.fetchConn().RoutingV2().WatchRouting(ctx, in, opts...)
+	}
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+
+	"github.com/couchbase/goprotostellar/genproto/transactions_v1"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// routingImpl_TransactionsV1 routes transaction requests.  Transactions are
+// held in-memory by the gateway which began them, so every operation within
+// a transaction must be sent to that same gateway.
+type routingImpl_TransactionsV1 struct {
+	client *RoutingClient
+}
+
+// Verify that routingImpl_TransactionsV1 implements TransactionsServiceClient
+var _ transactions_v1.TransactionsServiceClient = (*routingImpl_TransactionsV1)(nil)
+
+func (c *routingImpl_TransactionsV1) TransactionBeginAttempt(ctx context.Context, in *transactions_v1.TransactionBeginAttemptRequest, opts ...grpc.CallOption) (*transactions_v1.TransactionBeginAttemptResponse, error) {
+	var conn *routingConn
+	if in.TransactionId != nil {
+		conn = c.client.fetchConnForTransaction(in.BucketName, *in.TransactionId)
+	} else {
+		conn = c.client.fetchConnForBucket(in.BucketName)
+	}
+
+	resp, err := conn.TransactionsV1().TransactionBeginAttempt(ctx, in, opts...)
+	if err != nil {
+		c.handleTransactionError(in.GetTransactionId(), err)
+		return nil, err
+	}
+
+	c.client.pinTransaction(resp.TransactionId, conn.address)
+	return resp, nil
+}
+
+func (c *routingImpl_TransactionsV1) TransactionCommit(ctx context.Context, in *transactions_v1.TransactionCommitRequest, opts ...grpc.CallOption) (*transactions_v1.TransactionCommitResponse, error) {
+	resp, err := c.client.fetchConnForTransaction(in.BucketName, in.TransactionId).TransactionsV1().TransactionCommit(ctx, in, opts...)
+	if err != nil {
+		c.handleTransactionError(in.TransactionId, err)
+		return nil, err
+	}
+
+	// once committed, the gateway no longer knows about the transaction
+	c.client.unpinTransaction(in.TransactionId)
+	return resp, nil
+}
+
+func (c *routingImpl_TransactionsV1) TransactionRollback(ctx context.Context, in *transactions_v1.TransactionRollbackRequest, opts ...grpc.CallOption) (*transactions_v1.TransactionRollbackResponse, error) {
+	resp, err := c.client.fetchConnForTransaction(in.BucketName, in.TransactionId).TransactionsV1().TransactionRollback(ctx, in, opts...)
+	if err != nil {
+		c.handleTransactionError(in.TransactionId, err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *routingImpl_TransactionsV1) TransactionGet(ctx context.Context, in *transactions_v1.TransactionGetRequest, opts ...grpc.CallOption) (*transactions_v1.TransactionGetResponse, error) {
+	resp, err := c.client.fetchConnForTransaction(in.BucketName, in.TransactionId).TransactionsV1().TransactionGet(ctx, in, opts...)
+	if err != nil {
+		c.handleTransactionError(in.TransactionId, err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *routingImpl_TransactionsV1) TransactionInsert(ctx context.Context, in *transactions_v1.TransactionInsertRequest, opts ...grpc.CallOption) (*transactions_v1.TransactionInsertResponse, error) {
+	resp, err := c.client.fetchConnForTransaction(in.BucketName, in.TransactionId).TransactionsV1().TransactionInsert(ctx, in, opts...)
+	if err != nil {
+		c.handleTransactionError(in.TransactionId, err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *routingImpl_TransactionsV1) TransactionReplace(ctx context.Context, in *transactions_v1.TransactionReplaceRequest, opts ...grpc.CallOption) (*transactions_v1.TransactionReplaceResponse, error) {
+	resp, err := c.client.fetchConnForTransaction(in.BucketName, in.TransactionId).TransactionsV1().TransactionReplace(ctx, in, opts...)
+	if err != nil {
+		c.handleTransactionError(in.TransactionId, err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *routingImpl_TransactionsV1) TransactionRemove(ctx context.Context, in *transactions_v1.TransactionRemoveRequest, opts ...grpc.CallOption) (*transactions_v1.TransactionRemoveResponse, error) {
+	resp, err := c.client.fetchConnForTransaction(in.BucketName, in.TransactionId).TransactionsV1().TransactionRemove(ctx, in, opts...)
+	if err != nil {
+		c.handleTransactionError(in.TransactionId, err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// handleTransactionError forgets about transactions which the gateway has
+// told us no longer exist.
+func (c *routingImpl_TransactionsV1) handleTransactionError(transactionId string, err error) {
+	if transactionId == "" {
+		return
+	}
+
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.NotFound {
+		return
+	}
+
+	for _, detail := range st.Details() {
+		if resourceInfo, ok := detail.(*epb.ResourceInfo); ok && resourceInfo.ResourceType == "transaction" {
+			c.client.unpinTransaction(transactionId)
+		}
+	}
+}
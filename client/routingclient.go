@@ -1,25 +1,64 @@
 package client
 
 import (
+	"context"
 	"crypto/x509"
 	"math/rand"
 	"net"
+	"slices"
 	"sync"
+	"time"
 
+	"github.com/couchbase/goprotostellar/genproto/admin_bucket_v1"
+	"github.com/couchbase/goprotostellar/genproto/admin_collection_v1"
+	"github.com/couchbase/goprotostellar/genproto/admin_query_v1"
+	"github.com/couchbase/goprotostellar/genproto/admin_search_v1"
+	"github.com/couchbase/goprotostellar/genproto/analytics_v1"
 	"github.com/couchbase/goprotostellar/genproto/kv_v1"
 	"github.com/couchbase/goprotostellar/genproto/query_v1"
+	"github.com/couchbase/goprotostellar/genproto/routing_v2"
+	"github.com/couchbase/goprotostellar/genproto/search_v1"
+	"github.com/couchbase/goprotostellar/genproto/transactions_v1"
 	"go.uber.org/zap"
 )
 
+// watchRoutingRetryInterval is how long we wait before re-establishing a
+// routing watch which has failed.
+const watchRoutingRetryInterval = 1 * time.Second
+
+// transactionAffinityTimeout is how long we remember which gateway a
+// transaction lives on after it was last used.
+const transactionAffinityTimeout = 5 * time.Minute
+
 type routingClient_Bucket struct {
 	RefCount uint
+	Ctx      context.Context
+	Cancel   context.CancelFunc
 }
 
 type RoutingClient struct {
-	routing *atomicRoutingTable
-	lock    sync.Mutex
-	buckets map[string]*routingClient_Bucket
-	logger  *zap.Logger
+	routing  *atomicRoutingTable
+	lock     sync.Mutex
+	buckets  map[string]*routingClient_Bucket
+	gateways map[string]*routingClient_Gateway
+	logger   *zap.Logger
+
+	target              string
+	connOpts            *routingConnOptions
+	numConnsPerGateway  int
+	healthCheckInterval time.Duration
+	resolveInterval     time.Duration
+
+	closeMaintenance context.CancelFunc
+
+	txnLock     sync.Mutex
+	txnAffinity map[string]*transactionAffinity
+}
+
+// transactionAffinity records which gateway a transaction was started on.
+type transactionAffinity struct {
+	Address  string
+	LastUsed time.Time
 }
 
 // Verify that RoutingClient implements Conn
@@ -30,6 +69,18 @@ type DialOptions struct {
 	Username          string
 	Password          string
 	Logger            *zap.Logger
+
+	// NumConnsPerGateway specifies how many connections to open to each
+	// gateway, which allows more concurrent requests than the gateway's
+	// limit on concurrent streams per connection.  Defaults to 1.
+	NumConnsPerGateway int
+
+	// HealthCheckInterval specifies how often each gateway is health checked.
+	HealthCheckInterval time.Duration
+
+	// ResolveInterval specifies how often the target is re-resolved to
+	// discover gateways which have been added or removed.
+	ResolveInterval time.Duration
 }
 
 func Dial(target string, opts *DialOptions) (*RoutingClient, error) {
@@ -42,41 +93,120 @@ func Dial(target string, opts *DialOptions) (*RoutingClient, error) {
 		}
 	}
 
-	conn, err := dialRoutingConn(target, &routingConnOptions{
-		ClientCertificate: opts.ClientCertificate,
-		Username:          opts.Username,
-		Password:          opts.Password,
-	})
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	numConnsPerGateway := opts.NumConnsPerGateway
+	if numConnsPerGateway <= 0 {
+		numConnsPerGateway = 1
+	}
+
+	healthCheckInterval := opts.HealthCheckInterval
+	if healthCheckInterval <= 0 {
+		healthCheckInterval = defaultHealthCheckInterval
+	}
+
+	resolveInterval := opts.ResolveInterval
+	if resolveInterval <= 0 {
+		resolveInterval = defaultResolveInterval
+	}
+
+	addresses, err := resolveGatewayAddresses(context.Background(), target)
+	if err != nil {
+		return nil, err
+	}
+
+	targetHost, _, err := net.SplitHostPort(target)
 	if err != nil {
 		return nil, err
 	}
 
 	routing := &atomicRoutingTable{}
-	routing.Store(&routingTable{
-		Conns: []*routingConn{conn},
-	})
+	routing.Store(&routingTable{})
+
+	c := &RoutingClient{
+		routing:  routing,
+		buckets:  make(map[string]*routingClient_Bucket),
+		gateways: make(map[string]*routingClient_Gateway),
+		logger:   logger,
+
+		txnAffinity: make(map[string]*transactionAffinity),
+
+		target: target,
+		connOpts: &routingConnOptions{
+			ClientCertificate: opts.ClientCertificate,
+			Username:          opts.Username,
+			Password:          opts.Password,
+			ServerName:        targetHost,
+		},
+		numConnsPerGateway:  numConnsPerGateway,
+		healthCheckInterval: healthCheckInterval,
+		resolveInterval:     resolveInterval,
+	}
+
+	c.lock.Lock()
+	for _, address := range addresses {
+		gateway, err := c.dialGateway(address)
+		if err != nil {
+			for _, gateway := range c.gateways {
+				c.removeGatewayLocked(gateway)
+			}
+			c.lock.Unlock()
+			return nil, err
+		}
+
+		c.addGatewayLocked(gateway)
+	}
+	c.rebuildRoutingLocked()
+	c.lock.Unlock()
+
+	maintenanceCtx, cancel := context.WithCancel(context.Background())
+	c.closeMaintenance = cancel
+	go c.maintenanceThread(maintenanceCtx)
 
-	return &RoutingClient{
-		routing: routing,
-		buckets: make(map[string]*routingClient_Bucket),
-		logger:  opts.Logger,
-	}, nil
+	return c, nil
+}
+
+// Close shuts down all connections held by the client.
+func (c *RoutingClient) Close() error {
+	c.closeMaintenance()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, bucket := range c.buckets {
+		bucket.Cancel()
+	}
+	for _, gateway := range c.gateways {
+		c.removeGatewayLocked(gateway)
+	}
+
+	return nil
 }
 
 func (c *RoutingClient) OpenBucket(bucketName string) {
 	c.lock.Lock()
+	defer c.lock.Unlock()
+
 	bucket := c.buckets[bucketName]
 	if bucket != nil {
 		bucket.RefCount++
-		c.lock.Unlock()
 		return
 	}
 
-	c.buckets[bucketName] = &routingClient_Bucket{
+	ctx, cancel := context.WithCancel(context.Background())
+	bucket = &routingClient_Bucket{
 		RefCount: 1,
+		Ctx:      ctx,
+		Cancel:   cancel,
 	}
+	c.buckets[bucketName] = bucket
 
-	c.lock.Unlock()
+	for _, gateway := range c.gateways {
+		c.startBucketWatchLocked(bucketName, bucket, gateway)
+	}
 }
 
 func (c *RoutingClient) CloseBucket(bucketName string) {
@@ -104,27 +234,228 @@ func (c *RoutingClient) CloseBucket(bucketName string) {
 	}
 
 	delete(c.buckets, bucketName)
+	bucket.Cancel()
 
 	c.lock.Unlock()
+
+	c.routing.Update(func(old *routingTable) *routingTable {
+		return old.withBucket(bucketName, nil)
+	})
+}
+
+// startBucketWatchLocked starts watching the routing of a bucket from a
+// particular gateway.  The watch is stopped when either the bucket is closed
+// or the gateway is removed.  It must be called with the client lock held.
+func (c *RoutingClient) startBucketWatchLocked(
+	bucketName string,
+	bucket *routingClient_Bucket,
+	gateway *routingClient_Gateway,
+) {
+	ctx, cancel := context.WithCancel(bucket.Ctx)
+	stop := context.AfterFunc(gateway.Ctx, cancel)
+
+	go func() {
+		defer stop()
+		defer cancel()
+		c.watchBucketRouting(ctx, bucketName, gateway.Conns[0])
+	}()
+}
+
+// watchBucketRouting watches the routing information for a bucket from a
+// single gateway, keeping the routing table up to date with which vbuckets
+// are local to that gateway until the context is cancelled.
+func (c *RoutingClient) watchBucketRouting(ctx context.Context, bucketName string, conn *routingConn) {
+	for {
+		err := c.watchBucketRoutingOnce(ctx, bucketName, conn)
+		if ctx.Err() != nil {
+			return
+		}
+
+		c.logger.Debug("bucket routing watch failed, falling back to random routing",
+			zap.Error(err),
+			zap.String("bucket", bucketName),
+			zap.String("address", conn.address))
+
+		// while we are unable to watch the routing, we remove any routing
+		// information we have as it may be stale.
+		c.updateBucketEndpoint(ctx, bucketName, conn.address, 0, nil)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRoutingRetryInterval):
+		}
+	}
+}
+
+func (c *RoutingClient) watchBucketRoutingOnce(ctx context.Context, bucketName string, conn *routingConn) error {
+	watchClient, err := conn.routingV2.WatchRouting(ctx, &routing_v2.WatchRoutingRequest{
+		BucketName: &bucketName,
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := watchClient.Recv()
+		if err != nil {
+			return err
+		}
+
+		dataRouting := resp.GetVbucketDataRouting()
+		if dataRouting == nil {
+			continue
+		}
+
+		localVbuckets := make([]int, len(dataRouting.LocalVbuckets))
+		for i, vbId := range dataRouting.LocalVbuckets {
+			localVbuckets[i] = int(vbId)
+		}
+		slices.Sort(localVbuckets)
+
+		groupVbuckets := make([]int, len(dataRouting.GroupVbuckets))
+		for i, vbId := range dataRouting.GroupVbuckets {
+			groupVbuckets[i] = int(vbId)
+		}
+		slices.Sort(groupVbuckets)
+
+		c.updateBucketEndpoint(ctx, bucketName, conn.address, int(dataRouting.NumVbuckets), &dataRoutingEndpoint{
+			Address:       conn.address,
+			LocalVbuckets: localVbuckets,
+			GroupVbuckets: groupVbuckets,
+		})
+	}
+}
+
+func (c *RoutingClient) updateBucketEndpoint(
+	ctx context.Context,
+	bucketName string,
+	address string,
+	numVbuckets int,
+	endpoint *dataRoutingEndpoint,
+) {
+	c.routing.Update(func(old *routingTable) *routingTable {
+		// the bucket may have been closed while we were processing the
+		// update, in which case we must not re-add it to the table.
+		if ctx.Err() != nil {
+			return old
+		}
+
+		bucket := old.Buckets[bucketName]
+		if bucket == nil {
+			if endpoint == nil {
+				return old
+			}
+			bucket = &bucketRoutingTable{}
+		}
+
+		if endpoint == nil {
+			numVbuckets = bucket.NumVbuckets
+		}
+
+		return old.withBucket(bucketName, bucket.withEndpoint(address, numVbuckets, endpoint))
+	})
 }
 
 func (c *RoutingClient) fetchConn() *routingConn {
-	// TODO(brett19): We should probably be more clever here...
 	r := c.routing.Load()
-	randConnIdx := rand.Intn(len(r.Conns) - 1)
+	randConnIdx := rand.Intn(len(r.Conns))
 	return r.Conns[randConnIdx]
 }
 
 func (c *RoutingClient) fetchConnForBucket(bucketName string) *routingConn {
-	// TODO(brett19): Implement routing of bucket-specific requests
+	r := c.routing.Load()
+
+	// prefer gateways which are co-located with a data node for the bucket
+	bucket := r.Buckets[bucketName]
+	if bucket != nil {
+		var conns []*routingConn
+		for _, endpoint := range bucket.Endpoints {
+			if len(endpoint.LocalVbuckets) == 0 {
+				continue
+			}
+
+			conn := r.connForAddress(endpoint.Address)
+			if conn != nil {
+				conns = append(conns, conn)
+			}
+		}
+
+		if len(conns) > 0 {
+			return conns[rand.Intn(len(conns))]
+		}
+	}
+
 	return c.fetchConn()
 }
 
 func (c *RoutingClient) fetchConnForKey(bucketName string, key string) *routingConn {
-	// TODO(brett19): Implement routing of key-specific requests.
+	r := c.routing.Load()
+
+	bucket := r.Buckets[bucketName]
+	if bucket != nil {
+		endpoint := bucket.endpointForKey(key)
+		if endpoint != nil {
+			conn := r.connForAddress(endpoint.Address)
+			if conn != nil {
+				return conn
+			}
+		}
+	}
+
 	return c.fetchConn()
 }
 
+func (c *RoutingClient) pinTransaction(transactionId string, address string) {
+	c.txnLock.Lock()
+	defer c.txnLock.Unlock()
+
+	c.txnAffinity[transactionId] = &transactionAffinity{
+		Address:  address,
+		LastUsed: time.Now(),
+	}
+}
+
+func (c *RoutingClient) unpinTransaction(transactionId string) {
+	c.txnLock.Lock()
+	defer c.txnLock.Unlock()
+
+	delete(c.txnAffinity, transactionId)
+}
+
+// pruneTransactions forgets about transactions which have not been used for
+// long enough that the gateway will have cleaned them up.
+func (c *RoutingClient) pruneTransactions() {
+	c.txnLock.Lock()
+	defer c.txnLock.Unlock()
+
+	for transactionId, affinity := range c.txnAffinity {
+		if time.Since(affinity.LastUsed) > transactionAffinityTimeout {
+			delete(c.txnAffinity, transactionId)
+		}
+	}
+}
+
+func (c *RoutingClient) fetchConnForTransaction(bucketName string, transactionId string) *routingConn {
+	c.txnLock.Lock()
+	affinity := c.txnAffinity[transactionId]
+	if affinity != nil {
+		affinity.LastUsed = time.Now()
+	}
+	c.txnLock.Unlock()
+
+	if affinity != nil {
+		conn := c.routing.Load().connForAddress(affinity.Address)
+		if conn != nil {
+			return conn
+		}
+	}
+
+	// if we don't know where the transaction lives (or that gateway is gone),
+	// the best we can do is let some gateway tell us it doesn't exist.
+	return c.fetchConnForBucket(bucketName)
+}
+
 func (c *RoutingClient) KvV1() kv_v1.KvServiceClient {
 	return &routingImpl_KvV1{c}
 }
@@ -132,3 +463,35 @@ func (c *RoutingClient) KvV1() kv_v1.KvServiceClient {
 func (c *RoutingClient) QueryV1() query_v1.QueryServiceClient {
 	return &routingImpl_QueryV1{c}
 }
+
+func (c *RoutingClient) SearchV1() search_v1.SearchServiceClient {
+	return &routingImpl_SearchV1{c}
+}
+
+func (c *RoutingClient) AnalyticsV1() analytics_v1.AnalyticsServiceClient {
+	return &routingImpl_AnalyticsV1{c}
+}
+
+func (c *RoutingClient) AdminBucketV1() admin_bucket_v1.BucketAdminServiceClient {
+	return &routingImpl_AdminBucketV1{c}
+}
+
+func (c *RoutingClient) AdminCollectionV1() admin_collection_v1.CollectionAdminServiceClient {
+	return &routingImpl_AdminCollectionV1{c}
+}
+
+func (c *RoutingClient) AdminQueryV1() admin_query_v1.QueryAdminServiceClient {
+	return &routingImpl_AdminQueryV1{c}
+}
+
+func (c *RoutingClient) AdminSearchV1() admin_search_v1.SearchAdminServiceClient {
+	return &routingImpl_AdminSearchV1{c}
+}
+
+func (c *RoutingClient) TransactionsV1() transactions_v1.TransactionsServiceClient {
+	return &routingImpl_TransactionsV1{c}
+}
+
+func (c *RoutingClient) RoutingV2() routing_v2.RoutingServiceClient {
+	return &routingImpl_RoutingV2{c}
+}
@@ -3,8 +3,16 @@ package client
 import (
 	"crypto/x509"
 
+	"github.com/couchbase/goprotostellar/genproto/admin_bucket_v1"
+	"github.com/couchbase/goprotostellar/genproto/admin_collection_v1"
+	"github.com/couchbase/goprotostellar/genproto/admin_query_v1"
+	"github.com/couchbase/goprotostellar/genproto/admin_search_v1"
+	"github.com/couchbase/goprotostellar/genproto/analytics_v1"
 	"github.com/couchbase/goprotostellar/genproto/kv_v1"
 	"github.com/couchbase/goprotostellar/genproto/query_v1"
+	"github.com/couchbase/goprotostellar/genproto/routing_v2"
+	"github.com/couchbase/goprotostellar/genproto/search_v1"
+	"github.com/couchbase/goprotostellar/genproto/transactions_v1"
 	"github.com/couchbase/stellar-gateway/contrib/grpcheaderauth"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -15,12 +23,25 @@ type routingConnOptions struct {
 	ClientCertificate *x509.CertPool
 	Username          string
 	Password          string
+
+	// ServerName is the name the gateway certificates are verified against,
+	// as the gateways are dialed by the addresses the target resolves to.
+	ServerName string
 }
 
 type routingConn struct {
-	conn    *grpc.ClientConn
-	kvV1    kv_v1.KvServiceClient
-	queryV1 query_v1.QueryServiceClient
+	address           string
+	conn              *grpc.ClientConn
+	kvV1              kv_v1.KvServiceClient
+	queryV1           query_v1.QueryServiceClient
+	searchV1          search_v1.SearchServiceClient
+	analyticsV1       analytics_v1.AnalyticsServiceClient
+	adminBucketV1     admin_bucket_v1.BucketAdminServiceClient
+	adminCollectionV1 admin_collection_v1.CollectionAdminServiceClient
+	adminQueryV1      admin_query_v1.QueryAdminServiceClient
+	adminSearchV1     admin_search_v1.SearchAdminServiceClient
+	transactionsV1    transactions_v1.TransactionsServiceClient
+	routingV2         routing_v2.RoutingServiceClient
 }
 
 // Verify that routingConn implements Conn
@@ -31,7 +52,7 @@ func dialRoutingConn(address string, opts *routingConnOptions) (*routingConn, er
 	var perRpcDialOpt grpc.DialOption
 
 	if opts.ClientCertificate != nil {
-		transportDialOpt = grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(opts.ClientCertificate, ""))
+		transportDialOpt = grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(opts.ClientCertificate, opts.ServerName))
 		perRpcDialOpt = nil
 	} else if opts.Username != "" && opts.Password != "" {
 		basicAuthCreds, err := grpcheaderauth.NewGrpcBasicAuth(opts.Username, opts.Password)
@@ -57,9 +78,18 @@ func dialRoutingConn(address string, opts *routingConnOptions) (*routingConn, er
 	}
 
 	return &routingConn{
-		conn:    conn,
-		kvV1:    kv_v1.NewKvServiceClient(conn),
-		queryV1: query_v1.NewQueryServiceClient(conn),
+		address:           address,
+		conn:              conn,
+		kvV1:              kv_v1.NewKvServiceClient(conn),
+		queryV1:           query_v1.NewQueryServiceClient(conn),
+		searchV1:          search_v1.NewSearchServiceClient(conn),
+		analyticsV1:       analytics_v1.NewAnalyticsServiceClient(conn),
+		adminBucketV1:     admin_bucket_v1.NewBucketAdminServiceClient(conn),
+		adminCollectionV1: admin_collection_v1.NewCollectionAdminServiceClient(conn),
+		adminQueryV1:      admin_query_v1.NewQueryAdminServiceClient(conn),
+		adminSearchV1:     admin_search_v1.NewSearchAdminServiceClient(conn),
+		transactionsV1:    transactions_v1.NewTransactionsServiceClient(conn),
+		routingV2:         routing_v2.NewRoutingServiceClient(conn),
 	}, nil
 }
 
@@ -70,3 +100,39 @@ func (c *routingConn) KvV1() kv_v1.KvServiceClient {
 func (c *routingConn) QueryV1() query_v1.QueryServiceClient {
 	return c.queryV1
 }
+
+func (c *routingConn) SearchV1() search_v1.SearchServiceClient {
+	return c.searchV1
+}
+
+func (c *routingConn) AnalyticsV1() analytics_v1.AnalyticsServiceClient {
+	return c.analyticsV1
+}
+
+func (c *routingConn) AdminBucketV1() admin_bucket_v1.BucketAdminServiceClient {
+	return c.adminBucketV1
+}
+
+func (c *routingConn) AdminCollectionV1() admin_collection_v1.CollectionAdminServiceClient {
+	return c.adminCollectionV1
+}
+
+func (c *routingConn) AdminQueryV1() admin_query_v1.QueryAdminServiceClient {
+	return c.adminQueryV1
+}
+
+func (c *routingConn) AdminSearchV1() admin_search_v1.SearchAdminServiceClient {
+	return c.adminSearchV1
+}
+
+func (c *routingConn) TransactionsV1() transactions_v1.TransactionsServiceClient {
+	return c.transactionsV1
+}
+
+func (c *routingConn) RoutingV2() routing_v2.RoutingServiceClient {
+	return c.routingV2
+}
+
+func (c *routingConn) Close() error {
+	return c.conn.Close()
+}
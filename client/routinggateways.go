@@ -0,0 +1,300 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const defaultHealthCheckInterval = 5 * time.Second
+const defaultHealthCheckTimeout = 2 * time.Second
+const defaultResolveInterval = 30 * time.Second
+
+// routingClient_Gateway represents a single gateway instance which we are
+// connected to, potentially with multiple underlying connections.
+type routingClient_Gateway struct {
+	Address string
+	Conns   []*routingConn
+	Healthy bool
+
+	// Ctx is cancelled once the gateway is removed from the pool, which
+	// stops any bucket routing watches against it.
+	Ctx    context.Context
+	Cancel context.CancelFunc
+}
+
+// resolveGatewayAddresses resolves the target to the list of gateway
+// addresses behind it, allowing a single DNS name to front many gateways.
+func resolveGatewayAddresses(ctx context.Context, target string) ([]string, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if net.ParseIP(host) != nil {
+		return []string{target}, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(addrs) == 0 {
+		return nil, errors.New("target did not resolve to any addresses")
+	}
+
+	targets := make([]string, len(addrs))
+	for i, addr := range addrs {
+		targets[i] = net.JoinHostPort(addr, port)
+	}
+
+	return targets, nil
+}
+
+func (c *RoutingClient) dialGateway(address string) (*routingClient_Gateway, error) {
+	conns := make([]*routingConn, 0, c.numConnsPerGateway)
+	for i := 0; i < c.numConnsPerGateway; i++ {
+		conn, err := dialRoutingConn(address, c.connOpts)
+		if err != nil {
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+			return nil, err
+		}
+
+		conns = append(conns, conn)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// gateways are assumed to be healthy until we are told otherwise, so that
+	// requests can be dispatched before the first health check completes.
+	return &routingClient_Gateway{
+		Address: address,
+		Conns:   conns,
+		Healthy: true,
+		Ctx:     ctx,
+		Cancel:  cancel,
+	}, nil
+}
+
+// addGatewayLocked must be called with the client lock held.
+func (c *RoutingClient) addGatewayLocked(gateway *routingClient_Gateway) {
+	c.gateways[gateway.Address] = gateway
+
+	for bucketName, bucket := range c.buckets {
+		c.startBucketWatchLocked(bucketName, bucket, gateway)
+	}
+}
+
+// removeGatewayLocked must be called with the client lock held.
+func (c *RoutingClient) removeGatewayLocked(gateway *routingClient_Gateway) {
+	delete(c.gateways, gateway.Address)
+	gateway.Cancel()
+
+	for _, conn := range gateway.Conns {
+		err := conn.Close()
+		if err != nil {
+			c.logger.Debug("failed to close gateway connection",
+				zap.Error(err),
+				zap.String("address", gateway.Address))
+		}
+	}
+}
+
+// rebuildRoutingLocked regenerates the list of connections available for
+// routing from the healthy gateways.  It must be called with the client
+// lock held.
+func (c *RoutingClient) rebuildRoutingLocked() {
+	var conns []*routingConn
+	var endpoints []*routingEndpoint
+	for _, gateway := range c.gateways {
+		if !gateway.Healthy {
+			continue
+		}
+
+		conns = append(conns, gateway.Conns...)
+		endpoints = append(endpoints, &routingEndpoint{
+			Address: gateway.Address,
+		})
+	}
+
+	// if every gateway is unhealthy, we still need somewhere to send requests,
+	// so we fall back to using all of them and let the requests fail normally.
+	if len(conns) == 0 {
+		for _, gateway := range c.gateways {
+			conns = append(conns, gateway.Conns...)
+		}
+	}
+
+	c.routing.Update(func(old *routingTable) *routingTable {
+		newTable := &routingTable{
+			Conns:     conns,
+			Endpoints: endpoints,
+			Buckets:   make(map[string]*bucketRoutingTable, len(old.Buckets)),
+		}
+
+		// drop the routing of any gateways which are no longer in the pool
+		for bucketName, bucket := range old.Buckets {
+			newBucket := &bucketRoutingTable{
+				NumVbuckets: bucket.NumVbuckets,
+			}
+			for _, endpoint := range bucket.Endpoints {
+				if c.gateways[endpoint.Address] != nil {
+					newBucket.Endpoints = append(newBucket.Endpoints, endpoint)
+				}
+			}
+			newTable.Buckets[bucketName] = newBucket
+		}
+
+		return newTable
+	})
+}
+
+func (c *RoutingClient) checkGatewayHealth(gateway *routingClient_Gateway) bool {
+	ctx, cancel := context.WithTimeout(gateway.Ctx, defaultHealthCheckTimeout)
+	defer cancel()
+
+	healthClient := grpc_health_v1.NewHealthClient(gateway.Conns[0].conn)
+	resp, err := healthClient.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		c.logger.Debug("gateway health check failed",
+			zap.Error(err),
+			zap.String("address", gateway.Address))
+		return false
+	}
+
+	return resp.Status == grpc_health_v1.HealthCheckResponse_SERVING
+}
+
+func (c *RoutingClient) checkGatewaysHealth() {
+	c.lock.Lock()
+	gateways := make([]*routingClient_Gateway, 0, len(c.gateways))
+	for _, gateway := range c.gateways {
+		gateways = append(gateways, gateway)
+	}
+	c.lock.Unlock()
+
+	healthy := make([]bool, len(gateways))
+	for i, gateway := range gateways {
+		healthy[i] = c.checkGatewayHealth(gateway)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	changed := false
+	for i, gateway := range gateways {
+		if c.gateways[gateway.Address] != gateway {
+			// removed while we were checking it
+			continue
+		}
+
+		if gateway.Healthy != healthy[i] {
+			c.logger.Info("gateway health changed",
+				zap.String("address", gateway.Address),
+				zap.Bool("healthy", healthy[i]))
+
+			gateway.Healthy = healthy[i]
+			changed = true
+		}
+	}
+
+	if changed {
+		c.rebuildRoutingLocked()
+	}
+}
+
+// refreshGateways re-resolves the target, connecting to any newly discovered
+// gateways and disconnecting from those which have disappeared once one of
+// the discovered gateways is connected.
+func (c *RoutingClient) refreshGateways(ctx context.Context) {
+	addresses, err := resolveGatewayAddresses(ctx, c.target)
+	if err != nil {
+		c.logger.Debug("failed to resolve gateway addresses",
+			zap.Error(err),
+			zap.String("target", c.target))
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	changed := false
+
+	seen := make(map[string]struct{}, len(addresses))
+	for _, address := range addresses {
+		seen[address] = struct{}{}
+
+		if c.gateways[address] != nil {
+			continue
+		}
+
+		gateway, err := c.dialGateway(address)
+		if err != nil {
+			c.logger.Debug("failed to connect to discovered gateway",
+				zap.Error(err),
+				zap.String("address", address))
+			continue
+		}
+
+		c.logger.Info("discovered new gateway", zap.String("address", address))
+		c.addGatewayLocked(gateway)
+		changed = true
+	}
+
+	// gateways which are no longer discovered are kept until we are connected
+	// to at least one of their replacements, so that we always have somewhere
+	// to send requests.
+	connected := false
+	for address := range seen {
+		if c.gateways[address] != nil {
+			connected = true
+			break
+		}
+	}
+	if !connected {
+		c.logger.Warn("failed to connect to any discovered gateway, keeping existing gateways",
+			zap.String("target", c.target))
+		return
+	}
+
+	for address, gateway := range c.gateways {
+		if _, ok := seen[address]; ok {
+			continue
+		}
+
+		c.logger.Info("gateway no longer discovered", zap.String("address", address))
+		c.removeGatewayLocked(gateway)
+		changed = true
+	}
+
+	if changed {
+		c.rebuildRoutingLocked()
+	}
+}
+
+func (c *RoutingClient) maintenanceThread(ctx context.Context) {
+	healthTicker := time.NewTicker(c.healthCheckInterval)
+	defer healthTicker.Stop()
+
+	resolveTicker := time.NewTicker(c.resolveInterval)
+	defer resolveTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-healthTicker.C:
+			c.checkGatewaysHealth()
+			c.pruneTransactions()
+		case <-resolveTicker.C:
+			c.refreshGateways(ctx)
+		}
+	}
+}
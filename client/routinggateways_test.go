@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func startTestGateway(t *testing.T) (string, *health.Server) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	healthSrv := health.NewServer()
+	srv := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(srv, healthSrv)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String(), healthSrv
+}
+
+func newTestRoutingClient(t *testing.T, addresses ...string) *RoutingClient {
+	routing := &atomicRoutingTable{}
+	routing.Store(&routingTable{})
+
+	c := &RoutingClient{
+		routing:            routing,
+		buckets:            make(map[string]*routingClient_Bucket),
+		gateways:           make(map[string]*routingClient_Gateway),
+		logger:             zap.NewNop(),
+		txnAffinity:        make(map[string]*transactionAffinity),
+		connOpts:           &routingConnOptions{},
+		numConnsPerGateway: 1,
+	}
+
+	c.lock.Lock()
+	for _, address := range addresses {
+		gateway, err := c.dialGateway(address)
+		require.NoError(t, err)
+		c.addGatewayLocked(gateway)
+	}
+	c.rebuildRoutingLocked()
+	c.lock.Unlock()
+
+	t.Cleanup(func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		for _, gateway := range c.gateways {
+			c.removeGatewayLocked(gateway)
+		}
+	})
+
+	return c
+}
+
+func routedAddresses(c *RoutingClient) []string {
+	var addresses []string
+	for _, conn := range c.routing.Load().Conns {
+		addresses = append(addresses, conn.address)
+	}
+	return addresses
+}
+
+func TestResolveGatewayAddresses(t *testing.T) {
+	addresses, err := resolveGatewayAddresses(context.Background(), "10.0.0.1:18098")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:18098"}, addresses)
+
+	addresses, err = resolveGatewayAddresses(context.Background(), "localhost:18098")
+	require.NoError(t, err)
+	require.NotEmpty(t, addresses)
+	for _, address := range addresses {
+		_, port, err := net.SplitHostPort(address)
+		require.NoError(t, err)
+		assert.Equal(t, "18098", port)
+	}
+
+	_, err = resolveGatewayAddresses(context.Background(), "localhost")
+	assert.Error(t, err)
+}
+
+func TestRoutingClientHealthFailover(t *testing.T) {
+	addrA, healthA := startTestGateway(t)
+	addrB, healthB := startTestGateway(t)
+	c := newTestRoutingClient(t, addrA, addrB)
+
+	assert.ElementsMatch(t, []string{addrA, addrB}, routedAddresses(c))
+
+	// unhealthy gateways are no longer routed to...
+	healthA.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	c.checkGatewaysHealth()
+	assert.Equal(t, []string{addrB}, routedAddresses(c))
+	assert.Nil(t, c.routing.Load().connForAddress(addrA))
+	assert.Equal(t, addrB, c.fetchConn().address)
+
+	// ...until they recover
+	healthA.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	c.checkGatewaysHealth()
+	assert.ElementsMatch(t, []string{addrA, addrB}, routedAddresses(c))
+
+	// if every gateway is unhealthy, requests are still sent to all of them
+	healthA.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	healthB.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	c.checkGatewaysHealth()
+	assert.ElementsMatch(t, []string{addrA, addrB}, routedAddresses(c))
+}
+
+func TestRoutingClientRefreshGateways(t *testing.T) {
+	addrA, _ := startTestGateway(t)
+	addrB, _ := startTestGateway(t)
+	c := newTestRoutingClient(t, addrA)
+
+	// gateways which are no longer discovered are replaced by those which are
+	c.target = addrB
+	c.refreshGateways(context.Background())
+
+	assert.Equal(t, []string{addrB}, routedAddresses(c))
+
+	c.lock.Lock()
+	assert.Nil(t, c.gateways[addrA])
+	assert.NotNil(t, c.gateways[addrB])
+	c.lock.Unlock()
+}
+
+func TestRoutingClientRefreshGatewaysKeepsExisting(t *testing.T) {
+	addrA, _ := startTestGateway(t)
+	c := newTestRoutingClient(t, addrA)
+
+	// if none of the discovered gateways can be connected to, the existing
+	// gateways are kept rather than leaving the pool empty.
+	c.target = "127.0.0.1:%zz"
+	c.refreshGateways(context.Background())
+
+	assert.Equal(t, []string{addrA}, routedAddresses(c))
+	assert.NotNil(t, c.fetchConn())
+}
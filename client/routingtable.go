@@ -1,6 +1,12 @@
 package client
 
-import "sync/atomic"
+import (
+	"hash/crc32"
+	"maps"
+	"math/rand"
+	"slices"
+	"sync/atomic"
+)
 
 type routingEndpoint struct {
 	Address string
@@ -13,7 +19,58 @@ type dataRoutingEndpoint struct {
 }
 
 type bucketRoutingTable struct {
-	Endpoints []*dataRoutingEndpoint
+	NumVbuckets int
+	Endpoints   []*dataRoutingEndpoint
+}
+
+// vbucketForKey maps a key to its vbucket using the same CRC32 based hashing
+// that the SDKs and the server use.
+func (t *bucketRoutingTable) vbucketForKey(key string) int {
+	crc := crc32.ChecksumIEEE([]byte(key))
+	return int((crc>>16)&0x7fff) % t.NumVbuckets
+}
+
+// endpointForKey returns the endpoint which is co-located with the active
+// node for the specified key, or failing that one within the same server
+// group as it, or nil if neither is known.
+func (t *bucketRoutingTable) endpointForKey(key string) *dataRoutingEndpoint {
+	if t.NumVbuckets == 0 {
+		return nil
+	}
+
+	vbId := t.vbucketForKey(key)
+	for _, endpoint := range t.Endpoints {
+		if _, found := slices.BinarySearch(endpoint.LocalVbuckets, vbId); found {
+			return endpoint
+		}
+	}
+
+	for _, endpoint := range t.Endpoints {
+		if _, found := slices.BinarySearch(endpoint.GroupVbuckets, vbId); found {
+			return endpoint
+		}
+	}
+
+	return nil
+}
+
+// withEndpoint returns a copy of the bucket routing table with the routing
+// of a particular endpoint replaced (or removed if endpoint is nil).
+func (t *bucketRoutingTable) withEndpoint(address string, numVbuckets int, endpoint *dataRoutingEndpoint) *bucketRoutingTable {
+	newTable := &bucketRoutingTable{
+		NumVbuckets: numVbuckets,
+	}
+
+	for _, existing := range t.Endpoints {
+		if existing.Address != address {
+			newTable.Endpoints = append(newTable.Endpoints, existing)
+		}
+	}
+	if endpoint != nil {
+		newTable.Endpoints = append(newTable.Endpoints, endpoint)
+	}
+
+	return newTable
 }
 
 type routingTable struct {
@@ -23,6 +80,44 @@ type routingTable struct {
 	Buckets map[string]*bucketRoutingTable
 }
 
+// connForAddress returns one of the connections to the gateway at a specific
+// address, or nil if that gateway is not currently available for routing.
+func (t *routingTable) connForAddress(address string) *routingConn {
+	var conns []*routingConn
+	for _, conn := range t.Conns {
+		if conn.address == address {
+			conns = append(conns, conn)
+		}
+	}
+
+	if len(conns) == 0 {
+		return nil
+	}
+	return conns[rand.Intn(len(conns))]
+}
+
+// withBucket returns a copy of the routing table with the routing of a
+// particular bucket replaced (or removed if bucket is nil).
+func (t *routingTable) withBucket(bucketName string, bucket *bucketRoutingTable) *routingTable {
+	newTable := &routingTable{
+		Conns:     t.Conns,
+		Endpoints: t.Endpoints,
+		Buckets:   maps.Clone(t.Buckets),
+	}
+
+	if newTable.Buckets == nil {
+		newTable.Buckets = make(map[string]*bucketRoutingTable)
+	}
+
+	if bucket != nil {
+		newTable.Buckets[bucketName] = bucket
+	} else {
+		delete(newTable.Buckets, bucketName)
+	}
+
+	return newTable
+}
+
 type atomicRoutingTable struct {
 	Value atomic.Value
 }
@@ -38,3 +133,13 @@ func (t *atomicRoutingTable) Store(new *routingTable) {
 func (t *atomicRoutingTable) CompareAndSwap(old, new *routingTable) bool {
 	return t.Value.CompareAndSwap(old, new)
 }
+
+// Update atomically applies a copy-on-write modification to the table.
+func (t *atomicRoutingTable) Update(fn func(old *routingTable) *routingTable) {
+	for {
+		old := t.Load()
+		if t.CompareAndSwap(old, fn(old)) {
+			return
+		}
+	}
+}
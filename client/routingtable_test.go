@@ -0,0 +1,111 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVbucketForKey(t *testing.T) {
+	testCases := []struct {
+		key         string
+		numVbuckets int
+		vbId        int
+	}{
+		{key: "foo", numVbuckets: 1024, vbId: 115},
+		{key: "hello", numVbuckets: 1024, vbId: 528},
+		{key: "airline_10", numVbuckets: 1024, vbId: 361},
+		{key: "user::1234", numVbuckets: 1024, vbId: 495},
+		{key: "", numVbuckets: 1024, vbId: 0},
+		{key: "foo", numVbuckets: 64, vbId: 51},
+		{key: "airline_10", numVbuckets: 64, vbId: 41},
+	}
+
+	for _, tc := range testCases {
+		table := &bucketRoutingTable{NumVbuckets: tc.numVbuckets}
+		assert.Equal(t, tc.vbId, table.vbucketForKey(tc.key), "key %q with %d vbuckets", tc.key, tc.numVbuckets)
+	}
+}
+
+func TestEndpointForKey(t *testing.T) {
+	table := &bucketRoutingTable{
+		NumVbuckets: 1024,
+		Endpoints: []*dataRoutingEndpoint{
+			{
+				Address:       "gateway-a:18098",
+				LocalVbuckets: []int{100, 115, 200},
+				GroupVbuckets: []int{361},
+			},
+			{
+				Address:       "gateway-b:18098",
+				LocalVbuckets: []int{361, 528},
+			},
+			{
+				Address:       "gateway-c:18098",
+				GroupVbuckets: []int{495},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		key     string
+		address string
+	}{
+		{name: "Local", key: "foo", address: "gateway-a:18098"},
+		{name: "LocalPreferredOverGroup", key: "airline_10", address: "gateway-b:18098"},
+		{name: "Group", key: "user::1234", address: "gateway-c:18098"},
+		{name: "Unknown", key: "", address: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			endpoint := table.endpointForKey(tc.key)
+			if tc.address == "" {
+				assert.Nil(t, endpoint)
+				return
+			}
+
+			require.NotNil(t, endpoint)
+			assert.Equal(t, tc.address, endpoint.Address)
+		})
+	}
+
+	// without routing, no endpoint is known
+	assert.Nil(t, (&bucketRoutingTable{}).endpointForKey("foo"))
+}
+
+func TestFetchConnForKey(t *testing.T) {
+	connA := &routingConn{address: "gateway-a:18098"}
+	connB := &routingConn{address: "gateway-b:18098"}
+
+	routing := &atomicRoutingTable{}
+	routing.Store(&routingTable{
+		Conns: []*routingConn{connA, connB},
+		Buckets: map[string]*bucketRoutingTable{
+			"default": {
+				NumVbuckets: 1024,
+				Endpoints: []*dataRoutingEndpoint{
+					{Address: "gateway-b:18098", LocalVbuckets: []int{115}},
+					{Address: "gateway-gone:18098", LocalVbuckets: []int{528}},
+				},
+			},
+		},
+	})
+	c := &RoutingClient{routing: routing}
+
+	for i := 0; i < 10; i++ {
+		assert.Same(t, connB, c.fetchConnForKey("default", "foo"))
+	}
+
+	// keys whose gateway is unknown or unavailable, and buckets without
+	// routing, fall back to any connection
+	for _, fallback := range []*routingConn{
+		c.fetchConnForKey("default", "hello"),
+		c.fetchConnForKey("default", "airline_10"),
+		c.fetchConnForKey("missing", "foo"),
+	} {
+		assert.Contains(t, []*routingConn{connA, connB}, fallback)
+	}
+}
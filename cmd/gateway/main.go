@@ -4,10 +4,13 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"reflect"
 	"runtime/pprof"
 	"strings"
 	"sync"
@@ -16,7 +19,14 @@ import (
 
 	"github.com/couchbase/gocbcorex/contrib/buildversion"
 	"github.com/couchbase/stellar-gateway/gateway"
+	"github.com/couchbase/stellar-gateway/gateway/apikeys"
+	"github.com/couchbase/stellar-gateway/gateway/auth/crl"
+	"github.com/couchbase/stellar-gateway/gateway/auth/jwtauth"
+	"github.com/couchbase/stellar-gateway/gateway/auth/lockout"
+	"github.com/couchbase/stellar-gateway/gateway/auth/userfile"
+	"github.com/couchbase/stellar-gateway/gateway/ratelimiting"
 	"github.com/couchbase/stellar-gateway/pkg/webapi"
+	"github.com/couchbase/stellar-gateway/utils/certwatcher"
 	"github.com/couchbase/stellar-gateway/utils/secretsmanager"
 	"github.com/couchbase/stellar-gateway/utils/selfsignedcert"
 	"github.com/fsnotify/fsnotify"
@@ -75,11 +85,13 @@ func init() {
 
 	configFlags := pflag.NewFlagSet("", pflag.ContinueOnError)
 	configFlags.String("log-level", "info", "the log level to run at")
-	configFlags.String("cb-host", "localhost", "the couchbase server host")
+	configFlags.String("cb-host", "localhost", "the couchbase server host, or a connection string listing multiple seed nodes or a DNS SRV record")
 	configFlags.String("cb-user", "Administrator", "the couchbase server username")
 	configFlags.String("cb-pass", "password", "the couchbase server password")
 	configFlags.Bool("cb-host-is-local", false, "specifies if the cb-host node is running locally")
 	configFlags.Bool("single-user-auth", false, "enables single-user authenticating to GRPC and Data API")
+	configFlags.String("single-user-users-file", "", "path to an htpasswd-style file of additional users for single-user auth, using bcrypt or postgres-style scram hashes and optionally followed by :obo-user:obo-domain, obo-user defaults to the username and @gateway performs requests as the gateway itself")
+	configFlags.String("single-user-cert-map-file", "", "path to a json file mapping client certificate common names or sans to users for single-user auth")
 	configFlags.String("bind-address", "0.0.0.0", "the local address to bind to")
 	configFlags.Int("data-port", 18098, "the data port")
 	configFlags.Int("dapi-port", -1, "the data api port")
@@ -87,14 +99,30 @@ func init() {
 	configFlags.Bool("self-sign", false, "specifies to allow a self-signed certificate")
 	configFlags.String("cert", "", "path to default tls cert")
 	configFlags.String("cluster-cert", "", "path to cluster tls ca cert")
+	configFlags.String("cb-client-cert", "", "path to a tls client cert the gateway presents to couchbase server, set cb-user and cb-pass to empty to authenticate using only the cert")
+	configFlags.String("cb-client-key", "", "path to the private tls key of cb-client-cert, or a secret reference")
 	configFlags.String("client-ca-cert", "", "path to tls ca cert for client certs for mtls")
-	configFlags.String("key", "", "path to default private tls key")
+	configFlags.String("client-crl", "", "a comma seperated list of crl files, or directories containing them, used to check client certs for revocation")
+	configFlags.Duration("client-crl-reload-interval", 5*time.Minute, "how often to reload the client cert crls")
+	configFlags.String("key", "", "path to default private tls key, or a secret reference such as 'vault://vault:8200/secret/tls?key=tls.key'")
 	configFlags.String("grpc-cert", "", "path to grpc tls cert for GRPC")
-	configFlags.String("grpc-key", "", "path to grpc private tls key for GRPC")
+	configFlags.String("grpc-key", "", "path to grpc private tls key for GRPC, or a secret reference")
 	configFlags.String("dapi-cert", "", "path to data api tls cert for Data API")
-	configFlags.String("dapi-key", "", "path to data api private tls key for Data API")
+	configFlags.String("dapi-key", "", "path to data api private tls key for Data API, or a secret reference")
+	configFlags.String("sni-certs", "", "a comma seperated list of additional tls cert/key pairs selected by SNI, e.g 'cert1.pem:key1.pem,cert2.pem:key2.pem'")
+	configFlags.String("grpc-sni-certs", "", "a comma seperated list of additional tls cert/key pairs selected by SNI for GRPC")
+	configFlags.String("dapi-sni-certs", "", "a comma seperated list of additional tls cert/key pairs selected by SNI for Data API")
 	configFlags.Int("rate-limit", 0, "specifies the maximum requests per second to allow")
+	configFlags.String("rate-limit-shared-bucket", "", "enables sharing rate-limit across gateways, using counter documents in the specified bucket")
+	configFlags.String("rate-limit-shared-scope", "_default", "the scope of rate-limit-shared-bucket to store counter documents in")
+	configFlags.String("rate-limit-shared-collection", "_default", "the collection of rate-limit-shared-bucket to store counter documents in")
+	configFlags.Duration("rate-limit-sync-interval", 100*time.Millisecond, "how often rate-limit usage is synced with other gateways")
+	configFlags.Int("concurrency-limit-max", 0, "enables adaptive concurrency limiting, specifying the maximum number of requests in flight")
+	configFlags.Int("concurrency-limit-min", 8, "the minimum number of requests in flight the adaptive concurrency limit may fall to")
+	configFlags.Int("concurrency-limit-queue", 128, "the number of requests which may wait for the concurrency limit before requests are shed")
+	configFlags.Duration("concurrency-limit-max-wait", 500*time.Millisecond, "how long a request may wait for the concurrency limit before it is shed")
 	configFlags.Duration("shutdown-timeout", 30*time.Second, "the graceful shutdown timeout")
+	configFlags.Duration("txn-lease-timeout", 15*time.Second, "how long an idle transaction is held before it is rolled back")
 	configFlags.String("otlp-endpoint", "", "opentelemetry endpoint to send telemetry to")
 	configFlags.Bool("disable-traces", false, "disable tracing")
 	configFlags.Bool("disable-metrics", false, "disable metrics")
@@ -115,6 +143,28 @@ func init() {
 	configFlags.String("cb-creds-azure-vault-name", "", "name of key vault storing cb-creds-azure-id")
 	configFlags.String("cb-creds-gcp-id", "", "id of secret in gcp sm storing couchbase server password")
 	configFlags.String("cb-creds-gcp-project-id", "", "id of project containing cb-creds-gcp-id")
+	configFlags.String("cb-creds-source", "", "a uri identifying the secret to fetch couchbase server credentials from, e.g 'aws://secret-id?region=us-east-1', 'file:///etc/couchbase-creds', 'exec:///usr/bin/fetch-creds' or 'vault://vault:8200/secret/couchbase'")
+	configFlags.Duration("cb-creds-refresh-interval", 5*time.Minute, "how often to re-fetch couchbase server credentials from the secret provider, 0 to disable")
+	configFlags.String("jwt-jwks-file", "", "path to a jwks file containing the keys used to verify jwt bearer tokens")
+	configFlags.String("jwt-jwks-url", "", "url of a jwks containing the keys used to verify jwt bearer tokens, e.g the jwks_uri of an oidc provider")
+	configFlags.Duration("jwt-jwks-refresh-interval", 1*time.Hour, "how often to re-fetch the jwks")
+	configFlags.String("jwt-issuer", "", "the required issuer (iss) of jwt bearer tokens")
+	configFlags.String("jwt-audience", "", "a comma seperated list of accepted audiences (aud) of jwt bearer tokens")
+	configFlags.String("jwt-user-claim", "sub", "the jwt claim identifying the couchbase user, nested claims may be separated by dots")
+	configFlags.String("jwt-domain-claim", "", "the jwt claim identifying the couchbase user domain")
+	configFlags.String("jwt-default-domain", "external", "the couchbase user domain used when the jwt does not specify one")
+	configFlags.Duration("jwt-clock-skew", 30*time.Second, "the allowed clock skew when checking jwt expiry")
+	configFlags.Duration("auth-cache-ttl", 0, "how long to cache successful authentications for, 0 disables caching")
+	configFlags.Duration("auth-cache-negative-ttl", 5*time.Second, "how long to cache rejected credentials for")
+	configFlags.Int("auth-cache-size", 10000, "the maximum number of cached authentication results")
+	configFlags.Int("auth-lockout-max-failures", 0, "the number of failed authentications for a user or address within the lockout window after which it is locked out, 0 disables lockouts")
+	configFlags.Duration("auth-lockout-window", 5*time.Minute, "the window over which failed authentications are counted")
+	configFlags.Duration("auth-lockout-duration", 15*time.Minute, "how long a user or address is locked out for")
+	configFlags.Duration("auth-lockout-base-delay", 1*time.Second, "the delay enforced after a failed authentication, doubling with each further failure, 0 disables delays")
+	configFlags.Duration("auth-lockout-max-delay", 30*time.Second, "the maximum delay enforced between failed authentications")
+	configFlags.String("auth-lockout-allowlist", "", "a comma separated list of addresses or cidr ranges which are exempt from lockouts")
+	configFlags.String("api-keys-file", "", "path to the file gateway-managed api keys are stored in, enables api key authentication")
+	configFlags.Duration("scram-session-ttl", 0, "enables scram authentication via grpc, issuing session tokens which expire after this duration.  with single-user-auth, users of single-user-users-file need scram hashes")
 	rootCmd.Flags().AddFlagSet(configFlags)
 
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
@@ -250,93 +300,219 @@ func getLogger() (zap.AtomicLevel, *zap.Logger) {
 	return logLevel, logger
 }
 
+// rateLimitConfig is a keyed rate limit, which can only be specified in the
+// config file.
+type rateLimitConfig struct {
+	Key       string                    `mapstructure:"key"`
+	Rate      float64                   `mapstructure:"rate"`
+	Burst     int                       `mapstructure:"burst"`
+	Overrides []rateLimitOverrideConfig `mapstructure:"overrides"`
+}
+
+// rateLimitOverrideConfig is the limit of a particular key.  Overrides are a
+// list rather than a map as viper does not preserve the case of map keys.
+type rateLimitOverrideConfig struct {
+	Name  string  `mapstructure:"name"`
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+// rateLimitCostsConfig assigns costs to operations for rate limiting, which
+// can only be specified in the config file.
+type rateLimitCostsConfig struct {
+	Default               float64               `mapstructure:"default"`
+	Operations            []operationCostConfig `mapstructure:"operations"`
+	RequestBytesPerToken  int                   `mapstructure:"request-bytes-per-token"`
+	ResponseBytesPerToken int                   `mapstructure:"response-bytes-per-token"`
+}
+
+// operationCostConfig is the cost of a gRPC method, Data API operation or
+// proxied service.  Operation names contain dots, which viper would treat as
+// nested keys if they were map keys.
+type operationCostConfig struct {
+	Name string  `mapstructure:"name"`
+	Cost float64 `mapstructure:"cost"`
+}
+
 type config struct {
-	logLevelStr           string
-	cbHost                string
-	cbUser                string
-	cbPass                string
-	cbHostIsLocal         bool
-	singleUserAuth        bool
-	bindAddress           string
-	dataPort              int
-	webPort               int
-	dapiPort              int
-	selfSign              bool
-	certPath              string
-	keyPath               string
-	grpcCertPath          string
-	grpcKeyPath           string
-	dapiCertPath          string
-	dapiKeyPath           string
-	clusterCaCertPath     string
-	clientCaCertPath      string
-	rateLimit             int
-	shutdownTimeout       time.Duration
-	otlpEndpoint          string
-	disableTraces         bool
-	disableMetrics        bool
-	disableOtlpTraces     bool
-	disableOtlpMetrics    bool
-	traceEverything       bool
-	otelExporterHeaders   string
-	dapiProxyServices     string
-	dapiNoProxyAdmin      bool
-	serverGroup           string
-	alphaEndpoints        bool
-	debug                 bool
-	pprof                 bool
-	cpuprofile            string
-	cbCredsAwsId          string
-	cbCredsAwsRegion      string
-	cbCredsAzureId        string
-	cbCredsAzureVaultName string
-	cbCredsGcpId          string
-	cbCredsGcpProjectId   string
+	logLevelStr            string
+	cbHost                 string
+	cbUser                 string
+	cbPass                 string
+	cbHostIsLocal          bool
+	singleUserAuth         bool
+	singleUserUsersFile    string
+	singleUserCertMapFile  string
+	bindAddress            string
+	dataPort               int
+	webPort                int
+	dapiPort               int
+	selfSign               bool
+	certPath               string
+	keyPath                string
+	grpcCertPath           string
+	grpcKeyPath            string
+	dapiCertPath           string
+	dapiKeyPath            string
+	sniCerts               string
+	grpcSniCerts           string
+	dapiSniCerts           string
+	clusterCaCertPath      string
+	cbClientCertPath       string
+	cbClientKeyPath        string
+	clientCaCertPath       string
+	clientCrl              string
+	clientCrlReload        time.Duration
+	rateLimit              int
+	rateLimitSharedBucket  string
+	rateLimitSharedScope   string
+	rateLimitSharedColl    string
+	rateLimitSyncInterval  time.Duration
+	rateLimits             []rateLimitConfig
+	rateLimitCosts         rateLimitCostsConfig
+	concurrencyLimitMax    int
+	concurrencyLimitMin    int
+	concurrencyLimitQueue  int
+	concurrencyLimitWait   time.Duration
+	shutdownTimeout        time.Duration
+	txnLeaseTimeout        time.Duration
+	otlpEndpoint           string
+	disableTraces          bool
+	disableMetrics         bool
+	disableOtlpTraces      bool
+	disableOtlpMetrics     bool
+	traceEverything        bool
+	otelExporterHeaders    string
+	dapiProxyServices      string
+	dapiNoProxyAdmin       bool
+	serverGroup            string
+	alphaEndpoints         bool
+	debug                  bool
+	pprof                  bool
+	cpuprofile             string
+	cbCredsAwsId           string
+	cbCredsAwsRegion       string
+	cbCredsAzureId         string
+	cbCredsAzureVaultName  string
+	cbCredsGcpId           string
+	cbCredsGcpProjectId    string
+	cbCredsSource          string
+	cbCredsRefresh         time.Duration
+	jwtJwksFile            string
+	jwtJwksUrl             string
+	jwtJwksRefresh         time.Duration
+	jwtIssuer              string
+	jwtAudience            string
+	jwtUserClaim           string
+	jwtDomainClaim         string
+	jwtDefaultDomain       string
+	jwtClockSkew           time.Duration
+	authCacheTtl           time.Duration
+	authCacheNegativeTtl   time.Duration
+	authCacheSize          int
+	authLockoutMaxFailures int
+	authLockoutWindow      time.Duration
+	authLockoutDuration    time.Duration
+	authLockoutBaseDelay   time.Duration
+	authLockoutMaxDelay    time.Duration
+	authLockoutAllowlist   string
+	apiKeysFile            string
+	scramSessionTtl        time.Duration
 }
 
 func readConfig(logger *zap.Logger) *config {
 	config := &config{
-		logLevelStr:           viper.GetString("log-level"),
-		cbHost:                viper.GetString("cb-host"),
-		cbUser:                viper.GetString("cb-user"),
-		cbPass:                viper.GetString("cb-pass"),
-		cbHostIsLocal:         viper.GetBool("cb-host-is-local"),
-		singleUserAuth:        viper.GetBool("single-user-auth"),
-		bindAddress:           viper.GetString("bind-address"),
-		dataPort:              viper.GetInt("data-port"),
-		webPort:               viper.GetInt("web-port"),
-		dapiPort:              viper.GetInt("dapi-port"),
-		selfSign:              viper.GetBool("self-sign"),
-		certPath:              viper.GetString("cert"),
-		keyPath:               viper.GetString("key"),
-		grpcCertPath:          viper.GetString("grpc-cert"),
-		grpcKeyPath:           viper.GetString("grpc-key"),
-		dapiCertPath:          viper.GetString("dapi-cert"),
-		dapiKeyPath:           viper.GetString("dapi-key"),
-		clusterCaCertPath:     viper.GetString("cluster-cert"),
-		clientCaCertPath:      viper.GetString("client-ca-cert"),
-		rateLimit:             viper.GetInt("rate-limit"),
-		shutdownTimeout:       viper.GetDuration("shutdown-timeout"),
-		otlpEndpoint:          viper.GetString("otlp-endpoint"),
-		disableTraces:         viper.GetBool("disable-traces"),
-		disableMetrics:        viper.GetBool("disable-metrics"),
-		disableOtlpTraces:     viper.GetBool("disable-otlp-traces"),
-		disableOtlpMetrics:    viper.GetBool("disable-otlp-metrics"),
-		traceEverything:       viper.GetBool("trace-everything"),
-		otelExporterHeaders:   viper.GetString("otel-exporter-headers"),
-		dapiProxyServices:     viper.GetString("dapi-proxy-services"),
-		dapiNoProxyAdmin:      viper.GetBool("dapi-no-proxy-admin"),
-		serverGroup:           viper.GetString("server-group"),
-		alphaEndpoints:        viper.GetBool("alpha-endpoints"),
-		debug:                 viper.GetBool("debug"),
-		pprof:                 viper.GetBool("pprof"),
-		cpuprofile:            viper.GetString("cpuprofile"),
-		cbCredsAwsId:          viper.GetString("cb-creds-aws-id"),
-		cbCredsAwsRegion:      viper.GetString("cb-creds-aws-region"),
-		cbCredsAzureId:        viper.GetString("cb-creds-azure-id"),
-		cbCredsAzureVaultName: viper.GetString("cb-creds-azure-vault-name"),
-		cbCredsGcpId:          viper.GetString("cb-creds-gcp-id"),
-		cbCredsGcpProjectId:   viper.GetString("cb-creds-gcp-project-id"),
+		logLevelStr:            viper.GetString("log-level"),
+		cbHost:                 viper.GetString("cb-host"),
+		cbUser:                 viper.GetString("cb-user"),
+		cbPass:                 viper.GetString("cb-pass"),
+		cbHostIsLocal:          viper.GetBool("cb-host-is-local"),
+		singleUserAuth:         viper.GetBool("single-user-auth"),
+		singleUserUsersFile:    viper.GetString("single-user-users-file"),
+		singleUserCertMapFile:  viper.GetString("single-user-cert-map-file"),
+		bindAddress:            viper.GetString("bind-address"),
+		dataPort:               viper.GetInt("data-port"),
+		webPort:                viper.GetInt("web-port"),
+		dapiPort:               viper.GetInt("dapi-port"),
+		selfSign:               viper.GetBool("self-sign"),
+		certPath:               viper.GetString("cert"),
+		keyPath:                viper.GetString("key"),
+		grpcCertPath:           viper.GetString("grpc-cert"),
+		grpcKeyPath:            viper.GetString("grpc-key"),
+		dapiCertPath:           viper.GetString("dapi-cert"),
+		dapiKeyPath:            viper.GetString("dapi-key"),
+		sniCerts:               viper.GetString("sni-certs"),
+		grpcSniCerts:           viper.GetString("grpc-sni-certs"),
+		dapiSniCerts:           viper.GetString("dapi-sni-certs"),
+		clusterCaCertPath:      viper.GetString("cluster-cert"),
+		cbClientCertPath:       viper.GetString("cb-client-cert"),
+		cbClientKeyPath:        viper.GetString("cb-client-key"),
+		clientCaCertPath:       viper.GetString("client-ca-cert"),
+		clientCrl:              viper.GetString("client-crl"),
+		clientCrlReload:        viper.GetDuration("client-crl-reload-interval"),
+		rateLimit:              viper.GetInt("rate-limit"),
+		rateLimitSharedBucket:  viper.GetString("rate-limit-shared-bucket"),
+		rateLimitSharedScope:   viper.GetString("rate-limit-shared-scope"),
+		rateLimitSharedColl:    viper.GetString("rate-limit-shared-collection"),
+		rateLimitSyncInterval:  viper.GetDuration("rate-limit-sync-interval"),
+		concurrencyLimitMax:    viper.GetInt("concurrency-limit-max"),
+		concurrencyLimitMin:    viper.GetInt("concurrency-limit-min"),
+		concurrencyLimitQueue:  viper.GetInt("concurrency-limit-queue"),
+		concurrencyLimitWait:   viper.GetDuration("concurrency-limit-max-wait"),
+		shutdownTimeout:        viper.GetDuration("shutdown-timeout"),
+		txnLeaseTimeout:        viper.GetDuration("txn-lease-timeout"),
+		otlpEndpoint:           viper.GetString("otlp-endpoint"),
+		disableTraces:          viper.GetBool("disable-traces"),
+		disableMetrics:         viper.GetBool("disable-metrics"),
+		disableOtlpTraces:      viper.GetBool("disable-otlp-traces"),
+		disableOtlpMetrics:     viper.GetBool("disable-otlp-metrics"),
+		traceEverything:        viper.GetBool("trace-everything"),
+		otelExporterHeaders:    viper.GetString("otel-exporter-headers"),
+		dapiProxyServices:      viper.GetString("dapi-proxy-services"),
+		dapiNoProxyAdmin:       viper.GetBool("dapi-no-proxy-admin"),
+		serverGroup:            viper.GetString("server-group"),
+		alphaEndpoints:         viper.GetBool("alpha-endpoints"),
+		debug:                  viper.GetBool("debug"),
+		pprof:                  viper.GetBool("pprof"),
+		cpuprofile:             viper.GetString("cpuprofile"),
+		cbCredsAwsId:           viper.GetString("cb-creds-aws-id"),
+		cbCredsAwsRegion:       viper.GetString("cb-creds-aws-region"),
+		cbCredsAzureId:         viper.GetString("cb-creds-azure-id"),
+		cbCredsAzureVaultName:  viper.GetString("cb-creds-azure-vault-name"),
+		cbCredsGcpId:           viper.GetString("cb-creds-gcp-id"),
+		cbCredsGcpProjectId:    viper.GetString("cb-creds-gcp-project-id"),
+		cbCredsSource:          viper.GetString("cb-creds-source"),
+		cbCredsRefresh:         viper.GetDuration("cb-creds-refresh-interval"),
+		jwtJwksFile:            viper.GetString("jwt-jwks-file"),
+		jwtJwksUrl:             viper.GetString("jwt-jwks-url"),
+		jwtJwksRefresh:         viper.GetDuration("jwt-jwks-refresh-interval"),
+		jwtIssuer:              viper.GetString("jwt-issuer"),
+		jwtAudience:            viper.GetString("jwt-audience"),
+		jwtUserClaim:           viper.GetString("jwt-user-claim"),
+		jwtDomainClaim:         viper.GetString("jwt-domain-claim"),
+		jwtDefaultDomain:       viper.GetString("jwt-default-domain"),
+		jwtClockSkew:           viper.GetDuration("jwt-clock-skew"),
+		authCacheTtl:           viper.GetDuration("auth-cache-ttl"),
+		authCacheNegativeTtl:   viper.GetDuration("auth-cache-negative-ttl"),
+		authCacheSize:          viper.GetInt("auth-cache-size"),
+		authLockoutMaxFailures: viper.GetInt("auth-lockout-max-failures"),
+		authLockoutWindow:      viper.GetDuration("auth-lockout-window"),
+		authLockoutDuration:    viper.GetDuration("auth-lockout-duration"),
+		authLockoutBaseDelay:   viper.GetDuration("auth-lockout-base-delay"),
+		authLockoutMaxDelay:    viper.GetDuration("auth-lockout-max-delay"),
+		authLockoutAllowlist:   viper.GetString("auth-lockout-allowlist"),
+		apiKeysFile:            viper.GetString("api-keys-file"),
+		scramSessionTtl:        viper.GetDuration("scram-session-ttl"),
+	}
+
+	err := viper.UnmarshalKey("rate-limits", &config.rateLimits)
+	if err != nil {
+		logger.Warn("failed to parse rate-limits configuration", zap.Error(err))
+	}
+
+	err = viper.UnmarshalKey("rate-limit-costs", &config.rateLimitCosts)
+	if err != nil {
+		logger.Warn("failed to parse rate-limit-costs configuration", zap.Error(err))
 	}
 
 	logger.Info("parsed gateway configuration",
@@ -346,6 +522,8 @@ func readConfig(logger *zap.Logger) *config {
 		// zap.String("cbPass", config.cbPass),
 		zap.Bool("cbHostIsLocal", config.cbHostIsLocal),
 		zap.Bool("singleUserAuth", config.singleUserAuth),
+		zap.String("singleUserUsersFile", config.singleUserUsersFile),
+		zap.String("singleUserCertMapFile", config.singleUserCertMapFile),
 		zap.String("bindAddress", config.bindAddress),
 		zap.Int("dataPort", config.dataPort),
 		zap.Int("webPort", config.webPort),
@@ -353,14 +531,32 @@ func readConfig(logger *zap.Logger) *config {
 		zap.Bool("selfSign", config.selfSign),
 		zap.String("certPath", config.certPath),
 		zap.String("clientCaCertPath", config.clientCaCertPath),
+		zap.String("clientCrl", config.clientCrl),
+		zap.Duration("clientCrlReload", config.clientCrlReload),
 		zap.String("keyPath", config.keyPath),
 		zap.String("grpcCertPath", config.grpcCertPath),
 		zap.String("grpcKeyPath", config.grpcKeyPath),
 		zap.String("dapiCertPath", config.dapiCertPath),
 		zap.String("dapiKeyPath", config.dapiKeyPath),
+		zap.String("sniCerts", config.sniCerts),
+		zap.String("grpcSniCerts", config.grpcSniCerts),
+		zap.String("dapiSniCerts", config.dapiSniCerts),
 		zap.String("clusterCaCertPath", config.clusterCaCertPath),
+		zap.String("cbClientCertPath", config.cbClientCertPath),
+		zap.String("cbClientKeyPath", config.cbClientKeyPath),
 		zap.Int("rateLimit", config.rateLimit),
+		zap.String("rateLimitSharedBucket", config.rateLimitSharedBucket),
+		zap.String("rateLimitSharedScope", config.rateLimitSharedScope),
+		zap.String("rateLimitSharedCollection", config.rateLimitSharedColl),
+		zap.Duration("rateLimitSyncInterval", config.rateLimitSyncInterval),
+		zap.Any("rateLimits", config.rateLimits),
+		zap.Any("rateLimitCosts", config.rateLimitCosts),
+		zap.Int("concurrencyLimitMax", config.concurrencyLimitMax),
+		zap.Int("concurrencyLimitMin", config.concurrencyLimitMin),
+		zap.Int("concurrencyLimitQueue", config.concurrencyLimitQueue),
+		zap.Duration("concurrencyLimitWait", config.concurrencyLimitWait),
 		zap.Duration("shutdownTimeout", config.shutdownTimeout),
+		zap.Duration("txnLeaseTimeout", config.txnLeaseTimeout),
 		zap.String("otlpEndpoint", config.otlpEndpoint),
 		zap.Bool("disableTraces", config.disableTraces),
 		zap.Bool("disableMetrics", config.disableMetrics),
@@ -380,11 +576,258 @@ func readConfig(logger *zap.Logger) *config {
 		zap.String("cbCredsAzureId", config.cbCredsAzureId),
 		zap.String("cbCredsAzureVaultName", config.cbCredsAzureVaultName),
 		zap.String("cbCredsGcpId", config.cbCredsGcpId),
-		zap.String("cbCredsGcpId", config.cbCredsGcpProjectId))
+		zap.String("cbCredsGcpId", config.cbCredsGcpProjectId),
+		zap.String("cbCredsSource", config.cbCredsSource),
+		zap.Duration("cbCredsRefresh", config.cbCredsRefresh),
+		zap.String("jwtJwksFile", config.jwtJwksFile),
+		zap.String("jwtJwksUrl", config.jwtJwksUrl),
+		zap.Duration("jwtJwksRefresh", config.jwtJwksRefresh),
+		zap.String("jwtIssuer", config.jwtIssuer),
+		zap.String("jwtAudience", config.jwtAudience),
+		zap.String("jwtUserClaim", config.jwtUserClaim),
+		zap.String("jwtDomainClaim", config.jwtDomainClaim),
+		zap.String("jwtDefaultDomain", config.jwtDefaultDomain),
+		zap.Duration("jwtClockSkew", config.jwtClockSkew),
+		zap.Duration("authCacheTtl", config.authCacheTtl),
+		zap.Duration("authCacheNegativeTtl", config.authCacheNegativeTtl),
+		zap.Int("authCacheSize", config.authCacheSize),
+		zap.Int("authLockoutMaxFailures", config.authLockoutMaxFailures),
+		zap.Duration("authLockoutWindow", config.authLockoutWindow),
+		zap.Duration("authLockoutDuration", config.authLockoutDuration),
+		zap.Duration("authLockoutBaseDelay", config.authLockoutBaseDelay),
+		zap.Duration("authLockoutMaxDelay", config.authLockoutMaxDelay),
+		zap.String("authLockoutAllowlist", config.authLockoutAllowlist),
+		zap.String("apiKeysFile", config.apiKeysFile),
+		zap.Duration("scramSessionTtl", config.scramSessionTtl))
 
 	return config
 }
 
+// newCredentialsProvider creates the provider which the couchbase server
+// credentials are fetched from, or nil if they are specified directly.
+func newCredentialsProvider(config *config) (secretsmanager.SecretProvider, error) {
+	numSources := 0
+	for _, source := range []string{
+		config.cbCredsSource,
+		config.cbCredsAwsId,
+		config.cbCredsAzureId,
+		config.cbCredsGcpId,
+	} {
+		if source != "" {
+			numSources++
+		}
+	}
+	if numSources > 1 {
+		return nil, errors.New("only one of cb-creds-source, cb-creds-aws-id, cb-creds-azure-id or cb-creds-gcp-id may be specified")
+	}
+
+	if config.cbCredsSource != "" {
+		return secretsmanager.NewProvider(config.cbCredsSource)
+	}
+
+	if config.cbCredsAwsId != "" {
+		if config.cbCredsAwsRegion == "" {
+			return nil, errors.New("must specify region and id when fetching secrets from aws")
+		}
+
+		return secretsmanager.NewAwsProvider(config.cbCredsAwsId, config.cbCredsAwsRegion), nil
+	}
+
+	if config.cbCredsAzureId != "" {
+		if config.cbCredsAzureVaultName == "" {
+			return nil, errors.New("must specify key vault name and id when fetching secrets from azure")
+		}
+
+		return secretsmanager.NewAzureProvider(config.cbCredsAzureId, config.cbCredsAzureVaultName), nil
+	}
+
+	if config.cbCredsGcpId != "" {
+		if config.cbCredsGcpProjectId == "" {
+			return nil, errors.New("must specify project and secret ids when fetching secrets from gcp")
+		}
+
+		return secretsmanager.NewGcpProvider(config.cbCredsGcpId, config.cbCredsGcpProjectId), nil
+	}
+
+	return nil, nil
+}
+
+// newAuthLockout creates the tracker for failed authentications, or nil if
+// lockouts are not enabled.
+func newAuthLockout(logger *zap.Logger, config *config) (*lockout.Tracker, error) {
+	if config.authLockoutMaxFailures <= 0 {
+		return nil, nil
+	}
+
+	allowlist, err := lockout.ParseAllowlist(strings.Split(config.authLockoutAllowlist, ","))
+	if err != nil {
+		return nil, err
+	}
+
+	baseDelay := config.authLockoutBaseDelay
+	if baseDelay == 0 {
+		baseDelay = -1
+	}
+
+	return lockout.NewTracker(&lockout.Options{
+		Logger:          logger,
+		MaxFailures:     config.authLockoutMaxFailures,
+		Window:          config.authLockoutWindow,
+		LockoutDuration: config.authLockoutDuration,
+		BaseDelay:       baseDelay,
+		MaxDelay:        config.authLockoutMaxDelay,
+		Allowlist:       allowlist,
+	}), nil
+}
+
+// newKeyedRateLimits converts the keyed rate limits of the config file.
+func newKeyedRateLimits(config *config) ([]ratelimiting.KeyedRule, error) {
+	rules := make([]ratelimiting.KeyedRule, 0, len(config.rateLimits))
+	for _, limitConfig := range config.rateLimits {
+		rule := ratelimiting.KeyedRule{
+			KeyType: ratelimiting.KeyType(limitConfig.Key),
+			Default: ratelimiting.Limit{
+				Rate:  limitConfig.Rate,
+				Burst: limitConfig.Burst,
+			},
+			Overrides: make(map[string]ratelimiting.Limit),
+		}
+
+		for _, override := range limitConfig.Overrides {
+			if _, ok := rule.Overrides[override.Name]; ok {
+				return nil, fmt.Errorf("duplicate rate limit override for %s `%s`", limitConfig.Key, override.Name)
+			}
+
+			rule.Overrides[override.Name] = ratelimiting.Limit{
+				Rate:  override.Rate,
+				Burst: override.Burst,
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// reconfigureRateLimits applies the rate limits of an updated configuration.
+func reconfigureRateLimits(gw *gateway.Gateway, config *config) error {
+	keyedRateLimits, err := newKeyedRateLimits(config)
+	if err != nil {
+		return err
+	}
+
+	rateLimitCosts, err := newRateLimitCosts(config)
+	if err != nil {
+		return err
+	}
+
+	return gw.Reconfigure(&gateway.ReconfigureOptions{
+		RateLimit:       config.rateLimit,
+		KeyedRateLimits: keyedRateLimits,
+		RateLimitCosts:  rateLimitCosts,
+	})
+}
+
+// newRateLimitCosts converts the rate limit costs of the config file.
+func newRateLimitCosts(config *config) (ratelimiting.Costs, error) {
+	costs := ratelimiting.Costs{
+		Default:               config.rateLimitCosts.Default,
+		Operations:            make(map[string]float64),
+		RequestBytesPerToken:  config.rateLimitCosts.RequestBytesPerToken,
+		ResponseBytesPerToken: config.rateLimitCosts.ResponseBytesPerToken,
+	}
+
+	for _, operation := range config.rateLimitCosts.Operations {
+		if _, ok := costs.Operations[operation.Name]; ok {
+			return ratelimiting.Costs{}, fmt.Errorf("duplicate rate limit cost for `%s`", operation.Name)
+		}
+
+		costs.Operations[operation.Name] = operation.Cost
+	}
+
+	return costs, nil
+}
+
+// newSharedRateLimitConfig returns where the rate limit is shared between
+// gateways, or nil if it is not shared.
+func newSharedRateLimitConfig(config *config) *gateway.SharedRateLimitConfig {
+	if config.rateLimitSharedBucket == "" {
+		return nil
+	}
+
+	return &gateway.SharedRateLimitConfig{
+		BucketName:     config.rateLimitSharedBucket,
+		ScopeName:      config.rateLimitSharedScope,
+		CollectionName: config.rateLimitSharedColl,
+		SyncInterval:   config.rateLimitSyncInterval,
+	}
+}
+
+// newConcurrencyLimiter creates the adaptive concurrency limiter, or nil if
+// concurrency limiting is not enabled.
+func newConcurrencyLimiter(logger *zap.Logger, config *config) (*ratelimiting.ConcurrencyLimiter, error) {
+	if config.concurrencyLimitMax <= 0 {
+		return nil, nil
+	}
+
+	if config.concurrencyLimitMin > config.concurrencyLimitMax {
+		return nil, errors.New("concurrency-limit-min cannot exceed concurrency-limit-max")
+	}
+
+	return ratelimiting.NewConcurrencyLimiter(&ratelimiting.ConcurrencyLimiterOptions{
+		Logger:   logger,
+		MinLimit: config.concurrencyLimitMin,
+		MaxLimit: config.concurrencyLimitMax,
+		MaxQueue: config.concurrencyLimitQueue,
+		MaxWait:  config.concurrencyLimitWait,
+	}), nil
+}
+
+// newJwtValidator creates the validator for jwt bearer tokens, or nil if jwt
+// authentication is not configured.
+func newJwtValidator(logger *zap.Logger, config *config) (*jwtauth.Validator, error) {
+	if config.jwtJwksFile != "" && config.jwtJwksUrl != "" {
+		return nil, errors.New("only one of jwt-jwks-file or jwt-jwks-url may be specified")
+	}
+
+	var keySet *jwtauth.CachingKeySet
+	if config.jwtJwksFile != "" {
+		keySet = jwtauth.NewFileKeySet(logger, config.jwtJwksFile, config.jwtJwksRefresh)
+	} else if config.jwtJwksUrl != "" {
+		keySet = jwtauth.NewUrlKeySet(logger, nil, config.jwtJwksUrl, config.jwtJwksRefresh)
+	} else {
+		return nil, nil
+	}
+
+	// we fetch the keys up front so that misconfiguration is reported early,
+	// but tolerate failures as the identity provider may be temporarily down.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := keySet.Refresh(ctx)
+	if err != nil {
+		logger.Warn("failed to fetch jwks, jwt authentication will fail until it can be fetched", zap.Error(err))
+	}
+
+	var audiences []string
+	for _, audience := range strings.Split(config.jwtAudience, ",") {
+		audience = strings.TrimSpace(audience)
+		if audience != "" {
+			audiences = append(audiences, audience)
+		}
+	}
+
+	return jwtauth.NewValidator(&jwtauth.ValidatorOptions{
+		KeySet:        keySet,
+		Issuer:        config.jwtIssuer,
+		Audiences:     audiences,
+		UserClaim:     config.jwtUserClaim,
+		DomainClaim:   config.jwtDomainClaim,
+		DefaultDomain: config.jwtDefaultDomain,
+		Leeway:        config.jwtClockSkew,
+	})
+}
+
 func startGateway() {
 	// initialize the logger
 	logLevel, logger := getLogger()
@@ -459,6 +902,39 @@ func startGateway() {
 		otel.SetMeterProvider(otlpMeterProvider)
 	}
 
+	keyedRateLimits, err := newKeyedRateLimits(config)
+	if err != nil {
+		logger.Error("invalid rate-limits configuration", zap.Error(err))
+		os.Exit(1)
+		return
+	}
+
+	rateLimitCosts, err := newRateLimitCosts(config)
+	if err != nil {
+		logger.Error("invalid rate-limit-costs configuration", zap.Error(err))
+		os.Exit(1)
+		return
+	}
+
+	concurrencyLimiter, err := newConcurrencyLimiter(logger.Named("concurrency-limiter"), config)
+	if err != nil {
+		logger.Error("invalid concurrency limit configuration", zap.Error(err))
+		os.Exit(1)
+		return
+	}
+
+	authLockout, err := newAuthLockout(logger.Named("auth-lockout"), config)
+	if err != nil {
+		logger.Error("invalid auth lockout configuration", zap.Error(err))
+		os.Exit(1)
+		return
+	}
+
+	var authLockoutHandler http.Handler
+	if authLockout != nil {
+		authLockoutHandler = authLockout.HttpHandler()
+	}
+
 	// setup the web service
 	webListenAddress := fmt.Sprintf("%s:%v", config.bindAddress, config.webPort)
 	webapi.InitializeWebServer(webapi.WebServerOptions{
@@ -466,6 +942,7 @@ func startGateway() {
 		LogLevel:      &logLevel,
 		ListenAddress: webListenAddress,
 		EnablePprof:   config.pprof,
+		AuthLockouts:  authLockoutHandler,
 	})
 
 	var selfSignedCert *tls.Certificate
@@ -480,69 +957,93 @@ func startGateway() {
 		selfSignedCert = generatedCert
 	}
 
-	var grpcCertificate tls.Certificate
+	grpcCerts := &serviceCertificates{service: "grpc"}
 	if config.dataPort != -1 {
 		// GRPC services are enabled
-		grpcCertPath := config.grpcCertPath
-		if grpcCertPath == "" {
-			grpcCertPath = config.certPath
+		certPath := config.grpcCertPath
+		if certPath == "" {
+			certPath = config.certPath
 		}
 
-		grpcKeyPath := config.grpcKeyPath
-		if grpcKeyPath == "" {
-			grpcKeyPath = config.keyPath
+		keyPath := config.grpcKeyPath
+		if keyPath == "" {
+			keyPath = config.keyPath
 		}
 
-		if grpcCertPath == "" || grpcKeyPath == "" {
+		if certPath == "" || keyPath == "" {
 			if selfSignedCert == nil {
 				logger.Error("must specify both grpc-cert/grpc-key or cert/key unless self-sign is specified")
 				os.Exit(1)
 				return
 			}
 
-			grpcCertificate = *selfSignedCert
+			grpcCerts.defaultCert = *selfSignedCert
 		} else {
-			loadedTlsCertificate, err := tls.LoadX509KeyPair(grpcCertPath, grpcKeyPath)
-			if err != nil {
-				logger.Error("failed to load tls certificate", zap.Error(err))
-				os.Exit(1)
-				return
-			}
+			grpcCerts.defaultPair = &tlsKeyPair{CertPath: certPath, KeyPath: keyPath}
+		}
 
-			grpcCertificate = loadedTlsCertificate
+		sniCerts := config.grpcSniCerts
+		if sniCerts == "" {
+			sniCerts = config.sniCerts
+		}
+
+		grpcCerts.sniPairs, err = parseTlsKeyPairs(sniCerts)
+		if err != nil {
+			logger.Error("failed to parse grpc sni certificates", zap.Error(err))
+			os.Exit(1)
+			return
+		}
+
+		err = grpcCerts.load(logger)
+		if err != nil {
+			logger.Error("failed to load tls certificate", zap.Error(err))
+			os.Exit(1)
+			return
 		}
 	}
 
-	var dapiCertificate tls.Certificate
+	dapiCerts := &serviceCertificates{service: "dapi"}
 	if config.dapiPort != -1 {
 		// Data API service is enabled
-		dapiCertPath := config.dapiCertPath
-		if dapiCertPath == "" {
-			dapiCertPath = config.certPath
+		certPath := config.dapiCertPath
+		if certPath == "" {
+			certPath = config.certPath
 		}
 
-		dapiKeyPath := config.dapiKeyPath
-		if dapiKeyPath == "" {
-			dapiKeyPath = config.keyPath
+		keyPath := config.dapiKeyPath
+		if keyPath == "" {
+			keyPath = config.keyPath
 		}
 
-		if dapiCertPath == "" || dapiKeyPath == "" {
+		if certPath == "" || keyPath == "" {
 			if selfSignedCert == nil {
 				logger.Error("must specify both dapi-cert/dapi-key or cert/key unless self-sign is specified")
 				os.Exit(1)
 				return
 			}
 
-			dapiCertificate = *selfSignedCert
+			dapiCerts.defaultCert = *selfSignedCert
 		} else {
-			loadedTlsCertificate, err := tls.LoadX509KeyPair(dapiCertPath, dapiKeyPath)
-			if err != nil {
-				logger.Error("failed to load tls certificate", zap.Error(err))
-				os.Exit(1)
-				return
-			}
+			dapiCerts.defaultPair = &tlsKeyPair{CertPath: certPath, KeyPath: keyPath}
+		}
+
+		sniCerts := config.dapiSniCerts
+		if sniCerts == "" {
+			sniCerts = config.sniCerts
+		}
+
+		dapiCerts.sniPairs, err = parseTlsKeyPairs(sniCerts)
+		if err != nil {
+			logger.Error("failed to parse data api sni certificates", zap.Error(err))
+			os.Exit(1)
+			return
+		}
 
-			dapiCertificate = loadedTlsCertificate
+		err = dapiCerts.load(logger)
+		if err != nil {
+			logger.Error("failed to load tls certificate", zap.Error(err))
+			os.Exit(1)
+			return
 		}
 	}
 
@@ -557,6 +1058,32 @@ func startGateway() {
 		caCertPool.AppendCertsFromPEM(caCert)
 	}
 
+	clusterClientCerts := &serviceCertificates{service: "cluster-client"}
+	var clusterClientCert *tls.Certificate
+	if config.cbClientCertPath != "" || config.cbClientKeyPath != "" {
+		if config.cbClientCertPath == "" || config.cbClientKeyPath == "" {
+			logger.Error("must specify both cb-client-cert and cb-client-key")
+			os.Exit(1)
+			return
+		}
+
+		clusterClientCerts.defaultPair = &tlsKeyPair{
+			CertPath: config.cbClientCertPath,
+			KeyPath:  config.cbClientKeyPath,
+		}
+
+		err = clusterClientCerts.load(logger)
+		if err != nil {
+			logger.Error("failed to load cluster client certificate", zap.Error(err))
+			os.Exit(1)
+			return
+		}
+
+		// copy the certificate, as reloads replace defaultCert under the lock
+		cert := clusterClientCerts.defaultCert
+		clusterClientCert = &cert
+	}
+
 	var clientCaCertPool *x509.CertPool
 	if config.clientCaCertPath != "" {
 		clientCaCertPool = x509.NewCertPool()
@@ -568,98 +1095,130 @@ func startGateway() {
 		clientCaCertPool.AppendCertsFromPEM(clientCaCert)
 	}
 
-	if config.cbCredsAwsId != "" {
-		if config.cbUser != "Administrator" || config.cbPass != "password" {
-			logger.Error("cannot use cb-pass or cb-user when fetching creds from cloud provider")
-			os.Exit(1)
-			return
-		}
-
-		if config.cbCredsAwsRegion == "" {
-			logger.Error("must specify region and id when fetching secrets from aws")
+	var clientCrl *crl.Checker
+	if config.clientCrl != "" {
+		if config.clientCaCertPath == "" {
+			logger.Error("client-crl requires client-ca-cert")
 			os.Exit(1)
 			return
 		}
 
-		logger.Info("fetching server credentials from aws secrets manager")
-		config.cbUser, config.cbPass, err = secretsmanager.FetchAWSSecret(config.cbCredsAwsId, config.cbCredsAwsRegion)
-
+		clientCrl, err = crl.NewChecker(&crl.Options{
+			Logger:         logger.Named("client-crl"),
+			Paths:          strings.Split(config.clientCrl, ","),
+			ReloadInterval: config.clientCrlReload,
+		})
 		if err != nil {
-			logger.Error("failed to fetch couchbase server password from aws", zap.Error(err))
+			logger.Error("failed to load client certificate crls", zap.Error(err))
 			os.Exit(1)
 			return
 		}
+		defer clientCrl.Close()
 	}
 
-	if config.cbCredsAzureId != "" {
-		if config.cbUser != "Administrator" || config.cbPass != "password" {
-			logger.Error("cannot use cb-pass or cb-user when fetching creds from cloud provider")
-			os.Exit(1)
-			return
-		}
+	credsProvider, err := newCredentialsProvider(config)
+	if err != nil {
+		logger.Error("invalid couchbase server credentials source", zap.Error(err))
+		os.Exit(1)
+		return
+	}
 
-		if config.cbCredsAzureVaultName == "" {
-			logger.Error("must specify key vault name and id when fetching secrets from azure")
+	if credsProvider != nil {
+		if config.cbUser != "Administrator" || config.cbPass != "password" {
+			logger.Error("cannot use cb-pass or cb-user when fetching creds from a secret provider")
 			os.Exit(1)
 			return
 		}
 
-		logger.Info("fetching server credentials from azure key vault")
-		config.cbUser, config.cbPass, err = secretsmanager.FetchAzureSecret(config.cbCredsAzureId, config.cbCredsAzureVaultName)
-
+		logger.Info("fetching server credentials from secret provider")
+		config.cbUser, config.cbPass, err = secretsmanager.FetchCredentials(context.Background(), credsProvider)
 		if err != nil {
-			logger.Error("failed to fetch couchbase server password from azure", zap.Error(err))
+			logger.Error("failed to fetch couchbase server credentials", zap.Error(err))
 			os.Exit(1)
 			return
 		}
 	}
 
-	if config.cbCredsGcpId != "" {
-		if config.cbUser != "Administrator" || config.cbPass != "password" {
-			logger.Error("cannot use cb-pass or cb-user when fetching creds from cloud provider")
+	jwtValidator, err := newJwtValidator(logger.Named("jwt"), config)
+	if err != nil {
+		logger.Error("invalid jwt authentication configuration", zap.Error(err))
+		os.Exit(1)
+		return
+	}
+
+	var singleUserUsers *userfile.Store
+	if config.singleUserUsersFile != "" || config.singleUserCertMapFile != "" {
+		if !config.singleUserAuth {
+			logger.Error("single-user-users-file and single-user-cert-map-file require single-user-auth")
 			os.Exit(1)
 			return
 		}
 
-		if config.cbCredsGcpProjectId == "" {
-			logger.Error("must specify project and secret ids when fetching secrets from gcp")
+		singleUserUsers, err = userfile.NewStore(&userfile.Options{
+			Logger:      logger.Named("single-user-users"),
+			UsersPath:   config.singleUserUsersFile,
+			CertMapPath: config.singleUserCertMapFile,
+		})
+		if err != nil {
+			logger.Error("failed to load single-user auth users", zap.Error(err))
 			os.Exit(1)
 			return
 		}
+	}
 
-		logger.Info("fetching server credentials from gcp secrets manager")
-		config.cbUser, config.cbPass, err = secretsmanager.FetchGcpSecret(config.cbCredsGcpId, config.cbCredsGcpProjectId)
-
+	var apiKeyStore *apikeys.Store
+	if config.apiKeysFile != "" {
+		apiKeyStore, err = apikeys.NewStore(&apikeys.StoreOptions{
+			Logger: logger.Named("apikeys"),
+			Path:   config.apiKeysFile,
+		})
 		if err != nil {
-			logger.Error("failed to fetch couchbase server password from gcp", zap.Error(err))
+			logger.Error("failed to load api keys", zap.Error(err))
 			os.Exit(1)
 			return
 		}
 	}
 
 	gatewayConfig := &gateway.Config{
-		Logger:              logger.Named("gateway"),
-		CbConnStr:           config.cbHost,
-		Username:            config.cbUser,
-		Password:            config.cbPass,
-		BoostrapNodeIsLocal: config.cbHostIsLocal,
-		SingleUserAuth:      config.singleUserAuth,
-		Daemon:              daemon,
-		Debug:               config.debug,
-		ProxyServices:       strings.Split(config.dapiProxyServices, ","),
-		ProxyBlockAdmin:     config.dapiNoProxyAdmin,
-		ServerGroup:         config.serverGroup,
-		AlphaEndpoints:      config.alphaEndpoints,
-		BindDataPort:        config.dataPort,
-		BindDapiPort:        config.dapiPort,
-		BindAddress:         config.bindAddress,
-		RateLimit:           config.rateLimit,
-		ShutdownTimeout:     config.shutdownTimeout,
-		GrpcCertificate:     grpcCertificate,
-		DapiCertificate:     dapiCertificate,
-		ClusterCaCert:       caCertPool,
-		ClientCaCert:        clientCaCertPool,
-		NumInstances:        1,
+		Logger:                   logger.Named("gateway"),
+		CbConnStr:                config.cbHost,
+		Username:                 config.cbUser,
+		Password:                 config.cbPass,
+		BoostrapNodeIsLocal:      config.cbHostIsLocal,
+		SingleUserAuth:           config.singleUserAuth,
+		SingleUserUsers:          singleUserUsers,
+		Daemon:                   daemon,
+		Debug:                    config.debug,
+		ProxyServices:            strings.Split(config.dapiProxyServices, ","),
+		ProxyBlockAdmin:          config.dapiNoProxyAdmin,
+		ServerGroup:              config.serverGroup,
+		AlphaEndpoints:           config.alphaEndpoints,
+		BindDataPort:             config.dataPort,
+		BindDapiPort:             config.dapiPort,
+		BindAddress:              config.bindAddress,
+		RateLimit:                config.rateLimit,
+		SharedRateLimit:          newSharedRateLimitConfig(config),
+		KeyedRateLimits:          keyedRateLimits,
+		RateLimitCosts:           rateLimitCosts,
+		ConcurrencyLimiter:       concurrencyLimiter,
+		ShutdownTimeout:          config.shutdownTimeout,
+		TxnLeaseTimeout:          config.txnLeaseTimeout,
+		GrpcCertificate:          grpcCerts.defaultCert,
+		DapiCertificate:          dapiCerts.defaultCert,
+		ClusterCaCert:            caCertPool,
+		ClientCaCert:             clientCaCertPool,
+		ClusterClientCertificate: clusterClientCert,
+		ClientCrl:                clientCrl,
+		GrpcSniCertificates:      grpcCerts.sniCerts,
+		DapiSniCertificates:      dapiCerts.sniCerts,
+		JwtValidator:             jwtValidator,
+		AuthCacheTTL:             config.authCacheTtl,
+		AuthCacheNegativeTTL:     config.authCacheNegativeTtl,
+		AuthCacheSize:            config.authCacheSize,
+		AuthLockout:              authLockout,
+		ApiKeyStore:              apiKeyStore,
+		ScramSessionTTL:          config.scramSessionTtl,
+		NumInstances:             1,
 		StartupCallback: func(m *gateway.StartupInfo) {
 			webapi.MarkSystemHealthy()
 		},
@@ -672,6 +1231,101 @@ func startGateway() {
 		return
 	}
 
+	clientCaCertPath := config.clientCaCertPath
+	reloadCertificates := func() {
+		if len(grpcCerts.paths()) > 0 {
+			err := gw.UpdateGrpcCertificates(grpcCerts.reload(logger))
+			if err != nil {
+				logger.Warn("failed to update grpc tls certificates", zap.Error(err))
+			}
+		}
+
+		if len(dapiCerts.paths()) > 0 {
+			err := gw.UpdateDapiCertificates(dapiCerts.reload(logger))
+			if err != nil {
+				logger.Warn("failed to update data api tls certificates", zap.Error(err))
+			}
+		}
+
+		if len(clusterClientCerts.paths()) > 0 {
+			clusterClientCert, _ := clusterClientCerts.reload(logger)
+			gw.UpdateClusterClientCertificate(clusterClientCert)
+		}
+
+		if clientCaCertPath != "" {
+			clientCaCert, err := os.ReadFile(clientCaCertPath)
+			if err != nil {
+				logger.Warn("failed to reload client tls ca certificate, continuing to use the existing certificate",
+					zap.Error(err))
+			} else {
+				pool := x509.NewCertPool()
+				if !pool.AppendCertsFromPEM(clientCaCert) {
+					logger.Warn("failed to parse reloaded client tls ca certificate, continuing to use the existing certificate")
+				} else {
+					gw.UpdateClientCaCert(pool)
+					logger.Info("reloaded client tls ca certificate")
+				}
+			}
+		}
+	}
+
+	var watchedCertPaths []string
+	watchedCertPaths = append(watchedCertPaths, grpcCerts.paths()...)
+	watchedCertPaths = append(watchedCertPaths, dapiCerts.paths()...)
+	watchedCertPaths = append(watchedCertPaths, clusterClientCerts.paths()...)
+	if clientCaCertPath != "" {
+		watchedCertPaths = append(watchedCertPaths, clientCaCertPath)
+	}
+	if len(watchedCertPaths) > 0 {
+		certWatcher, err := certwatcher.New(&certwatcher.Options{
+			Logger: logger.Named("certwatcher"),
+			Paths:  watchedCertPaths,
+			OnChange: func() {
+				logger.Info("tls certificate change detected")
+				reloadCertificates()
+			},
+		})
+		if err != nil {
+			logger.Warn("failed to watch tls certificates for changes, certificates will only be reloaded on SIGHUP",
+				zap.Error(err))
+		} else {
+			defer certWatcher.Close()
+		}
+	}
+
+	// refreshCredentials re-fetches the credentials from the secret provider,
+	// continuing to use the existing credentials if this fails.
+	refreshCredentials := func() {
+		username, password, err := secretsmanager.FetchCredentials(context.Background(), credsProvider)
+		if err != nil {
+			logger.Warn("failed to refresh couchbase server credentials, continuing to use the existing credentials",
+				zap.Error(err))
+			return
+		}
+
+		gw.UpdateCredentials(username, password)
+	}
+
+	// the periodic refresh is stopped once the gateway has shut down, so that
+	// it does not update the credentials of a stopped gateway.
+	stopCredsRefreshCh := make(chan struct{})
+	if credsProvider != nil && config.cbCredsRefresh > 0 {
+		refreshInterval := config.cbCredsRefresh
+		go func() {
+			ticker := time.NewTicker(refreshInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					refreshCredentials()
+				case <-stopCredsRefreshCh:
+					return
+				}
+			}
+		}()
+	}
+
 	var configLock sync.Mutex
 	reloadConfiguration := func() {
 		configLock.Lock()
@@ -686,10 +1340,28 @@ func startGateway() {
 		newConfig := readConfig(logger)
 
 		if newConfig.cbHost != config.cbHost ||
-			newConfig.cbUser != config.cbUser ||
-			newConfig.cbPass != config.cbPass ||
 			newConfig.singleUserAuth != config.singleUserAuth {
-			logger.Warn("config changes for cbHost, cbUser, cbPass or singleUserAuth require a restart")
+			logger.Warn("config changes for cbHost or singleUserAuth require a restart")
+		}
+
+		if newConfig.singleUserUsersFile != config.singleUserUsersFile ||
+			newConfig.singleUserCertMapFile != config.singleUserCertMapFile {
+			logger.Warn("config changes for singleUserUsersFile or singleUserCertMapFile require a restart")
+		} else if singleUserUsers != nil {
+			err := singleUserUsers.Reload()
+			if err != nil {
+				logger.Warn("failed to reload single-user auth users", zap.Error(err))
+			}
+		}
+
+		if newConfig.clientCrl != config.clientCrl ||
+			newConfig.clientCrlReload != config.clientCrlReload {
+			logger.Warn("config changes for clientCrl or clientCrlReload require a restart")
+		} else if clientCrl != nil {
+			err := clientCrl.Reload()
+			if err != nil {
+				logger.Warn("failed to reload client certificate crls", zap.Error(err))
+			}
 		}
 
 		if newConfig.bindAddress != config.bindAddress ||
@@ -699,6 +1371,10 @@ func startGateway() {
 			logger.Warn("config changes for bindAddress, dataPort, dapiPort or shutdownTimeout require a restart")
 		}
 
+		if newConfig.txnLeaseTimeout != config.txnLeaseTimeout {
+			logger.Warn("config changes for txnLeaseTimeout require a restart")
+		}
+
 		if newConfig.selfSign != config.selfSign {
 			logger.Warn("config changes for selfSign require a restart")
 		}
@@ -712,6 +1388,24 @@ func startGateway() {
 			logger.Warn("config changes for certPath, keyPath, grpcCertPath, grpcKeyPath, dapiCertPath, or dapiKeyPath require a restart")
 		}
 
+		if newConfig.sniCerts != config.sniCerts ||
+			newConfig.grpcSniCerts != config.grpcSniCerts ||
+			newConfig.dapiSniCerts != config.dapiSniCerts {
+			logger.Warn("config changes for sniCerts, grpcSniCerts or dapiSniCerts require a restart")
+		}
+
+		if newConfig.clientCaCertPath != config.clientCaCertPath {
+			logger.Warn("config changes for clientCaCertPath require a restart")
+		}
+
+		if newConfig.cbClientCertPath != config.cbClientCertPath ||
+			newConfig.cbClientKeyPath != config.cbClientKeyPath {
+			logger.Warn("config changes for cbClientCertPath or cbClientKeyPath require a restart")
+		}
+
+		// the certificate contents may have changed even if the paths did not
+		reloadCertificates()
+
 		if newConfig.otlpEndpoint != config.otlpEndpoint ||
 			newConfig.disableTraces != config.disableTraces ||
 			newConfig.disableMetrics != config.disableMetrics ||
@@ -760,10 +1454,10 @@ func startGateway() {
 				zap.String("newLevel", newParsedLogLevel.String()))
 		}
 
-		if newConfig.rateLimit != config.rateLimit {
-			err := gw.Reconfigure(&gateway.ReconfigureOptions{
-				RateLimit: newConfig.rateLimit,
-			})
+		if newConfig.rateLimit != config.rateLimit ||
+			!reflect.DeepEqual(newConfig.rateLimits, config.rateLimits) ||
+			!reflect.DeepEqual(newConfig.rateLimitCosts, config.rateLimitCosts) {
+			err := reconfigureRateLimits(gw, newConfig)
 			if err != nil {
 				logger.Warn("failed to reconfigure system", zap.Error(err))
 			}
@@ -774,8 +1468,69 @@ func startGateway() {
 			newConfig.cbCredsAzureId != config.cbCredsAzureId ||
 			newConfig.cbCredsAzureVaultName != config.cbCredsAzureVaultName ||
 			newConfig.cbCredsGcpId != config.cbCredsGcpId ||
-			newConfig.cbCredsGcpProjectId != config.cbCredsGcpProjectId {
-			logger.Warn("config changes for cbCredsAwsId, cbCredsAwsRegion, cbCredsAzureId, cbCredsAzureVaultName, cbCredsGcpId or cbCredsGcpProjectId require a restart")
+			newConfig.cbCredsGcpProjectId != config.cbCredsGcpProjectId ||
+			newConfig.cbCredsSource != config.cbCredsSource ||
+			newConfig.cbCredsRefresh != config.cbCredsRefresh {
+			logger.Warn("config changes for cbCredsAwsId, cbCredsAwsRegion, cbCredsAzureId, cbCredsAzureVaultName, cbCredsGcpId, cbCredsGcpProjectId, cbCredsSource or cbCredsRefresh require a restart")
+		}
+
+		if newConfig.jwtJwksFile != config.jwtJwksFile ||
+			newConfig.jwtJwksUrl != config.jwtJwksUrl ||
+			newConfig.jwtJwksRefresh != config.jwtJwksRefresh ||
+			newConfig.jwtIssuer != config.jwtIssuer ||
+			newConfig.jwtAudience != config.jwtAudience ||
+			newConfig.jwtUserClaim != config.jwtUserClaim ||
+			newConfig.jwtDomainClaim != config.jwtDomainClaim ||
+			newConfig.jwtDefaultDomain != config.jwtDefaultDomain ||
+			newConfig.jwtClockSkew != config.jwtClockSkew {
+			logger.Warn("config changes for jwt authentication require a restart")
+		}
+
+		if newConfig.authCacheTtl != config.authCacheTtl ||
+			newConfig.authCacheNegativeTtl != config.authCacheNegativeTtl ||
+			newConfig.authCacheSize != config.authCacheSize {
+			logger.Warn("config changes for the auth cache require a restart")
+		}
+
+		if newConfig.authLockoutMaxFailures != config.authLockoutMaxFailures ||
+			newConfig.authLockoutWindow != config.authLockoutWindow ||
+			newConfig.authLockoutDuration != config.authLockoutDuration ||
+			newConfig.authLockoutBaseDelay != config.authLockoutBaseDelay ||
+			newConfig.authLockoutMaxDelay != config.authLockoutMaxDelay ||
+			newConfig.authLockoutAllowlist != config.authLockoutAllowlist {
+			logger.Warn("config changes for auth lockouts require a restart")
+		}
+
+		if newConfig.concurrencyLimitMax != config.concurrencyLimitMax ||
+			newConfig.concurrencyLimitMin != config.concurrencyLimitMin ||
+			newConfig.concurrencyLimitQueue != config.concurrencyLimitQueue ||
+			newConfig.concurrencyLimitWait != config.concurrencyLimitWait {
+			logger.Warn("config changes for the concurrency limit require a restart")
+		}
+
+		if newConfig.rateLimitSharedBucket != config.rateLimitSharedBucket ||
+			newConfig.rateLimitSharedScope != config.rateLimitSharedScope ||
+			newConfig.rateLimitSharedColl != config.rateLimitSharedColl ||
+			newConfig.rateLimitSyncInterval != config.rateLimitSyncInterval {
+			logger.Warn("config changes for the shared rate limit require a restart")
+		}
+
+		if newConfig.apiKeysFile != config.apiKeysFile {
+			logger.Warn("config changes for apiKeysFile require a restart")
+		}
+
+		if newConfig.scramSessionTtl != config.scramSessionTtl {
+			logger.Warn("config changes for scramSessionTtl require a restart")
+		}
+
+		if credsProvider != nil {
+			// the secret source is fixed at startup, but its contents may
+			// have been rotated since we last fetched them.
+			refreshCredentials()
+
+			newConfig.cbUser, newConfig.cbPass = config.cbUser, config.cbPass
+		} else if newConfig.cbUser != config.cbUser || newConfig.cbPass != config.cbPass {
+			gw.UpdateCredentials(newConfig.cbUser, newConfig.cbPass)
 		}
 
 		config = newConfig
@@ -830,6 +1585,19 @@ func startGateway() {
 		return
 	}
 
+	close(stopCredsRefreshCh)
+
+	if singleUserUsers != nil {
+		_ = singleUserUsers.Close()
+	}
+
+	if apiKeyStore != nil {
+		err = apiKeyStore.Close()
+		if err != nil {
+			logger.Warn("failed to persist api keys", zap.Error(err))
+		}
+	}
+
 	logger.Info("gateway shutdown gracefully")
 }
 
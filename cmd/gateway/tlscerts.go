@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/pkg/metrics"
+	"github.com/couchbase/stellar-gateway/utils/secretsmanager"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+type tlsKeyPair struct {
+	CertPath string
+	KeyPath  string
+}
+
+// parseTlsKeyPairs parses a comma separated list of cert/key path pairs, e.g
+// 'cert1.pem:key1.pem,cert2.pem:key2.pem'.
+func parseTlsKeyPairs(str string) ([]tlsKeyPair, error) {
+	var pairs []tlsKeyPair
+	for _, pairStr := range strings.Split(str, ",") {
+		pairStr = strings.TrimSpace(pairStr)
+		if pairStr == "" {
+			continue
+		}
+
+		certPath, keyPath, ok := strings.Cut(pairStr, ":")
+		if !ok || certPath == "" || keyPath == "" {
+			return nil, fmt.Errorf("invalid cert/key pair '%s', expected 'cert-path:key-path'", pairStr)
+		}
+
+		pairs = append(pairs, tlsKeyPair{
+			CertPath: certPath,
+			KeyPath:  keyPath,
+		})
+	}
+
+	return pairs, nil
+}
+
+// serviceCertificates tracks the certificates served by a single listener so
+// that they can be reloaded from disk when they change.
+type serviceCertificates struct {
+	service string
+
+	// defaultPair is nil when the default certificate was not loaded from
+	// disk (for instance when it is self-signed), in which case it is never
+	// reloaded.
+	defaultPair *tlsKeyPair
+	sniPairs    []tlsKeyPair
+
+	lock        sync.Mutex
+	defaultCert tls.Certificate
+	sniCerts    []tls.Certificate
+}
+
+// load performs the initial load of the certificates, failing if any of
+// them cannot be loaded.
+func (c *serviceCertificates) load(logger *zap.Logger) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.defaultPair != nil {
+		cert, err := loadX509KeyPair(c.defaultPair.CertPath, c.defaultPair.KeyPath)
+		if err != nil {
+			return err
+		}
+
+		c.defaultCert = cert
+		recordCertificateExpiry(logger, c.service, c.defaultPair.CertPath, &cert)
+	}
+
+	c.sniCerts = make([]tls.Certificate, len(c.sniPairs))
+	for i, pair := range c.sniPairs {
+		cert, err := loadX509KeyPair(pair.CertPath, pair.KeyPath)
+		if err != nil {
+			return err
+		}
+
+		c.sniCerts[i] = cert
+		recordCertificateExpiry(logger, c.service, pair.CertPath, &cert)
+	}
+
+	return nil
+}
+
+// reload re-reads the certificates from disk.  Any certificate which fails to
+// load is left as it was, so that a partially written or invalid pair does
+// not take down the listener.
+func (c *serviceCertificates) reload(logger *zap.Logger) (tls.Certificate, []tls.Certificate) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.defaultPair != nil {
+		cert, err := loadX509KeyPair(c.defaultPair.CertPath, c.defaultPair.KeyPath)
+		if err != nil {
+			logger.Warn("failed to reload tls certificate, continuing to use the existing certificate",
+				zap.Error(err),
+				zap.String("service", c.service),
+				zap.String("certPath", c.defaultPair.CertPath))
+		} else {
+			c.defaultCert = cert
+			recordCertificateExpiry(logger, c.service, c.defaultPair.CertPath, &cert)
+		}
+	}
+
+	sniCerts := make([]tls.Certificate, len(c.sniCerts))
+	copy(sniCerts, c.sniCerts)
+	for i, pair := range c.sniPairs {
+		cert, err := loadX509KeyPair(pair.CertPath, pair.KeyPath)
+		if err != nil {
+			logger.Warn("failed to reload sni tls certificate, continuing to use the existing certificate",
+				zap.Error(err),
+				zap.String("service", c.service),
+				zap.String("certPath", pair.CertPath))
+			continue
+		}
+
+		sniCerts[i] = cert
+		recordCertificateExpiry(logger, c.service, pair.CertPath, &cert)
+	}
+	c.sniCerts = sniCerts
+
+	return c.defaultCert, c.sniCerts
+}
+
+// paths returns all the files which the certificates are loaded from.  Keys
+// fetched from a secret provider are not included, as they cannot be watched.
+func (c *serviceCertificates) paths() []string {
+	var paths []string
+	addPair := func(pair tlsKeyPair) {
+		paths = append(paths, pair.CertPath)
+		if !secretsmanager.IsSecretReference(pair.KeyPath) {
+			paths = append(paths, pair.KeyPath)
+		}
+	}
+
+	if c.defaultPair != nil {
+		addPair(*c.defaultPair)
+	}
+	for _, pair := range c.sniPairs {
+		addPair(pair)
+	}
+	return paths
+}
+
+// loadX509KeyPair loads a certificate and its private key, where the key may
+// be a reference to a secret rather than a file on disk.
+func loadX509KeyPair(certPath, keyPath string) (tls.Certificate, error) {
+	if !secretsmanager.IsSecretReference(keyPath) {
+		return tls.LoadX509KeyPair(certPath, keyPath)
+	}
+
+	certPEMBlock, err := os.ReadFile(certPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	provider, err := secretsmanager.NewProvider(keyPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	keyPEMBlock, err := provider.FetchSecret(ctx)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to fetch tls private key: %w", err)
+	}
+
+	return tls.X509KeyPair(certPEMBlock, keyPEMBlock)
+}
+
+// recordCertificateExpiry logs and publishes the expiry time of a loaded
+// certificate, making upcoming expirations easier to catch.
+func recordCertificateExpiry(logger *zap.Logger, service, certPath string, cert *tls.Certificate) {
+	leaf := cert.Leaf
+	if leaf == nil {
+		if len(cert.Certificate) == 0 {
+			return
+		}
+
+		parsedLeaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			logger.Warn("failed to parse tls certificate to determine expiry",
+				zap.Error(err),
+				zap.String("service", service),
+				zap.String("certPath", certPath))
+			return
+		}
+
+		leaf = parsedLeaf
+	}
+
+	logger.Info("loaded tls certificate",
+		zap.String("service", service),
+		zap.String("certPath", certPath),
+		zap.String("subject", leaf.Subject.String()),
+		zap.Strings("dnsNames", leaf.DNSNames),
+		zap.Time("notAfter", leaf.NotAfter),
+		zap.Duration("expiresIn", time.Until(leaf.NotAfter).Round(time.Second)))
+
+	metrics.GetSnMetrics().CertificateExpiry.Record(context.Background(),
+		float64(leaf.NotAfter.Unix()),
+		metric.WithAttributes(
+			attribute.String("service", service),
+			attribute.String("cert_path", certPath)))
+}
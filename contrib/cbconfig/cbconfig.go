@@ -19,6 +19,8 @@ type FullNodeJson struct {
 	CouchApiBase string         `json:"couchApiBase,omitempty"`
 	Hostname     string         `json:"hostname,omitempty"`
 	NodeUUID     string         `json:"nodeUUID,omitempty"`
+	ThisNode     bool           `json:"thisNode,omitempty"`
+	ServerGroup  string         `json:"serverGroup,omitempty"`
 	Ports        map[string]int `json:"ports,omitempty"`
 	Services     []string       `json:"services"`
 }
@@ -37,6 +39,11 @@ type FullConfigJson struct {
 	Nodes                  []FullNodeJson        `json:"nodes,omitempty"`
 }
 
+type PoolConfigJson struct {
+	Name  string         `json:"name,omitempty"`
+	Nodes []FullNodeJson `json:"nodes,omitempty"`
+}
+
 type ServerGroupGroupJson struct {
 	Name  string         `json:"name,omitempty"`
 	Nodes []FullNodeJson `json:"nodes"`
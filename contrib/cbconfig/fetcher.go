@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 
@@ -19,6 +21,10 @@ import (
 // TODO(brett19): Need to add support for $HOST replacement, but this requires us to do a
 // streaming replace on the IO stream, since we will support streaming configurations.
 
+// ErrNotFound is returned when the requested resource does not exist, such
+// as when attempting to stream the configuration of a missing bucket.
+var ErrNotFound = errors.New("resource not found")
+
 type FetcherOptions struct {
 	HttpClient *http.Client
 	Host       string
@@ -123,6 +129,59 @@ func (f *Fetcher) doGetJsonConfig(ctx context.Context, path string, data any) er
 	return nil
 }
 
+// doStreamJsonConfig reads a streaming configuration endpoint, invoking the
+// handler for each configuration which is received.  It returns once the
+// stream is closed by the server, the context is cancelled or the handler
+// returns an error.
+func (f *Fetcher) doStreamJsonConfig(ctx context.Context, path string, handler func(json.RawMessage) error) error {
+	req, err := f.newRequest(ctx, "GET", path)
+	if err != nil {
+		return err
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err := resp.Body.Close()
+		if err != nil {
+			f.logger.Debug("unexpected close error", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d streaming %s", resp.StatusCode, path)
+	}
+
+	// ns_server separates each configuration with a series of newlines, which
+	// the json decoder will happily skip over as whitespace for us.
+	decoder := json.NewDecoder(resp.Body)
+	hostname := f.deriveHostname()
+
+	for {
+		var configBytes json.RawMessage
+		err := decoder.Decode(&configBytes)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		configBytes = bytes.ReplaceAll(configBytes, []byte("$HOST"), []byte(hostname))
+
+		err = handler(configBytes)
+		if err != nil {
+			return err
+		}
+	}
+}
+
 func (f *Fetcher) FetchNodeServices(ctx context.Context) (*TerseConfigJson, error) {
 	var config TerseConfigJson
 	err := f.doGetJsonConfig(ctx, "/pools/default/nodeServices", &config)
@@ -145,10 +204,40 @@ func (f *Fetcher) FetchServerGroups(ctx context.Context) (*ServerGroupConfigJson
 
 func (f *Fetcher) FetchTerseBucket(ctx context.Context, bucketName string) (*TerseConfigJson, error) {
 	var config TerseConfigJson
-	err := f.doGetJsonConfig(ctx, fmt.Sprintf("/pools/default/b/%s", bucketName), &config)
+	err := f.doGetJsonConfig(ctx, fmt.Sprintf("/pools/default/b/%s", url.PathEscape(bucketName)), &config)
 	if err != nil {
 		return nil, err
 	}
 
 	return &config, nil
 }
+
+// StreamTerseBucket streams the terse configuration of a bucket, invoking the
+// handler each time the configuration changes.
+func (f *Fetcher) StreamTerseBucket(ctx context.Context, bucketName string, handler func(*TerseConfigJson)) error {
+	return f.doStreamJsonConfig(ctx, fmt.Sprintf("/pools/default/bs/%s", url.PathEscape(bucketName)), func(configBytes json.RawMessage) error {
+		var config TerseConfigJson
+		err := json.Unmarshal(configBytes, &config)
+		if err != nil {
+			return err
+		}
+
+		handler(&config)
+		return nil
+	})
+}
+
+// StreamPool streams the configuration of the default pool, invoking the
+// handler each time the cluster topology changes.
+func (f *Fetcher) StreamPool(ctx context.Context, handler func(*PoolConfigJson)) error {
+	return f.doStreamJsonConfig(ctx, "/poolsStreaming/default", func(configBytes json.RawMessage) error {
+		var config PoolConfigJson
+		err := json.Unmarshal(configBytes, &config)
+		if err != nil {
+			return err
+		}
+
+		handler(&config)
+		return nil
+	})
+}
@@ -23,8 +23,11 @@ type scramServer struct {
 	clientFinalMsgWithoutProof []byte
 	clientNonce                []byte
 	salt                       []byte
+	iterations                 int
 	hashFn                     func() hash.Hash
 	saltedPassword             []byte
+	storedKey                  []byte
+	serverKey                  []byte
 
 	Username string
 }
@@ -55,9 +58,10 @@ func newScramServer(hashFnName string) (*scramServer, error) {
 	}
 
 	s := &scramServer{
-		n:      n,
-		salt:   salt,
-		hashFn: hashFn,
+		n:          n,
+		salt:       salt,
+		iterations: iterCount,
+		hashFn:     hashFn,
 	}
 	s.out.Grow(256)
 
@@ -71,9 +75,10 @@ func newScramServerWithSaltAndNonce(hashFnName, salt, nonce string) (*scramServe
 	}
 
 	s := &scramServer{
-		n:      []byte(nonce),
-		salt:   []byte(salt),
-		hashFn: hashFn,
+		n:          []byte(nonce),
+		salt:       []byte(salt),
+		iterations: iterCount,
+		hashFn:     hashFn,
 	}
 	s.out.Grow(256)
 
@@ -108,38 +113,32 @@ func (s *scramServer) authMessage() []byte {
 	return msg.Bytes()
 }
 
-func (s *scramServer) clientProof() ([]byte, error) {
-	mac := hmac.New(s.hashFn, s.saltedPassword)
-	if _, err := mac.Write([]byte("Client Key")); err != nil {
-		return nil, err
+// verifyClientProof checks the proof sent by the client against the stored
+// key, as described by RFC 5802.
+func (s *scramServer) verifyClientProof(clientProof []byte) (bool, error) {
+	mac := hmac.New(s.hashFn, s.storedKey)
+	if _, err := mac.Write(s.authMessage()); err != nil {
+		return false, err
 	}
-	clientKey := mac.Sum(nil)
-	hash := s.hashFn()
-	if _, err := hash.Write(clientKey); err != nil {
-		return nil, err
+	clientSignature := mac.Sum(nil)
+	if len(clientProof) != len(clientSignature) {
+		return false, nil
 	}
-	storedKey := hash.Sum(nil)
-	mac = hmac.New(s.hashFn, storedKey)
-	if _, err := mac.Write(s.authMessage()); err != nil {
-		return nil, err
+
+	clientKey := make([]byte, len(clientProof))
+	for i, b := range clientProof {
+		clientKey[i] = b ^ clientSignature[i]
 	}
-	clientProof := mac.Sum(nil)
-	for i, b := range clientKey {
-		clientProof[i] ^= b
+	hash := s.hashFn()
+	if _, err := hash.Write(clientKey); err != nil {
+		return false, err
 	}
-	clientProof64 := make([]byte, b64.EncodedLen(len(clientProof)))
-	b64.Encode(clientProof64, clientProof)
-	return clientProof64, nil
+
+	return hmac.Equal(hash.Sum(nil), s.storedKey), nil
 }
 
 func (s *scramServer) serverSignature() ([]byte, error) {
-	mac := hmac.New(s.hashFn, s.saltedPassword)
-	if _, err := mac.Write([]byte("Server Key")); err != nil {
-		return nil, err
-	}
-	serverKey := mac.Sum(nil)
-
-	mac = hmac.New(s.hashFn, serverKey)
+	mac := hmac.New(s.hashFn, s.serverKey)
 	if _, err := mac.Write(s.authMessage()); err != nil {
 		return nil, err
 	}
@@ -152,7 +151,17 @@ func (s *scramServer) serverSignature() ([]byte, error) {
 
 // Start performs the first step of the process, given request data from a client.
 func (s *scramServer) Start(in []byte) (string, error) {
-	s.out.Reset()
+	username, err := s.parseClientFirst(in)
+	if err != nil {
+		return "", err
+	}
+
+	s.writeServerFirst()
+	return username, nil
+}
+
+// parseClientFirst reads the client-first-message, returning the username.
+func (s *scramServer) parseClientFirst(in []byte) (string, error) {
 	fields := bytes.Split(in, []byte(","))
 	if len(fields) != 4 {
 		return "", fmt.Errorf("expected 4 fields in first SCRAM-SHA-1 client message, got %d: %q", len(fields), in)
@@ -176,6 +185,13 @@ func (s *scramServer) Start(in []byte) (string, error) {
 	copy(username, fields[2][2:])
 
 	s.clientNonce = fields[3][2:]
+
+	return string(username), nil
+}
+
+// writeServerFirst writes the server-first-message to the output buffer.
+func (s *scramServer) writeServerFirst() {
+	s.out.Reset()
 	s.out.WriteString("r=")
 	s.out.Write(s.clientNonce)
 	s.out.Write(s.n)
@@ -186,12 +202,10 @@ func (s *scramServer) Start(in []byte) (string, error) {
 	s.out.Write(encodedSalt)
 
 	s.out.WriteString(",i=")
-	s.out.Write([]byte(strconv.Itoa(iterCount)))
+	s.out.Write([]byte(strconv.Itoa(s.iterations)))
 
 	s.serverFirstMsg = make([]byte, s.out.Len())
 	copy(s.serverFirstMsg, s.out.Bytes())
-
-	return string(username), nil
 }
 
 // Step1 performs the first "step".
@@ -219,14 +233,22 @@ func (s *scramServer) Step1(in []byte) error {
 	idx := bytes.Index(in, []byte(",p="))
 	s.clientFinalMsgWithoutProof = in[:idx]
 
-	rvdClientProof := fields[2][2:]
-	ourClientProof, err := s.clientProof()
+	if s.storedKey == nil {
+		return errors.New("no credentials were set for the user")
+	}
+
+	rvdClientProof := make([]byte, b64.DecodedLen(len(fields[2][2:])))
+	proofLen, err := b64.Decode(rvdClientProof, fields[2][2:])
 	if err != nil {
-		return err
+		return fmt.Errorf("client sent an invalid SCRAM-SHA-1 proof: %q", fields[2])
 	}
 
-	if !bytes.Equal(rvdClientProof, ourClientProof) {
-		return fmt.Errorf("client proof did not match our proof: %q != %q", rvdClientProof, ourClientProof)
+	ok, err := s.verifyClientProof(rvdClientProof[:proofLen])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("client proof did not match our proof: %q", fields[2][2:])
 	}
 
 	srvSig, err := s.serverSignature()
@@ -264,7 +286,7 @@ func (s *scramServer) saltPassword(password []byte) error {
 	ui := mac.Sum(nil)
 	hi := make([]byte, len(ui))
 	copy(hi, ui)
-	for i := 1; i < iterCount; i++ {
+	for i := 1; i < s.iterations; i++ {
 		mac.Reset()
 		if _, err := mac.Write(ui); err != nil {
 			return err
@@ -275,9 +297,60 @@ func (s *scramServer) saltPassword(password []byte) error {
 		}
 	}
 	s.saltedPassword = hi
+
+	mac = hmac.New(s.hashFn, s.saltedPassword)
+	if _, err := mac.Write([]byte("Client Key")); err != nil {
+		return err
+	}
+	hash := s.hashFn()
+	if _, err := hash.Write(mac.Sum(nil)); err != nil {
+		return err
+	}
+	s.storedKey = hash.Sum(nil)
+
+	mac = hmac.New(s.hashFn, s.saltedPassword)
+	if _, err := mac.Write([]byte("Server Key")); err != nil {
+		return err
+	}
+	s.serverKey = mac.Sum(nil)
+
 	return nil
 }
 
+// Credentials are the keys stored for a user, which allow SCRAM to be
+// performed without knowing their password.
+type Credentials struct {
+	Salt       []byte
+	Iterations int
+	StoredKey  []byte
+	ServerKey  []byte
+}
+
+// NewCredentials derives the credentials for a password.
+func NewCredentials(hashFn string, password string, salt []byte, iterations int) (*Credentials, error) {
+	hashFnImpl, err := parseHashFn(hashFn)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &scramServer{
+		salt:       salt,
+		iterations: iterations,
+		hashFn:     hashFnImpl,
+	}
+	err = s.saltPassword([]byte(password))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credentials{
+		Salt:       salt,
+		Iterations: iterations,
+		StoredKey:  s.storedKey,
+		ServerKey:  s.serverKey,
+	}, nil
+}
+
 // ScramServer is a server implementation of SCRAM auth with a slightly improved interface.
 type ScramServer struct {
 	srv      *scramServer
@@ -302,6 +375,39 @@ func (s *ScramServer) Start(in []byte, hashFn string) ([]byte, error) {
 	return srv.Out(), nil
 }
 
+// StartWithCredentials performs the first step of the SCRAM process, using the
+// credentials returned by lookup for the user named by the client.
+func (s *ScramServer) StartWithCredentials(
+	in []byte,
+	hashFn string,
+	lookup func(username string) (*Credentials, error),
+) ([]byte, error) {
+	srv, err := newScramServer(hashFn)
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := srv.parseClientFirst(in)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := lookup(username)
+	if err != nil {
+		return nil, err
+	}
+
+	srv.salt = creds.Salt
+	srv.iterations = creds.Iterations
+	srv.storedKey = creds.StoredKey
+	srv.serverKey = creds.ServerKey
+	srv.writeServerFirst()
+
+	s.srv = srv
+	s.username = username
+	return srv.Out(), nil
+}
+
 // Step performs one step of the SCRAM process. Returns nil if SCRAM completes.
 func (s *ScramServer) Step(in []byte) ([]byte, error) {
 	if s.srv == nil {
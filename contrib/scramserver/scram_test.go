@@ -41,3 +41,48 @@ func TestScram(t *testing.T) {
 			"was: %s", string(out))
 	}
 }
+
+func TestScramCredentials(t *testing.T) {
+	// the example from RFC 5802
+	creds, err := NewCredentials("SCRAM-SHA1", "pencil", []byte("A%\xc2G\xe4:\xb1\xe9<m\xffv"), 4096)
+	if err != nil {
+		t.Fatalf("Failed to create credentials: %v", err)
+	}
+
+	srvr, err := newScramServerWithSaltAndNonce("SCRAM-SHA1", "", "3rfcNHYJY1ZVvWVs7j")
+	if err != nil {
+		t.Fatalf("Failed to create scram auth: %v", err)
+	}
+
+	u, err := srvr.parseClientFirst([]byte("n,,n=user,r=fyko+d2lbbFgONRv9qkxdawL"))
+	if err != nil {
+		t.Fatalf("Failed to start scram auth: %v", err)
+	}
+
+	if u != "user" {
+		t.Fatalf("Username should have been user but was: %s", u)
+	}
+
+	srvr.salt = creds.Salt
+	srvr.iterations = creds.Iterations
+	srvr.storedKey = creds.StoredKey
+	srvr.serverKey = creds.ServerKey
+	srvr.writeServerFirst()
+
+	out := srvr.Out()
+	if string(out) != "r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096" {
+		t.Fatalf("Output from start should have been r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096 "+
+			"was: %s", string(out))
+	}
+
+	err = srvr.Step1([]byte("c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts="))
+	if err != nil {
+		t.Fatalf("Failed to step scram auth: %v", err)
+	}
+
+	out = srvr.Out()
+	if string(out) != "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=" {
+		t.Fatalf("Output from step should have been v=rmF9pqV8S7suAoZWja4dJRkFsKQ= "+
+			"was: %s", string(out))
+	}
+}
@@ -0,0 +1,55 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/couchbase/stellar-gateway/gateway/auth/lockout"
+	"github.com/couchbase/stellar-gateway/gateway/credentials"
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+)
+
+// ErrAdminRequired indicates that the credentials presented to manage api keys
+// were not the gateway's administrator credentials.
+var ErrAdminRequired = errors.New("api keys may only be managed using the gateway's administrator credentials")
+
+// AdminVerifier verifies that callers managing api keys authenticated using the
+// same credentials the gateway uses to connect to the cluster, which are the
+// only credentials permitted to manage them.
+type AdminVerifier struct {
+	Creds *credentials.Provider
+
+	// Lockout optionally throttles callers after repeated failures, without
+	// which the endpoints could be used to guess the cluster credentials.
+	Lockout *lockout.Tracker
+}
+
+// Verify checks the username and password presented by a caller, returning
+// ErrAdminRequired if they are incorrect, or a lockout.LockedOutError if the
+// caller is currently locked out.
+func (v *AdminVerifier) Verify(ctx context.Context, username, password string) error {
+	clientIp := reqctx.ClientIPFromContext(ctx)
+	if v.Lockout != nil {
+		err := v.Lockout.Check(ctx, username, clientIp)
+		if err != nil {
+			return err
+		}
+	}
+
+	adminUser, adminPass := v.Creds.Get()
+	userOk := subtle.ConstantTimeCompare([]byte(username), []byte(adminUser)) == 1
+	passOk := subtle.ConstantTimeCompare([]byte(password), []byte(adminPass)) == 1
+	if username == "" || !userOk || !passOk {
+		if v.Lockout != nil {
+			v.Lockout.RecordFailure(ctx, username, clientIp)
+		}
+		return ErrAdminRequired
+	}
+
+	if v.Lockout != nil {
+		v.Lockout.RecordSuccess(ctx, username, clientIp)
+	}
+
+	return nil
+}
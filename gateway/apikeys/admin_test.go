@@ -0,0 +1,31 @@
+package apikeys
+
+import (
+	"context"
+	"testing"
+
+	"github.com/couchbase/stellar-gateway/gateway/auth/lockout"
+	"github.com/couchbase/stellar-gateway/gateway/credentials"
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminVerifierLockout(t *testing.T) {
+	verifier := &AdminVerifier{
+		Creds: credentials.NewProvider("Administrator", "password"),
+		Lockout: lockout.NewTracker(&lockout.Options{
+			MaxFailures: 3,
+			BaseDelay:   -1,
+		}),
+	}
+	ctx := reqctx.WithClientIP(context.Background(), "10.0.0.1")
+
+	assert.NoError(t, verifier.Verify(ctx, "Administrator", "password"))
+	assert.ErrorIs(t, verifier.Verify(ctx, "", ""), ErrAdminRequired)
+	assert.ErrorIs(t, verifier.Verify(ctx, "Administrator", "guess1"), ErrAdminRequired)
+
+	// once locked out, even the correct password is refused
+	assert.ErrorIs(t, verifier.Verify(ctx, "Administrator", "guess2"), ErrAdminRequired)
+	assert.ErrorIs(t, verifier.Verify(ctx, "Administrator", "password"), lockout.ErrLockedOut)
+
+}
@@ -0,0 +1,125 @@
+package apikeys
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+	"google.golang.org/grpc"
+)
+
+// RestrictableResourceFromContext returns the bucket and scope which a
+// request is confined to, which are empty if the request may access any
+// bucket, regardless of the bucket it names.
+func RestrictableResourceFromContext(ctx context.Context) (string, string) {
+	res := reqctx.ResourceFromContext(ctx)
+	if res.Unbounded {
+		return "", ""
+	}
+	return res.Bucket, res.Scope
+}
+
+// isUnboundedMethod returns whether a gRPC method executes statements which
+// can reference any bucket, so the bucket and scope of the request do not
+// confine what it accesses.
+func isUnboundedMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/couchbase.query.") ||
+		strings.HasPrefix(fullMethod, "/couchbase.search.") ||
+		strings.HasPrefix(fullMethod, "/couchbase.analytics.")
+}
+
+type bucketNameGetter interface {
+	GetBucketName() string
+}
+
+type scopeNameGetter interface {
+	GetScopeName() string
+}
+
+func withRequestResource(ctx context.Context, fullMethod string, req interface{}) context.Context {
+	res := reqctx.Resource{
+		Unbounded: isUnboundedMethod(fullMethod),
+	}
+	if getter, ok := req.(bucketNameGetter); ok {
+		res.Bucket = getter.GetBucketName()
+	}
+	if getter, ok := req.(scopeNameGetter); ok {
+		res.Scope = getter.GetScopeName()
+	}
+
+	return reqctx.WithResource(ctx, res)
+}
+
+// GrpcUnaryInterceptor records the bucket and scope of each request.
+func GrpcUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		return handler(withRequestResource(ctx, info.FullMethod, req), req)
+	}
+}
+
+type resourceServerStream struct {
+	grpc.ServerStream
+	ctx        context.Context
+	fullMethod string
+}
+
+func (s *resourceServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *resourceServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err != nil {
+		return err
+	}
+
+	s.ctx = withRequestResource(s.ServerStream.Context(), s.fullMethod, m)
+	return nil
+}
+
+// GrpcStreamInterceptor records the bucket and scope of the most recently
+// received message of each stream.
+func GrpcStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &resourceServerStream{
+			ServerStream: ss,
+			ctx:          ss.Context(),
+			fullMethod:   info.FullMethod,
+		})
+	}
+}
+
+// HttpMiddleware records the bucket and scope of Data API requests, and
+// accepts keys passed via the X-API-Key header in place of a bearer token.
+func HttpMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey := r.Header.Get("X-API-Key"); apiKey != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+apiKey)
+			r.Header.Del("X-API-Key")
+		}
+
+		bucket, scope := parseResourcePath(r.URL.Path)
+		ctx := reqctx.WithResource(r.Context(), reqctx.Resource{
+			Bucket: bucket,
+			Scope:  scope,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// parseResourcePath extracts the bucket and scope from Data API paths of the
+// form /v1/buckets/{bucketName}/scopes/{scopeName}/...
+func parseResourcePath(path string) (string, string) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) < 3 || parts[1] != "buckets" {
+		return "", ""
+	}
+
+	bucket := parts[2]
+	if len(parts) < 5 || parts[3] != "scopes" {
+		return bucket, ""
+	}
+
+	return bucket, parts[4]
+}
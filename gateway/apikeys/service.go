@@ -0,0 +1,173 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/gateway/auth/lockout"
+	"github.com/couchbase/stellar-gateway/genproto/admin_apikey_v1"
+	"github.com/couchbase/stellar-gateway/utils/authhdr"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type ServiceOptions struct {
+	Logger *zap.Logger
+	Store  *Store
+
+	Admin *AdminVerifier
+}
+
+// Service implements the ApiKeyAdminService, the gRPC equivalent of the api
+// key endpoints of the Data API.
+type Service struct {
+	admin_apikey_v1.UnimplementedApiKeyAdminServiceServer
+
+	logger *zap.Logger
+	store  *Store
+	admin  *AdminVerifier
+}
+
+func NewService(opts *ServiceOptions) (*Service, error) {
+	if opts.Store == nil || opts.Admin == nil {
+		return nil, errors.New("a store and admin verifier must be specified")
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Service{
+		logger: logger,
+		store:  opts.Store,
+		admin:  opts.Admin,
+	}, nil
+}
+
+// checkAdmin verifies that the caller presented the gateway's administrator
+// credentials.
+func (s *Service) checkAdmin(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	authHdrs := md.Get("authorization")
+	if len(authHdrs) == 0 {
+		return status.Errorf(codes.Unauthenticated, "You must send authentication to use this endpoint.")
+	}
+
+	username, password, ok := authhdr.DecodeBasicAuth(authHdrs[0])
+	if !ok {
+		return status.Errorf(codes.InvalidArgument, "Failed to parse authorization header.")
+	}
+
+	err := s.admin.Verify(ctx, username, password)
+	if err != nil {
+		if errors.Is(err, lockout.ErrLockedOut) {
+			return status.Errorf(codes.ResourceExhausted,
+				"Too many failed authentication attempts, try again later.")
+		}
+
+		return status.Errorf(codes.PermissionDenied,
+			"Only the credentials the gateway connects to the cluster with may manage api keys.")
+	}
+
+	return nil
+}
+
+func timeFromGo(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func apiKeyToPs(key *Key) *admin_apikey_v1.ApiKey {
+	return &admin_apikey_v1.ApiKey{
+		Id:         key.ID,
+		Name:       key.Name,
+		User:       key.User,
+		Domain:     key.Domain,
+		Bucket:     key.Bucket,
+		Scope:      key.Scope,
+		CreatedAt:  timestamppb.New(key.CreatedAt),
+		ExpiresAt:  timeFromGo(key.ExpiresAt),
+		LastUsedAt: timeFromGo(key.LastUsedAt),
+	}
+}
+
+func (s *Service) ListApiKeys(
+	ctx context.Context, in *admin_apikey_v1.ListApiKeysRequest,
+) (*admin_apikey_v1.ListApiKeysResponse, error) {
+	if err := s.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	keys := s.store.List()
+
+	apiKeys := make([]*admin_apikey_v1.ApiKey, len(keys))
+	for i := range keys {
+		apiKeys[i] = apiKeyToPs(&keys[i])
+	}
+
+	return &admin_apikey_v1.ListApiKeysResponse{
+		ApiKeys: apiKeys,
+	}, nil
+}
+
+func (s *Service) CreateApiKey(
+	ctx context.Context, in *admin_apikey_v1.CreateApiKeyRequest,
+) (*admin_apikey_v1.CreateApiKeyResponse, error) {
+	if err := s.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	opts := &CreateOptions{
+		Name:   in.Name,
+		User:   in.User,
+		Domain: in.Domain,
+		Bucket: in.Bucket,
+		Scope:  in.Scope,
+	}
+	if in.ExpiresAt != nil {
+		expiresAt := in.ExpiresAt.AsTime()
+		opts.ExpiresAt = &expiresAt
+	}
+
+	key, token, err := s.store.Create(opts)
+	if err != nil {
+		if errors.Is(err, ErrInvalidOptions) {
+			return nil, status.Errorf(codes.InvalidArgument, "%s", err.Error())
+		}
+
+		s.logger.Warn("failed to create api key", zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "Failed to create api key.")
+	}
+
+	return &admin_apikey_v1.CreateApiKeyResponse{
+		ApiKey: apiKeyToPs(key),
+		Token:  token,
+	}, nil
+}
+
+func (s *Service) RevokeApiKey(
+	ctx context.Context, in *admin_apikey_v1.RevokeApiKeyRequest,
+) (*admin_apikey_v1.RevokeApiKeyResponse, error) {
+	if err := s.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	err := s.store.Revoke(in.Id)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return nil, status.Errorf(codes.NotFound, "Api key '%s' not found.", in.Id)
+		}
+
+		s.logger.Warn("failed to revoke api key", zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "Failed to revoke api key.")
+	}
+
+	return &admin_apikey_v1.RevokeApiKeyResponse{}, nil
+}
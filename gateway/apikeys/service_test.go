@@ -0,0 +1,112 @@
+package apikeys
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/gateway/credentials"
+	"github.com/couchbase/stellar-gateway/genproto/admin_apikey_v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestService(t *testing.T) {
+	store, err := NewStore(&StoreOptions{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	svc, err := NewService(&ServiceOptions{
+		Store: store,
+		Admin: &AdminVerifier{
+			Creds: credentials.NewProvider("Administrator", "password"),
+		},
+	})
+	require.NoError(t, err)
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	admin_apikey_v1.RegisterApiKeyAdminServiceServer(srv, svc)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	client := admin_apikey_v1.NewApiKeyAdminServiceClient(conn)
+
+	withAuth := func(authHdr string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", authHdr)
+	}
+
+	// Administrator:password
+	adminCtx := withAuth("Basic QWRtaW5pc3RyYXRvcjpwYXNzd29yZA==")
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	createReq := &admin_apikey_v1.CreateApiKeyRequest{
+		Name:      "ingest",
+		User:      "ingest-user",
+		Bucket:    "travel-sample",
+		ExpiresAt: timestamppb.New(expiresAt),
+	}
+
+	_, err = client.CreateApiKey(context.Background(), createReq)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// user:pass
+	_, err = client.CreateApiKey(withAuth("Basic dXNlcjpwYXNz"), createReq)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	createResp, err := client.CreateApiKey(adminCtx, createReq)
+	require.NoError(t, err)
+	assert.True(t, IsApiKey(createResp.Token))
+
+	_, err = store.Authenticate(createResp.Token, "travel-sample", "")
+	require.NoError(t, err)
+
+	listResp, err := client.ListApiKeys(adminCtx, &admin_apikey_v1.ListApiKeysRequest{})
+	require.NoError(t, err)
+	require.Len(t, listResp.ApiKeys, 1)
+	key := listResp.ApiKeys[0]
+	assert.Equal(t, createResp.ApiKey.Id, key.Id)
+	assert.Equal(t, "ingest", key.Name)
+	assert.Equal(t, "ingest-user", key.User)
+	assert.Equal(t, "local", key.Domain)
+	assert.Equal(t, "travel-sample", key.Bucket)
+	assert.Equal(t, "", key.Scope)
+	require.NotNil(t, key.ExpiresAt)
+	assert.True(t, expiresAt.Equal(key.ExpiresAt.AsTime()))
+	assert.NotNil(t, key.CreatedAt)
+	assert.NotNil(t, key.LastUsedAt)
+
+	_, err = client.RevokeApiKey(adminCtx, &admin_apikey_v1.RevokeApiKeyRequest{Id: key.Id})
+	require.NoError(t, err)
+
+	_, err = client.RevokeApiKey(adminCtx, &admin_apikey_v1.RevokeApiKeyRequest{Id: key.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// scopes cannot be restricted without a bucket
+	_, err = client.CreateApiKey(adminCtx, &admin_apikey_v1.CreateApiKeyRequest{
+		User:  "ingest-user",
+		Scope: "inventory",
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
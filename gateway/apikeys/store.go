@@ -0,0 +1,445 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TokenPrefix identifies bearer tokens which are API keys rather than JWTs.
+const TokenPrefix = "cbk_"
+
+var (
+	ErrInvalidKey     = errors.New("invalid api key")
+	ErrKeyExpired     = errors.New("api key has expired")
+	ErrKeyRestricted  = errors.New("api key is not permitted to access this resource")
+	ErrKeyNotFound    = errors.New("api key not found")
+	ErrInvalidOptions = errors.New("invalid api key options")
+)
+
+// Key describes an API key.  The secret portion of the key is never stored,
+// only its hash.
+type Key struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	User   string `json:"user"`
+	Domain string `json:"domain"`
+
+	// Bucket and Scope optionally restrict the key to only being usable
+	// against a particular bucket, or a scope within that bucket.  Restricted
+	// keys cannot be used for queries, which may access any bucket.
+	Bucket string `json:"bucket,omitempty"`
+	Scope  string `json:"scope,omitempty"`
+
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// Allows returns whether the key may be used to access a bucket and scope.
+// Requests which do not target a bucket are not permitted for restricted
+// keys, as they could otherwise reach any bucket the user has access to.
+func (k *Key) Allows(bucket, scope string) bool {
+	if k.Bucket != "" && k.Bucket != bucket {
+		return false
+	}
+
+	if k.Scope != "" && k.Scope != scope {
+		return false
+	}
+
+	return true
+}
+
+func (k *Key) isExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+type storedKey struct {
+	Key
+	Hash string `json:"hash"`
+
+	lastUsed atomic.Int64
+}
+
+func (k *storedKey) snapshot() Key {
+	key := k.Key
+	if lastUsed := k.lastUsed.Load(); lastUsed != 0 {
+		lastUsedAt := time.Unix(0, lastUsed).UTC()
+		key.LastUsedAt = &lastUsedAt
+	}
+	return key
+}
+
+type storeFile struct {
+	Keys []*storedKey `json:"keys"`
+}
+
+type StoreOptions struct {
+	Logger *zap.Logger
+
+	// Path is the file the keys are persisted to.  If empty, keys are only
+	// held in memory.
+	Path string
+
+	// FlushInterval is how often last-used timestamps are written to disk,
+	// these are not written on every use to avoid a write per request.
+	// Defaults to 1 minute.
+	FlushInterval time.Duration
+}
+
+// Store manages API keys, persisting them to a local file.
+type Store struct {
+	logger *zap.Logger
+	path   string
+
+	lock  sync.RWMutex
+	keys  map[string]*storedKey
+	dirty atomic.Bool
+
+	// writeLock serializes writes to the file, separately from lock so that
+	// authentication is not blocked on disk io.
+	writeLock sync.Mutex
+
+	closeSig  chan struct{}
+	closeOnce sync.Once
+	closeWg   sync.WaitGroup
+}
+
+func NewStore(opts *StoreOptions) (*Store, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	s := &Store{
+		logger:   logger,
+		path:     opts.Path,
+		keys:     make(map[string]*storedKey),
+		closeSig: make(chan struct{}),
+	}
+
+	if s.path != "" {
+		err := s.load()
+		if err != nil {
+			return nil, err
+		}
+
+		flushInterval := opts.FlushInterval
+		if flushInterval <= 0 {
+			flushInterval = 1 * time.Minute
+		}
+
+		s.closeWg.Add(1)
+		go s.flushThread(flushInterval)
+	}
+
+	return s, nil
+}
+
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to read api keys file: %w", err)
+	}
+
+	var file storeFile
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return fmt.Errorf("failed to parse api keys file: %w", err)
+	}
+
+	for _, key := range file.Keys {
+		if key.LastUsedAt != nil {
+			key.lastUsed.Store(key.LastUsedAt.UnixNano())
+		}
+
+		s.keys[key.ID] = key
+	}
+
+	return nil
+}
+
+func (s *Store) flushThread(interval time.Duration) {
+	defer s.closeWg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.dirty.Load() {
+				err := s.save()
+				if err != nil {
+					s.logger.Warn("failed to persist api key last-used timestamps", zap.Error(err))
+				}
+			}
+		case <-s.closeSig:
+			return
+		}
+	}
+}
+
+// save writes the keys to disk, replacing the file atomically so that a
+// crash cannot leave it partially written.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	s.dirty.Store(false)
+
+	s.lock.RLock()
+	file := storeFile{
+		Keys: make([]*storedKey, 0, len(s.keys)),
+	}
+	for _, key := range s.keys {
+		fileKey := &storedKey{
+			Key:  key.snapshot(),
+			Hash: key.Hash,
+		}
+		file.Keys = append(file.Keys, fileKey)
+	}
+	s.lock.RUnlock()
+
+	sort.Slice(file.Keys, func(i, j int) bool {
+		return file.Keys[i].CreatedAt.Before(file.Keys[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(&file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		s.dirty.Store(true)
+		return fmt.Errorf("failed to write api keys file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmpFile.Name())
+	}()
+
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), s.path)
+	}
+	if err != nil {
+		s.dirty.Store(true)
+		return fmt.Errorf("failed to write api keys file: %w", err)
+	}
+
+	return nil
+}
+
+type CreateOptions struct {
+	Name      string
+	User      string
+	Domain    string
+	Bucket    string
+	Scope     string
+	ExpiresAt *time.Time
+}
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// Create generates a new API key, returning its details along with the
+// token which must be presented to use it.  The token cannot be retrieved
+// again later.
+func (s *Store) Create(opts *CreateOptions) (*Key, string, error) {
+	if opts.User == "" {
+		return nil, "", fmt.Errorf("%w: api keys must be mapped to a user", ErrInvalidOptions)
+	}
+	if opts.Scope != "" && opts.Bucket == "" {
+		return nil, "", fmt.Errorf("%w: a bucket must be specified to restrict an api key to a scope", ErrInvalidOptions)
+	}
+	if opts.ExpiresAt != nil && !opts.ExpiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: api key expiry must be in the future", ErrInvalidOptions)
+	}
+
+	idBytes := make([]byte, 8)
+	_, err := rand.Read(idBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secretBytes := make([]byte, 32)
+	_, err = rand.Read(secretBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id := hex.EncodeToString(idBytes)
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	domain := opts.Domain
+	if domain == "" {
+		domain = "local"
+	}
+
+	var expiresAt *time.Time
+	if opts.ExpiresAt != nil {
+		expiry := opts.ExpiresAt.UTC()
+		expiresAt = &expiry
+	}
+
+	key := &storedKey{
+		Key: Key{
+			ID:        id,
+			Name:      opts.Name,
+			User:      opts.User,
+			Domain:    domain,
+			Bucket:    opts.Bucket,
+			Scope:     opts.Scope,
+			CreatedAt: time.Now().UTC(),
+			ExpiresAt: expiresAt,
+		},
+		Hash: hashSecret(secret),
+	}
+
+	s.lock.Lock()
+	s.keys[id] = key
+	s.lock.Unlock()
+
+	err = s.save()
+	if err != nil {
+		s.lock.Lock()
+		delete(s.keys, id)
+		s.lock.Unlock()
+
+		return nil, "", err
+	}
+
+	info := key.snapshot()
+	return &info, TokenPrefix + id + "_" + secret, nil
+}
+
+// List returns all the keys, ordered by creation time.
+func (s *Store) List() []Key {
+	s.lock.RLock()
+	keys := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key.snapshot())
+	}
+	s.lock.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys
+}
+
+// Revoke deletes a key, after which it can no longer be used.
+func (s *Store) Revoke(id string) error {
+	s.lock.Lock()
+	key, ok := s.keys[id]
+	if !ok {
+		s.lock.Unlock()
+		return ErrKeyNotFound
+	}
+	delete(s.keys, id)
+	s.lock.Unlock()
+
+	err := s.save()
+	if err != nil {
+		s.lock.Lock()
+		s.keys[id] = key
+		s.lock.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// IsApiKey returns whether a bearer token is in the format of an API key.
+func IsApiKey(token string) bool {
+	return strings.HasPrefix(token, TokenPrefix)
+}
+
+// Authenticate validates a token and checks that it may access the bucket
+// and scope being requested, recording when the key was last used.
+func (s *Store) Authenticate(token, bucket, scope string) (*Key, error) {
+	keyStr, ok := strings.CutPrefix(token, TokenPrefix)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	id, secret, ok := strings.Cut(keyStr, "_")
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	s.lock.RLock()
+	key, ok := s.keys[id]
+	s.lock.RUnlock()
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.Hash)) != 1 {
+		return nil, ErrInvalidKey
+	}
+
+	now := time.Now()
+	if key.isExpired(now) {
+		return nil, ErrKeyExpired
+	}
+
+	if !key.Allows(bucket, scope) {
+		return nil, ErrKeyRestricted
+	}
+
+	key.lastUsed.Store(now.UnixNano())
+	s.dirty.Store(true)
+
+	info := key.snapshot()
+	return &info, nil
+}
+
+// Close stops the store, persisting any outstanding last-used timestamps.
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closeSig)
+	})
+	s.closeWg.Wait()
+
+	if s.dirty.Load() {
+		return s.save()
+	}
+
+	return nil
+}
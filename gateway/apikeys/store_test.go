@@ -0,0 +1,215 @@
+package apikeys
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestStoreAuthenticate(t *testing.T) {
+	store, err := NewStore(&StoreOptions{})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	key, token, err := store.Create(&CreateOptions{
+		Name: "ingest",
+		User: "ingest-user",
+	})
+	require.NoError(t, err)
+	assert.True(t, IsApiKey(token))
+	assert.Equal(t, "local", key.Domain)
+	assert.Nil(t, key.LastUsedAt)
+
+	authedKey, err := store.Authenticate(token, "travel-sample", "inventory")
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, authedKey.ID)
+	assert.Equal(t, "ingest-user", authedKey.User)
+	require.NotNil(t, authedKey.LastUsedAt)
+
+	listed := store.List()
+	require.Len(t, listed, 1)
+	assert.NotNil(t, listed[0].LastUsedAt)
+
+	_, err = store.Authenticate(token+"x", "", "")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = store.Authenticate(TokenPrefix+"missing_secret", "", "")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	require.NoError(t, store.Revoke(key.ID))
+	_, err = store.Authenticate(token, "", "")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	assert.ErrorIs(t, store.Revoke(key.ID), ErrKeyNotFound)
+}
+
+func TestStoreRestrictions(t *testing.T) {
+	store, err := NewStore(&StoreOptions{})
+	require.NoError(t, err)
+
+	_, bucketToken, err := store.Create(&CreateOptions{
+		User:   "user",
+		Bucket: "travel-sample",
+	})
+	require.NoError(t, err)
+
+	_, scopeToken, err := store.Create(&CreateOptions{
+		User:   "user",
+		Bucket: "travel-sample",
+		Scope:  "inventory",
+	})
+	require.NoError(t, err)
+
+	_, err = store.Authenticate(bucketToken, "travel-sample", "tenant_agent_00")
+	assert.NoError(t, err)
+	_, err = store.Authenticate(bucketToken, "beer-sample", "_default")
+	assert.ErrorIs(t, err, ErrKeyRestricted)
+	_, err = store.Authenticate(bucketToken, "", "")
+	assert.ErrorIs(t, err, ErrKeyRestricted)
+
+	_, err = store.Authenticate(scopeToken, "travel-sample", "inventory")
+	assert.NoError(t, err)
+	_, err = store.Authenticate(scopeToken, "travel-sample", "tenant_agent_00")
+	assert.ErrorIs(t, err, ErrKeyRestricted)
+
+	_, _, err = store.Create(&CreateOptions{
+		User:  "user",
+		Scope: "inventory",
+	})
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+}
+
+func TestStoreExpiry(t *testing.T) {
+	store, err := NewStore(&StoreOptions{})
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(50 * time.Millisecond)
+	_, token, err := store.Create(&CreateOptions{
+		User:      "user",
+		ExpiresAt: &expiresAt,
+	})
+	require.NoError(t, err)
+
+	_, err = store.Authenticate(token, "", "")
+	require.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = store.Authenticate(token, "", "")
+	assert.ErrorIs(t, err, ErrKeyExpired)
+
+	pastExpiry := time.Now().Add(-time.Minute)
+	_, _, err = store.Create(&CreateOptions{
+		User:      "user",
+		ExpiresAt: &pastExpiry,
+	})
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+}
+
+func TestStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+
+	store, err := NewStore(&StoreOptions{Path: path})
+	require.NoError(t, err)
+
+	key, token, err := store.Create(&CreateOptions{
+		User:   "user",
+		Domain: "external",
+	})
+	require.NoError(t, err)
+
+	// the secret itself must never be written to disk
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	_, secret, _ := strings.Cut(strings.TrimPrefix(token, TokenPrefix), "_")
+	assert.NotContains(t, string(data), secret)
+
+	_, err = store.Authenticate(token, "", "")
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	reopened, err := NewStore(&StoreOptions{Path: path})
+	require.NoError(t, err)
+	defer func() {
+		_ = reopened.Close()
+	}()
+
+	authedKey, err := reopened.Authenticate(token, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, authedKey.ID)
+	assert.Equal(t, "external", authedKey.Domain)
+
+	listed := reopened.List()
+	require.Len(t, listed, 1)
+	assert.NotNil(t, listed[0].LastUsedAt)
+}
+
+func TestHttpMiddleware(t *testing.T) {
+	var authHdr, bucket, scope string
+	handler := HttpMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHdr = r.Header.Get("Authorization")
+		res := reqctx.ResourceFromContext(r.Context())
+		bucket, scope = res.Bucket, res.Scope
+	}))
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/v1/buckets/travel-sample/scopes/inventory/collections/airline/documents/key", nil)
+	req.Header.Set("X-API-Key", "cbk_abc_def")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "Bearer cbk_abc_def", authHdr)
+	assert.Equal(t, "travel-sample", bucket)
+	assert.Equal(t, "inventory", scope)
+
+	req = httptest.NewRequest(http.MethodGet, "/_p/query/query/service", nil)
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	req.Header.Set("X-API-Key", "cbk_abc_def")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "Basic dXNlcjpwYXNz", authHdr)
+	assert.Equal(t, "", bucket)
+	assert.Equal(t, "", scope)
+}
+
+type testBucketRequest struct {
+	bucket string
+}
+
+func (r *testBucketRequest) GetBucketName() string {
+	return r.bucket
+}
+
+func TestGrpcUnaryInterceptor(t *testing.T) {
+	var bucket, restrictedBucket string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		bucket = reqctx.ResourceFromContext(ctx).Bucket
+		restrictedBucket, _ = RestrictableResourceFromContext(ctx)
+		return nil, nil
+	}
+
+	interceptor := GrpcUnaryInterceptor()
+	req := &testBucketRequest{bucket: "travel-sample"}
+
+	_, err := interceptor(context.Background(), req,
+		&grpc.UnaryServerInfo{FullMethod: "/couchbase.kv.v1.KvService/Get"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "travel-sample", bucket)
+	assert.Equal(t, "travel-sample", restrictedBucket)
+
+	// a query may reference any bucket, whichever it names
+	_, err = interceptor(context.Background(), req,
+		&grpc.UnaryServerInfo{FullMethod: "/couchbase.query.v1.QueryService/Query"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "travel-sample", bucket)
+	assert.Equal(t, "", restrictedBucket)
+}
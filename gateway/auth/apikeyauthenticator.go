@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/couchbase/stellar-gateway/gateway/apikeys"
+)
+
+// ApiKeyAuthenticator extends another authenticator with support for API keys
+// managed by the gateway.  Bearer tokens which are not API keys are passed on
+// to the wrapped authenticator.
+type ApiKeyAuthenticator struct {
+	Authenticator
+	Store *apikeys.Store
+}
+
+var _ Authenticator = (*ApiKeyAuthenticator)(nil)
+
+func (a *ApiKeyAuthenticator) ValidateTokenForObo(ctx context.Context, token string) (string, string, error) {
+	if !apikeys.IsApiKey(token) {
+		return a.Authenticator.ValidateTokenForObo(ctx, token)
+	}
+
+	// requests such as queries can access buckets besides the one they name,
+	// so restricted keys are not permitted to make them.
+	bucket, scope := apikeys.RestrictableResourceFromContext(ctx)
+	key, err := a.Store.Authenticate(token, bucket, scope)
+	if err != nil {
+		if errors.Is(err, apikeys.ErrKeyRestricted) {
+			return "", "", fmt.Errorf("%w: %s", ErrTokenRestricted, err.Error())
+		}
+
+		return "", "", fmt.Errorf("%w: %s", ErrInvalidToken, err.Error())
+	}
+
+	return key.User, key.Domain, nil
+}
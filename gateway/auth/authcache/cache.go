@@ -0,0 +1,230 @@
+package authcache
+
+import (
+	"container/list"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+var meter = otel.Meter("github.com/couchbase/stellar-gateway/gateway/auth/authcache")
+
+// Entry is the result of an authentication attempt.  A non-nil Err indicates
+// the credentials were rejected.
+type Entry struct {
+	User   string
+	Domain string
+	Err    error
+}
+
+type Options struct {
+	Logger *zap.Logger
+
+	// Size is the maximum number of entries held, the least recently used
+	// entries are evicted first.  Defaults to 10000.
+	Size int
+
+	// TTL is how long successful authentications are cached for.
+	TTL time.Duration
+
+	// NegativeTTL is how long rejected credentials are cached for, this is
+	// kept short so that newly created users are usable quickly.  Defaults
+	// to 5 seconds.
+	NegativeTTL time.Duration
+}
+
+type cacheItem struct {
+	key       string
+	entry     Entry
+	expiresAt time.Time
+}
+
+// Cache is a bounded LRU cache of authentication results.  Credentials are
+// never stored directly, entries are keyed by a salted hash of them.
+type Cache struct {
+	logger      *zap.Logger
+	size        int
+	ttl         time.Duration
+	negativeTtl time.Duration
+	salt        []byte
+
+	lock       sync.Mutex
+	items      map[string]*list.Element
+	lru        *list.List
+	generation uint64
+
+	numHits   metric.Int64Counter
+	numMisses metric.Int64Counter
+}
+
+func NewCache(opts *Options) (*Cache, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	size := opts.Size
+	if size <= 0 {
+		size = 10000
+	}
+
+	negativeTtl := opts.NegativeTTL
+	if negativeTtl <= 0 {
+		negativeTtl = 5 * time.Second
+	}
+
+	salt := make([]byte, 32)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	numHits, err := meter.Int64Counter("auth_cache_hits")
+	if err != nil {
+		logger.Warn("failed to initialize auth cache hits counter", zap.Error(err))
+	}
+
+	numMisses, err := meter.Int64Counter("auth_cache_misses")
+	if err != nil {
+		logger.Warn("failed to initialize auth cache misses counter", zap.Error(err))
+	}
+
+	return &Cache{
+		logger:      logger,
+		size:        size,
+		ttl:         opts.TTL,
+		negativeTtl: negativeTtl,
+		salt:        salt,
+		items:       make(map[string]*list.Element),
+		lru:         list.New(),
+		numHits:     numHits,
+		numMisses:   numMisses,
+	}, nil
+}
+
+// UserPassKey returns the cache key for a username and password.
+func (c *Cache) UserPassKey(user, pass string) string {
+	mac := hmac.New(sha256.New, c.salt)
+	_, _ = mac.Write([]byte(user))
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write([]byte(pass))
+	return "userpass:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// CertKey returns the cache key for the client certificate of a connection,
+// which is the fingerprint of the leaf certificate.  Connections without a
+// client certificate cannot be cached.
+func (c *Cache) CertKey(connState *tls.ConnectionState) (string, bool) {
+	if connState == nil || len(connState.PeerCertificates) == 0 {
+		return "", false
+	}
+
+	fingerprint := sha256.Sum256(connState.PeerCertificates[0].Raw)
+	return "cert:" + hex.EncodeToString(fingerprint[:]), true
+}
+
+// Get returns the cached result for a key, along with the current generation
+// of the cache which must be passed to Add when caching a new result.
+func (c *Cache) Get(ctx context.Context, authType string, key string) (Entry, uint64, bool) {
+	now := time.Now()
+
+	c.lock.Lock()
+	generation := c.generation
+	elem, ok := c.items[key]
+	if ok {
+		item := elem.Value.(*cacheItem)
+		if now.Before(item.expiresAt) {
+			c.lru.MoveToFront(elem)
+			entry := item.entry
+			c.lock.Unlock()
+
+			c.record(ctx, c.numHits, authType)
+			return entry, generation, true
+		}
+
+		c.removeElement(elem)
+	}
+	c.lock.Unlock()
+
+	c.record(ctx, c.numMisses, authType)
+	return Entry{}, generation, false
+}
+
+func (c *Cache) record(ctx context.Context, counter metric.Int64Counter, authType string) {
+	if counter == nil {
+		return
+	}
+
+	counter.Add(ctx, 1, metric.WithAttributes(attribute.String("auth_type", authType)))
+}
+
+// Add caches a result.  Results from lookups which began before the cache was
+// last invalidated are discarded, as they may be stale.
+func (c *Cache) Add(generation uint64, key string, entry Entry) {
+	ttl := c.ttl
+	if entry.Err != nil {
+		ttl = c.negativeTtl
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	item := &cacheItem{
+		key:       key,
+		entry:     entry,
+		expiresAt: time.Now().Add(ttl),
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value = item
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.lru.PushFront(item)
+
+	for c.lru.Len() > c.size {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *Cache) removeElement(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.items, elem.Value.(*cacheItem).key)
+}
+
+// Invalidate removes all cached results, this is used when the cluster's
+// authentication configuration may have changed.
+func (c *Cache) Invalidate() {
+	c.lock.Lock()
+	c.items = make(map[string]*list.Element)
+	c.lru.Init()
+	c.generation++
+	c.lock.Unlock()
+
+	c.logger.Debug("invalidated auth cache")
+}
+
+// Len returns the number of cached results.
+func (c *Cache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
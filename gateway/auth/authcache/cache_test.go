@@ -0,0 +1,122 @@
+package authcache
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheHitAndExpiry(t *testing.T) {
+	cache, err := NewCache(&Options{
+		TTL:         500 * time.Millisecond,
+		NegativeTTL: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	key := cache.UserPassKey("user", "hunter2")
+	assert.NotContains(t, key, "hunter2")
+	assert.NotEqual(t, key, cache.UserPassKey("user", "other"))
+	assert.NotEqual(t, cache.UserPassKey("ab", "c"), cache.UserPassKey("a", "bc"))
+
+	_, gen, ok := cache.Get(ctx, "password", key)
+	assert.False(t, ok)
+
+	cache.Add(gen, key, Entry{User: "user", Domain: "local"})
+	entry, _, ok := cache.Get(ctx, "password", key)
+	require.True(t, ok)
+	assert.Equal(t, "user", entry.User)
+	assert.Equal(t, "local", entry.Domain)
+
+	badKey := cache.UserPassKey("user", "wrong")
+	cache.Add(gen, badKey, Entry{Err: errors.New("invalid credentials")})
+	entry, _, ok = cache.Get(ctx, "password", badKey)
+	require.True(t, ok)
+	assert.Error(t, entry.Err)
+
+	// negative results expire sooner than positive ones
+	time.Sleep(20 * time.Millisecond)
+	_, _, ok = cache.Get(ctx, "password", badKey)
+	assert.False(t, ok)
+	_, _, ok = cache.Get(ctx, "password", key)
+	assert.True(t, ok)
+
+	time.Sleep(500 * time.Millisecond)
+	_, _, ok = cache.Get(ctx, "password", key)
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestCacheEviction(t *testing.T) {
+	cache, err := NewCache(&Options{
+		Size: 2,
+		TTL:  time.Minute,
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, gen, _ := cache.Get(ctx, "password", "a")
+	cache.Add(gen, "a", Entry{User: "a"})
+	cache.Add(gen, "b", Entry{User: "b"})
+
+	// touching a makes b the least recently used
+	_, _, ok := cache.Get(ctx, "password", "a")
+	require.True(t, ok)
+
+	cache.Add(gen, "c", Entry{User: "c"})
+	assert.Equal(t, 2, cache.Len())
+
+	_, _, ok = cache.Get(ctx, "password", "b")
+	assert.False(t, ok)
+	_, _, ok = cache.Get(ctx, "password", "a")
+	assert.True(t, ok)
+	_, _, ok = cache.Get(ctx, "password", "c")
+	assert.True(t, ok)
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	cache, err := NewCache(&Options{TTL: time.Minute})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, gen, _ := cache.Get(ctx, "password", "a")
+	cache.Add(gen, "a", Entry{User: "a"})
+
+	// a lookup which is in-flight while the cache is invalidated
+	_, staleGen, _ := cache.Get(ctx, "password", "b")
+
+	cache.Invalidate()
+
+	_, _, ok := cache.Get(ctx, "password", "a")
+	assert.False(t, ok)
+
+	cache.Add(staleGen, "b", Entry{User: "b"})
+	_, _, ok = cache.Get(ctx, "password", "b")
+	assert.False(t, ok)
+}
+
+func TestCertKey(t *testing.T) {
+	cache, err := NewCache(&Options{TTL: time.Minute})
+	require.NoError(t, err)
+
+	_, ok := cache.CertKey(nil)
+	assert.False(t, ok)
+	_, ok = cache.CertKey(&tls.ConnectionState{})
+	assert.False(t, ok)
+
+	keyA, ok := cache.CertKey(&tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{{Raw: []byte("cert-a")}},
+	})
+	require.True(t, ok)
+	keyB, ok := cache.CertKey(&tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{{Raw: []byte("cert-b")}},
+	})
+	require.True(t, ok)
+	assert.NotEqual(t, keyA, keyB)
+}
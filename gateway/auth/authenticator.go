@@ -8,4 +8,5 @@ import (
 type Authenticator interface {
 	ValidateUserForObo(ctx context.Context, user, pass string) (string, string, error)
 	ValidateConnStateForObo(ctx context.Context, connState *tls.ConnectionState) (string, string, error)
+	ValidateTokenForObo(ctx context.Context, token string) (string, string, error)
 }
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+
+	"github.com/couchbase/stellar-gateway/gateway/auth/authcache"
+)
+
+// CachingAuthenticator caches the results of validating usernames/passwords
+// and client certificates with another authenticator.  Only definitive
+// results are cached, transient failures are always retried.
+type CachingAuthenticator struct {
+	Authenticator
+	Cache *authcache.Cache
+}
+
+var _ Authenticator = (*CachingAuthenticator)(nil)
+
+func isCacheableAuthErr(err error) bool {
+	return err == nil ||
+		errors.Is(err, ErrInvalidCredentials) ||
+		errors.Is(err, ErrInvalidCertificate) ||
+		errors.Is(err, ErrCertAuthDisabled)
+}
+
+func (a *CachingAuthenticator) ValidateUserForObo(ctx context.Context, user, pass string) (string, string, error) {
+	key := a.Cache.UserPassKey(user, pass)
+	entry, generation, ok := a.Cache.Get(ctx, "password", key)
+	if ok {
+		return entry.User, entry.Domain, entry.Err
+	}
+
+	oboUser, oboDomain, err := a.Authenticator.ValidateUserForObo(ctx, user, pass)
+	if isCacheableAuthErr(err) {
+		a.Cache.Add(generation, key, authcache.Entry{
+			User:   oboUser,
+			Domain: oboDomain,
+			Err:    err,
+		})
+	}
+
+	return oboUser, oboDomain, err
+}
+
+func (a *CachingAuthenticator) ValidateConnStateForObo(ctx context.Context, connState *tls.ConnectionState) (string, string, error) {
+	key, ok := a.Cache.CertKey(connState)
+	if !ok {
+		return a.Authenticator.ValidateConnStateForObo(ctx, connState)
+	}
+
+	entry, generation, ok := a.Cache.Get(ctx, "certificate", key)
+	if ok {
+		return entry.User, entry.Domain, entry.Err
+	}
+
+	oboUser, oboDomain, err := a.Authenticator.ValidateConnStateForObo(ctx, connState)
+	if isCacheableAuthErr(err) {
+		a.Cache.Add(generation, key, authcache.Entry{
+			User:   oboUser,
+			Domain: oboDomain,
+			Err:    err,
+		})
+	}
+
+	return oboUser, oboDomain, err
+}
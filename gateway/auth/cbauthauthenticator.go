@@ -14,11 +14,18 @@ import (
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrInvalidCertificate = errors.New("invalid certificate")
+	ErrCertificateRevoked = errors.New("certificate revoked")
 	ErrCertAuthDisabled   = errors.New("client cert auth disabled")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrTokenAuthDisabled  = errors.New("token auth disabled")
+	ErrTokenRestricted    = errors.New("token is not permitted to access the resource")
+	ErrAuthLockedOut      = errors.New("authentication temporarily locked out")
 )
 
 type CbAuthAuthenticator struct {
 	Authenticator *cbauthx.CbAuth
+
+	useTls bool
 }
 
 var _ Authenticator = (*CbAuthAuthenticator)(nil)
@@ -30,12 +37,23 @@ type NewCbAuthAuthenticatorOptions struct {
 	Addresses   []string
 	Username    string
 	Password    string
+
+	// TLSConfig enables connecting to the cluster over TLS, in which case
+	// Addresses must be the TLS management ports.  Any client certificate
+	// the config provides is presented to the cluster, allowing the
+	// username and password to be omitted.
+	TLSConfig *tls.Config
 }
 
-func rewriteCbAuthAddresses(addresses []string) []string {
+func rewriteCbAuthAddresses(addresses []string, useTls bool) []string {
+	scheme := "http://"
+	if useTls {
+		scheme = "https://"
+	}
+
 	out := make([]string, 0, len(addresses))
 	for _, address := range addresses {
-		out = append(out, "http://"+address)
+		out = append(out, scheme+address)
 	}
 	return out
 }
@@ -47,7 +65,7 @@ func NewCbAuthAuthenticator(ctx context.Context, opts NewCbAuthAuthenticatorOpti
 	}
 
 	auth, err := cbauthx.NewCbAuth(ctx, &cbauthx.CbAuthConfig{
-		Endpoints:   rewriteCbAuthAddresses(opts.Addresses),
+		Endpoints:   rewriteCbAuthAddresses(opts.Addresses, opts.TLSConfig != nil),
 		Username:    opts.Username,
 		Password:    opts.Password,
 		ClusterUuid: opts.ClusterUUID,
@@ -58,6 +76,7 @@ func NewCbAuthAuthenticator(ctx context.Context, opts NewCbAuthAuthenticatorOpti
 		HeartbeatTimeout:  15 * time.Second,
 		LivenessTimeout:   20 * time.Second,
 		ConnectTimeout:    5 * time.Second,
+		TLSConfig:         opts.TLSConfig,
 	})
 	if err != nil {
 		return nil, err
@@ -65,6 +84,7 @@ func NewCbAuthAuthenticator(ctx context.Context, opts NewCbAuthAuthenticatorOpti
 
 	return &CbAuthAuthenticator{
 		Authenticator: auth,
+		useTls:        opts.TLSConfig != nil,
 	}, nil
 }
 
@@ -77,7 +97,7 @@ type CbAuthAuthenticatorReconfigureOptions struct {
 
 func (a *CbAuthAuthenticator) Reconfigure(opts CbAuthAuthenticatorReconfigureOptions) error {
 	return a.Authenticator.Reconfigure(&cbauthx.CbAuthConfig{
-		Endpoints:   rewriteCbAuthAddresses(opts.Addresses),
+		Endpoints:   rewriteCbAuthAddresses(opts.Addresses, a.useTls),
 		Username:    opts.Username,
 		Password:    opts.Password,
 		ClusterUuid: opts.ClusterUUID,
@@ -112,6 +132,10 @@ func (a *CbAuthAuthenticator) ValidateConnStateForObo(ctx context.Context, connS
 	return info.User, info.Domain, nil
 }
 
+func (a *CbAuthAuthenticator) ValidateTokenForObo(ctx context.Context, token string) (string, string, error) {
+	return "", "", ErrTokenAuthDisabled
+}
+
 func (a *CbAuthAuthenticator) Close() error {
 	return a.Authenticator.Close()
 }
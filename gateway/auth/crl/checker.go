@@ -0,0 +1,346 @@
+package crl
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrCertificateRevoked = errors.New("certificate has been revoked")
+
+// RevokedError identifies the certificate which was found to be revoked.
+type RevokedError struct {
+	Subject   string
+	Serial    string
+	RevokedAt time.Time
+}
+
+func (e *RevokedError) Error() string {
+	return fmt.Sprintf("certificate `%s` with serial %s was revoked at %s",
+		e.Subject, e.Serial, e.RevokedAt.Format(time.RFC3339))
+}
+
+func (e *RevokedError) Unwrap() error {
+	return ErrCertificateRevoked
+}
+
+type list struct {
+	path    string
+	crl     *x509.RevocationList
+	revoked map[string]time.Time
+
+	// verifiedIssuers caches whether the list was signed by a particular
+	// issuer, keyed by the hash of the issuer certificate.
+	verifiedIssuers sync.Map
+}
+
+func (l *list) signedBy(issuer *x509.Certificate) bool {
+	key := sha256.Sum256(issuer.Raw)
+	if verified, ok := l.verifiedIssuers.Load(key); ok {
+		return verified.(bool)
+	}
+
+	verified := l.crl.CheckSignatureFrom(issuer) == nil
+	l.verifiedIssuers.Store(key, verified)
+	return verified
+}
+
+type state struct {
+	// lists maps the raw subject of an issuer to the lists it issued.
+	lists map[string][]*list
+	count int
+}
+
+type Options struct {
+	Logger *zap.Logger
+
+	// Paths is a list of CRL files, or directories containing them.  Files
+	// may be DER encoded, or contain one or more PEM encoded CRLs.
+	Paths []string
+
+	// ReloadInterval is how often the CRLs are re-read.  Defaults to 5
+	// minutes, a negative interval disables reloading.
+	ReloadInterval time.Duration
+}
+
+// Checker checks certificate chains against a set of certificate revocation
+// lists.  A list is only used for certificates whose issuer in the verified
+// chain signed it.
+type Checker struct {
+	logger *zap.Logger
+	paths  []string
+
+	state atomic.Pointer[state]
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+func NewChecker(opts *Options) (*Checker, error) {
+	if len(opts.Paths) == 0 {
+		return nil, errors.New("at least one crl path must be specified")
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	reloadInterval := opts.ReloadInterval
+	if reloadInterval == 0 {
+		reloadInterval = 5 * time.Minute
+	}
+
+	c := &Checker{
+		logger:  logger,
+		paths:   opts.Paths,
+		closeCh: make(chan struct{}),
+	}
+
+	err := c.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	if reloadInterval > 0 {
+		go c.reloadThread(reloadInterval)
+	}
+
+	return c, nil
+}
+
+func (c *Checker) reloadThread(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := c.Reload()
+			if err != nil {
+				c.logger.Warn("failed to reload crls, continuing to use previous crls", zap.Error(err))
+			}
+		case <-c.closeCh:
+			return
+		}
+	}
+}
+
+// Reload re-reads the CRLs.  If any cannot be loaded, the previously loaded
+// CRLs remain in use.
+func (c *Checker) Reload() error {
+	var files []string
+	for _, path := range c.paths {
+		pathFiles, err := listFiles(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, pathFiles...)
+	}
+
+	newState := &state{
+		lists: make(map[string][]*list),
+	}
+
+	now := time.Now()
+	for _, file := range files {
+		crls, err := loadFile(file)
+		if err != nil {
+			return err
+		}
+
+		for _, crl := range crls {
+			if !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate) {
+				c.logger.Warn("crl is past its next update time",
+					zap.String("path", file),
+					zap.String("issuer", crl.Issuer.String()),
+					zap.Time("nextUpdate", crl.NextUpdate))
+			}
+
+			revoked := make(map[string]time.Time, len(crl.RevokedCertificateEntries))
+			for _, entry := range crl.RevokedCertificateEntries {
+				revoked[entry.SerialNumber.String()] = entry.RevocationTime
+			}
+
+			issuerKey := string(crl.RawIssuer)
+			newState.lists[issuerKey] = append(newState.lists[issuerKey], &list{
+				path:    file,
+				crl:     crl,
+				revoked: revoked,
+			})
+			newState.count++
+		}
+	}
+
+	c.state.Store(newState)
+
+	c.logger.Debug("loaded crls",
+		zap.Int("numFiles", len(files)),
+		zap.Int("numCrls", newState.count))
+
+	return nil
+}
+
+func listFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crl path: %w", err)
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crl directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		// hidden entries include the data directories of mounted kubernetes
+		// secrets, whose files are also linked at the top level.
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		filePath := filepath.Join(path, entry.Name())
+
+		// entries may be symlinks, so we stat them rather than use their type
+		fileInfo, err := os.Stat(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read crl file: %w", err)
+		}
+		if !fileInfo.Mode().IsRegular() {
+			continue
+		}
+
+		files = append(files, filePath)
+	}
+
+	return files, nil
+}
+
+func loadFile(path string) ([]*x509.RevocationList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crl file: %w", err)
+	}
+
+	if !strings.Contains(string(data), "-----BEGIN") {
+		crl, err := x509.ParseRevocationList(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse crl file %s: %w", path, err)
+		}
+
+		return []*x509.RevocationList{crl}, nil
+	}
+
+	var crls []*x509.RevocationList
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "X509 CRL" {
+			continue
+		}
+
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse crl file %s: %w", path, err)
+		}
+
+		crls = append(crls, crl)
+	}
+
+	if len(crls) == 0 {
+		return nil, fmt.Errorf("no crls found in file %s", path)
+	}
+
+	return crls, nil
+}
+
+// CheckChain returns a RevokedError if any certificate in a verified chain,
+// other than its root, has been revoked.
+func (c *Checker) CheckChain(chain []*x509.Certificate) error {
+	lists := c.state.Load().lists
+
+	for i := 0; i < len(chain)-1; i++ {
+		cert := chain[i]
+		issuer := chain[i+1]
+
+		for _, l := range lists[string(cert.RawIssuer)] {
+			revokedAt, ok := l.revoked[cert.SerialNumber.String()]
+			if !ok {
+				continue
+			}
+
+			if !l.signedBy(issuer) {
+				c.logger.Debug("ignoring crl which was not signed by the certificate issuer",
+					zap.String("path", l.path))
+				continue
+			}
+
+			return &RevokedError{
+				Subject:   cert.Subject.String(),
+				Serial:    cert.SerialNumber.String(),
+				RevokedAt: revokedAt,
+			}
+		}
+	}
+
+	return nil
+}
+
+// VerifyPeerCertificate checks the verified chains of a peer, matching the
+// signature of tls.Config.VerifyPeerCertificate.
+func (c *Checker) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	for _, chain := range verifiedChains {
+		err := c.CheckChain(chain)
+		if err != nil {
+			c.logger.Debug("rejected revoked peer certificate", zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckConnState checks the verified chains of an established connection,
+// which may have been established before the CRLs were last reloaded.
+func (c *Checker) CheckConnState(connState *tls.ConnectionState) error {
+	if connState == nil {
+		return nil
+	}
+
+	for _, chain := range connState.VerifiedChains {
+		err := c.CheckChain(chain)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close stops reloading the CRLs.
+func (c *Checker) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+	})
+	return nil
+}
@@ -0,0 +1,164 @@
+package crl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCa struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCa(t *testing.T, name string) *testCa {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCa{cert: cert, key: key}
+}
+
+func (ca *testCa) issue(t *testing.T, serial int64) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func (ca *testCa) writeCrl(t *testing.T, path string, revokedSerials ...int64) {
+	var entries []x509.RevocationListEntry
+	for _, serial := range revokedSerials {
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now().Add(-time.Minute),
+		})
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(time.Now().UnixNano()),
+		ThisUpdate:                time.Now().Add(-time.Minute),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: entries,
+	}, ca.cert, ca.key)
+	require.NoError(t, err)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0600))
+}
+
+func TestCheckerRevocation(t *testing.T) {
+	ca := newTestCa(t, "ca")
+	otherCa := newTestCa(t, "other-ca")
+
+	crlDir := t.TempDir()
+	ca.writeCrl(t, filepath.Join(crlDir, "ca.crl"), 100)
+
+	checker, err := NewChecker(&Options{
+		Paths:          []string{crlDir},
+		ReloadInterval: -1,
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = checker.Close()
+	}()
+
+	revoked := ca.issue(t, 100)
+	valid := ca.issue(t, 101)
+
+	err = checker.VerifyPeerCertificate(nil, [][]*x509.Certificate{{revoked, ca.cert}})
+	assert.ErrorIs(t, err, ErrCertificateRevoked)
+
+	var revokedErr *RevokedError
+	require.ErrorAs(t, err, &revokedErr)
+	assert.Equal(t, "100", revokedErr.Serial)
+
+	assert.NoError(t, checker.VerifyPeerCertificate(nil, [][]*x509.Certificate{{valid, ca.cert}}))
+
+	// the same serial from a different issuer is unaffected
+	assert.NoError(t, checker.CheckChain([]*x509.Certificate{otherCa.issue(t, 100), otherCa.cert}))
+
+	// a crl claiming to be from our ca but signed by another is ignored
+	forged := &testCa{cert: ca.cert, key: otherCa.key}
+	forged.writeCrl(t, filepath.Join(crlDir, "forged.crl"), 101)
+	require.NoError(t, checker.Reload())
+	assert.NoError(t, checker.CheckChain([]*x509.Certificate{valid, ca.cert}))
+
+	// newly revoked certificates are picked up on reload, including for
+	// connections established beforehand.
+	connState := &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{valid, ca.cert}},
+	}
+	assert.NoError(t, checker.CheckConnState(connState))
+
+	ca.writeCrl(t, filepath.Join(crlDir, "ca.crl"), 100, 101)
+	require.NoError(t, checker.Reload())
+	assert.ErrorIs(t, checker.CheckConnState(connState), ErrCertificateRevoked)
+
+	// invalid files are rejected, leaving the previous crls in place
+	require.NoError(t, os.WriteFile(filepath.Join(crlDir, "bad.crl"), []byte("not a crl"), 0600))
+	assert.Error(t, checker.Reload())
+	assert.ErrorIs(t, checker.CheckConnState(connState), ErrCertificateRevoked)
+}
+
+func TestCheckerPeriodicReload(t *testing.T) {
+	ca := newTestCa(t, "ca")
+	crlPath := filepath.Join(t.TempDir(), "ca.crl")
+	ca.writeCrl(t, crlPath)
+
+	checker, err := NewChecker(&Options{
+		Paths:          []string{crlPath},
+		ReloadInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = checker.Close()
+	}()
+
+	cert := ca.issue(t, 5)
+	require.NoError(t, checker.CheckChain([]*x509.Certificate{cert, ca.cert}))
+
+	ca.writeCrl(t, crlPath, 5)
+	require.Eventually(t, func() bool {
+		return checker.CheckChain([]*x509.Certificate{cert, ca.cert}) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,376 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var errUnknownKey = errors.New("no matching key found in jwks")
+
+// KeySet provides the keys which token signatures are verified against.
+type KeySet interface {
+	// GetKeys returns the keys matching a key id, or all the keys if the
+	// token did not specify one.
+	GetKeys(ctx context.Context, kid string) ([]crypto.PublicKey, error)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type parsedKey struct {
+	kid string
+	key crypto.PublicKey
+}
+
+func decodeBase64Url(str string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(str)
+}
+
+func decodeBigInt(str string) (*big.Int, error) {
+	data, err := decodeBase64Url(str)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
+
+func parseJsonWebKey(jwk jsonWebKey) (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsa modulus: %w", err)
+		}
+
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsa exponent: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid rsa exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported elliptic curve `%s`", jwk.Crv)
+		}
+
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ec x coordinate: %w", err)
+		}
+
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ec y coordinate: %w", err)
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("ec point is not on the curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported edwards curve `%s`", jwk.Crv)
+		}
+
+		x, err := decodeBase64Url(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ed25519 key: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key length")
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type `%s`", jwk.Kty)
+	}
+}
+
+// parseKeySet parses a JSON Web Key Set.  Keys which are not intended for
+// signatures or which use unsupported algorithms are skipped.
+func parseKeySet(logger *zap.Logger, data []byte) ([]parsedKey, error) {
+	var jwks jsonWebKeySet
+	err := json.Unmarshal(data, &jwks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	keys := make([]parsedKey, 0, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := parseJsonWebKey(jwk)
+		if err != nil {
+			logger.Debug("skipping unusable jwks key",
+				zap.Error(err),
+				zap.String("kid", jwk.Kid))
+			continue
+		}
+
+		keys = append(keys, parsedKey{
+			kid: jwk.Kid,
+			key: key,
+		})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("jwks does not contain any usable signing keys")
+	}
+
+	return keys, nil
+}
+
+// CachingKeySetOptions configures a key set which is periodically re-fetched.
+type CachingKeySetOptions struct {
+	Logger *zap.Logger
+
+	// Fetch returns the raw JSON Web Key Set.  Fetches are shared between
+	// callers and are not cancelled when a caller gives up, so Fetch must
+	// bound how long it takes.
+	Fetch func(ctx context.Context) ([]byte, error)
+
+	// RefreshInterval is how long fetched keys are used before being
+	// re-fetched.  Defaults to 1 hour.
+	RefreshInterval time.Duration
+
+	// MinRefreshInterval limits how often the keys are re-fetched when a
+	// token references an unknown key id, which typically happens when the
+	// identity provider rotates its keys.  Defaults to 30 seconds.
+	MinRefreshInterval time.Duration
+}
+
+// CachingKeySet caches the keys from a JSON Web Key Set, re-fetching them
+// periodically and when an unknown key is encountered.  If a re-fetch fails
+// the previously fetched keys continue to be used.  Only one fetch is made at
+// a time, which concurrent requests share.
+type CachingKeySet struct {
+	logger             *zap.Logger
+	fetch              func(ctx context.Context) ([]byte, error)
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+
+	lock        sync.Mutex
+	keys        []parsedKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	lastErr     error
+	refreshing  *keySetRefresh
+}
+
+// keySetRefresh is a fetch of the keys which is in progress.
+type keySetRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+var _ KeySet = (*CachingKeySet)(nil)
+
+func NewCachingKeySet(opts *CachingKeySetOptions) *CachingKeySet {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	refreshInterval := opts.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = 1 * time.Hour
+	}
+
+	minRefreshInterval := opts.MinRefreshInterval
+	if minRefreshInterval <= 0 {
+		minRefreshInterval = 30 * time.Second
+	}
+
+	return &CachingKeySet{
+		logger:             logger,
+		fetch:              opts.Fetch,
+		refreshInterval:    refreshInterval,
+		minRefreshInterval: minRefreshInterval,
+	}
+}
+
+// NewFileKeySet creates a key set which is read from a file on disk.
+func NewFileKeySet(logger *zap.Logger, path string, refreshInterval time.Duration) *CachingKeySet {
+	return NewCachingKeySet(&CachingKeySetOptions{
+		Logger: logger,
+		Fetch: func(ctx context.Context) ([]byte, error) {
+			return os.ReadFile(path)
+		},
+		RefreshInterval: refreshInterval,
+	})
+}
+
+// NewUrlKeySet creates a key set which is fetched from a URL, typically the
+// `jwks_uri` of an OpenID Connect provider.
+func NewUrlKeySet(logger *zap.Logger, httpClient *http.Client, url string, refreshInterval time.Duration) *CachingKeySet {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return NewCachingKeySet(&CachingKeySetOptions{
+		Logger: logger,
+		Fetch: func(ctx context.Context) ([]byte, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Accept", "application/json")
+
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+			}
+
+			return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		},
+		RefreshInterval: refreshInterval,
+	})
+}
+
+// Refresh fetches the keys, replacing the cached keys on success.  If a fetch
+// is already in progress, its result is waited for instead.
+func (s *CachingKeySet) Refresh(ctx context.Context) error {
+	s.lock.Lock()
+	refresh := s.refreshing
+	if refresh == nil {
+		refresh = &keySetRefresh{done: make(chan struct{})}
+		s.refreshing = refresh
+		s.attemptedAt = time.Now()
+
+		// the fetch is shared by every caller waiting for it, so it is not
+		// cancelled along with the context of whichever caller started it.
+		go s.doRefresh(context.WithoutCancel(ctx), refresh)
+	}
+	s.lock.Unlock()
+
+	select {
+	case <-refresh.done:
+		return refresh.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *CachingKeySet) doRefresh(ctx context.Context, refresh *keySetRefresh) {
+	var keys []parsedKey
+	data, err := s.fetch(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to fetch jwks: %w", err)
+	} else {
+		keys, err = parseKeySet(s.logger, data)
+	}
+
+	s.lock.Lock()
+	if err == nil {
+		s.keys = keys
+		s.fetchedAt = time.Now()
+	}
+	s.lastErr = err
+	s.refreshing = nil
+	s.lock.Unlock()
+
+	refresh.err = err
+	close(refresh.done)
+}
+
+func (s *CachingKeySet) matchingKeysLocked(kid string) []crypto.PublicKey {
+	var keys []crypto.PublicKey
+	for _, key := range s.keys {
+		if kid == "" || key.kid == kid {
+			keys = append(keys, key.key)
+		}
+	}
+	return keys
+}
+
+func (s *CachingKeySet) GetKeys(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	s.lock.Lock()
+	keys := s.matchingKeysLocked(kid)
+	stale := s.keys == nil || time.Since(s.fetchedAt) >= s.refreshInterval
+
+	// failed fetches, including the initial one, are retried no more often
+	// than the minimum refresh interval, so that an unavailable identity
+	// provider is not hammered.  Fetches already in progress are shared.
+	canRefresh := s.refreshing != nil || time.Since(s.attemptedAt) >= s.minRefreshInterval
+	s.lock.Unlock()
+
+	var refreshErr error
+	if canRefresh && (stale || len(keys) == 0) {
+		refreshErr = s.Refresh(ctx)
+	}
+
+	s.lock.Lock()
+	keys = s.matchingKeysLocked(kid)
+	loaded := s.keys != nil
+	if refreshErr == nil && !loaded {
+		refreshErr = s.lastErr
+	}
+	s.lock.Unlock()
+
+	if refreshErr != nil {
+		if !loaded {
+			return nil, refreshErr
+		}
+
+		s.logger.Warn("failed to refresh jwks, continuing to use the existing keys",
+			zap.Error(refreshErr),
+			zap.String("kid", kid))
+	}
+
+	if len(keys) == 0 {
+
+		return nil, fmt.Errorf("%w: kid `%s`", errUnknownKey, kid)
+	}
+
+	return keys, nil
+}
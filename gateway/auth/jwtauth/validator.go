@@ -0,0 +1,163 @@
+package jwtauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// The signing algorithms we accept, symmetric algorithms are intentionally
+// excluded as the keys are published.
+var validMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+type ValidatorOptions struct {
+	KeySet KeySet
+
+	// Issuer is the required `iss` claim, if specified.
+	Issuer string
+
+	// Audiences are the accepted `aud` claims, a token must be issued for
+	// at least one of them.  If empty, the audience is not checked.
+	Audiences []string
+
+	// UserClaim is the claim which holds the couchbase user the request is
+	// performed on behalf of.  Nested claims may be specified using dots,
+	// e.g. `couchbase.user`.  Defaults to `sub`.
+	UserClaim string
+
+	// DomainClaim is the claim which holds the domain of the user, if not
+	// specified or not present in the token, DefaultDomain is used.
+	DomainClaim string
+
+	// DefaultDomain defaults to `external`.
+	DefaultDomain string
+
+	// Leeway is the allowed clock skew when checking exp and nbf.
+	Leeway time.Duration
+}
+
+// Validator validates JWT bearer tokens, mapping them to the couchbase user
+// the request should be performed on behalf of.
+type Validator struct {
+	keySet        KeySet
+	parser        *jwt.Parser
+	userClaim     string
+	domainClaim   string
+	defaultDomain string
+}
+
+func NewValidator(opts *ValidatorOptions) (*Validator, error) {
+	if opts.KeySet == nil {
+		return nil, errors.New("a key set must be specified")
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(validMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(opts.Leeway),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if len(opts.Audiences) > 0 {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audiences...))
+	}
+
+	userClaim := opts.UserClaim
+	if userClaim == "" {
+		userClaim = "sub"
+	}
+
+	defaultDomain := opts.DefaultDomain
+	if defaultDomain == "" {
+		defaultDomain = "external"
+	}
+
+	return &Validator{
+		keySet:        opts.KeySet,
+		parser:        jwt.NewParser(parserOpts...),
+		userClaim:     userClaim,
+		domainClaim:   opts.DomainClaim,
+		defaultDomain: defaultDomain,
+	}, nil
+}
+
+// Validate checks the signature and registered claims of a token, returning
+// the user and domain it maps to.
+func (v *Validator) Validate(ctx context.Context, tokenStr string) (string, string, error) {
+	var lookupErr error
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		keys, err := v.keySet.GetKeys(ctx, kid)
+		if err != nil {
+			lookupErr = err
+			return nil, err
+		}
+
+		verificationKeys := make([]jwt.VerificationKey, len(keys))
+		for i, key := range keys {
+			verificationKeys[i] = key
+		}
+
+		return jwt.VerificationKeySet{Keys: verificationKeys}, nil
+	})
+	if err != nil {
+		if lookupErr != nil && !errors.Is(lookupErr, errUnknownKey) {
+			// failing to load the keys is not the fault of the token
+			return "", "", lookupErr
+		}
+
+		return "", "", fmt.Errorf("%w: %s", ErrInvalidToken, err.Error())
+	}
+
+	user, ok := lookupClaim(map[string]interface{}(claims), v.userClaim)
+	if !ok {
+		return "", "", fmt.Errorf("%w: missing `%s` claim", ErrInvalidToken, v.userClaim)
+	}
+
+	domain := v.defaultDomain
+	if v.domainClaim != "" {
+		if claimDomain, ok := lookupClaim(map[string]interface{}(claims), v.domainClaim); ok {
+			domain = claimDomain
+		}
+	}
+
+	return user, domain, nil
+}
+
+// lookupClaim finds a non-empty string claim, following dots into nested
+// objects.
+func lookupClaim(claims map[string]interface{}, name string) (string, bool) {
+	var value interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+
+		value, ok = obj[part]
+		if !ok {
+			return "", false
+		}
+	}
+
+	str, ok := value.(string)
+	if !ok || str == "" {
+		return "", false
+	}
+
+	return str, true
+}
@@ -0,0 +1,296 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func rsaJwk(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   encodeBigInt(key.N),
+		"e":   encodeBigInt(big.NewInt(int64(key.E))),
+	}
+}
+
+func ecJwk(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   encodeBigInt(key.X),
+		"y":   encodeBigInt(key.Y),
+	}
+}
+
+func marshalJwks(t *testing.T, keys ...map[string]string) []byte {
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
+	tokenStr, err := token.SignedString(key)
+	require.NoError(t, err)
+	return tokenStr
+}
+
+func staticKeySet(data []byte) *CachingKeySet {
+	return NewCachingKeySet(&CachingKeySetOptions{
+		Fetch: func(ctx context.Context) ([]byte, error) {
+			return data, nil
+		},
+	})
+}
+
+func TestValidator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keySet := staticKeySet(marshalJwks(t,
+		rsaJwk("rsa-key", &rsaKey.PublicKey),
+		ecJwk("ec-key", &ecKey.PublicKey)))
+
+	validator, err := NewValidator(&ValidatorOptions{
+		KeySet:      keySet,
+		Issuer:      "https://idp.example.com",
+		Audiences:   []string{"stellar-gateway"},
+		UserClaim:   "couchbase.user",
+		DomainClaim: "couchbase.domain",
+	})
+	require.NoError(t, err)
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": "https://idp.example.com",
+			"aud": []string{"other", "stellar-gateway"},
+			"exp": time.Now().Add(time.Hour).Unix(),
+			"couchbase": map[string]interface{}{
+				"user": "alice",
+			},
+		}
+	}
+
+	t.Run("Rsa", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodRS256, "rsa-key", rsaKey, validClaims())
+
+		user, domain, err := validator.Validate(context.Background(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", user)
+		assert.Equal(t, "external", domain)
+	})
+
+	t.Run("EcWithDomain", func(t *testing.T) {
+		claims := validClaims()
+		claims["couchbase"] = map[string]interface{}{
+			"user":   "bob",
+			"domain": "local",
+		}
+		token := signToken(t, jwt.SigningMethodES256, "ec-key", ecKey, claims)
+
+		user, domain, err := validator.Validate(context.Background(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "bob", user)
+		assert.Equal(t, "local", domain)
+	})
+
+	invalidTests := []struct {
+		name  string
+		token func() string
+	}{
+		{"WrongKey", func() string {
+			return signToken(t, jwt.SigningMethodRS256, "rsa-key", otherKey, validClaims())
+		}},
+		{"UnknownKid", func() string {
+			return signToken(t, jwt.SigningMethodRS256, "missing-key", rsaKey, validClaims())
+		}},
+		{"Expired", func() string {
+			claims := validClaims()
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			return signToken(t, jwt.SigningMethodRS256, "rsa-key", rsaKey, claims)
+		}},
+		{"NoExpiry", func() string {
+			claims := validClaims()
+			delete(claims, "exp")
+			return signToken(t, jwt.SigningMethodRS256, "rsa-key", rsaKey, claims)
+		}},
+		{"NotYetValid", func() string {
+			claims := validClaims()
+			claims["nbf"] = time.Now().Add(time.Hour).Unix()
+			return signToken(t, jwt.SigningMethodRS256, "rsa-key", rsaKey, claims)
+		}},
+		{"WrongIssuer", func() string {
+			claims := validClaims()
+			claims["iss"] = "https://evil.example.com"
+			return signToken(t, jwt.SigningMethodRS256, "rsa-key", rsaKey, claims)
+		}},
+		{"WrongAudience", func() string {
+			claims := validClaims()
+			claims["aud"] = "other"
+			return signToken(t, jwt.SigningMethodRS256, "rsa-key", rsaKey, claims)
+		}},
+		{"MissingUser", func() string {
+			claims := validClaims()
+			delete(claims, "couchbase")
+			return signToken(t, jwt.SigningMethodRS256, "rsa-key", rsaKey, claims)
+		}},
+		{"Hmac", func() string {
+			return signToken(t, jwt.SigningMethodHS256, "rsa-key", []byte("secret"), validClaims())
+		}},
+		{"Garbage", func() string {
+			return "not-a-token"
+		}},
+	}
+	for _, test := range invalidTests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := validator.Validate(context.Background(), test.token())
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestUrlKeySetRefreshesOnUnknownKey(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var jwks atomic.Pointer[[]byte]
+	oldJwks := marshalJwks(t, rsaJwk("old", &oldKey.PublicKey))
+	jwks.Store(&oldJwks)
+
+	var numFetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numFetches.Add(1)
+		_, _ = w.Write(*jwks.Load())
+	}))
+	defer srv.Close()
+
+	keySet := NewUrlKeySet(zap.NewNop(), srv.Client(), srv.URL, time.Hour)
+	keySet.minRefreshInterval = 0
+
+	validator, err := NewValidator(&ValidatorOptions{KeySet: keySet})
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	_, _, err = validator.Validate(context.Background(),
+		signToken(t, jwt.SigningMethodRS256, "old", oldKey, claims))
+	require.NoError(t, err)
+	_, _, err = validator.Validate(context.Background(),
+		signToken(t, jwt.SigningMethodRS256, "old", oldKey, claims))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), numFetches.Load())
+
+	// the identity provider rotates its keys
+	newJwks := marshalJwks(t, rsaJwk("new", &newKey.PublicKey))
+	jwks.Store(&newJwks)
+
+	user, _, err := validator.Validate(context.Background(),
+		signToken(t, jwt.SigningMethodRS256, "new", newKey, claims))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user)
+	assert.Equal(t, int32(2), numFetches.Load())
+}
+
+func TestKeySetFetchFailure(t *testing.T) {
+	var numFetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numFetches.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	keySet := NewUrlKeySet(zap.NewNop(), srv.Client(), srv.URL, time.Hour)
+	validator, err := NewValidator(&ValidatorOptions{KeySet: keySet})
+	require.NoError(t, err)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	token := signToken(t, jwt.SigningMethodRS256, "key", key, jwt.MapClaims{
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	_, _, err = validator.Validate(context.Background(), token)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidToken)
+
+	// the initial fetch is not retried until the minimum refresh interval
+	_, _, err = validator.Validate(context.Background(), token)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, int32(1), numFetches.Load())
+}
+
+func TestKeySetSharesFetches(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks := marshalJwks(t, rsaJwk("key", &key.PublicKey))
+
+	var numFetches atomic.Int32
+	release := make(chan struct{})
+	keySet := NewCachingKeySet(&CachingKeySetOptions{
+		Fetch: func(ctx context.Context) ([]byte, error) {
+			numFetches.Add(1)
+			<-release
+			return jwks, nil
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			keys, err := keySet.GetKeys(context.Background(), "key")
+			assert.NoError(t, err)
+			assert.Len(t, keys, 1)
+		}()
+	}
+
+	// callers which give up do not cancel the fetch for the others
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = keySet.GetKeys(ctx, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), numFetches.Load())
+}
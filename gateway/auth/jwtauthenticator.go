@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/couchbase/stellar-gateway/gateway/auth/jwtauth"
+)
+
+// JwtAuthenticator extends another authenticator with support for JWT bearer
+// tokens, which are mapped to the user the request is performed on behalf of.
+type JwtAuthenticator struct {
+	Authenticator
+	Validator *jwtauth.Validator
+}
+
+var _ Authenticator = (*JwtAuthenticator)(nil)
+
+func (a *JwtAuthenticator) ValidateTokenForObo(ctx context.Context, token string) (string, string, error) {
+	user, domain, err := a.Validator.Validate(ctx, token)
+	if err != nil {
+		if errors.Is(err, jwtauth.ErrInvalidToken) {
+			return "", "", fmt.Errorf("%w: %s", ErrInvalidToken, err.Error())
+		}
+
+		return "", "", fmt.Errorf("failed to validate jwt: %w", err)
+	}
+
+	return user, domain, nil
+}
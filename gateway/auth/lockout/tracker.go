@@ -0,0 +1,424 @@
+package lockout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+var meter = otel.Meter("github.com/couchbase/stellar-gateway/gateway/auth/lockout")
+
+var ErrLockedOut = errors.New("too many failed authentication attempts")
+
+// LockedOutError indicates that authentication attempts are being refused
+// until a particular time, either due to back-off after a failure or due to
+// a lockout.
+type LockedOutError struct {
+	Type  string
+	Until time.Time
+}
+
+func (e *LockedOutError) Error() string {
+	return fmt.Sprintf("%s: %s blocked until %s", ErrLockedOut.Error(), e.Type, e.Until.Format(time.RFC3339))
+}
+
+func (e *LockedOutError) Unwrap() error {
+	return ErrLockedOut
+}
+
+const (
+	TypeUser = "user"
+	TypeIP   = "ip"
+)
+
+type Options struct {
+	Logger *zap.Logger
+
+	// MaxFailures is the number of failures within Window after which a
+	// user or source address is locked out.  Defaults to 10.
+	MaxFailures int
+
+	// Window is the period over which failures are counted.  Defaults to 5
+	// minutes.
+	Window time.Duration
+
+	// LockoutDuration is how long a lockout lasts.  Defaults to 15 minutes.
+	LockoutDuration time.Duration
+
+	// BaseDelay is the time after a first failure during which further
+	// attempts are refused, doubling with each subsequent failure up to
+	// MaxDelay.  Defaults to 1 second, a negative value disables back-off.
+	BaseDelay time.Duration
+
+	// MaxDelay defaults to 30 seconds.
+	MaxDelay time.Duration
+
+	// Allowlist contains networks which are exempt from tracking.
+	Allowlist []*net.IPNet
+}
+
+type record struct {
+	failures    int
+	windowStart time.Time
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+type recordKey struct {
+	Type string
+	Name string
+}
+
+// Tracker tracks failed authentications per user and per source address,
+// throttling and then locking out further attempts.
+type Tracker struct {
+	logger          *zap.Logger
+	maxFailures     int
+	window          time.Duration
+	lockoutDuration time.Duration
+	baseDelay       time.Duration
+	maxDelay        time.Duration
+	allowlist       []*net.IPNet
+
+	lock      sync.Mutex
+	records   map[recordKey]*record
+	lastPrune time.Time
+
+	numFailures metric.Int64Counter
+	numLockouts metric.Int64Counter
+	numRefused  metric.Int64Counter
+}
+
+func NewTracker(opts *Options) *Tracker {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	maxFailures := opts.MaxFailures
+	if maxFailures <= 0 {
+		maxFailures = 10
+	}
+
+	window := opts.Window
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+
+	lockoutDuration := opts.LockoutDuration
+	if lockoutDuration <= 0 {
+		lockoutDuration = 15 * time.Minute
+	}
+
+	baseDelay := opts.BaseDelay
+	if baseDelay < 0 {
+		baseDelay = 0
+	} else if baseDelay == 0 {
+		baseDelay = 1 * time.Second
+	}
+
+	maxDelay := opts.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+
+	numFailures, err := meter.Int64Counter("auth_failures")
+	if err != nil {
+		logger.Warn("failed to initialize auth failures counter", zap.Error(err))
+	}
+
+	numLockouts, err := meter.Int64Counter("auth_lockouts")
+	if err != nil {
+		logger.Warn("failed to initialize auth lockouts counter", zap.Error(err))
+	}
+
+	numRefused, err := meter.Int64Counter("auth_lockout_refused")
+	if err != nil {
+		logger.Warn("failed to initialize auth refused counter", zap.Error(err))
+	}
+
+	return &Tracker{
+		logger:          logger,
+		maxFailures:     maxFailures,
+		window:          window,
+		lockoutDuration: lockoutDuration,
+		baseDelay:       baseDelay,
+		maxDelay:        maxDelay,
+		allowlist:       opts.Allowlist,
+		records:         make(map[recordKey]*record),
+		numFailures:     numFailures,
+		numLockouts:     numLockouts,
+		numRefused:      numRefused,
+	}
+}
+
+// ParseAllowlist parses a list of IP addresses and CIDR ranges.
+func ParseAllowlist(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		if entry == "" {
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allowlist entry `%s`", entry)
+			}
+
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			ipNet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+func (t *Tracker) isAllowlisted(ip string) bool {
+	if ip == "" || len(t.allowlist) == 0 {
+		return false
+	}
+
+	parsedIp := net.ParseIP(ip)
+	if parsedIp == nil {
+		return false
+	}
+
+	for _, ipNet := range t.allowlist {
+		if ipNet.Contains(parsedIp) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *Tracker) keys(user, ip string) []recordKey {
+	keys := make([]recordKey, 0, 2)
+	if user != "" {
+		keys = append(keys, recordKey{Type: TypeUser, Name: user})
+	}
+	if ip != "" {
+		keys = append(keys, recordKey{Type: TypeIP, Name: ip})
+	}
+	return keys
+}
+
+func (t *Tracker) delay(failures int) time.Duration {
+	if failures <= 0 || t.baseDelay == 0 {
+		return 0
+	}
+
+	delay := t.baseDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= t.maxDelay {
+			return t.maxDelay
+		}
+	}
+
+	return delay
+}
+
+// Check returns a LockedOutError if authentication attempts for the user or
+// from the source address are currently being refused.
+func (t *Tracker) Check(ctx context.Context, user, ip string) error {
+	if t.isAllowlisted(ip) {
+		return nil
+	}
+
+	now := time.Now()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, key := range t.keys(user, ip) {
+		rec, ok := t.records[key]
+		if !ok {
+			continue
+		}
+
+		until := rec.lockedUntil
+		if backoffUntil := rec.lastFailure.Add(t.delay(rec.failures)); backoffUntil.After(until) {
+			until = backoffUntil
+		}
+
+		if now.Before(until) {
+			t.record(ctx, t.numRefused, key.Type)
+			return &LockedOutError{
+				Type:  key.Type,
+				Until: until,
+			}
+		}
+	}
+
+	return nil
+}
+
+// RecordFailure records a failed authentication for the user and source
+// address, locking them out if there have been too many.
+func (t *Tracker) RecordFailure(ctx context.Context, user, ip string) {
+	if t.isAllowlisted(ip) {
+		return
+	}
+
+	now := time.Now()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.maybePrune(now)
+
+	for _, key := range t.keys(user, ip) {
+		rec, ok := t.records[key]
+		if !ok {
+			rec = &record{}
+			t.records[key] = rec
+		}
+
+		if now.Sub(rec.windowStart) > t.window {
+			rec.failures = 0
+			rec.windowStart = now
+		}
+
+		rec.failures++
+		rec.lastFailure = now
+
+		if rec.failures >= t.maxFailures {
+			rec.lockedUntil = now.Add(t.lockoutDuration)
+			rec.failures = 0
+			rec.windowStart = rec.lockedUntil
+
+			t.logger.Warn("locking out authentication after repeated failures",
+				zap.String("type", key.Type),
+				zap.String("name", key.Name),
+				zap.Time("until", rec.lockedUntil))
+			t.record(ctx, t.numLockouts, key.Type)
+		}
+	}
+
+	t.record(ctx, t.numFailures, "")
+}
+
+// RecordSuccess records a successful authentication, which resets the failures
+// of the user.  Failures from the source address are not reset, otherwise an
+// attacker with one valid account could use it to avoid being locked out.
+func (t *Tracker) RecordSuccess(ctx context.Context, user, ip string) {
+	if user == "" {
+		return
+	}
+
+	t.lock.Lock()
+	delete(t.records, recordKey{Type: TypeUser, Name: user})
+	t.lock.Unlock()
+}
+
+func (t *Tracker) record(ctx context.Context, counter metric.Int64Counter, recordType string) {
+	if counter == nil {
+		return
+	}
+
+	if recordType == "" {
+		counter.Add(ctx, 1)
+		return
+	}
+
+	counter.Add(ctx, 1, metric.WithAttributes(attribute.String("type", recordType)))
+}
+
+// maybePrune removes records which no longer have any effect, at most once
+// per window.  Must be called with the lock held.
+func (t *Tracker) maybePrune(now time.Time) {
+	if now.Sub(t.lastPrune) < t.window {
+		return
+	}
+	t.lastPrune = now
+
+	for key, rec := range t.records {
+		if t.isExpired(rec, now) {
+			delete(t.records, key)
+		}
+	}
+}
+
+func (t *Tracker) isExpired(rec *record, now time.Time) bool {
+	return !now.Before(rec.lockedUntil) &&
+		now.Sub(rec.windowStart) > t.window &&
+		!now.Before(rec.lastFailure.Add(t.delay(rec.failures)))
+}
+
+// State describes the failures tracked for a user or source address.
+type State struct {
+	Type        string     `json:"type"`
+	Name        string     `json:"name"`
+	Failures    int        `json:"failures"`
+	LastFailure time.Time  `json:"lastFailure"`
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+}
+
+// States returns the currently tracked failures and lockouts.
+func (t *Tracker) States() []State {
+	now := time.Now()
+
+	t.lock.Lock()
+	states := make([]State, 0, len(t.records))
+	for key, rec := range t.records {
+		if t.isExpired(rec, now) {
+			continue
+		}
+
+		state := State{
+			Type:        key.Type,
+			Name:        key.Name,
+			Failures:    rec.failures,
+			LastFailure: rec.lastFailure,
+		}
+		if now.Before(rec.lockedUntil) {
+			lockedUntil := rec.lockedUntil
+			state.LockedUntil = &lockedUntil
+		}
+
+		states = append(states, state)
+	}
+	t.lock.Unlock()
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Type != states[j].Type {
+			return states[i].Type < states[j].Type
+		}
+		return states[i].Name < states[j].Name
+	})
+
+	return states
+}
+
+// HttpHandler serves the current lockout state as JSON.
+func (t *Tracker) HttpHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"lockouts": t.States(),
+		})
+		if err != nil {
+			t.logger.Debug("failed to write lockout state", zap.Error(err))
+		}
+	})
+}
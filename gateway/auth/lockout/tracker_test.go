@@ -0,0 +1,108 @@
+package lockout
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerBackoff(t *testing.T) {
+	tracker := NewTracker(&Options{
+		BaseDelay: 20 * time.Millisecond,
+		MaxDelay:  40 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	require.NoError(t, tracker.Check(ctx, "alice", "10.0.0.1"))
+	tracker.RecordFailure(ctx, "alice", "10.0.0.1")
+
+	err := tracker.Check(ctx, "alice", "10.0.0.2")
+	require.ErrorIs(t, err, ErrLockedOut)
+	var lockedErr *LockedOutError
+	require.ErrorAs(t, err, &lockedErr)
+	assert.Equal(t, TypeUser, lockedErr.Type)
+
+	err = tracker.Check(ctx, "bob", "10.0.0.1")
+	require.ErrorAs(t, err, &lockedErr)
+	assert.Equal(t, TypeIP, lockedErr.Type)
+
+	assert.NoError(t, tracker.Check(ctx, "bob", "10.0.0.2"))
+
+	time.Sleep(30 * time.Millisecond)
+	require.NoError(t, tracker.Check(ctx, "alice", "10.0.0.1"))
+
+	// the delay doubles with each failure, up to the maximum
+	assert.Equal(t, 20*time.Millisecond, tracker.delay(1))
+	assert.Equal(t, 40*time.Millisecond, tracker.delay(2))
+	assert.Equal(t, 40*time.Millisecond, tracker.delay(5))
+}
+
+func TestTrackerLockout(t *testing.T) {
+	tracker := NewTracker(&Options{
+		MaxFailures:     3,
+		BaseDelay:       -1,
+		LockoutDuration: 50 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, tracker.Check(ctx, "alice", "10.0.0.1"))
+		tracker.RecordFailure(ctx, "alice", "10.0.0.1")
+	}
+
+	// a success resets the failures of the user, but not the address
+	tracker.RecordSuccess(ctx, "alice", "10.0.0.1")
+	tracker.RecordFailure(ctx, "alice", "10.0.0.1")
+
+	assert.NoError(t, tracker.Check(ctx, "alice", "10.0.0.2"))
+	err := tracker.Check(ctx, "bob", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrLockedOut)
+
+	states := tracker.States()
+	require.Len(t, states, 2)
+	assert.Equal(t, TypeIP, states[0].Type)
+	assert.Equal(t, "10.0.0.1", states[0].Name)
+	assert.NotNil(t, states[0].LockedUntil)
+	assert.Equal(t, TypeUser, states[1].Type)
+	assert.Equal(t, 1, states[1].Failures)
+	assert.Nil(t, states[1].LockedUntil)
+
+	rec := httptest.NewRecorder()
+	tracker.HttpHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/auth/lockouts", nil))
+	var body struct {
+		Lockouts []State `json:"lockouts"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Len(t, body.Lockouts, 2)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, tracker.Check(ctx, "bob", "10.0.0.1"))
+}
+
+func TestTrackerAllowlist(t *testing.T) {
+	allowlist, err := ParseAllowlist([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	require.NoError(t, err)
+
+	_, err = ParseAllowlist([]string{"not-an-ip"})
+	assert.Error(t, err)
+
+	tracker := NewTracker(&Options{
+		MaxFailures: 1,
+		Allowlist:   allowlist,
+	})
+	ctx := context.Background()
+
+	for _, ip := range []string{"10.1.2.3", "192.168.1.1", "::1"} {
+		tracker.RecordFailure(ctx, "alice", ip)
+		assert.NoError(t, tracker.Check(ctx, "alice", ip))
+	}
+
+	tracker.RecordFailure(ctx, "alice", "192.168.1.2")
+	assert.ErrorIs(t, tracker.Check(ctx, "alice", "192.168.1.2"), ErrLockedOut)
+	assert.NoError(t, tracker.Check(ctx, "alice", "10.1.2.3"))
+}
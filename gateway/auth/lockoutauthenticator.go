@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/couchbase/stellar-gateway/gateway/auth/lockout"
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+)
+
+// LockoutAuthenticator throttles and locks out password authentication for
+// users and source addresses after repeated failures, to prevent the gateway
+// being used to guess the passwords of cluster users.
+type LockoutAuthenticator struct {
+	Authenticator
+	Tracker *lockout.Tracker
+}
+
+var _ Authenticator = (*LockoutAuthenticator)(nil)
+
+func (a *LockoutAuthenticator) ValidateUserForObo(ctx context.Context, user, pass string) (string, string, error) {
+	clientIp := reqctx.ClientIPFromContext(ctx)
+
+	err := a.Tracker.Check(ctx, user, clientIp)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %s", ErrAuthLockedOut, err.Error())
+	}
+
+	oboUser, oboDomain, err := a.Authenticator.ValidateUserForObo(ctx, user, pass)
+	if errors.Is(err, ErrInvalidCredentials) {
+		a.Tracker.RecordFailure(ctx, user, clientIp)
+	} else if err == nil || errors.Is(err, ErrSingleUserAuthValid) {
+		a.Tracker.RecordSuccess(ctx, user, clientIp)
+	}
+
+	return oboUser, oboDomain, err
+}
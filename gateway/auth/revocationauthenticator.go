@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/couchbase/stellar-gateway/gateway/auth/crl"
+)
+
+// RevocationAuthenticator rejects client certificates which have been
+// revoked.  Revoked certificates are normally refused during the handshake,
+// but connections may outlive the CRLs which were in place when they were
+// established, so they are checked again on each request.
+type RevocationAuthenticator struct {
+	Authenticator
+	Checker *crl.Checker
+}
+
+var _ Authenticator = (*RevocationAuthenticator)(nil)
+
+func (a *RevocationAuthenticator) ValidateConnStateForObo(ctx context.Context, connState *tls.ConnectionState) (string, string, error) {
+	err := a.Checker.CheckConnState(connState)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %s", ErrCertificateRevoked, err.Error())
+	}
+
+	return a.Authenticator.ValidateConnStateForObo(ctx, connState)
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/couchbase/stellar-gateway/gateway/scramauth"
+)
+
+// SessionAuthenticator extends another authenticator with support for the
+// session tokens issued by SCRAM handshakes.  Bearer tokens which are not
+// session tokens are passed on to the wrapped authenticator.
+type SessionAuthenticator struct {
+	Authenticator
+	Sessions *scramauth.SessionStore
+}
+
+var _ Authenticator = (*SessionAuthenticator)(nil)
+
+func (a *SessionAuthenticator) ValidateTokenForObo(ctx context.Context, token string) (string, string, error) {
+	if !scramauth.IsSessionToken(token) {
+		return a.Authenticator.ValidateTokenForObo(ctx, token)
+	}
+
+	identity, err := a.Sessions.Validate(token)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %s", ErrInvalidToken, err.Error())
+	}
+
+	if identity.User == "" {
+		return "", "", ErrSingleUserAuthValid
+	}
+
+	return identity.User, identity.Domain, nil
+}
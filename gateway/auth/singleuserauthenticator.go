@@ -4,25 +4,62 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+
+	"github.com/couchbase/stellar-gateway/gateway/auth/userfile"
+	"github.com/couchbase/stellar-gateway/gateway/credentials"
 )
 
 // We intentionally use an error for this case so that we only permit non-obo requests
 // to be produced if the caller is explicitly checking for this condition.
 var ErrSingleUserAuthValid = errors.New("single user authentication successful")
 
+// SingleUserAuthenticator only permits the same credentials the gateway uses
+// to connect to the cluster, following any changes to them.  If Users is set,
+// the users and client certificates it contains are also permitted, and only
+// users explicitly marked as acting as the gateway are treated the same as
+// the gateway's own credentials.
 type SingleUserAuthenticator struct {
-	Username string
-	Password string
+	Credentials *credentials.Provider
+	Users       *userfile.Store
 }
 
 func (a *SingleUserAuthenticator) ValidateUserForObo(ctx context.Context, user, pass string) (string, string, error) {
-	if user == a.Username && pass == a.Password {
+	username, password := a.Credentials.Get()
+	// the gateway may authenticate to the cluster with only a certificate,
+	// in which case there are no credentials to match.
+	if username != "" && user == username && pass == password {
 		return "", "", ErrSingleUserAuthValid
 	}
 
+	if a.Users != nil {
+		fileUser, err := a.Users.Authenticate(user, pass)
+		if err != nil {
+			return "", "", ErrInvalidCredentials
+		}
+
+		if fileUser.ActsAsGateway {
+			return "", "", ErrSingleUserAuthValid
+		}
+
+		return fileUser.OboUser, fileUser.OboDomain, nil
+	}
+
 	return "", "", ErrInvalidCredentials
 }
 
 func (a *SingleUserAuthenticator) ValidateConnStateForObo(ctx context.Context, connState *tls.ConnectionState) (string, string, error) {
+	if a.Users != nil {
+		user, domain, err := a.Users.AuthenticateCertificate(connState)
+		if err != nil {
+			return "", "", ErrInvalidCertificate
+		}
+
+		return user, domain, nil
+	}
+
 	return "", "", ErrInvalidCertificate
 }
+
+func (a *SingleUserAuthenticator) ValidateTokenForObo(ctx context.Context, token string) (string, string, error) {
+	return "", "", ErrTokenAuthDisabled
+}
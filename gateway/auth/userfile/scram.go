@@ -0,0 +1,99 @@
+package userfile
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/couchbase/stellar-gateway/contrib/scramserver"
+)
+
+// scramHashFns maps the SCRAM mechanisms hashes may be stored for to the hash
+// function names used by the scram server.
+var scramHashFns = map[string]string{
+	"SCRAM-SHA-256": "SCRAM-SHA256",
+	"SCRAM-SHA-512": "SCRAM-SHA512",
+}
+
+// ScramHash is a password hash in the format used by PostgreSQL, of the form
+// `SCRAM-SHA-256$<iterations>:<salt>$<StoredKey>:<ServerKey>`.  Unlike bcrypt
+// hashes, these allow the user to authenticate using SCRAM with the mechanism
+// of the hash.  SCRAM-SHA-512 hashes are also supported.
+type ScramHash struct {
+	Mechanism   string
+	Credentials *scramserver.Credentials
+}
+
+func isScramHash(hash string) bool {
+	return strings.HasPrefix(hash, "SCRAM-")
+}
+
+func parseScramHash(hash string) (*ScramHash, error) {
+	errInvalid := errors.New("invalid scram hash")
+
+	mechanism, rest, ok := strings.Cut(hash, "$")
+	if !ok || scramHashFns[mechanism] == "" {
+		return nil, errInvalid
+	}
+
+	params, keys, ok := strings.Cut(rest, "$")
+	if !ok {
+		return nil, errInvalid
+	}
+
+	iterationsStr, saltStr, ok := strings.Cut(params, ":")
+	if !ok {
+		return nil, errInvalid
+	}
+	storedKeyStr, serverKeyStr, ok := strings.Cut(keys, ":")
+	if !ok {
+		return nil, errInvalid
+	}
+
+	iterations, err := strconv.Atoi(iterationsStr)
+	if err != nil || iterations <= 0 {
+		return nil, errInvalid
+	}
+
+	var decoded [3][]byte
+	for i, str := range []string{saltStr, storedKeyStr, serverKeyStr} {
+		decoded[i], err = base64.StdEncoding.DecodeString(str)
+		if err != nil || len(decoded[i]) == 0 {
+			return nil, errInvalid
+		}
+	}
+
+	return &ScramHash{
+		Mechanism: mechanism,
+		Credentials: &scramserver.Credentials{
+			Salt:       decoded[0],
+			Iterations: iterations,
+			StoredKey:  decoded[1],
+			ServerKey:  decoded[2],
+		},
+	}, nil
+}
+
+// verifyPassword checks a plaintext password against the hash.
+func (h *ScramHash) verifyPassword(password string) bool {
+	creds, err := scramserver.NewCredentials(scramHashFns[h.Mechanism], password,
+		h.Credentials.Salt, h.Credentials.Iterations)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(creds.StoredKey, h.Credentials.StoredKey)
+}
+
+// ScramCredentials returns the SCRAM credentials of a user for a mechanism,
+// which are only known for users with a hash of that mechanism.
+func (s *Store) ScramCredentials(username, mechanism string) (*User, *scramserver.Credentials, bool) {
+	user, ok := s.state.Load().users[username]
+	if !ok || user.Scram == nil || user.Scram.Mechanism != mechanism {
+		return nil, nil, false
+	}
+
+	return user, user.Scram.Credentials, true
+}
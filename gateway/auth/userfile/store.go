@@ -0,0 +1,359 @@
+package userfile
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/couchbase/stellar-gateway/utils/certwatcher"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidCertificate = errors.New("invalid certificate")
+)
+
+const defaultDomain = "local"
+
+// gatewayOboUser is the obo-user which permits a user to perform requests as
+// the gateway itself.  Cluster usernames may not contain an @, so this never
+// conflicts with a real user.
+const gatewayOboUser = "@gateway"
+
+// dummyHash is compared against when a user does not exist, so that unknown
+// users take as long to reject as incorrect passwords.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// User is an entry in the users file.  Each line of the file is of the form
+// `username:bcrypt-hash[:obo-user[:obo-domain]]`, which is compatible with
+// htpasswd files generated using `htpasswd -B`.  A ScramHash may be used in
+// place of the bcrypt hash.  If no obo-user is specified, requests are
+// performed on behalf of the cluster user of the same name.
+type User struct {
+	Name  string
+	Hash  []byte
+	Scram *ScramHash
+
+	// OboUser is the cluster user that requests are performed on behalf of.
+	OboUser   string
+	OboDomain string
+
+	// ActsAsGateway indicates that requests are performed as the gateway
+	// itself rather than on behalf of a cluster user, which must be opted
+	// into by specifying an obo-user of `@gateway`.
+	ActsAsGateway bool
+}
+
+// CertMapping maps client certificates with a particular common name or
+// subject alternative name to a cluster user.
+type CertMapping struct {
+	CommonName string `json:"cn,omitempty"`
+	SAN        string `json:"san,omitempty"`
+	User       string `json:"user"`
+	Domain     string `json:"domain,omitempty"`
+}
+
+type certMapFile struct {
+	Mappings []CertMapping `json:"mappings"`
+}
+
+type state struct {
+	users    map[string]*User
+	mappings []CertMapping
+}
+
+type Options struct {
+	Logger *zap.Logger
+
+	// UsersPath is the path of the users file.
+	UsersPath string
+
+	// CertMapPath is the path of a JSON file of the form
+	// `{"mappings": [{"cn": "...", "user": "...", "domain": "..."}]}`.
+	CertMapPath string
+}
+
+// Store holds gateway-level users and client certificate mappings loaded from
+// files, reloading them whenever the files change.
+type Store struct {
+	logger      *zap.Logger
+	usersPath   string
+	certMapPath string
+
+	state   atomic.Pointer[state]
+	watcher *certwatcher.Watcher
+
+	watchersLock sync.Mutex
+	watchers     []func()
+}
+
+func NewStore(opts *Options) (*Store, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	s := &Store{
+		logger:      logger,
+		usersPath:   opts.UsersPath,
+		certMapPath: opts.CertMapPath,
+	}
+
+	err := s.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := certwatcher.New(&certwatcher.Options{
+		Logger: logger.Named("watcher"),
+		Paths:  []string{opts.UsersPath, opts.CertMapPath},
+		OnChange: func() {
+			logger.Info("reloading users after file change")
+			err := s.Reload()
+			if err != nil {
+				logger.Warn("failed to reload users, continuing to use previous users", zap.Error(err))
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.watcher = watcher
+
+	return s, nil
+}
+
+// Reload re-reads the files.  If either cannot be loaded, the previously
+// loaded users and mappings remain in use.
+func (s *Store) Reload() error {
+	newState := &state{
+		users: make(map[string]*User),
+	}
+
+	if s.usersPath != "" {
+		users, err := loadUsers(s.usersPath)
+		if err != nil {
+			return err
+		}
+		newState.users = users
+	}
+
+	if s.certMapPath != "" {
+		mappings, err := loadCertMappings(s.certMapPath)
+		if err != nil {
+			return err
+		}
+		newState.mappings = mappings
+	}
+
+	s.watchersLock.Lock()
+	s.state.Store(newState)
+
+	// watchers are invoked with the lock held so that they always observe
+	// the reloads in the order they were made.
+	for _, watcher := range s.watchers {
+		watcher()
+	}
+	s.watchersLock.Unlock()
+
+	s.logger.Debug("loaded users",
+		zap.Int("numUsers", len(newState.users)),
+		zap.Int("numCertMappings", len(newState.mappings)))
+
+	return nil
+}
+
+// Watch registers a function which is invoked whenever the users or mappings
+// are reloaded.  The function must not call back into the store.
+func (s *Store) Watch(fn func()) {
+	s.watchersLock.Lock()
+	defer s.watchersLock.Unlock()
+
+	s.watchers = append(s.watchers, fn)
+}
+
+func loadUsers(path string) (map[string]*User, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users file: %w", err)
+	}
+
+	users := make(map[string]*User)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// bcrypt hashes never contain a colon, so we can split on them, but
+		// scram hashes contain two which must be rejoined.
+		parts := strings.Split(line, ":")
+		if len(parts) >= 4 && isScramHash(parts[1]) {
+			parts = append([]string{parts[0], strings.Join(parts[1:4], ":")}, parts[4:]...)
+		}
+		if len(parts) < 2 || len(parts) > 4 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid users file entry on line %d", lineNum)
+		}
+
+		user := &User{
+			Name:      parts[0],
+			OboUser:   parts[0],
+			OboDomain: defaultDomain,
+		}
+
+		if isScramHash(parts[1]) {
+			scramHash, err := parseScramHash(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid scram password hash for user `%s` on line %d", parts[0], lineNum)
+			}
+			user.Scram = scramHash
+		} else {
+			hash := []byte(parts[1])
+			if _, err := bcrypt.Cost(hash); err != nil {
+				return nil, fmt.Errorf("invalid password hash for user `%s` on line %d, only bcrypt and scram are supported", parts[0], lineNum)
+			}
+			user.Hash = hash
+		}
+		if len(parts) > 2 && parts[2] == gatewayOboUser {
+			if len(parts) > 3 && parts[3] != "" {
+				return nil, fmt.Errorf("obo-domain cannot be specified for gateway user `%s` on line %d", parts[0], lineNum)
+			}
+			user.OboUser = ""
+			user.OboDomain = ""
+			user.ActsAsGateway = true
+		} else {
+			if len(parts) > 2 && parts[2] != "" {
+				user.OboUser = parts[2]
+			}
+			if len(parts) > 3 && parts[3] != "" {
+				user.OboDomain = parts[3]
+			}
+		}
+
+		if _, ok := users[user.Name]; ok {
+			return nil, fmt.Errorf("duplicate user `%s` on line %d", user.Name, lineNum)
+		}
+		users[user.Name] = user
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read users file: %w", err)
+	}
+
+	return users, nil
+}
+
+func loadCertMappings(path string) ([]CertMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate mapping file: %w", err)
+	}
+
+	var file certMapFile
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate mapping file: %w", err)
+	}
+
+	for i, mapping := range file.Mappings {
+		if (mapping.CommonName == "") == (mapping.SAN == "") {
+			return nil, fmt.Errorf("certificate mapping %d must specify exactly one of cn or san", i)
+		}
+		if mapping.User == "" {
+			return nil, fmt.Errorf("certificate mapping %d must specify a user", i)
+		}
+		if mapping.Domain == "" {
+			file.Mappings[i].Domain = defaultDomain
+		}
+	}
+
+	return file.Mappings, nil
+}
+
+// Authenticate checks a username and password against the users file,
+// returning the user the request should be performed on behalf of.
+func (s *Store) Authenticate(username, password string) (*User, error) {
+	user, ok := s.state.Load().users[username]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.Scram != nil {
+		if !user.Scram.verifyPassword(password) {
+			return nil, ErrInvalidCredentials
+		}
+
+		return user, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword(user.Hash, []byte(password))
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+// AuthenticateCertificate finds the mapping for a verified client certificate,
+// mappings are checked in the order they appear in the file.
+func (s *Store) AuthenticateCertificate(connState *tls.ConnectionState) (string, string, error) {
+	if connState == nil || len(connState.VerifiedChains) == 0 {
+		return "", "", ErrInvalidCertificate
+	}
+
+	cert := connState.VerifiedChains[0][0]
+	for _, mapping := range s.state.Load().mappings {
+		if mapping.matches(cert) {
+			return mapping.User, mapping.Domain, nil
+		}
+	}
+
+	return "", "", ErrInvalidCertificate
+}
+
+func (m *CertMapping) matches(cert *x509.Certificate) bool {
+	if m.CommonName != "" {
+		return cert.Subject.CommonName == m.CommonName
+	}
+
+	for _, name := range cert.DNSNames {
+		if strings.EqualFold(name, m.SAN) {
+			return true
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if email == m.SAN {
+			return true
+		}
+	}
+	for _, uri := range cert.URIs {
+		if uri.String() == m.SAN {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Close stops watching the files for changes.
+func (s *Store) Close() error {
+	return s.watcher.Close()
+}
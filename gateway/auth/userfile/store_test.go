@@ -0,0 +1,197 @@
+package userfile
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/contrib/scramserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hashPassword(t *testing.T, password string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return string(hash)
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+}
+
+func TestStoreUsers(t *testing.T) {
+	usersPath := filepath.Join(t.TempDir(), "users")
+	writeFile(t, usersPath, "# gateway users\n"+
+		"alice:"+hashPassword(t, "alice-pass")+":app-user\n"+
+		"\n"+
+		"bob:"+hashPassword(t, "bob-pass")+":ldap-user:external\n"+
+		"carol:"+hashPassword(t, "carol-pass")+"\n"+
+		"admin:"+hashPassword(t, "admin-pass")+":@gateway\n")
+
+	store, err := NewStore(&Options{UsersPath: usersPath})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	user, err := store.Authenticate("alice", "alice-pass")
+	require.NoError(t, err)
+	assert.Equal(t, "app-user", user.OboUser)
+	assert.Equal(t, "local", user.OboDomain)
+
+	user, err = store.Authenticate("bob", "bob-pass")
+	require.NoError(t, err)
+	assert.Equal(t, "ldap-user", user.OboUser)
+	assert.Equal(t, "external", user.OboDomain)
+
+	// without an obo-user, requests are performed as the user of the same name
+	user, err = store.Authenticate("carol", "carol-pass")
+	require.NoError(t, err)
+	assert.Equal(t, "carol", user.OboUser)
+	assert.Equal(t, "local", user.OboDomain)
+	assert.False(t, user.ActsAsGateway)
+
+	user, err = store.Authenticate("admin", "admin-pass")
+	require.NoError(t, err)
+	assert.Equal(t, "", user.OboUser)
+	assert.True(t, user.ActsAsGateway)
+
+	_, err = store.Authenticate("alice", "bob-pass")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = store.Authenticate("dave", "alice-pass")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestStoreScramUsers(t *testing.T) {
+	creds, err := scramserver.NewCredentials("SCRAM-SHA256", "carol-pass", []byte("0123456789abcdef"), 4096)
+	require.NoError(t, err)
+	scramHash := "SCRAM-SHA-256$4096:" + base64.StdEncoding.EncodeToString(creds.Salt) +
+		"$" + base64.StdEncoding.EncodeToString(creds.StoredKey) +
+		":" + base64.StdEncoding.EncodeToString(creds.ServerKey)
+
+	usersPath := filepath.Join(t.TempDir(), "users")
+	writeFile(t, usersPath, "carol:"+scramHash+":app-user:local\n"+
+		"dave:"+hashPassword(t, "dave-pass")+"\n")
+
+	store, err := NewStore(&Options{UsersPath: usersPath})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	user, err := store.Authenticate("carol", "carol-pass")
+	require.NoError(t, err)
+	assert.Equal(t, "app-user", user.OboUser)
+	_, err = store.Authenticate("carol", "dave-pass")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	user, userCreds, ok := store.ScramCredentials("carol", "SCRAM-SHA-256")
+	require.True(t, ok)
+	assert.Equal(t, "app-user", user.OboUser)
+	assert.Equal(t, creds, userCreds)
+
+	// only the mechanism of the hash is known, and never for bcrypt users
+	_, _, ok = store.ScramCredentials("carol", "SCRAM-SHA-512")
+	assert.False(t, ok)
+	_, _, ok = store.ScramCredentials("dave", "SCRAM-SHA-256")
+	assert.False(t, ok)
+
+	writeFile(t, usersPath, "carol:SCRAM-SHA-256$4096:bad$bad:bad\n")
+	assert.Error(t, store.Reload())
+}
+
+func TestStoreReload(t *testing.T) {
+	usersPath := filepath.Join(t.TempDir(), "users")
+	writeFile(t, usersPath, "alice:"+hashPassword(t, "old-pass")+"\n")
+
+	store, err := NewStore(&Options{UsersPath: usersPath})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	_, err = store.Authenticate("alice", "old-pass")
+	require.NoError(t, err)
+
+	var reloads atomic.Int32
+	store.Watch(func() {
+		reloads.Add(1)
+	})
+
+	// replace the file via rename, as most tooling does
+	tmpPath := usersPath + ".tmp"
+	writeFile(t, tmpPath, "alice:"+hashPassword(t, "new-pass")+"\n")
+	require.NoError(t, os.Rename(tmpPath, usersPath))
+
+	require.Eventually(t, func() bool {
+		_, err := store.Authenticate("alice", "new-pass")
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Positive(t, reloads.Load())
+
+	// invalid files are rejected, leaving the previous users in place
+	writeFile(t, usersPath, "alice:plaintext-password\n")
+	assert.Error(t, store.Reload())
+
+	_, err = store.Authenticate("alice", "new-pass")
+	assert.NoError(t, err)
+}
+
+func TestStoreCertMappings(t *testing.T) {
+	certMapPath := filepath.Join(t.TempDir(), "certmap.json")
+	writeFile(t, certMapPath, `{"mappings": [
+		{"cn": "client-one", "user": "app-one"},
+		{"san": "client-two.example.com", "user": "app-two", "domain": "external"}
+	]}`)
+
+	store, err := NewStore(&Options{CertMapPath: certMapPath})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	verified := func(cert *x509.Certificate) *tls.ConnectionState {
+		return &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}
+	}
+
+	user, domain, err := store.AuthenticateCertificate(verified(&x509.Certificate{
+		Subject: pkix.Name{CommonName: "client-one"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "app-one", user)
+	assert.Equal(t, "local", domain)
+
+	user, domain, err = store.AuthenticateCertificate(verified(&x509.Certificate{
+		Subject:  pkix.Name{CommonName: "something-else"},
+		DNSNames: []string{"Client-Two.example.com"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "app-two", user)
+	assert.Equal(t, "external", domain)
+
+	_, _, err = store.AuthenticateCertificate(verified(&x509.Certificate{
+		Subject: pkix.Name{CommonName: "unknown"},
+	}))
+	assert.ErrorIs(t, err, ErrInvalidCertificate)
+
+	// certificates which were not verified against the client CAs are never
+	// accepted, regardless of their names.
+	_, _, err = store.AuthenticateCertificate(&tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "client-one"}}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidCertificate)
+
+	writeFile(t, certMapPath, `{"mappings": [{"cn": "a", "san": "b", "user": "c"}]}`)
+	assert.Error(t, store.Reload())
+}
@@ -0,0 +1,129 @@
+package configwatcher
+
+import (
+	"context"
+	"sync"
+)
+
+// configStream holds the latest configuration received from a single
+// streaming endpoint and fans it out to all of its subscribers.
+type configStream[T any] struct {
+	// parentLock is the watcher lock which guards creation and removal of
+	// streams, and therefore also the addition and removal of subscribers.
+	parentLock *sync.Mutex
+	remove     func()
+	cancel     context.CancelFunc
+
+	lock   sync.Mutex
+	latest *T
+	err    error
+	subs   map[*Subscription[T]]struct{}
+}
+
+func newConfigStream[T any](parentLock *sync.Mutex, cancel context.CancelFunc, remove func()) *configStream[T] {
+	return &configStream[T]{
+		parentLock: parentLock,
+		remove:     remove,
+		cancel:     cancel,
+		subs:       make(map[*Subscription[T]]struct{}),
+	}
+}
+
+// subscribe must be called with the parent lock held.
+func (s *configStream[T]) subscribe() *Subscription[T] {
+	sub := &Subscription[T]{
+		stream:   s,
+		notifyCh: make(chan struct{}, 1),
+	}
+
+	s.lock.Lock()
+	s.subs[sub] = struct{}{}
+	if s.latest != nil || s.err != nil {
+		sub.notify()
+	}
+	s.lock.Unlock()
+
+	return sub
+}
+
+func (s *configStream[T]) unsubscribe(sub *Subscription[T]) {
+	s.parentLock.Lock()
+	defer s.parentLock.Unlock()
+
+	s.lock.Lock()
+	delete(s.subs, sub)
+	numSubs := len(s.subs)
+	s.lock.Unlock()
+
+	// once nobody is interested in this stream anymore, we stop streaming it
+	// from the cluster.
+	if numSubs == 0 {
+		s.cancel()
+		s.remove()
+	}
+}
+
+func (s *configStream[T]) publish(config *T) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.latest = config
+	for sub := range s.subs {
+		sub.notify()
+	}
+}
+
+// fail marks the stream as permanently failed, all subscribers will observe
+// the error the next time they check for the latest configuration.
+func (s *configStream[T]) fail(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.err = err
+	for sub := range s.subs {
+		sub.notify()
+	}
+}
+
+func (s *configStream[T]) get() (*T, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.latest, s.err
+}
+
+// Subscription represents interest in a particular configuration stream.  Its
+// Changed channel is signalled whenever a new configuration is available,
+// with multiple changes being coalesced into a single notification so that a
+// slow subscriber never blocks the stream or other subscribers.
+type Subscription[T any] struct {
+	stream    *configStream[T]
+	notifyCh  chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *Subscription[T]) notify() {
+	select {
+	case s.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// Changed returns a channel which is signalled when the configuration has
+// changed and Latest should be called to retrieve it.
+func (s *Subscription[T]) Changed() <-chan struct{} {
+	return s.notifyCh
+}
+
+// Latest returns the most recent configuration received, or an error if the
+// stream has permanently failed (such as when the bucket was deleted).
+func (s *Subscription[T]) Latest() (*T, error) {
+	return s.stream.get()
+}
+
+// Close unsubscribes from the stream.  It is safe to call Close more than once.
+func (s *Subscription[T]) Close() {
+	s.closeOnce.Do(func() {
+		s.stream.unsubscribe(s)
+	})
+}
@@ -0,0 +1,168 @@
+package configwatcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/contrib/cbconfig"
+	"go.uber.org/zap"
+)
+
+const defaultRetryInterval = 1 * time.Second
+
+type WatcherOptions struct {
+	Logger  *zap.Logger
+	Fetcher *cbconfig.Fetcher
+
+	// RetryInterval specifies how long to wait before re-establishing a
+	// configuration stream which was closed or failed.
+	RetryInterval time.Duration
+}
+
+// Watcher maintains a single streaming configuration subscription to the
+// cluster for each bucket (and for the cluster topology itself) which is
+// shared between all interested parties, rather than having each of them
+// poll ns_server independently.
+type Watcher struct {
+	logger        *zap.Logger
+	fetcher       *cbconfig.Fetcher
+	retryInterval time.Duration
+
+	lock    sync.Mutex
+	closed  bool
+	buckets map[string]*configStream[cbconfig.TerseConfigJson]
+	pool    *configStream[cbconfig.PoolConfigJson]
+}
+
+func NewWatcher(opts *WatcherOptions) *Watcher {
+	retryInterval := opts.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultRetryInterval
+	}
+
+	return &Watcher{
+		logger:        opts.Logger,
+		fetcher:       opts.Fetcher,
+		retryInterval: retryInterval,
+		buckets:       make(map[string]*configStream[cbconfig.TerseConfigJson]),
+	}
+}
+
+// WatchBucket subscribes to configuration changes for a particular bucket.
+// The caller must Close the subscription once it is no longer needed.
+func (w *Watcher) WatchBucket(bucketName string) *Subscription[cbconfig.TerseConfigJson] {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	stream := w.buckets[bucketName]
+	if stream == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		stream = newConfigStream[cbconfig.TerseConfigJson](&w.lock, cancel, nil)
+		stream.remove = func() {
+			if w.buckets[bucketName] == stream {
+				delete(w.buckets, bucketName)
+			}
+		}
+		w.buckets[bucketName] = stream
+
+		logger := w.logger.With(zap.String("bucket", bucketName))
+		if w.closed {
+			cancel()
+		} else {
+			go runStream(ctx, w, logger, stream,
+				func(ctx context.Context, handler func(*cbconfig.TerseConfigJson)) error {
+					return w.fetcher.StreamTerseBucket(ctx, bucketName, handler)
+				})
+		}
+	}
+
+	return stream.subscribe()
+}
+
+// WatchPool subscribes to changes in the cluster topology.  The caller must
+// Close the subscription once it is no longer needed.
+func (w *Watcher) WatchPool() *Subscription[cbconfig.PoolConfigJson] {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	stream := w.pool
+	if stream == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		stream = newConfigStream[cbconfig.PoolConfigJson](&w.lock, cancel, nil)
+		stream.remove = func() {
+			if w.pool == stream {
+				w.pool = nil
+			}
+		}
+		w.pool = stream
+
+		if w.closed {
+			cancel()
+		} else {
+			go runStream(ctx, w, w.logger, stream, w.fetcher.StreamPool)
+		}
+	}
+
+	return stream.subscribe()
+}
+
+// runStream keeps a configuration stream connected until its context is
+// cancelled, publishing every configuration received to the subscribers.
+func runStream[T any](
+	ctx context.Context,
+	w *Watcher,
+	logger *zap.Logger,
+	stream *configStream[T],
+	streamFn func(context.Context, func(*T)) error,
+) {
+	for {
+		logger.Debug("starting config stream")
+
+		err := streamFn(ctx, stream.publish)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if errors.Is(err, cbconfig.ErrNotFound) {
+			logger.Debug("config stream target no longer exists")
+
+			// we remove the stream so that a later subscriber (for instance
+			// after the bucket is recreated) starts a fresh stream.
+			w.lock.Lock()
+			stream.remove()
+			w.lock.Unlock()
+
+			stream.fail(err)
+			return
+		}
+
+		logger.Debug("config stream closed, reconnecting",
+			zap.Error(err),
+			zap.Duration("retryInterval", w.retryInterval))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.retryInterval):
+		}
+	}
+}
+
+// Close stops all configuration streams.
+func (w *Watcher) Close() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.closed = true
+
+	for _, stream := range w.buckets {
+		stream.cancel()
+	}
+	if w.pool != nil {
+		w.pool.cancel()
+	}
+}
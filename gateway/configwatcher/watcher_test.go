@@ -0,0 +1,113 @@
+package configwatcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/contrib/cbconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func waitForChange[T any](t *testing.T, sub *Subscription[T]) {
+	select {
+	case <-sub.Changed():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for config change")
+	}
+}
+
+func TestWatcherSharesBucketStream(t *testing.T) {
+	var numStreams atomic.Int32
+	revCh := make(chan int)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pools/default/bs/default" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		numStreams.Add(1)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case rev := <-revCh:
+				_, _ = fmt.Fprintf(w, `{"rev":%d,"name":"default","nodes":[{"hostname":"$HOST:8091"}]}`+"\n\n\n\n", rev)
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer srv.Close()
+
+	watcher := NewWatcher(&WatcherOptions{
+		Logger: zap.NewNop(),
+		Fetcher: cbconfig.NewFetcher(cbconfig.FetcherOptions{
+			Host:   srv.URL,
+			Logger: zap.NewNop(),
+		}),
+	})
+	defer watcher.Close()
+
+	subA := watcher.WatchBucket("default")
+	defer subA.Close()
+
+	revCh <- 1
+	waitForChange(t, subA)
+
+	config, err := subA.Latest()
+	require.NoError(t, err)
+	assert.Equal(t, 1, config.Rev)
+	assert.Equal(t, "127.0.0.1:8091", config.Nodes[0].Hostname)
+
+	// a second subscriber should immediately see the latest config, without
+	// a second stream being opened to the cluster.
+	subB := watcher.WatchBucket("default")
+	defer subB.Close()
+
+	waitForChange(t, subB)
+	config, err = subB.Latest()
+	require.NoError(t, err)
+	assert.Equal(t, 1, config.Rev)
+
+	revCh <- 2
+	waitForChange(t, subA)
+	waitForChange(t, subB)
+
+	config, err = subB.Latest()
+	require.NoError(t, err)
+	assert.Equal(t, 2, config.Rev)
+
+	assert.Equal(t, int32(1), numStreams.Load())
+}
+
+func TestWatcherMissingBucket(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	watcher := NewWatcher(&WatcherOptions{
+		Logger: zap.NewNop(),
+		Fetcher: cbconfig.NewFetcher(cbconfig.FetcherOptions{
+			Host:   srv.URL,
+			Logger: zap.NewNop(),
+		}),
+	})
+	defer watcher.Close()
+
+	sub := watcher.WatchBucket("missing")
+	defer sub.Close()
+
+	waitForChange(t, sub)
+	_, err := sub.Latest()
+	assert.ErrorIs(t, err, cbconfig.ErrNotFound)
+}
@@ -0,0 +1,137 @@
+package credentials
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Provider holds the credentials the gateway uses to authenticate against
+// the cluster.  The credentials may be rotated at runtime, so consumers must
+// fetch them each time they are needed rather than caching them, or watch
+// for changes.
+type Provider struct {
+	current    atomic.Pointer[Credentials]
+	clientCert atomic.Pointer[tls.Certificate]
+
+	lock     sync.Mutex
+	watchers []func(Credentials)
+}
+
+func NewProvider(username, password string) *Provider {
+	p := &Provider{}
+	p.current.Store(&Credentials{
+		Username: username,
+		Password: password,
+	})
+	return p
+}
+
+// Get returns the current username and password.
+func (p *Provider) Get() (string, string) {
+	creds := p.current.Load()
+	return creds.Username, creds.Password
+}
+
+// Update replaces the current credentials, notifying any watchers if they
+// have changed.  It returns whether the credentials changed.
+func (p *Provider) Update(username, password string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	newCreds := Credentials{
+		Username: username,
+		Password: password,
+	}
+	if *p.current.Load() == newCreds {
+		return false
+	}
+
+	p.current.Store(&newCreds)
+
+	// watchers are invoked with the lock held so that they always observe
+	// the updates in the order they were made.
+	for _, watcher := range p.watchers {
+		watcher(newCreds)
+	}
+
+	return true
+}
+
+// Watch registers a function which is invoked whenever the credentials
+// change.  The function must not call back into the provider.
+func (p *Provider) Watch(fn func(Credentials)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.watchers = append(p.watchers, fn)
+}
+
+// SetClientCertificate sets the certificate the gateway presents when
+// connecting to the cluster.  Existing connections are unaffected, new
+// connections use the latest certificate.
+func (p *Provider) SetClientCertificate(cert *tls.Certificate) {
+	p.clientCert.Store(cert)
+}
+
+// ClientCertificate returns the current client certificate, or nil if the
+// gateway does not use one.
+func (p *Provider) ClientCertificate() *tls.Certificate {
+	return p.clientCert.Load()
+}
+
+// GetClientCertificate returns the current client certificate, matching the
+// signature of tls.Config.GetClientCertificate.
+func (p *Provider) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert := p.clientCert.Load()
+	if cert == nil {
+		// an empty certificate indicates that none should be sent
+		return &tls.Certificate{}, nil
+	}
+	return cert, nil
+}
+
+// CertificateOnly returns whether the gateway authenticates using only its
+// client certificate, in which case no basic auth should be sent.
+func (p *Provider) CertificateOnly() bool {
+	return p.clientCert.Load() != nil && p.current.Load().Username == ""
+}
+
+// WrapTransport returns a round tripper which replaces the basic auth of any
+// request with the current credentials, allowing HTTP clients which were
+// configured with fixed credentials to pick up rotations.  When only a client
+// certificate is used, the basic auth is removed instead.
+func (p *Provider) WrapTransport(transport http.RoundTripper) http.RoundTripper {
+	return &roundTripper{
+		provider:  p,
+		transport: transport,
+	}
+}
+
+type roundTripper struct {
+	provider  *Provider
+	transport http.RoundTripper
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// requests without basic auth (for instance those using client
+	// certificates) are left untouched.
+	if _, _, ok := req.BasicAuth(); !ok {
+		return t.transport.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the request it was given.
+	newReq := req.Clone(req.Context())
+	if t.provider.CertificateOnly() {
+		newReq.Header.Del("Authorization")
+	} else {
+		newReq.SetBasicAuth(t.provider.Get())
+	}
+	return t.transport.RoundTrip(newReq)
+}
@@ -0,0 +1,73 @@
+package credentials
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderUpdate(t *testing.T) {
+	provider := NewProvider("user", "pass1")
+
+	var seen []Credentials
+	provider.Watch(func(creds Credentials) {
+		seen = append(seen, creds)
+	})
+
+	assert.False(t, provider.Update("user", "pass1"))
+	assert.True(t, provider.Update("user", "pass2"))
+
+	username, password := provider.Get()
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "pass2", password)
+
+	assert.Equal(t, []Credentials{{Username: "user", Password: "pass2"}}, seen)
+}
+
+func TestProviderWrapTransport(t *testing.T) {
+	var lastUser, lastPass string
+	var lastHasAuth bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastUser, lastPass, lastHasAuth = r.BasicAuth()
+	}))
+	defer srv.Close()
+
+	provider := NewProvider("user", "pass1")
+	client := &http.Client{Transport: provider.WrapTransport(http.DefaultTransport)}
+
+	doRequest := func(withAuth bool) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		if withAuth {
+			req.SetBasicAuth("stale", "stale")
+		}
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	doRequest(true)
+	assert.True(t, lastHasAuth)
+	assert.Equal(t, "user", lastUser)
+	assert.Equal(t, "pass1", lastPass)
+
+	provider.Update("user", "pass2")
+
+	doRequest(true)
+	assert.Equal(t, "pass2", lastPass)
+
+	doRequest(false)
+	assert.False(t, lastHasAuth)
+
+	// with only a client certificate, basic auth is never sent
+	provider.SetClientCertificate(&tls.Certificate{})
+	provider.Update("", "")
+
+	doRequest(true)
+	assert.False(t, lastHasAuth)
+}
@@ -3,7 +3,9 @@ package dapiimpl
 import (
 	"github.com/couchbase/gocbcorex"
 	"github.com/couchbase/stellar-gateway/dataapiv1"
+	"github.com/couchbase/stellar-gateway/gateway/apikeys"
 	"github.com/couchbase/stellar-gateway/gateway/auth"
+	"github.com/couchbase/stellar-gateway/gateway/credentials"
 	"github.com/couchbase/stellar-gateway/gateway/dapiimpl/proxy"
 	"github.com/couchbase/stellar-gateway/gateway/dapiimpl/server_v1"
 	"go.uber.org/zap"
@@ -19,8 +21,9 @@ type NewOptions struct {
 	ProxyBlockAdmin bool
 	Debug           bool
 
-	Username string
-	Password string
+	Credentials *credentials.Provider
+	ApiKeys     *apikeys.Store
+	ApiKeyAdmin *apikeys.AdminVerifier
 }
 
 type Servers struct {
@@ -49,12 +52,13 @@ func New(opts *NewOptions) *Servers {
 			opts.ProxyBlockAdmin,
 			opts.Debug,
 			v1AuthHandler,
-			opts.Username,
-			opts.Password,
+			opts.Credentials,
 		),
 		DataApiV1Server: server_v1.NewDataApiServer(
 			opts.Logger.Named("dapi-serverv1"),
 			v1ErrHandler,
-			v1AuthHandler),
+			v1AuthHandler,
+			opts.ApiKeys,
+			opts.ApiKeyAdmin),
 	}
 }
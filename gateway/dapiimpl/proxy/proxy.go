@@ -21,7 +21,11 @@ import (
 	"github.com/couchbase/gocbcorex/cbauthx"
 	"github.com/couchbase/gocbcorex/contrib/buildversion"
 	"github.com/couchbase/stellar-gateway/gateway/auth"
+	"github.com/couchbase/stellar-gateway/gateway/credentials"
 	"github.com/couchbase/stellar-gateway/gateway/dapiimpl/server_v1"
+	"github.com/couchbase/stellar-gateway/gateway/ratelimiting"
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+	"github.com/couchbase/stellar-gateway/utils/authhdr"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
@@ -57,9 +61,8 @@ type DataApiProxy struct {
 	ttfbMillis     metric.Int64Histogram
 	durationMillis metric.Int64Histogram
 
-	adminUsername string
-	adminPassword string
-	authHander    *server_v1.AuthHandler
+	adminCreds *credentials.Provider
+	authHander *server_v1.AuthHandler
 }
 
 func NewDataApiProxy(
@@ -69,7 +72,7 @@ func NewDataApiProxy(
 	disableAdmin bool,
 	debugMode bool,
 	authHandler *server_v1.AuthHandler,
-	username, password string,
+	adminCreds *credentials.Provider,
 ) *DataApiProxy {
 	mux := http.NewServeMux()
 
@@ -103,8 +106,7 @@ func NewDataApiProxy(
 		numRequests:    numRequests,
 		ttfbMillis:     ttfbMillis,
 		durationMillis: durationMillis,
-		adminUsername:  username,
-		adminPassword:  password,
+		adminCreds:     adminCreds,
 		authHander:     authHandler,
 	}
 
@@ -156,6 +158,18 @@ func (p *DataApiProxy) writeErrorWithStatus(w http.ResponseWriter, err error, ms
 	}
 }
 
+// setGatewayAuth authenticates a request as the gateway itself.  When the
+// gateway uses only a client certificate, which the transport presents, any
+// basic auth the client sent is removed instead.
+func (p *DataApiProxy) setGatewayAuth(req *http.Request) {
+	if p.adminCreds.CertificateOnly() {
+		req.Header.Del("Authorization")
+		return
+	}
+
+	req.SetBasicAuth(p.adminCreds.Get())
+}
+
 func (p *DataApiProxy) proxyService(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -163,6 +177,15 @@ func (p *DataApiProxy) proxyService(
 ) {
 	ctx := r.Context()
 
+	quota, err := ratelimiting.AdmitHttpOperation(ctx, "proxy:"+string(serviceName))
+	if err != nil {
+		p.logger.Debug("rate limited proxy request", zap.Error(err))
+		ratelimiting.SetRateLimitedHeaders(w.Header(), err)
+		p.writeErrorWithStatus(w, err, "rate limit exceeded", 429)
+		return
+	}
+	ratelimiting.SetQuotaHeaders(w.Header(), quota)
+
 	stime := time.Now()
 
 	agent, err := p.cbClient.GetClusterAgent(ctx)
@@ -270,14 +293,77 @@ func (p *DataApiProxy) proxyService(
 	// copy some other details
 	proxyReq.Header = r.Header
 
-	// If no auth header has been given, check for a client cert
+	// Bearer tokens are validated by the gateway and converted into an
+	// on-behalf-of request, unless token auth is disabled in which case the
+	// token is passed through for the cluster to handle.
+	//
+	// authUser is the user the request was authenticated as, which is empty
+	// for requests acting as the gateway without presenting a username.
 	authHdr := proxyReq.Header.Get("Authorization")
+	authenticated := false
+	authUser := ""
+	if token, ok := authhdr.DecodeBearerAuth(authHdr); ok {
+		oboUser, oboDomain, err := p.authHander.Authenticator.ValidateTokenForObo(ctx, token)
+		if errors.Is(err, auth.ErrSingleUserAuthValid) {
+			// the token acts as the gateway itself, so no obo header is sent
+			p.setGatewayAuth(proxyReq)
+			authenticated = true
+		} else if err != nil {
+			if errors.Is(err, auth.ErrInvalidToken) {
+				p.writeErrorWithStatus(w, err, "failed to validate bearer token", 401)
+				return
+			} else if errors.Is(err, auth.ErrTokenRestricted) {
+				p.writeErrorWithStatus(w, err, "api key is not permitted to access this resource", 403)
+				return
+			} else if !errors.Is(err, auth.ErrTokenAuthDisabled) {
+				p.writeError(w, err, "received an unexpected token authentication error")
+				return
+			}
+		} else {
+			oboHdrStr := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", oboUser, oboDomain)))
+			proxyReq.Header.Set("cb-on-behalf-of", oboHdrStr)
+			p.setGatewayAuth(proxyReq)
+			authenticated, authUser = true, oboUser
+		}
+	}
+
+	// Basic credentials are validated by the gateway and converted into an
+	// on-behalf-of request, so that they are subject to lockouts and may be
+	// users the gateway maps to another identity.
+	if username, password, ok := authhdr.DecodeBasicAuth(authHdr); ok {
+		oboUser, oboDomain, err := p.authHander.Authenticator.ValidateUserForObo(ctx, username, password)
+		if errors.Is(err, auth.ErrSingleUserAuthValid) {
+			p.setGatewayAuth(proxyReq)
+			authenticated, authUser = true, username
+		} else if err != nil {
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				p.writeErrorWithStatus(w, err, "invalid username or password", 401)
+				return
+			} else if errors.Is(err, auth.ErrAuthLockedOut) {
+				p.writeErrorWithStatus(w, err, "too many failed authentication attempts, try again later", 429)
+				return
+			}
+
+			p.writeError(w, err, "received an unexpected authentication error")
+			return
+		} else {
+			oboHdrStr := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", oboUser, oboDomain)))
+			proxyReq.Header.Set("cb-on-behalf-of", oboHdrStr)
+			p.setGatewayAuth(proxyReq)
+			authenticated, authUser = true, oboUser
+		}
+	}
+
+	// If no auth header has been given, check for a client cert
 	if authHdr == "" {
 		oboUser, oboDomain, err := p.authHander.Authenticator.ValidateConnStateForObo(ctx, r.TLS)
 		if err != nil {
 			if errors.Is(err, auth.ErrInvalidCertificate) {
 				p.writeError(w, err, "failed to validate certificate")
 				return
+			} else if errors.Is(err, auth.ErrCertificateRevoked) {
+				p.writeErrorWithStatus(w, err, "client certificate has been revoked", 403)
+				return
 			} else if errors.Is(err, cbauthx.ErrNoCert) {
 				p.writeErrorWithStatus(w, err, "authorization header or client cert are required", 401)
 				return
@@ -291,10 +377,22 @@ func (p *DataApiProxy) proxyService(
 		// we set the onbehalf of header to an empty string and use the admin
 		// creds then the server seems to ignore the obo header.
 		if oboUser != "" {
-			// ING-1371 (support CNG -> cluster mtls)
 			oboHdrStr := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", oboUser, oboDomain)))
 			proxyReq.Header.Set("cb-on-behalf-of", oboHdrStr)
-			proxyReq.SetBasicAuth(p.adminUsername, p.adminPassword)
+			p.setGatewayAuth(proxyReq)
+		} else if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			// certificates mapped to the gateway in single user mode act as
+			// the gateway itself.
+			p.setGatewayAuth(proxyReq)
+		}
+		authenticated, authUser = true, oboUser
+	}
+
+	if authenticated {
+		err := reqctx.Authenticated(ctx, authUser)
+		if err != nil {
+			p.writeErrorWithStatus(w, err, "rate limit exceeded", 429)
+			return
 		}
 	}
 
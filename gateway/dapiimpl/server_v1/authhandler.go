@@ -10,6 +10,8 @@ import (
 	"github.com/couchbase/gocbcorex/cbhttpx"
 	"github.com/couchbase/gocbcorex/cbmgmtx"
 	"github.com/couchbase/stellar-gateway/gateway/auth"
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+	"github.com/couchbase/stellar-gateway/utils/authhdr"
 	"go.uber.org/zap"
 )
 
@@ -43,6 +45,11 @@ func (a AuthHandler) MaybeGetUserPassFromRequest(authHdr *string) (string, strin
 		return "", "", nil
 	}
 
+	// bearer tokens are handled by MaybeGetBearerTokenFromRequest
+	if _, ok := authhdr.DecodeBearerAuth(*authHdr); ok {
+		return "", "", nil
+	}
+
 	username, password, err := a.getUserPassFromRequest(*authHdr)
 	if err != nil {
 		return "", "", a.ErrorHandler.NewInvalidAuthHeaderStatus(err)
@@ -51,6 +58,59 @@ func (a AuthHandler) MaybeGetUserPassFromRequest(authHdr *string) (string, strin
 	return username, password, nil
 }
 
+func (a AuthHandler) MaybeGetBearerTokenFromRequest(authHdr *string) string {
+	if authHdr == nil {
+		return ""
+	}
+
+	token, _ := authhdr.DecodeBearerAuth(*authHdr)
+	return token
+}
+
+func (a AuthHandler) validateTokenForObo(ctx context.Context, token string) (string, string, *Status) {
+	oboUser, oboDomain, err := a.Authenticator.ValidateTokenForObo(ctx, token)
+	if err != nil {
+		if errors.Is(err, auth.ErrSingleUserAuthValid) {
+			return "", "", nil
+		}
+
+		if errors.Is(err, auth.ErrInvalidToken) {
+			a.Logger.Debug("bearer token was rejected", zap.Error(err))
+			return "", "", a.ErrorHandler.NewInvalidTokenStatus()
+		} else if errors.Is(err, auth.ErrTokenRestricted) {
+			return "", "", a.ErrorHandler.NewTokenRestrictedStatus()
+		} else if errors.Is(err, auth.ErrTokenAuthDisabled) {
+			return "", "", a.ErrorHandler.NewTokenAuthDisabledStatus()
+		}
+
+		a.Logger.Error("received an unexpected token authentication error", zap.Error(err))
+		return "", "", a.ErrorHandler.NewInternalStatus()
+	}
+
+	return oboUser, oboDomain, nil
+}
+
+func (a AuthHandler) validateUserForObo(ctx context.Context, username, password string) (string, string, *Status) {
+	oboUser, oboDomain, err := a.Authenticator.ValidateUserForObo(ctx, username, password)
+	if err != nil {
+		if errors.Is(err, auth.ErrSingleUserAuthValid) {
+			return "", "", nil
+		}
+
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return "", "", a.ErrorHandler.NewInvalidCredentialsStatus()
+		} else if errors.Is(err, auth.ErrAuthLockedOut) {
+			a.Logger.Debug("refused authentication due to lockout", zap.Error(err))
+			return "", "", a.ErrorHandler.NewAuthLockedOutStatus()
+		}
+
+		a.Logger.Error("received an unexpected authentication error", zap.Error(err))
+		return "", "", a.ErrorHandler.NewInternalStatus()
+	}
+
+	return oboUser, oboDomain, nil
+}
+
 func (a AuthHandler) MaybeGetConnStateFromContext(ctx context.Context) (*tls.ConnectionState, *Status) {
 	connState, ok := ctx.Value(CtxKeyTlsConnState{}).(*tls.ConnectionState)
 	if connState == nil || !ok {
@@ -60,7 +120,43 @@ func (a AuthHandler) MaybeGetConnStateFromContext(ctx context.Context) (*tls.Con
 	return connState, nil
 }
 
+// authenticated notifies the gateway's middleware, such as its rate limiters,
+// of the user a request was authenticated as.  Requests which act as the
+// gateway itself are identified by the username they presented, if any.
+func (a AuthHandler) authenticated(ctx context.Context, authHdr *string, oboUser string) *Status {
+	user := oboUser
+	if user == "" {
+		user, _, _ = a.MaybeGetUserPassFromRequest(authHdr)
+	}
+
+	err := reqctx.Authenticated(ctx, user)
+	if err != nil {
+		a.Logger.Debug("request was rejected once authenticated", zap.Error(err))
+		return a.ErrorHandler.NewRateLimitedStatus()
+	}
+
+	return nil
+}
+
 func (a AuthHandler) MaybeGetOboUserFromContext(ctx context.Context, authHdr *string) (string, string, *Status) {
+	oboUser, oboDomain, errSt := a.maybeGetOboUserFromContext(ctx, authHdr)
+	if errSt != nil {
+		return "", "", errSt
+	}
+
+	errSt = a.authenticated(ctx, authHdr, oboUser)
+	if errSt != nil {
+		return "", "", errSt
+	}
+
+	return oboUser, oboDomain, nil
+}
+
+func (a AuthHandler) maybeGetOboUserFromContext(ctx context.Context, authHdr *string) (string, string, *Status) {
+	if token := a.MaybeGetBearerTokenFromRequest(authHdr); token != "" {
+		return a.validateTokenForObo(ctx, token)
+	}
+
 	username, password, errHe := a.MaybeGetUserPassFromRequest(authHdr)
 	if errHe != nil {
 		return "", "", errHe
@@ -85,6 +181,9 @@ func (a AuthHandler) MaybeGetOboUserFromContext(ctx context.Context, authHdr *st
 		if err != nil {
 			if errors.Is(err, auth.ErrInvalidCertificate) {
 				return "", "", a.ErrorHandler.NewInvalidCertificateStatus()
+			} else if errors.Is(err, auth.ErrCertificateRevoked) {
+				a.Logger.Debug("client certificate was revoked", zap.Error(err))
+				return "", "", a.ErrorHandler.NewCertificateRevokedStatus()
 			}
 
 			a.Logger.Error("received an unexpected cert authentication error", zap.Error(err))
@@ -94,21 +193,7 @@ func (a AuthHandler) MaybeGetOboUserFromContext(ctx context.Context, authHdr *st
 		return oboUser, oboDomain, nil
 	}
 
-	oboUser, oboDomain, err := a.Authenticator.ValidateUserForObo(ctx, username, password)
-	if err != nil {
-		if errors.Is(err, auth.ErrSingleUserAuthValid) {
-			return "", "", nil
-		}
-
-		if errors.Is(err, auth.ErrInvalidCredentials) {
-			return "", "", a.ErrorHandler.NewInvalidCredentialsStatus()
-		}
-
-		a.Logger.Error("received an unexpected authentication error", zap.Error(err))
-		return "", "", a.ErrorHandler.NewInternalStatus()
-	}
-
-	return oboUser, oboDomain, nil
+	return a.validateUserForObo(ctx, username, password)
 }
 
 func (a AuthHandler) GetOboUserFromRequest(ctx context.Context, authHdr *string) (string, string, *Status) {
@@ -121,6 +206,42 @@ func (a AuthHandler) GetOboUserFromRequest(ctx context.Context, authHdr *string)
 }
 
 func (a AuthHandler) GetHttpOboInfoFromContext(ctx context.Context, authHdr string) (*cbhttpx.OnBehalfOfInfo, *Status) {
+	oboInfo, errSt := a.getHttpOboInfoFromContext(ctx, authHdr)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	var oboUser string
+	if oboInfo != nil {
+		oboUser = oboInfo.Username
+	}
+
+	errSt = a.authenticated(ctx, &authHdr, oboUser)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	return oboInfo, nil
+}
+
+func (a AuthHandler) getHttpOboInfoFromContext(ctx context.Context, authHdr string) (*cbhttpx.OnBehalfOfInfo, *Status) {
+	if token := a.MaybeGetBearerTokenFromRequest(&authHdr); token != "" {
+		oboUser, oboDomain, errSt := a.validateTokenForObo(ctx, token)
+		if errSt != nil {
+			return nil, errSt
+		}
+
+		// tokens without a user act as the gateway itself
+		if oboUser == "" {
+			return nil, nil
+		}
+
+		return &cbhttpx.OnBehalfOfInfo{
+			Username: oboUser,
+			Domain:   oboDomain,
+		}, nil
+	}
+
 	username, password, errHe := a.MaybeGetUserPassFromRequest(&authHdr)
 	if errHe != nil {
 		return nil, errHe
@@ -130,9 +251,22 @@ func (a AuthHandler) GetHttpOboInfoFromContext(ctx context.Context, authHdr stri
 		return nil, a.ErrorHandler.NewNoAuthStatus()
 	}
 
+	// credentials are validated by the gateway rather than being passed on to
+	// the cluster, so that they are subject to lockouts and may be users the
+	// gateway maps to another identity.
+	oboUser, oboDomain, errHe := a.validateUserForObo(ctx, username, password)
+	if errHe != nil {
+		return nil, errHe
+	}
+
+	// users without an obo user act as the gateway itself
+	if oboUser == "" {
+		return nil, nil
+	}
+
 	return &cbhttpx.OnBehalfOfInfo{
-		Username: username,
-		Password: password,
+		Username: oboUser,
+		Domain:   oboDomain,
 	}, nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"strconv"
 
 	"github.com/couchbase/stellar-gateway/dataapiv1"
+	"github.com/couchbase/stellar-gateway/gateway/apikeys"
 	"go.uber.org/zap"
 )
 
@@ -11,6 +12,8 @@ type DataApiServer struct {
 	logger       *zap.Logger
 	errorHandler *ErrorHandler
 	authHandler  *AuthHandler
+	apiKeys      *apikeys.Store
+	apiKeyAdmin  *apikeys.AdminVerifier
 }
 
 var _ dataapiv1.StrictServerInterface = &DataApiServer{}
@@ -19,11 +22,15 @@ func NewDataApiServer(
 	logger *zap.Logger,
 	errorHandler *ErrorHandler,
 	authHandler *AuthHandler,
+	apiKeys *apikeys.Store,
+	apiKeyAdmin *apikeys.AdminVerifier,
 ) *DataApiServer {
 	return &DataApiServer{
 		logger:       logger,
 		errorHandler: errorHandler,
 		authHandler:  authHandler,
+		apiKeys:      apiKeys,
+		apiKeyAdmin:  apiKeyAdmin,
 	}
 }
 
@@ -0,0 +1,144 @@
+package server_v1
+
+import (
+	"context"
+	"errors"
+
+	"github.com/couchbase/stellar-gateway/dataapiv1"
+	"github.com/couchbase/stellar-gateway/gateway/apikeys"
+	"github.com/couchbase/stellar-gateway/gateway/auth/lockout"
+)
+
+// checkApiKeyAdmin verifies that the caller presented the gateway's
+// administrator credentials, which are the only credentials permitted to
+// manage api keys.
+func (s *DataApiServer) checkApiKeyAdmin(ctx context.Context, authHdr *string) *Status {
+	if s.apiKeys == nil || s.apiKeyAdmin == nil {
+		return s.errorHandler.NewApiKeysDisabledStatus()
+	}
+
+	if authHdr == nil {
+		return s.errorHandler.NewNoAuthStatus()
+	}
+
+	username, password, errSt := s.authHandler.MaybeGetUserPassFromRequest(authHdr)
+	if errSt != nil {
+		return errSt
+	}
+
+	err := s.apiKeyAdmin.Verify(ctx, username, password)
+	if err != nil {
+		if errors.Is(err, lockout.ErrLockedOut) {
+			return s.errorHandler.NewAuthLockedOutStatus()
+		}
+
+		return s.errorHandler.NewAdminRequiredStatus()
+	}
+
+	return nil
+}
+
+func apiKeyToDapi(key *apikeys.Key) dataapiv1.ApiKey {
+	var name, bucket, scope *string
+	if key.Name != "" {
+		name = &key.Name
+	}
+	if key.Bucket != "" {
+		bucket = &key.Bucket
+	}
+	if key.Scope != "" {
+		scope = &key.Scope
+	}
+
+	return dataapiv1.ApiKey{
+		Id:         key.ID,
+		Name:       name,
+		User:       key.User,
+		Domain:     key.Domain,
+		Bucket:     bucket,
+		Scope:      scope,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+	}
+}
+
+func (s *DataApiServer) ListApiKeys(
+	ctx context.Context, in dataapiv1.ListApiKeysRequestObject,
+) (dataapiv1.ListApiKeysResponseObject, error) {
+	errSt := s.checkApiKeyAdmin(ctx, in.Params.Authorization)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	keys := s.apiKeys.List()
+
+	apiKeys := make([]dataapiv1.ApiKey, len(keys))
+	for i := range keys {
+		apiKeys[i] = apiKeyToDapi(&keys[i])
+	}
+
+	return dataapiv1.ListApiKeys200JSONResponse{
+		ApiKeys: apiKeys,
+	}, nil
+}
+
+func (s *DataApiServer) CreateApiKey(
+	ctx context.Context, in dataapiv1.CreateApiKeyRequestObject,
+) (dataapiv1.CreateApiKeyResponseObject, error) {
+	errSt := s.checkApiKeyAdmin(ctx, in.Params.Authorization)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	opts := &apikeys.CreateOptions{
+		User:      in.Body.User,
+		ExpiresAt: in.Body.ExpiresAt,
+	}
+	if in.Body.Name != nil {
+		opts.Name = *in.Body.Name
+	}
+	if in.Body.Domain != nil {
+		opts.Domain = *in.Body.Domain
+	}
+	if in.Body.Bucket != nil {
+		opts.Bucket = *in.Body.Bucket
+	}
+	if in.Body.Scope != nil {
+		opts.Scope = *in.Body.Scope
+	}
+
+	key, token, err := s.apiKeys.Create(opts)
+	if err != nil {
+		if errors.Is(err, apikeys.ErrInvalidOptions) {
+			return nil, s.errorHandler.NewInvalidApiKeyOptionsStatus(err).Err()
+		}
+
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return dataapiv1.CreateApiKey201JSONResponse{
+		ApiKey: apiKeyToDapi(key),
+		Token:  token,
+	}, nil
+}
+
+func (s *DataApiServer) RevokeApiKey(
+	ctx context.Context, in dataapiv1.RevokeApiKeyRequestObject,
+) (dataapiv1.RevokeApiKeyResponseObject, error) {
+	errSt := s.checkApiKeyAdmin(ctx, in.Params.Authorization)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	err := s.apiKeys.Revoke(in.ApiKeyId)
+	if err != nil {
+		if errors.Is(err, apikeys.ErrKeyNotFound) {
+			return nil, s.errorHandler.NewApiKeyMissingStatus(err, in.ApiKeyId).Err()
+		}
+
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return dataapiv1.RevokeApiKey200Response{}, nil
+}
@@ -139,6 +139,100 @@ func (e ErrorHandler) NewInvalidCertificateStatus() *Status {
 	return st
 }
 
+func (e ErrorHandler) NewCertificateRevokedStatus() *Status {
+	st := &Status{
+		StatusCode: http.StatusForbidden,
+		Code:       dataapiv1.ErrorCodeInvalidAuth,
+		Message:    "Your certificate has been revoked.",
+	}
+	return st
+}
+
+func (e ErrorHandler) NewInvalidTokenStatus() *Status {
+	st := &Status{
+		StatusCode: http.StatusForbidden,
+		Code:       dataapiv1.ErrorCodeInvalidAuth,
+		Message:    "Your bearer token is invalid.",
+	}
+	return st
+}
+
+func (e ErrorHandler) NewAuthLockedOutStatus() *Status {
+	st := &Status{
+		StatusCode: http.StatusTooManyRequests,
+		Code:       dataapiv1.ErrorCodeInvalidAuth,
+		Message:    "Too many failed authentication attempts, try again later.",
+	}
+	return st
+}
+
+// NewRateLimitedStatus is returned when a request is rejected by the rate
+// limits of its user, which describe when to retry in the response headers.
+func (e ErrorHandler) NewRateLimitedStatus() *Status {
+	st := &Status{
+		StatusCode: http.StatusTooManyRequests,
+		Message:    "Rate limit exceeded, try again later.",
+	}
+	return st
+}
+
+func (e ErrorHandler) NewTokenRestrictedStatus() *Status {
+	st := &Status{
+		StatusCode: http.StatusForbidden,
+		Code:       dataapiv1.ErrorCodeUnauthorized,
+		Message:    "Your API key is not permitted to access this resource.",
+	}
+	return st
+}
+
+func (e ErrorHandler) NewAdminRequiredStatus() *Status {
+	st := &Status{
+		StatusCode: http.StatusForbidden,
+		Code:       dataapiv1.ErrorCodeUnauthorized,
+		Message:    "This endpoint requires the gateway's administrator credentials.",
+	}
+	return st
+}
+
+func (e ErrorHandler) NewApiKeysDisabledStatus() *Status {
+	st := &Status{
+		StatusCode: http.StatusNotFound,
+		Code:       dataapiv1.ErrorCodeInvalidArgument,
+		Message:    "API key management is not enabled on the gateway.",
+	}
+	return st
+}
+
+func (e ErrorHandler) NewApiKeyMissingStatus(baseErr error, apiKeyId string) *Status {
+	st := &Status{
+		StatusCode: http.StatusNotFound,
+		Code:       dataapiv1.ErrorCodeApiKeyNotFound,
+		Message:    fmt.Sprintf("API key '%s' not found.", apiKeyId),
+		Resource:   fmt.Sprintf("/admin/apiKeys/%s", apiKeyId),
+	}
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewInvalidApiKeyOptionsStatus(baseErr error) *Status {
+	st := &Status{
+		StatusCode: http.StatusBadRequest,
+		Code:       dataapiv1.ErrorCodeInvalidArgument,
+		Message:    "Invalid API key options.",
+	}
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewTokenAuthDisabledStatus() *Status {
+	st := &Status{
+		StatusCode: http.StatusUnauthorized,
+		Code:       dataapiv1.ErrorCodeUnauthorized,
+		Message:    "Bearer token auth is not enabled on the gateway.",
+	}
+	return st
+}
+
 func (e ErrorHandler) NewInternalStatus() *Status {
 	st := &Status{
 		StatusCode: http.StatusInternalServerError,
@@ -4,7 +4,9 @@ import (
 	"github.com/couchbase/gocbcorex"
 	"github.com/couchbase/gocbcorex/cbmgmtx"
 	"github.com/couchbase/stellar-gateway/gateway/auth"
+	"github.com/couchbase/stellar-gateway/gateway/configwatcher"
 	"github.com/couchbase/stellar-gateway/gateway/dataimpl/server_v1"
+	"github.com/couchbase/stellar-gateway/gateway/transactions"
 	"go.uber.org/zap"
 )
 
@@ -14,10 +16,13 @@ type NewOptions struct {
 	CbClient      *gocbcorex.BucketsTrackingAgentManager
 	Mgmt          *cbmgmtx.Management
 	Authenticator auth.Authenticator
+	TxnManager    *transactions.Manager
+	ConfigWatcher *configwatcher.Watcher
 
 	Debug            bool
 	LocalhostConnstr bool
 	BootstrapNode    string
+	ServerGroup      string
 }
 
 type Servers struct {
@@ -92,6 +97,7 @@ func New(opts *NewOptions) *Servers {
 			opts.Logger.Named("transactions"),
 			v1ErrHandler,
 			v1AuthHandler,
+			opts.TxnManager,
 		),
 		XdcrV1Server: server_v1.NewXdcrServer(
 			opts.Logger.Named("xdcr"),
@@ -103,8 +109,10 @@ func New(opts *NewOptions) *Servers {
 			v1ErrHandler,
 			v1AuthHandler,
 			opts.Mgmt,
+			opts.ConfigWatcher,
 			opts.LocalhostConnstr,
 			opts.BootstrapNode,
+			opts.ServerGroup,
 		),
 	}
 }
@@ -1,8 +1,18 @@
 package server_v1
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/cbanalyticsx"
 	"github.com/couchbase/goprotostellar/genproto/analytics_v1"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type AnalyticsServer struct {
@@ -24,3 +34,174 @@ func NewAnalyticsServer(
 		authHandler:  authHandler,
 	}
 }
+
+func (s *AnalyticsServer) translateError(ctx context.Context, err error) *status.Status {
+	var analyticsErrs *cbanalyticsx.ServerErrors
+	if errors.As(err, &analyticsErrs) {
+		if len(analyticsErrs.Errors) == 0 {
+			return s.errorHandler.NewInternalStatus(ctx)
+		}
+
+		firstErr := analyticsErrs.Errors[0]
+		if errors.Is(firstErr, cbanalyticsx.ErrParsingFailure) ||
+			errors.Is(firstErr, cbanalyticsx.ErrCompilationFailure) {
+			return s.errorHandler.NewInvalidAnalyticsQueryStatus(ctx, err, firstErr.Msg)
+		} else if errors.Is(firstErr, cbanalyticsx.ErrAuthenticationFailure) {
+			return s.errorHandler.NewAnalyticsNoAccessStatus(ctx, err)
+		} else if errors.Is(firstErr, cbanalyticsx.ErrDatasetNotFound) {
+			return s.errorHandler.NewAnalyticsDatasetMissingStatus(ctx, err, firstErr.Msg)
+		} else if errors.Is(firstErr, cbanalyticsx.ErrDataverseNotFound) {
+			return s.errorHandler.NewAnalyticsDataverseMissingStatus(ctx, err, firstErr.Msg)
+		} else if errors.Is(firstErr, cbanalyticsx.ErrJobQueueFull) {
+			return s.errorHandler.NewAnalyticsJobQueueFullStatus(ctx, err)
+		} else if errors.Is(firstErr, cbanalyticsx.ErrTemporaryFailure) {
+			return s.errorHandler.NewUnavailableStatus(ctx, err)
+		}
+	}
+
+	return s.errorHandler.NewGenericStatus(ctx, err)
+}
+
+func (s *AnalyticsServer) AnalyticsQuery(in *analytics_v1.AnalyticsQueryRequest, out analytics_v1.AnalyticsService_AnalyticsQueryServer) error {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(out.Context(), nil)
+	if errSt != nil {
+		return errSt.Err()
+	}
+
+	var opts gocbcorex.AnalyticsOptions
+	opts.OnBehalfOf = oboInfo
+
+	opts.Statement = in.Statement
+
+	if in.AnalyticsScopeName != nil {
+		opts.QueryContext = fmt.Sprintf("default:`%s`", in.GetAnalyticsScopeName())
+	}
+
+	if in.ReadOnly != nil {
+		opts.ReadOnly = *in.ReadOnly
+	}
+
+	if in.Priority != nil && *in.Priority {
+		opts.Priority = -1
+	}
+
+	if in.ClientContextId != nil {
+		opts.ClientContextId = *in.ClientContextId
+	} else {
+		opts.ClientContextId = uuid.NewString()
+	}
+
+	if in.ScanConsistency != nil {
+		scanConsistency, errSt := scanConsistencyToCbanalyticsx(*in.ScanConsistency)
+		if errSt != nil {
+			return errSt.Err()
+		}
+		opts.ScanConsistency = scanConsistency
+	}
+
+	named := in.GetNamedParameters()
+	pos := in.GetPositionalParameters()
+	if len(named) > 0 && len(pos) > 0 {
+		return status.Errorf(codes.InvalidArgument, "named and positional parameters must be used exclusively")
+	}
+	if len(named) > 0 {
+		params := make(map[string]json.RawMessage, len(named))
+		for k, v := range named {
+			params[k] = v
+		}
+		opts.NamedArgs = params
+	}
+	if len(pos) > 0 {
+		params := make([]json.RawMessage, len(pos))
+		for i, p := range pos {
+			params[i] = p
+		}
+		opts.Args = params
+	}
+
+	result, err := agent.Analytics(out.Context(), &opts)
+	if err != nil {
+		return s.translateError(out.Context(), err).Err()
+	}
+
+	var rowCache [][]byte
+	rowCacheNumBytes := 0
+	const MaxRowBytes = 1024
+
+	for result.HasMoreRows() {
+		rowBytes, err := result.ReadRow()
+		if err != nil {
+			return s.translateError(out.Context(), err).Err()
+		}
+
+		rowNumBytes := len(rowBytes)
+
+		if rowCacheNumBytes+rowNumBytes > MaxRowBytes {
+			// adding this row to the cache would exceed its maximum number of
+			// bytes, so we need to evict all these rows...
+			err := out.Send(&analytics_v1.AnalyticsQueryResponse{
+				Rows:     rowCache,
+				MetaData: nil,
+			})
+			if err != nil {
+				return s.errorHandler.NewGenericStatus(out.Context(), err).Err()
+			}
+
+			rowCache = nil
+			rowCacheNumBytes = 0
+		}
+
+		rowCache = append(rowCache, rowBytes)
+		rowCacheNumBytes += rowNumBytes
+	}
+
+	var psMetaData *analytics_v1.AnalyticsQueryResponse_MetaData
+
+	metaData, err := result.MetaData()
+	if err == nil {
+		psMetaData = &analytics_v1.AnalyticsQueryResponse_MetaData{
+			RequestId:       metaData.RequestID,
+			ClientContextId: metaData.ClientContextID,
+			Status:          string(metaData.Status),
+			Metrics: &analytics_v1.AnalyticsQueryResponse_Metrics{
+				ElapsedTime:      durationFromGo(metaData.Metrics.ElapsedTime),
+				ExecutionTime:    durationFromGo(metaData.Metrics.ExecutionTime),
+				ResultCount:      metaData.Metrics.ResultCount,
+				ResultSize:       metaData.Metrics.ResultSize,
+				ErrorCount:       metaData.Metrics.ErrorCount,
+				WarningCount:     metaData.Metrics.WarningCount,
+				ProcessedObjects: metaData.Metrics.ProcessedObjects,
+			},
+		}
+
+		warnings := make([]*analytics_v1.AnalyticsQueryResponse_MetaData_Warning, len(metaData.Warnings))
+		for i, warning := range metaData.Warnings {
+			warnings[i] = &analytics_v1.AnalyticsQueryResponse_MetaData_Warning{
+				Code:    warning.Code,
+				Message: warning.Message,
+			}
+		}
+		psMetaData.Warnings = warnings
+
+		if len(metaData.Signature) > 0 {
+			sig, err := json.Marshal(metaData.Signature)
+			if err == nil {
+				psMetaData.Signature = sig
+			}
+		}
+	}
+
+	// if we have any rows or meta-data left to stream, we send that first
+	// before we process any errors that occurred.
+	if rowCache != nil || psMetaData != nil {
+		err := out.Send(&analytics_v1.AnalyticsQueryResponse{
+			Rows:     rowCache,
+			MetaData: psMetaData,
+		})
+		if err != nil {
+			return s.errorHandler.NewGenericStatus(out.Context(), err).Err()
+		}
+	}
+
+	return nil
+}
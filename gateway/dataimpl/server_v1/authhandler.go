@@ -9,6 +9,7 @@ import (
 	"github.com/couchbase/gocbcorex/cbhttpx"
 	"github.com/couchbase/gocbcorex/cbmgmtx"
 	"github.com/couchbase/stellar-gateway/gateway/auth"
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
 	"github.com/couchbase/stellar-gateway/utils/authhdr"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/credentials"
@@ -24,19 +25,35 @@ type AuthHandler struct {
 	CbClient      *gocbcorex.BucketsTrackingAgentManager
 }
 
-func (a AuthHandler) MaybeGetUserPassFromContext(ctx context.Context) (string, string, *status.Status) {
+func (a AuthHandler) maybeGetAuthHeaderFromContext(ctx context.Context) (string, *status.Status) {
 	authValues := metadata.ValueFromIncomingContext(ctx, "Authorization")
 	if len(authValues) > 1 {
 		a.Logger.Debug("more than a single authorization header was found")
-		return "", "", a.ErrorHandler.NewInvalidAuthHeaderStatus(ctx,
+		return "", a.ErrorHandler.NewInvalidAuthHeaderStatus(ctx,
 			errors.New("more than a single authorization header was found"))
 	}
 
 	if len(authValues) == 0 {
+		return "", nil
+	}
+
+	return authValues[len(authValues)-1], nil
+}
+
+func (a AuthHandler) MaybeGetUserPassFromContext(ctx context.Context) (string, string, *status.Status) {
+	authValue, errSt := a.maybeGetAuthHeaderFromContext(ctx)
+	if errSt != nil {
+		return "", "", errSt
+	}
+
+	if authValue == "" {
 		return "", "", nil
 	}
 
-	authValue := authValues[len(authValues)-1]
+	// bearer tokens are handled by MaybeGetBearerTokenFromContext
+	if _, ok := authhdr.DecodeBearerAuth(authValue); ok {
+		return "", "", nil
+	}
 
 	username, password, ok := authhdr.DecodeBasicAuth(authValue)
 	if !ok {
@@ -48,6 +65,70 @@ func (a AuthHandler) MaybeGetUserPassFromContext(ctx context.Context) (string, s
 	return username, password, nil
 }
 
+func (a AuthHandler) MaybeGetBearerTokenFromContext(ctx context.Context) (string, *status.Status) {
+	authValue, errSt := a.maybeGetAuthHeaderFromContext(ctx)
+	if errSt != nil {
+		return "", errSt
+	}
+
+	if authValue == "" {
+		// api keys may alternatively be passed in their own header
+		apiKeys := metadata.ValueFromIncomingContext(ctx, "X-API-Key")
+		if len(apiKeys) > 0 {
+			return apiKeys[len(apiKeys)-1], nil
+		}
+
+		return "", nil
+	}
+
+	token, _ := authhdr.DecodeBearerAuth(authValue)
+	return token, nil
+}
+
+func (a AuthHandler) validateTokenForObo(ctx context.Context, token string) (string, string, *status.Status) {
+	oboUser, oboDomain, err := a.Authenticator.ValidateTokenForObo(ctx, token)
+	if err != nil {
+		if errors.Is(err, auth.ErrSingleUserAuthValid) {
+			return "", "", nil
+		}
+
+		if errors.Is(err, auth.ErrInvalidToken) {
+			a.Logger.Debug("bearer token was rejected", zap.Error(err))
+			return "", "", a.ErrorHandler.NewInvalidTokenStatus(ctx)
+		} else if errors.Is(err, auth.ErrTokenRestricted) {
+			return "", "", a.ErrorHandler.NewTokenRestrictedStatus(ctx)
+		} else if errors.Is(err, auth.ErrTokenAuthDisabled) {
+			return "", "", a.ErrorHandler.NewTokenAuthDisabledStatus(ctx)
+		}
+
+		a.Logger.Error("received an unexpected token authentication error", zap.Error(err))
+		return "", "", a.ErrorHandler.NewInternalStatus(ctx)
+	}
+
+	return oboUser, oboDomain, nil
+}
+
+func (a AuthHandler) validateUserForObo(ctx context.Context, username, password string) (string, string, *status.Status) {
+	oboUser, oboDomain, err := a.Authenticator.ValidateUserForObo(ctx, username, password)
+	if err != nil {
+		if errors.Is(err, auth.ErrSingleUserAuthValid) {
+			return "", "", nil
+		}
+
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return "", "", a.ErrorHandler.NewInvalidCredentialsStatus(ctx)
+		} else if errors.Is(err, auth.ErrAuthLockedOut) {
+			a.Logger.Debug("refused authentication due to lockout", zap.Error(err))
+			return "", "", a.ErrorHandler.NewAuthLockedOutStatus(ctx)
+		}
+
+		a.Logger.Error("received an unexpected authentication error", zap.Error(err))
+		return "", "", a.ErrorHandler.NewInternalStatus(ctx)
+	}
+
+	return oboUser, oboDomain, nil
+}
+
 func (a AuthHandler) MaybeGetConnStateFromContext(ctx context.Context) (*tls.ConnectionState, *status.Status) {
 	p, ok := peer.FromContext(ctx)
 	if !ok {
@@ -63,7 +144,48 @@ func (a AuthHandler) MaybeGetConnStateFromContext(ctx context.Context) (*tls.Con
 	return &tlsInfo.State, nil
 }
 
+// authenticated notifies the gateway's middleware, such as its rate limiters,
+// of the user a request was authenticated as.  Requests which act as the
+// gateway itself are identified by the username they presented, if any.
+func (a AuthHandler) authenticated(ctx context.Context, oboUser string) *status.Status {
+	user := oboUser
+	if user == "" {
+		user, _, _ = a.MaybeGetUserPassFromContext(ctx)
+	}
+
+	err := reqctx.Authenticated(ctx, user)
+	if err != nil {
+		// the middleware rejecting the request describes why in its status
+		return status.Convert(err)
+	}
+
+	return nil
+}
+
 func (a AuthHandler) MaybeGetOboUserFromContext(ctx context.Context) (string, string, *status.Status) {
+	oboUser, oboDomain, errSt := a.maybeGetOboUserFromContext(ctx)
+	if errSt != nil {
+		return "", "", errSt
+	}
+
+	errSt = a.authenticated(ctx, oboUser)
+	if errSt != nil {
+		return "", "", errSt
+	}
+
+	return oboUser, oboDomain, nil
+}
+
+func (a AuthHandler) maybeGetOboUserFromContext(ctx context.Context) (string, string, *status.Status) {
+	token, errSt := a.MaybeGetBearerTokenFromContext(ctx)
+	if errSt != nil {
+		return "", "", errSt
+	}
+
+	if token != "" {
+		return a.validateTokenForObo(ctx, token)
+	}
+
 	username, password, errSt := a.MaybeGetUserPassFromContext(ctx)
 	if errSt != nil {
 		return "", "", errSt
@@ -88,6 +210,9 @@ func (a AuthHandler) MaybeGetOboUserFromContext(ctx context.Context) (string, st
 		if err != nil {
 			if errors.Is(err, auth.ErrInvalidCertificate) {
 				return "", "", a.ErrorHandler.NewInvalidCertificateStatus(ctx)
+			} else if errors.Is(err, auth.ErrCertificateRevoked) {
+				a.Logger.Debug("client certificate was revoked", zap.Error(err))
+				return "", "", a.ErrorHandler.NewCertificateRevokedStatus(ctx)
 			} else if errors.Is(err, auth.ErrCertAuthDisabled) {
 				return "", "", a.ErrorHandler.NewCertAuthDisabledStatus(ctx)
 			}
@@ -99,21 +224,7 @@ func (a AuthHandler) MaybeGetOboUserFromContext(ctx context.Context) (string, st
 		return oboUser, oboDomain, nil
 	}
 
-	oboUser, oboDomain, err := a.Authenticator.ValidateUserForObo(ctx, username, password)
-	if err != nil {
-		if errors.Is(err, auth.ErrSingleUserAuthValid) {
-			return "", "", nil
-		}
-
-		if errors.Is(err, auth.ErrInvalidCredentials) {
-			return "", "", a.ErrorHandler.NewInvalidCredentialsStatus(ctx)
-		}
-
-		a.Logger.Error("received an unexpected authentication error", zap.Error(err))
-		return "", "", a.ErrorHandler.NewInternalStatus(ctx)
-	}
-
-	return oboUser, oboDomain, nil
+	return a.validateUserForObo(ctx, username, password)
 }
 
 func (a AuthHandler) GetOboUserFromContext(ctx context.Context) (string, string, *status.Status) {
@@ -126,6 +237,47 @@ func (a AuthHandler) GetOboUserFromContext(ctx context.Context) (string, string,
 }
 
 func (a AuthHandler) GetHttpOboInfoFromContext(ctx context.Context) (*cbhttpx.OnBehalfOfInfo, *status.Status) {
+	oboInfo, errSt := a.getHttpOboInfoFromContext(ctx)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	var oboUser string
+	if oboInfo != nil {
+		oboUser = oboInfo.Username
+	}
+
+	errSt = a.authenticated(ctx, oboUser)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	return oboInfo, nil
+}
+
+func (a AuthHandler) getHttpOboInfoFromContext(ctx context.Context) (*cbhttpx.OnBehalfOfInfo, *status.Status) {
+	token, errSt := a.MaybeGetBearerTokenFromContext(ctx)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	if token != "" {
+		oboUser, oboDomain, errSt := a.validateTokenForObo(ctx, token)
+		if errSt != nil {
+			return nil, errSt
+		}
+
+		// tokens without a user act as the gateway itself
+		if oboUser == "" {
+			return nil, nil
+		}
+
+		return &cbhttpx.OnBehalfOfInfo{
+			Username: oboUser,
+			Domain:   oboDomain,
+		}, nil
+	}
+
 	username, password, errSt := a.MaybeGetUserPassFromContext(ctx)
 	if errSt != nil {
 		return nil, errSt
@@ -150,21 +302,42 @@ func (a AuthHandler) GetHttpOboInfoFromContext(ctx context.Context) (*cbhttpx.On
 		if err != nil {
 			if errors.Is(err, auth.ErrInvalidCertificate) {
 				return nil, a.ErrorHandler.NewInvalidCertificateStatus(ctx)
+			} else if errors.Is(err, auth.ErrCertificateRevoked) {
+				a.Logger.Debug("client certificate was revoked", zap.Error(err))
+				return nil, a.ErrorHandler.NewCertificateRevokedStatus(ctx)
 			}
 
 			a.Logger.Error("received an unexpected cert authentication error", zap.Error(err))
 			return nil, a.ErrorHandler.NewInternalStatus(ctx)
 		}
 
+		// certificates mapped to the gateway act as the gateway itself
+		if oboUser == "" {
+			return nil, nil
+		}
+
 		return &cbhttpx.OnBehalfOfInfo{
 			Username: oboUser,
 			Domain:   oboDomain,
 		}, nil
 	}
 
+	// credentials are validated by the gateway rather than being passed on to
+	// the cluster, so that they are subject to lockouts and may be users the
+	// gateway maps to another identity.
+	oboUser, oboDomain, errSt := a.validateUserForObo(ctx, username, password)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	// users without an obo user act as the gateway itself
+	if oboUser == "" {
+		return nil, nil
+	}
+
 	return &cbhttpx.OnBehalfOfInfo{
-		Username: username,
-		Password: password,
+		Username: oboUser,
+		Domain:   oboDomain,
 	}, nil
 }
 
@@ -9,6 +9,7 @@ import (
 
 	"google.golang.org/grpc/metadata"
 
+	"github.com/couchbase/gocbcorex/cbanalyticsx"
 	"github.com/couchbase/gocbcorex/cbmgmtx"
 	"github.com/couchbase/gocbcorex/cbqueryx"
 	"github.com/couchbase/gocbcorex/cbsearchx"
@@ -115,6 +116,19 @@ func (e ErrorHandler) NewUnknownStatus(ctx context.Context, baseErr error) *stat
 		return st
 	}
 
+	var analyticsErr *cbanalyticsx.ServerErrors
+	if errors.As(baseErr, &analyticsErr) {
+		var analyticsErrDescs []string
+		for _, analyticsSubErr := range analyticsErr.Errors {
+			analyticsErrDescs = append(analyticsErrDescs, fmt.Sprintf("%d - %s", analyticsSubErr.Code, analyticsSubErr.Msg))
+		}
+
+		st := e.newStatus(ctx, codes.Unknown,
+			fmt.Sprintf("An unknown analytics error occurred (descs: %s).", strings.Join(analyticsErrDescs, "; ")))
+		st = e.tryAttachExtraContext(st, baseErr)
+		return st
+	}
+
 	var searchErr *cbsearchx.ServerError
 	if errors.As(baseErr, &searchErr) {
 		st := e.newStatus(ctx, codes.Unknown,
@@ -213,6 +227,13 @@ func (e ErrorHandler) NewBucketAccessDeniedStatus(ctx context.Context, baseErr e
 	return st
 }
 
+func (e ErrorHandler) NewClusterAccessDeniedStatus(ctx context.Context, baseErr error) *status.Status {
+	msg := "No permissions to read the cluster configuration."
+	st := e.newStatus(ctx, codes.PermissionDenied, msg)
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
 func (e ErrorHandler) NewCollectionInvalidArgStatus(ctx context.Context, baseErr error, msg string, bucket, scope, collection string) *status.Status {
 	if msg == "" {
 		msg = "invalid argument"
@@ -625,6 +646,80 @@ func (e ErrorHandler) NewDocConflictStatus(ctx context.Context, baseErr error, b
 	return st
 }
 
+func (e ErrorHandler) NewTransactionMissingStatus(ctx context.Context, baseErr error, transactionId string) *status.Status {
+	st := e.newStatus(ctx, codes.NotFound,
+		fmt.Sprintf("Transaction '%s' was not found, it may have expired or been started on another gateway.",
+			transactionId))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "transaction",
+		ResourceName: transactionId,
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewTransactionAttemptMismatchStatus(ctx context.Context, baseErr error, transactionId, attemptId string) *status.Status {
+	st := e.newStatus(ctx, codes.FailedPrecondition,
+		fmt.Sprintf("Attempt '%s' is not the active attempt of transaction '%s'.",
+			attemptId, transactionId))
+	st = e.tryAttachStatusDetails(st, &epb.PreconditionFailure{
+		Violations: []*epb.PreconditionFailure_Violation{{
+			Type:        "ATTEMPT_MISMATCH",
+			Subject:     "transaction/" + transactionId,
+			Description: "",
+		}},
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewTransactionBucketMismatchStatus(ctx context.Context, baseErr error, transactionId, bucketName string) *status.Status {
+	st := e.newStatus(ctx, codes.InvalidArgument,
+		fmt.Sprintf("Transaction '%s' was not started against bucket '%s'.",
+			transactionId, bucketName))
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewTransactionDocNotReadStatus(ctx context.Context, baseErr error, bucketName, scopeName, collectionName, docId string) *status.Status {
+	st := e.newStatus(ctx, codes.FailedPrecondition,
+		fmt.Sprintf("Document '%s' in '%s/%s/%s' must be read within the transaction before it can be modified.",
+			docId, bucketName, scopeName, collectionName))
+	st = e.tryAttachStatusDetails(st, &epb.PreconditionFailure{
+		Violations: []*epb.PreconditionFailure_Violation{{
+			Type:        "DOC_NOT_READ",
+			Subject:     fmt.Sprintf("document/%s/%s/%s/%s", bucketName, scopeName, collectionName, docId),
+			Description: "",
+		}},
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewTransactionExpiredStatus(ctx context.Context, baseErr error, transactionId string) *status.Status {
+	st := e.newStatus(ctx, codes.Aborted,
+		fmt.Sprintf("Transaction '%s' expired before it could be completed.", transactionId))
+	st = e.tryAttachStatusDetails(st, &epb.ErrorInfo{
+		Reason: "TRANSACTION_EXPIRED",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewTransactionAttemptFailedStatus(ctx context.Context, baseErr error, transactionId string, shouldRetry bool) *status.Status {
+	st := e.newStatus(ctx, codes.Aborted,
+		fmt.Sprintf("The current attempt of transaction '%s' failed.", transactionId))
+	st = e.tryAttachStatusDetails(st, &epb.ErrorInfo{
+		Reason: "TRANSACTION_ATTEMPT_FAILED",
+		Metadata: map[string]string{
+			"retry": fmt.Sprintf("%t", shouldRetry),
+		},
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
 func (e ErrorHandler) NewZeroCasStatus(ctx context.Context, casField string) *status.Status {
 	var st *status.Status
 	if casField == "" {
@@ -1026,6 +1121,16 @@ func (e ErrorHandler) NewInvalidCertificateStatus(ctx context.Context) *status.S
 	return st
 }
 
+func (e ErrorHandler) NewCertificateRevokedStatus(ctx context.Context) *status.Status {
+	st := e.newStatus(ctx, codes.PermissionDenied, "Your certificate has been revoked.")
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "user",
+		ResourceName: "",
+		Description:  "",
+	})
+	return st
+}
+
 func (e ErrorHandler) NewCertAuthDisabledStatus(ctx context.Context) *status.Status {
 	st := e.newStatus(ctx, codes.Unauthenticated, "Client cert auth disabled on the cluster.")
 	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
@@ -1036,6 +1141,31 @@ func (e ErrorHandler) NewCertAuthDisabledStatus(ctx context.Context) *status.Sta
 	return st
 }
 
+func (e ErrorHandler) NewInvalidTokenStatus(ctx context.Context) *status.Status {
+	st := e.newStatus(ctx, codes.PermissionDenied, "Your bearer token is invalid.")
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "user",
+		ResourceName: "",
+		Description:  "",
+	})
+	return st
+}
+
+func (e ErrorHandler) NewAuthLockedOutStatus(ctx context.Context) *status.Status {
+	st := e.newStatus(ctx, codes.ResourceExhausted, "Too many failed authentication attempts, try again later.")
+	return st
+}
+
+func (e ErrorHandler) NewTokenRestrictedStatus(ctx context.Context) *status.Status {
+	st := e.newStatus(ctx, codes.PermissionDenied, "Your API key is not permitted to access this resource.")
+	return st
+}
+
+func (e ErrorHandler) NewTokenAuthDisabledStatus(ctx context.Context) *status.Status {
+	st := e.newStatus(ctx, codes.Unauthenticated, "Bearer token auth is not enabled on the gateway.")
+	return st
+}
+
 func (e ErrorHandler) NewUnexpectedAuthTypeStatus(ctx context.Context) *status.Status {
 	st := e.newStatus(ctx, codes.InvalidArgument, "Unexpected auth type.")
 	return st
@@ -1067,6 +1197,56 @@ func (e ErrorHandler) NewQueryNoAccessStatus(ctx context.Context, baseErr error)
 	return st
 }
 
+func (e ErrorHandler) NewInvalidAnalyticsQueryStatus(ctx context.Context, baseErr error, analyticsErrStr string) *status.Status {
+	st := e.newStatus(ctx, codes.InvalidArgument,
+		fmt.Sprintf("Analytics query compilation failed: %s", analyticsErrStr))
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewAnalyticsNoAccessStatus(ctx context.Context, baseErr error) *status.Status {
+	st := e.newStatus(ctx, codes.PermissionDenied,
+		"No permissions to perform analytics queries.")
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "user",
+		ResourceName: "",
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewAnalyticsDatasetMissingStatus(ctx context.Context, baseErr error, analyticsErrStr string) *status.Status {
+	st := e.newStatus(ctx, codes.NotFound,
+		fmt.Sprintf("Analytics dataset was not found: %s", analyticsErrStr))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "dataset",
+		ResourceName: "",
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewAnalyticsDataverseMissingStatus(ctx context.Context, baseErr error, analyticsErrStr string) *status.Status {
+	st := e.newStatus(ctx, codes.NotFound,
+		fmt.Sprintf("Analytics dataverse was not found: %s", analyticsErrStr))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "dataverse",
+		ResourceName: "",
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewAnalyticsJobQueueFullStatus(ctx context.Context, baseErr error) *status.Status {
+	st := e.newStatus(ctx, codes.ResourceExhausted,
+		"The analytics job queue is full.")
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
 func (e ErrorHandler) NewNeedIndexFieldsStatus(ctx context.Context) *status.Status {
 	st := e.newStatus(ctx, codes.InvalidArgument,
 		"You must specify fields when creating a new index.")
@@ -1130,10 +1310,3 @@ func (e ErrorHandler) NewUnimplementedServerVersionStatus(ctx context.Context) *
 		"The requested feature is not available on this server version.")
 	return st
 }
-
-func (e ErrorHandler) NewServerRoutingUnimplementedError(ctx context.Context) *status.Status {
-	st := e.newStatus(ctx,
-		codes.Unimplemented,
-		"Bucket name required because Cluster-level server routing is not implemented")
-	return st
-}
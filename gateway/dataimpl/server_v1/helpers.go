@@ -6,12 +6,14 @@ import (
 	"time"
 
 	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/cbanalyticsx"
 	"github.com/couchbase/gocbcorex/cbmgmtx"
 	"github.com/couchbase/gocbcorex/cbqueryx"
 	"github.com/couchbase/gocbcorex/cbsearchx"
 	"github.com/couchbase/gocbcorex/memdx"
 	"github.com/couchbase/goprotostellar/genproto/admin_bucket_v1"
 	"github.com/couchbase/goprotostellar/genproto/admin_query_v1"
+	"github.com/couchbase/goprotostellar/genproto/analytics_v1"
 	"github.com/couchbase/goprotostellar/genproto/kv_v1"
 	"github.com/couchbase/goprotostellar/genproto/query_v1"
 	"github.com/couchbase/goprotostellar/genproto/search_v1"
@@ -286,6 +288,17 @@ func scanConsistencyToCbqueryx(t query_v1.QueryRequest_ScanConsistency) (cbquery
 	return cbqueryx.ScanConsistency(""), status.New(codes.InvalidArgument, "invalid scan consistency option specified")
 }
 
+func scanConsistencyToCbanalyticsx(t analytics_v1.AnalyticsQueryRequest_ScanConsistency) (cbanalyticsx.ScanConsistency, *status.Status) {
+	switch t {
+	case analytics_v1.AnalyticsQueryRequest_SCAN_CONSISTENCY_NOT_BOUNDED:
+		return cbanalyticsx.ScanConsistencyNotBounded, nil
+	case analytics_v1.AnalyticsQueryRequest_SCAN_CONSISTENCY_REQUEST_PLUS:
+		return cbanalyticsx.ScanConsistencyRequestPlus, nil
+	}
+
+	return cbanalyticsx.ScanConsistency(""), status.New(codes.InvalidArgument, "invalid scan consistency option specified")
+}
+
 func durabilityLevelToCbqueryx(t query_v1.QueryRequest_DurabilityLevel) (cbqueryx.DurabilityLevel, *status.Status) {
 	switch t {
 	case query_v1.QueryRequest_DURABILITY_LEVEL_NONE:
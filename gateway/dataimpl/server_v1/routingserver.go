@@ -2,21 +2,25 @@ package server_v1
 
 import (
 	"errors"
-	"time"
+	"slices"
 
+	"github.com/couchbase/gocbcorex/cbhttpx"
 	"github.com/couchbase/gocbcorex/cbmgmtx"
-	"github.com/couchbase/gocbcorex/contrib/cbconfig"
 	"github.com/couchbase/goprotostellar/genproto/routing_v2"
+	"github.com/couchbase/stellar-gateway/contrib/cbconfig"
+	"github.com/couchbase/stellar-gateway/gateway/configwatcher"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
 )
 
 type RoutingServer struct {
 	routing_v2.UnimplementedRoutingServiceServer
 
-	logger       *zap.Logger
-	errorHandler *ErrorHandler
-	authHandler  *AuthHandler
-	mgmt         *cbmgmtx.Management
+	logger        *zap.Logger
+	errorHandler  *ErrorHandler
+	authHandler   *AuthHandler
+	mgmt          *cbmgmtx.Management
+	configWatcher *configwatcher.Watcher
 
 	bootstrapNodeIsLocal bool
 
@@ -24,6 +28,10 @@ type RoutingServer struct {
 	// use thisNode because that just depends on the node we happen to get the
 	// bucket config from
 	bootstrapHost string
+
+	// The server group this gateway is deployed within, used when the
+	// bootstrap node is not local and we cannot infer it from the cluster.
+	serverGroup string
 }
 
 func NewRoutingServer(
@@ -31,8 +39,10 @@ func NewRoutingServer(
 	errorHandler *ErrorHandler,
 	authHandler *AuthHandler,
 	mgmt *cbmgmtx.Management,
+	configWatcher *configwatcher.Watcher,
 	bootstrapNodeIsLocal bool,
 	bootsrapHost string,
+	serverGroup string,
 ) *RoutingServer {
 	mgmt.UserAgent = "routing-server"
 
@@ -41,8 +51,10 @@ func NewRoutingServer(
 		errorHandler:         errorHandler,
 		authHandler:          authHandler,
 		mgmt:                 mgmt,
+		configWatcher:        configWatcher,
 		bootstrapNodeIsLocal: bootstrapNodeIsLocal,
 		bootstrapHost:        bootsrapHost,
+		serverGroup:          serverGroup,
 	}
 }
 
@@ -50,66 +62,176 @@ func (s *RoutingServer) WatchRouting(
 	in *routing_v2.WatchRoutingRequest,
 	out routing_v2.RoutingService_WatchRoutingServer,
 ) error {
-	var bucketName string
-	if in.BucketName == nil {
-		// ING-1357 (support non-kv optimised routing)
-		return s.errorHandler.NewServerRoutingUnimplementedError(out.Context()).Err()
-	} else {
-		bucketName = *in.BucketName
-	}
-
 	_, oboInfo, errSt := s.authHandler.GetHttpOboAgent(out.Context(), nil)
 	if errSt != nil {
 		return errSt.Err()
 	}
 
-	ticker := time.NewTicker(5 * time.Second)
-	var prevVBucketMap cbconfig.VBucketServerMapJson
+	if in.BucketName == nil {
+		return s.watchServerRouting(out, oboInfo)
+	}
+
+	return s.watchBucketRouting(*in.BucketName, out, oboInfo)
+}
+
+func (s *RoutingServer) watchBucketRouting(
+	bucketName string,
+	out routing_v2.RoutingService_WatchRoutingServer,
+	oboInfo *cbhttpx.OnBehalfOfInfo,
+) error {
+	// the shared config stream is established using the gateways own
+	// credentials, so we first confirm that the user has access to the bucket.
+	_, err := s.mgmt.GetTerseBucketConfig(out.Context(), &cbmgmtx.GetTerseBucketConfigOptions{
+		BucketName: bucketName,
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		if errors.Is(err, cbmgmtx.ErrBucketNotFound) {
+			return s.errorHandler.NewBucketMissingStatus(out.Context(), err, bucketName).Err()
+		}
+
+		return s.errorHandler.NewGenericStatus(out.Context(), err).Err()
+	}
+
+	sub := s.configWatcher.WatchBucket(bucketName)
+	defer sub.Close()
+
+	var prevResp *routing_v2.WatchRoutingResponse
 	for {
-		bucket, err := s.mgmt.GetTerseBucketConfig(out.Context(), &cbmgmtx.GetTerseBucketConfigOptions{
-			BucketName: bucketName,
-			OnBehalfOf: oboInfo,
-		})
+		select {
+		case <-out.Context().Done():
+			return nil
+		case <-sub.Changed():
+		}
 
+		bucket, err := sub.Latest()
 		if err != nil {
-			if errors.Is(err, cbmgmtx.ErrBucketNotFound) {
+			if errors.Is(err, cbconfig.ErrNotFound) {
 				return s.errorHandler.NewBucketMissingStatus(out.Context(), err, bucketName).Err()
 			}
 
 			return s.errorHandler.NewGenericStatus(out.Context(), err).Err()
 		}
 
-		if !prevVBucketMap.Equal(*bucket.VBucketServerMap) {
-			prevVBucketMap = *bucket.VBucketServerMap
+		if bucket.VBucketServerMap == nil {
+			continue
+		}
 
-			var localVBuckets []uint32
-			for i, node := range bucket.Nodes {
-				if node.Hostname == s.bootstrapHost && s.bootstrapNodeIsLocal {
-					localVBuckets = vBucketIdsForServer(i, bucket.VBucketServerMap.VBucketMap)
-				}
+		var localVBuckets []uint32
+		for i, node := range bucket.Nodes {
+			if node.Hostname == s.bootstrapHost && s.bootstrapNodeIsLocal {
+				localVBuckets = vBucketIdsForServer(i, bucket.VBucketServerMap.VBucketMap)
 			}
+		}
 
-			resp := &routing_v2.WatchRoutingResponse{
-				VbucketDataRouting: &routing_v2.VbucketRouting{
-					NumVbuckets:   uint32(len(bucket.VBucketServerMap.VBucketMap)),
-					LocalVbuckets: localVBuckets,
-				},
-			}
+		resp := &routing_v2.WatchRoutingResponse{
+			VbucketDataRouting: &routing_v2.VbucketRouting{
+				NumVbuckets:   uint32(len(bucket.VBucketServerMap.VBucketMap)),
+				LocalVbuckets: localVBuckets,
+			},
+		}
+
+		if !proto.Equal(resp, prevResp) {
+			prevResp = resp
 
 			err = out.Send(resp)
 			if err != nil {
 				return err
 			}
 		}
+	}
+}
+
+func (s *RoutingServer) watchServerRouting(
+	out routing_v2.RoutingService_WatchRoutingServer,
+	oboInfo *cbhttpx.OnBehalfOfInfo,
+) error {
+	// the shared config stream is established using the gateways own
+	// credentials, so we first confirm that the user can read the cluster
+	// configuration.
+	_, err := s.mgmt.GetClusterConfig(out.Context(), &cbmgmtx.GetClusterConfigOptions{
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		if errors.Is(err, cbmgmtx.ErrAccessDenied) {
+			return s.errorHandler.NewClusterAccessDeniedStatus(out.Context(), err).Err()
+		}
 
+		return s.errorHandler.NewGenericStatus(out.Context(), err).Err()
+	}
+
+	sub := s.configWatcher.WatchPool()
+	defer sub.Close()
+
+	var prevResp *routing_v2.WatchRoutingResponse
+	for {
 		select {
 		case <-out.Context().Done():
 			return nil
-		case <-ticker.C:
+		case <-sub.Changed():
+		}
+
+		pool, err := sub.Latest()
+		if err != nil {
+			return s.errorHandler.NewGenericStatus(out.Context(), err).Err()
+		}
+
+		resp := s.buildServerRouting(pool.Nodes)
+		if !proto.Equal(resp, prevResp) {
+			prevResp = resp
+
+			err = out.Send(resp)
+			if err != nil {
+				return err
+			}
 		}
 	}
 }
 
+// buildServerRouting generates the routing information for the non-kv
+// services, indicating how many nodes running each service are co-located
+// with this gateway, or are within the same server group as it.
+func (s *RoutingServer) buildServerRouting(nodes []cbconfig.FullNodeJson) *routing_v2.WatchRoutingResponse {
+	localGroup := s.serverGroup
+	isLocalNode := func(node *cbconfig.FullNodeJson) bool {
+		return s.bootstrapNodeIsLocal && node.Hostname == s.bootstrapHost
+	}
+
+	// if we are co-located with the bootstrap node, its server group is
+	// authoritative over whatever we were configured with.
+	for i := range nodes {
+		if isLocalNode(&nodes[i]) && nodes[i].ServerGroup != "" {
+			localGroup = nodes[i].ServerGroup
+		}
+	}
+
+	serverRoutingFor := func(serviceName string) *routing_v2.ServerRouting {
+		routing := &routing_v2.ServerRouting{}
+		for i := range nodes {
+			node := &nodes[i]
+			if serviceName != "" && !slices.Contains(node.Services, serviceName) {
+				continue
+			}
+
+			if isLocalNode(node) {
+				routing.NumLocalServers++
+			}
+			if localGroup != "" && node.ServerGroup == localGroup {
+				routing.NumGroupServers++
+			}
+		}
+		return routing
+	}
+
+	return &routing_v2.WatchRoutingResponse{
+		ServerRouting:    serverRoutingFor(""),
+		ViewsRouting:     serverRoutingFor("kv"),
+		QueryRouting:     serverRoutingFor("n1ql"),
+		SearchRouting:    serverRoutingFor("fts"),
+		AnalyticsRouting: serverRoutingFor("cbas"),
+	}
+}
+
 func vBucketIdsForServer(serverIndex int, vbMap [][]int) []uint32 {
 	var vbIds []uint32
 	for i, vBucket := range vbMap {
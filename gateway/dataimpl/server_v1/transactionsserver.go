@@ -1,8 +1,15 @@
 package server_v1
 
 import (
+	"context"
+	"errors"
+
+	"github.com/couchbase/gocbcorex/memdx"
+	"github.com/couchbase/gocbcorex/transactionsx"
 	"github.com/couchbase/goprotostellar/genproto/transactions_v1"
+	"github.com/couchbase/stellar-gateway/gateway/transactions"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
 )
 
 type TransactionsServer struct {
@@ -11,16 +18,213 @@ type TransactionsServer struct {
 	logger       *zap.Logger
 	errorHandler *ErrorHandler
 	authHandler  *AuthHandler
+	txnManager   *transactions.Manager
 }
 
 func NewTransactionsServer(
 	logger *zap.Logger,
 	errorHandler *ErrorHandler,
 	authHandler *AuthHandler,
+	txnManager *transactions.Manager,
 ) *TransactionsServer {
 	return &TransactionsServer{
 		logger:       logger,
 		errorHandler: errorHandler,
 		authHandler:  authHandler,
+		txnManager:   txnManager,
 	}
 }
+
+func (s *TransactionsServer) translateTxnError(
+	ctx context.Context,
+	err error,
+	bucketName, transactionId, attemptId string,
+) *status.Status {
+	if errors.Is(err, transactions.ErrTransactionNotFound) {
+		return s.errorHandler.NewTransactionMissingStatus(ctx, err, transactionId)
+	} else if errors.Is(err, transactions.ErrAttemptMismatch) {
+		return s.errorHandler.NewTransactionAttemptMismatchStatus(ctx, err, transactionId, attemptId)
+	} else if errors.Is(err, transactions.ErrBucketMismatch) {
+		return s.errorHandler.NewTransactionBucketMismatchStatus(ctx, err, transactionId, bucketName)
+	} else if errors.Is(err, transactionsx.ErrAttemptExpired) {
+		return s.errorHandler.NewTransactionExpiredStatus(ctx, err, transactionId)
+	}
+
+	var opFailedErr *transactionsx.TransactionOperationFailedError
+	if errors.As(err, &opFailedErr) {
+		return s.errorHandler.NewTransactionAttemptFailedStatus(ctx, err, transactionId, opFailedErr.Retry())
+	}
+
+	return s.errorHandler.NewGenericStatus(ctx, err)
+}
+
+func (s *TransactionsServer) translateDocError(
+	ctx context.Context,
+	err error,
+	bucketName, transactionId, attemptId, scopeName, collectionName, docId string,
+) *status.Status {
+	if errors.Is(err, transactions.ErrDocumentNotRead) {
+		return s.errorHandler.NewTransactionDocNotReadStatus(ctx, err, bucketName, scopeName, collectionName, docId)
+	} else if errors.Is(err, transactions.ErrCasMismatch) {
+		return s.errorHandler.NewDocCasMismatchStatus(ctx, err, bucketName, scopeName, collectionName, docId)
+	} else if errors.Is(err, transactionsx.ErrDocumentNotFound) {
+		return s.errorHandler.NewDocMissingStatus(ctx, err, bucketName, scopeName, collectionName, docId)
+	} else if errors.Is(err, transactionsx.ErrDocumentAlreadyExists) {
+		return s.errorHandler.NewDocExistsStatus(ctx, err, bucketName, scopeName, collectionName, docId)
+	} else if errors.Is(err, memdx.ErrUnknownCollectionName) {
+		return s.errorHandler.NewCollectionMissingStatus(ctx, err, bucketName, scopeName, collectionName)
+	} else if errors.Is(err, memdx.ErrUnknownScopeName) {
+		return s.errorHandler.NewScopeMissingStatus(ctx, err, bucketName, scopeName)
+	} else if errors.Is(err, memdx.ErrAccessError) {
+		return s.errorHandler.NewCollectionNoWriteAccessStatus(ctx, err, bucketName, scopeName, collectionName)
+	}
+
+	return s.translateTxnError(ctx, err, bucketName, transactionId, attemptId)
+}
+
+func (s *TransactionsServer) TransactionBeginAttempt(ctx context.Context, in *transactions_v1.TransactionBeginAttemptRequest) (*transactions_v1.TransactionBeginAttemptResponse, error) {
+	_, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	txn, err := s.txnManager.Begin(ctx, in.BucketName, oboUser, in.TransactionId)
+	if err != nil {
+		return nil, s.translateTxnError(ctx, err, in.BucketName, in.GetTransactionId(), "").Err()
+	}
+	defer txn.Release()
+
+	return &transactions_v1.TransactionBeginAttemptResponse{
+		TransactionId: txn.ID(),
+		AttemptId:     txn.AttemptID(),
+	}, nil
+}
+
+func (s *TransactionsServer) TransactionCommit(ctx context.Context, in *transactions_v1.TransactionCommitRequest) (*transactions_v1.TransactionCommitResponse, error) {
+	_, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	txn, err := s.txnManager.Acquire(in.TransactionId, in.AttemptId, in.BucketName, oboUser)
+	if err != nil {
+		return nil, s.translateTxnError(ctx, err, in.BucketName, in.TransactionId, in.AttemptId).Err()
+	}
+	defer txn.Release()
+
+	err = txn.Commit(ctx)
+	if err != nil {
+		return nil, s.translateTxnError(ctx, err, in.BucketName, in.TransactionId, in.AttemptId).Err()
+	}
+
+	return &transactions_v1.TransactionCommitResponse{}, nil
+}
+
+func (s *TransactionsServer) TransactionRollback(ctx context.Context, in *transactions_v1.TransactionRollbackRequest) (*transactions_v1.TransactionRollbackResponse, error) {
+	_, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	txn, err := s.txnManager.Acquire(in.TransactionId, in.AttemptId, in.BucketName, oboUser)
+	if err != nil {
+		return nil, s.translateTxnError(ctx, err, in.BucketName, in.TransactionId, in.AttemptId).Err()
+	}
+	defer txn.Release()
+
+	err = txn.Rollback(ctx)
+	if err != nil {
+		return nil, s.translateTxnError(ctx, err, in.BucketName, in.TransactionId, in.AttemptId).Err()
+	}
+
+	return &transactions_v1.TransactionRollbackResponse{}, nil
+}
+
+func (s *TransactionsServer) TransactionGet(ctx context.Context, in *transactions_v1.TransactionGetRequest) (*transactions_v1.TransactionGetResponse, error) {
+	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	txn, err := s.txnManager.Acquire(in.TransactionId, in.AttemptId, in.BucketName, oboUser)
+	if err != nil {
+		return nil, s.translateTxnError(ctx, err, in.BucketName, in.TransactionId, in.AttemptId).Err()
+	}
+	defer txn.Release()
+
+	result, err := txn.Get(ctx, bucketAgent, in.ScopeName, in.CollectionName, in.Key)
+	if err != nil {
+		return nil, s.translateDocError(ctx, err, in.BucketName, in.TransactionId, in.AttemptId, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	return &transactions_v1.TransactionGetResponse{
+		Cas:   uint64(result.Cas),
+		Value: result.Value,
+	}, nil
+}
+
+func (s *TransactionsServer) TransactionInsert(ctx context.Context, in *transactions_v1.TransactionInsertRequest) (*transactions_v1.TransactionInsertResponse, error) {
+	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	txn, err := s.txnManager.Acquire(in.TransactionId, in.AttemptId, in.BucketName, oboUser)
+	if err != nil {
+		return nil, s.translateTxnError(ctx, err, in.BucketName, in.TransactionId, in.AttemptId).Err()
+	}
+	defer txn.Release()
+
+	result, err := txn.Insert(ctx, bucketAgent, in.ScopeName, in.CollectionName, in.Key, in.Value)
+	if err != nil {
+		return nil, s.translateDocError(ctx, err, in.BucketName, in.TransactionId, in.AttemptId, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	return &transactions_v1.TransactionInsertResponse{
+		Cas: uint64(result.Cas),
+	}, nil
+}
+
+func (s *TransactionsServer) TransactionReplace(ctx context.Context, in *transactions_v1.TransactionReplaceRequest) (*transactions_v1.TransactionReplaceResponse, error) {
+	_, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	txn, err := s.txnManager.Acquire(in.TransactionId, in.AttemptId, in.BucketName, oboUser)
+	if err != nil {
+		return nil, s.translateTxnError(ctx, err, in.BucketName, in.TransactionId, in.AttemptId).Err()
+	}
+	defer txn.Release()
+
+	result, err := txn.Replace(ctx, in.ScopeName, in.CollectionName, in.Key, in.Cas, in.Value)
+	if err != nil {
+		return nil, s.translateDocError(ctx, err, in.BucketName, in.TransactionId, in.AttemptId, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	return &transactions_v1.TransactionReplaceResponse{
+		Cas: uint64(result.Cas),
+	}, nil
+}
+
+func (s *TransactionsServer) TransactionRemove(ctx context.Context, in *transactions_v1.TransactionRemoveRequest) (*transactions_v1.TransactionRemoveResponse, error) {
+	_, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	txn, err := s.txnManager.Acquire(in.TransactionId, in.AttemptId, in.BucketName, oboUser)
+	if err != nil {
+		return nil, s.translateTxnError(ctx, err, in.BucketName, in.TransactionId, in.AttemptId).Err()
+	}
+	defer txn.Release()
+
+	result, err := txn.Remove(ctx, in.ScopeName, in.CollectionName, in.Key, in.Cas)
+	if err != nil {
+		return nil, s.translateDocError(ctx, err, in.BucketName, in.TransactionId, in.AttemptId, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	return &transactions_v1.TransactionRemoveResponse{
+		Cas: uint64(result.Cas),
+	}, nil
+}
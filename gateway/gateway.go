@@ -4,9 +4,10 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -15,14 +16,26 @@ import (
 	"github.com/couchbase/gocbcorex"
 	"github.com/couchbase/gocbcorex/cbhttpx"
 	"github.com/couchbase/gocbcorex/cbmgmtx"
+	"github.com/couchbase/stellar-gateway/contrib/cbconfig"
+	"github.com/couchbase/stellar-gateway/gateway/apikeys"
 	"github.com/couchbase/stellar-gateway/gateway/auth"
+	"github.com/couchbase/stellar-gateway/gateway/auth/authcache"
+	"github.com/couchbase/stellar-gateway/gateway/auth/crl"
+	"github.com/couchbase/stellar-gateway/gateway/auth/jwtauth"
+	"github.com/couchbase/stellar-gateway/gateway/auth/lockout"
+	"github.com/couchbase/stellar-gateway/gateway/auth/userfile"
+	"github.com/couchbase/stellar-gateway/gateway/configwatcher"
+	"github.com/couchbase/stellar-gateway/gateway/credentials"
 	"github.com/couchbase/stellar-gateway/gateway/dapiimpl"
 	"github.com/couchbase/stellar-gateway/gateway/dapiimpl/proxy"
 	"github.com/couchbase/stellar-gateway/gateway/dataimpl"
 	"github.com/couchbase/stellar-gateway/gateway/hooks"
 	"github.com/couchbase/stellar-gateway/gateway/ratelimiting"
+	"github.com/couchbase/stellar-gateway/gateway/scramauth"
 	"github.com/couchbase/stellar-gateway/gateway/system"
+	"github.com/couchbase/stellar-gateway/gateway/transactions"
 	"github.com/couchbase/stellar-gateway/pkg/metrics"
+	"github.com/couchbase/stellar-gateway/utils/certselector"
 	"github.com/couchbase/stellar-gateway/utils/netutils"
 	"github.com/couchbaselabs/gocbconnstr"
 	"github.com/google/uuid"
@@ -62,6 +75,15 @@ type Config struct {
 	Password            string
 	SingleUserAuth      bool
 
+	// ClusterClientCertificate is presented by the gateway when connecting to
+	// the cluster.  If Username is empty, the gateway authenticates using
+	// only the certificate.
+	ClusterClientCertificate *tls.Certificate
+
+	// SingleUserUsers provides additional gateway-level users and client
+	// certificate mappings when using single user auth.
+	SingleUserUsers *userfile.Store
+
 	BindAddress      string
 	BindDataPort     int
 	BindDapiPort     int
@@ -70,48 +92,139 @@ type Config struct {
 
 	RateLimit       int
 	ShutdownTimeout time.Duration
+	TxnLeaseTimeout time.Duration
 
 	GrpcCertificate tls.Certificate
 	DapiCertificate tls.Certificate
 	ClusterCaCert   *x509.CertPool
 	ClientCaCert    *x509.CertPool
 
+	// ClientCrl enables checking client certificates against certificate
+	// revocation lists, both during the handshake and on each request.
+	ClientCrl *crl.Checker
+
+	// GrpcSniCertificates and DapiSniCertificates are additional certificates
+	// which are presented instead of the defaults when a client requests one
+	// of the names they are issued for via SNI.
+	GrpcSniCertificates []tls.Certificate
+	DapiSniCertificates []tls.Certificate
+
+	// JwtValidator enables authenticating requests with JWT bearer tokens,
+	// which are mapped to the user the request is performed on behalf of.
+	JwtValidator *jwtauth.Validator
+
+	// AuthCacheTTL enables caching the results of authenticating users and
+	// client certificates against the cluster for the given duration.
+	// AuthCacheSize bounds the number of cached results, and rejected
+	// credentials are cached for AuthCacheNegativeTTL.  Cached results are
+	// discarded whenever the cluster's configuration revision changes.
+	AuthCacheTTL         time.Duration
+	AuthCacheNegativeTTL time.Duration
+	AuthCacheSize        int
+
+	// AuthLockout enables throttling and locking out password authentication
+	// after repeated failures.
+	AuthLockout *lockout.Tracker
+
+	// ApiKeyStore enables authenticating requests with gateway-managed API
+	// keys, and managing those keys via the Data API and the
+	// ApiKeyAdminService on the data port.
+	ApiKeyStore *apikeys.Store
+
+	// ScramSessionTTL enables the SCRAM handshake service on the data port,
+	// issuing session tokens which expire after the given duration.  With
+	// single user auth, the gateway's own credentials and users of the users
+	// file with SCRAM hashes may authenticate.  Otherwise, handshakes are
+	// relayed to the cluster so that its users may authenticate.
+	ScramSessionTTL time.Duration
+
+	// KeyedRateLimits limits requests using a token bucket for each user,
+	// client certificate, bucket or source address.
+	KeyedRateLimits []ratelimiting.KeyedRule
+
+	// RateLimitCosts specifies how much of the rate limits each operation
+	// consumes.
+	RateLimitCosts ratelimiting.Costs
+
+	// ConcurrencyLimiter enables limiting the number of requests in flight,
+	// shedding requests when the cluster slows down.
+	ConcurrencyLimiter *ratelimiting.ConcurrencyLimiter
+
+	// SharedRateLimit enables sharing the RateLimit between every instance
+	// and gateway, rather than applying it to each instance separately.
+	SharedRateLimit *SharedRateLimitConfig
+
 	NumInstances    uint
 	StartupCallback func(*StartupInfo)
 }
 
+// SharedRateLimitConfig specifies the collection whose counter documents
+// are used to share the rate limit, and how often they are synced.
+type SharedRateLimitConfig struct {
+	BucketName     string
+	ScopeName      string
+	CollectionName string
+	SyncInterval   time.Duration
+}
+
 type Gateway struct {
 	config Config
 
 	isShutdown     atomic.Bool
 	shutdownSig    chan struct{}
-	atomicGrpcCert atomic.Pointer[tls.Certificate]
-	atomicDapiCert atomic.Pointer[tls.Certificate]
-
-	reconfigureLock sync.Mutex
-	rateLimiters    []*ratelimiting.GlobalRateLimiter
+	atomicGrpcCert atomic.Pointer[certselector.Selector]
+	atomicDapiCert atomic.Pointer[certselector.Selector]
+	atomicClientCa atomic.Pointer[x509.CertPool]
+	creds          *credentials.Provider
+
+	reconfigureLock  sync.Mutex
+	rateLimiters     []*ratelimiting.GlobalRateLimiter
+	keyedRateLimiter *ratelimiting.KeyedRateLimiter
+	rateLimitCosts   *ratelimiting.CostTable
 }
 
 func NewGateway(config *Config) (*Gateway, error) {
 	gw := &Gateway{
 		config:      *config,
 		shutdownSig: make(chan struct{}),
+		creds:       credentials.NewProvider(config.Username, config.Password),
 	}
 
-	grpcCert := config.GrpcCertificate
-	gw.atomicGrpcCert.Store(&grpcCert)
+	err := gw.UpdateGrpcCertificates(config.GrpcCertificate, config.GrpcSniCertificates)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid grpc certificates")
+	}
+
+	err = gw.UpdateDapiCertificates(config.DapiCertificate, config.DapiSniCertificates)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid data api certificates")
+	}
+
+	gw.atomicClientCa.Store(config.ClientCaCert)
 
-	dapiCert := config.DapiCertificate
-	gw.atomicDapiCert.Store(&dapiCert)
+	if config.ClusterClientCertificate != nil {
+		gw.creds.SetClientCertificate(config.ClusterClientCertificate)
+	}
 
 	return gw, nil
 }
 
-func connStrToMgmtHostPortAndScheme(connStr string) (string, string, error) {
+// lookupSRV resolves DNS SRV records, it can be replaced by tests.
+var lookupSRV = net.DefaultResolver.LookupSRV
+
+// joinHostPort combines a host and port, bracketing IPv6 addresses.  Hosts
+// parsed from connection strings retain their brackets, so these are removed
+// first to avoid them being doubled.
+func joinHostPort(host string, port int) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func connStrToMgmtHostPortsAndScheme(ctx context.Context, connStr string) ([]string, string, error) {
 	// attempt to parse the connection string
 	connSpec, err := gocbconnstr.Parse(connStr)
 	if err != nil {
-		return "", "", err
+		return nil, "", err
 	}
 
 	// if the connection string is blank, assume http
@@ -119,28 +232,47 @@ func connStrToMgmtHostPortAndScheme(connStr string) (string, string, error) {
 		connSpec.Scheme = "http"
 	}
 
-	// we only support a single host to bootstrap with
-	if len(connSpec.Addresses) != 1 {
-		return "", "", errors.New("you must pass exactly one address in the connection string")
+	if len(connSpec.Addresses) == 0 {
+		return nil, "", errors.New("you must pass at least one address in the connection string")
 	}
 
-	// grab the address
-	address := connSpec.Addresses[0]
-
-	// if the port is undefined, and we aren't using tls assume its 8091,
-	// if using tls then assume 18091.
-	if address.Port == -1 {
-		if connSpec.Scheme == "couchbases" {
-			address.Port = 18091
-		} else {
-			address.Port = 8091
+	addresses := connSpec.Addresses
+
+	// a single couchbase:// host without a port may refer to a DNS SRV record
+	// listing the nodes of the cluster.  If the lookup fails, we fall back to
+	// treating it as a normal hostname, just like the SDKs do.
+	if srvRecordName := connSpec.SrvRecordName(); srvRecordName != "" {
+		_, srvAddrs, err := lookupSRV(ctx, "", "", srvRecordName)
+		if err == nil && len(srvAddrs) > 0 {
+			addresses = make([]gocbconnstr.Address, 0, len(srvAddrs))
+			for _, srvAddr := range srvAddrs {
+				// the SRV records point at the memcached ports of the nodes,
+				// so we use the default management port for each of them.
+				addresses = append(addresses, gocbconnstr.Address{
+					Host: strings.TrimSuffix(srvAddr.Target, "."),
+					Port: -1,
+				})
+			}
 		}
 	}
 
-	// calculate the full host/port pair
-	hostPort := fmt.Sprintf("%s:%d", address.Host, address.Port)
+	hostPorts := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		// if the port is undefined, and we aren't using tls assume its 8091,
+		// if using tls then assume 18091.
+		if address.Port == -1 {
+			if connSpec.Scheme == "couchbases" {
+				address.Port = 18091
+			} else {
+				address.Port = 8091
+			}
+		}
+
+		// calculate the full host/port pair
+		hostPorts = append(hostPorts, joinHostPort(address.Host, address.Port))
+	}
 
-	return hostPort, connSpec.Scheme, nil
+	return hostPorts, connSpec.Scheme, nil
 }
 
 func mgmtHostPortToAuthHostPort(mgmtHostPort string) (string, error) {
@@ -161,7 +293,7 @@ func mgmtHostPortToAuthHostPort(mgmtHostPort string) (string, error) {
 	}
 
 	// calculate the full host/port pair
-	hostPort := fmt.Sprintf("%s:%d", address.Host, authPort)
+	hostPort := joinHostPort(address.Host, authPort)
 
 	return hostPort, nil
 }
@@ -217,28 +349,37 @@ func (g *Gateway) Run(ctx context.Context) error {
 
 	serverGroup := config.ServerGroup
 
+	// the credentials may have been rotated since the gateway was created
+	username, password := g.creds.Get()
+
 	// start connecting to the underlying cluster
-	config.Logger.Info("linking to couchbase cluster", zap.String("connectionString", config.CbConnStr), zap.String("User", config.Username))
+	config.Logger.Info("linking to couchbase cluster", zap.String("connectionString", config.CbConnStr), zap.String("User", username))
 
-	// identify the ns_server host/port
-	mgmtHostPort, scheme, err := connStrToMgmtHostPortAndScheme(config.CbConnStr)
+	// identify the ns_server host/ports
+	mgmtHostPorts, scheme, err := connStrToMgmtHostPortsAndScheme(ctx, config.CbConnStr)
 	if err != nil {
 		config.Logger.Error("failed to parse connection string", zap.Error(err))
 		return err
 	}
-	config.Logger.Info("identified couchbase server address", zap.String("address", mgmtHostPort))
+	config.Logger.Info("identified couchbase server addresses", zap.Strings("addresses", mgmtHostPorts))
 
 	// ping the cluster first to make sure its alive
-	config.Logger.Info("waiting for couchbase server to become available", zap.String("address", mgmtHostPort))
+	config.Logger.Info("waiting for couchbase server to become available", zap.Strings("addresses", mgmtHostPorts))
 
 	var tlsConfig *tls.Config
 	if scheme == "couchbases" {
 		tlsConfig = &tls.Config{
-			RootCAs: config.ClusterCaCert,
+			RootCAs:              config.ClusterCaCert,
+			GetClientCertificate: g.creds.GetClientCertificate,
 		}
+	} else if g.creds.ClientCertificate() != nil {
+		return errors.New("a cluster client certificate requires a couchbases:// connection string")
 	}
 
-	mgmt := initStartupMgmt(tlsConfig, mgmtHostPort, config.Username, config.Password)
+	seedMgmts := make([]*cbmgmtx.Management, len(mgmtHostPorts))
+	for seedIdx, seedHostPort := range mgmtHostPorts {
+		seedMgmts[seedIdx] = initStartupMgmt(tlsConfig, seedHostPort, g.creds)
+	}
 
 	// Use a startup-scoped context for the ping loop that is cancelled when
 	// the gateway is asked to shut down.  This ensures we don't hang during
@@ -256,10 +397,31 @@ func (g *Gateway) Run(ctx context.Context) error {
 
 	var clusterUUID string
 	var bootstrapNodeAddr string
+	var mgmtHostPort string
+	var mgmt *cbmgmtx.Management
 	for {
-		currentUUID, nodeAddr, err := pingCouchbaseCluster(startupCtx, mgmt, config.Logger)
+		// try each of the seed nodes in order, using the first which responds
+		var currentUUID, nodeAddr string
+		for seedIdx, seedMgmt := range seedMgmts {
+			currentUUID, nodeAddr, err = pingCouchbaseCluster(startupCtx, seedMgmt, config.Logger)
+			if err != nil {
+				config.Logger.Warn("failed to ping cluster via seed node",
+					zap.Error(err),
+					zap.String("address", mgmtHostPorts[seedIdx]))
+
+				if startupCtx.Err() != nil {
+					break
+				}
+
+				continue
+			}
+
+			mgmtHostPort = mgmtHostPorts[seedIdx]
+			mgmt = seedMgmt
+			break
+		}
 		if err != nil {
-			config.Logger.Warn("failed to ping cluster", zap.Error(err))
+			config.Logger.Warn("failed to ping cluster via any seed node", zap.Error(err))
 
 			// if we are not in daemon mode, we just immediately return the error to the user
 			if !config.Daemon {
@@ -288,10 +450,34 @@ func (g *Gateway) Run(ctx context.Context) error {
 	// Startup ping is done, clean up the scoped context.
 	startupCancel()
 
-	authHostPort, err := mgmtHostPortToAuthHostPort(mgmtHostPort)
-	if err != nil {
-		config.Logger.Error("failed to form auth host port", zap.Error(err))
-		return err
+	config.Logger.Info("connected to couchbase server via seed node", zap.String("address", mgmtHostPort))
+
+	// the seed node which responded is placed first, so that it is preferred
+	// by everything which bootstraps from these addresses.
+	httpAddrs := []string{mgmtHostPort}
+	for _, seedHostPort := range mgmtHostPorts {
+		if seedHostPort != mgmtHostPort {
+			httpAddrs = append(httpAddrs, seedHostPort)
+		}
+	}
+
+	// cbauth connects over TLS whenever the cluster is, so that it can
+	// authenticate with our client certificate, otherwise it uses the plain
+	// management ports.
+	authHostPorts := make([]string, 0, len(httpAddrs))
+	for _, httpAddr := range httpAddrs {
+		if tlsConfig != nil {
+			authHostPorts = append(authHostPorts, httpAddr)
+			continue
+		}
+
+		authHostPort, err := mgmtHostPortToAuthHostPort(httpAddr)
+		if err != nil {
+			config.Logger.Error("failed to form auth host port", zap.Error(err))
+			return err
+		}
+
+		authHostPorts = append(authHostPorts, authHostPort)
 	}
 
 	// initialize cb-auth
@@ -300,38 +486,235 @@ func (g *Gateway) Run(ctx context.Context) error {
 	if !config.SingleUserAuth {
 		cbAuthAuthenticator, err = auth.NewCbAuthAuthenticator(ctx, auth.NewCbAuthAuthenticatorOptions{
 			NodeId:      nodeID,
-			Addresses:   []string{authHostPort},
-			Username:    config.Username,
-			Password:    config.Password,
+			Addresses:   authHostPorts,
+			Username:    username,
+			Password:    password,
 			ClusterUUID: clusterUUID,
+			TLSConfig:   tlsConfig,
 			Logger:      config.Logger.Named("cbauth"),
 		})
 		if err != nil {
 			config.Logger.Error("failed to initialize cbauth connection",
 				zap.Error(err),
-				zap.String("hostPort", authHostPort),
-				zap.String("user", config.Username))
+				zap.Strings("hostPorts", authHostPorts),
+				zap.String("user", username))
 			return err
 		}
 
 		authenticator = cbAuthAuthenticator
 	} else {
 		authenticator = &auth.SingleUserAuthenticator{
-			Username: config.Username,
-			Password: config.Password,
+			Credentials: g.creds,
+			Users:       config.SingleUserUsers,
+		}
+	}
+
+	var authCache *authcache.Cache
+	if config.AuthCacheTTL > 0 {
+		authCache, err = authcache.NewCache(&authcache.Options{
+			Logger:      config.Logger.Named("auth-cache"),
+			Size:        config.AuthCacheSize,
+			TTL:         config.AuthCacheTTL,
+			NegativeTTL: config.AuthCacheNegativeTTL,
+		})
+		if err != nil {
+			config.Logger.Error("failed to initialize auth cache", zap.Error(err))
+			return err
+		}
+
+		authenticator = &auth.CachingAuthenticator{
+			Authenticator: authenticator,
+			Cache:         authCache,
+		}
+
+		if cbAuthAuthenticator == nil {
+			// single user auth depends only on our own credentials and the
+			// users file, with cbauth the cache is invalidated once it has
+			// been reconfigured.
+			g.creds.Watch(func(credentials.Credentials) {
+				authCache.Invalidate()
+			})
+
+			if config.SingleUserUsers != nil {
+				config.SingleUserUsers.Watch(func() {
+					authCache.Invalidate()
+				})
+			}
+		}
+	}
+
+	if config.AuthLockout != nil {
+		authenticator = &auth.LockoutAuthenticator{
+			Authenticator: authenticator,
+			Tracker:       config.AuthLockout,
+		}
+	}
+
+	if config.ClientCrl != nil {
+		authenticator = &auth.RevocationAuthenticator{
+			Authenticator: authenticator,
+			Checker:       config.ClientCrl,
+		}
+	}
+
+	if config.JwtValidator != nil {
+		authenticator = &auth.JwtAuthenticator{
+			Authenticator: authenticator,
+			Validator:     config.JwtValidator,
+		}
+	}
+
+	if config.ApiKeyStore != nil {
+		authenticator = &auth.ApiKeyAuthenticator{
+			Authenticator: authenticator,
+			Store:         config.ApiKeyStore,
+		}
+	}
+
+	var apiKeyAdmin *apikeys.AdminVerifier
+	var apiKeyService *apikeys.Service
+	if config.ApiKeyStore != nil {
+		apiKeyAdmin = &apikeys.AdminVerifier{
+			Creds:   g.creds,
+			Lockout: config.AuthLockout,
+		}
+
+		apiKeyService, err = apikeys.NewService(&apikeys.ServiceOptions{
+			Logger: config.Logger.Named("api-keys"),
+			Store:  config.ApiKeyStore,
+			Admin:  apiKeyAdmin,
+		})
+		if err != nil {
+			config.Logger.Error("failed to initialize api key service", zap.Error(err))
+			return err
+		}
+	}
+
+	var scramService *scramauth.Service
+	if config.ScramSessionTTL > 0 {
+		scramSessions := scramauth.NewSessionStore(config.ScramSessionTTL)
+
+		var scramBackend scramauth.Backend
+		if config.SingleUserAuth {
+			scramBackend, err = scramauth.NewLocalBackend(func(username, mechanism string) (*scramauth.LocalUser, bool) {
+				// sessions established with our own credentials act as the
+				// gateway itself.
+				gatewayUser, gatewayPass := g.creds.Get()
+				if gatewayUser != "" && username == gatewayUser {
+					return &scramauth.LocalUser{Password: gatewayPass}, true
+				}
+
+				if config.SingleUserUsers != nil {
+					fileUser, creds, ok := config.SingleUserUsers.ScramCredentials(username, mechanism)
+					if ok {
+						return &scramauth.LocalUser{
+							Credentials: creds,
+							Identity: scramauth.Identity{
+								User:   fileUser.OboUser,
+								Domain: fileUser.OboDomain,
+							},
+						}, true
+					}
+				}
+
+				return nil, false
+			})
+		} else {
+			// the cluster authenticates the requests we relay using only the
+			// scram headers, so no client certificate is presented.
+			var scramTlsConfig *tls.Config
+			scramScheme := "http://"
+			if tlsConfig != nil {
+				scramTlsConfig = &tls.Config{RootCAs: config.ClusterCaCert}
+				scramScheme = "https://"
+			}
+
+			scramEndpoints := make([]string, 0, len(httpAddrs))
+			for _, httpAddr := range httpAddrs {
+				scramEndpoints = append(scramEndpoints, scramScheme+httpAddr)
+			}
+
+			scramBackend, err = scramauth.NewClusterBackend(&scramauth.ClusterBackendOptions{
+				HttpClient: &http.Client{
+					Transport: &http.Transport{
+						Proxy:               http.ProxyFromEnvironment,
+						TLSClientConfig:     scramTlsConfig,
+						TLSHandshakeTimeout: 10 * time.Second,
+					},
+					Timeout: 10 * time.Second,
+				},
+				Endpoints: scramEndpoints,
+			})
+		}
+		if err != nil {
+			config.Logger.Error("failed to initialize scram backend", zap.Error(err))
+			return err
+		}
+
+		scramService, err = scramauth.NewService(&scramauth.ServiceOptions{
+			Logger:   config.Logger.Named("scram"),
+			Sessions: scramSessions,
+			Backend:  scramBackend,
+			Lockout:  config.AuthLockout,
+		})
+		if err != nil {
+			config.Logger.Error("failed to initialize scram service", zap.Error(err))
+			return err
+		}
+
+		authenticator = &auth.SessionAuthenticator{
+			Authenticator: authenticator,
+			Sessions:      scramSessions,
 		}
+
+		// sessions are revoked whenever the credentials they may have been
+		// established with change.  Sessions of cluster users are not aware
+		// of changes made on the cluster, so last until they expire, though
+		// every request is still authorized as the user by the cluster.
+		g.creds.Watch(func(credentials.Credentials) {
+			scramSessions.RevokeAll()
+		})
+		if config.SingleUserAuth && config.SingleUserUsers != nil {
+			config.SingleUserUsers.Watch(scramSessions.RevokeAll)
+		}
+	}
+
+	rateLimitCosts, err := ratelimiting.NewCostTable(config.RateLimitCosts)
+	if err != nil {
+		config.Logger.Error("invalid rate limit costs", zap.Error(err))
+		return err
+	}
+
+	// the keyed limiter is shared by all instances so that each key is limited
+	// across them, and always exists so that limits can be added at runtime.
+	keyedRateLimiter, err := ratelimiting.NewKeyedRateLimiter(&ratelimiting.KeyedRateLimiterOptions{
+		Logger: config.Logger.Named("rate-limiter"),
+		Rules:  config.KeyedRateLimits,
+		GatewayUser: func() string {
+			gatewayUser, _ := g.creds.Get()
+			return gatewayUser
+		},
+		Costs: rateLimitCosts,
+	})
+	if err != nil {
+		config.Logger.Error("failed to initialize keyed rate limiter", zap.Error(err))
+		return err
 	}
 
+	g.reconfigureLock.Lock()
+	g.keyedRateLimiter = keyedRateLimiter
+	g.rateLimitCosts = rateLimitCosts
+	g.reconfigureLock.Unlock()
+
 	// try to establish a client connection to the cluster
 	agentMgr, err := gocbcorex.CreateBucketsTrackingAgentManager(ctx, gocbcorex.BucketsTrackingAgentManagerOptions{
 		Logger:    config.Logger.Named("gocbcorex"),
 		TLSConfig: tlsConfig,
-		Authenticator: &gocbcorex.PasswordAuthenticator{
-			Username: config.Username,
-			Password: config.Password,
+		Authenticator: &agentAuthenticator{
+			creds: g.creds,
 		},
 		SeedConfig: gocbcorex.SeedConfig{
-			HTTPAddrs: []string{mgmtHostPort},
+			HTTPAddrs: httpAddrs,
 		},
 		IoConfig: gocbcorex.IoConfig{
 			ConnectionPoolSize: 8,
@@ -340,8 +723,8 @@ func (g *Gateway) Run(ctx context.Context) error {
 	if err != nil {
 		config.Logger.Error("failed to connect to couchbase cluster",
 			zap.Error(err),
-			zap.String("httpAddr", mgmtHostPort),
-			zap.String("user", config.Username))
+			zap.Strings("httpAddrs", httpAddrs),
+			zap.String("user", username))
 		return err
 	}
 
@@ -350,19 +733,84 @@ func (g *Gateway) Run(ctx context.Context) error {
 
 	config.Logger.Info("connected to couchbase cluster")
 
+	txnManager, err := transactions.NewManager(&transactions.ManagerOptions{
+		Logger:       config.Logger.Named("transactions"),
+		CbClient:     agentMgr,
+		LeaseTimeout: config.TxnLeaseTimeout,
+	})
+	if err != nil {
+		config.Logger.Error("failed to initialize transactions manager", zap.Error(err))
+		return err
+	}
+
+	go func() {
+		<-g.shutdownSig
+		txnManager.Close()
+	}()
+
+	configWatcher := configwatcher.NewWatcher(&configwatcher.WatcherOptions{
+		Logger: config.Logger.Named("config-watcher"),
+		Fetcher: cbconfig.NewFetcher(cbconfig.FetcherOptions{
+			HttpClient: &http.Client{Transport: mgmt.Transport},
+			Host:       mgmt.Endpoint,
+			Username:   username,
+			Password:   password,
+			Logger:     config.Logger.Named("config-fetcher"),
+		}),
+	})
+
+	go func() {
+		<-g.shutdownSig
+		configWatcher.Close()
+	}()
+
 	var proxyServices []proxy.ServiceType
 	for _, serviceName := range config.ProxyServices {
 		proxyServices = append(proxyServices, proxy.ServiceType(serviceName))
 	}
 
 	if cbAuthAuthenticator != nil {
+		credsChangedCh := make(chan struct{}, 1)
+		g.creds.Watch(func(credentials.Credentials) {
+			select {
+			case credsChangedCh <- struct{}{}:
+			default:
+			}
+		})
+
 		go func() {
 			watchCh := agentMgr.WatchConfig(context.Background())
+			cbAuthAddresses := authHostPorts
+			var lastRevEpoch, lastRevID int64
+
+			reconfigureCbAuth := func() {
+				username, password := g.creds.Get()
+				err := cbAuthAuthenticator.Reconfigure(auth.CbAuthAuthenticatorReconfigureOptions{
+					Addresses:   cbAuthAddresses,
+					Username:    username,
+					Password:    password,
+					ClusterUUID: clusterUUID,
+				})
+				if err != nil {
+					config.Logger.Warn("failed to reconfigure cbauth",
+						zap.Error(err))
+				}
+			}
+
 		runLoop:
 			for {
 				select {
 				case <-g.shutdownSig:
 					break runLoop
+				case <-credsChangedCh:
+					config.Logger.Info("reconfiguring cbauth with updated credentials")
+					reconfigureCbAuth()
+
+					// results cached whilst cbauth was using the previous
+					// credentials are discarded.
+					if authCache != nil {
+						authCache.Invalidate()
+					}
 				case cfg := <-watchCh:
 					if cfg == nil {
 						continue
@@ -370,21 +818,30 @@ func (g *Gateway) Run(ctx context.Context) error {
 
 					mgmtEndpointsList := make([]string, 0, len(cfg.Nodes))
 					for _, node := range cfg.Nodes {
-						if node.Addresses.NonSSLPorts.Mgmt > 0 {
+						mgmtPort := node.Addresses.NonSSLPorts.Mgmt
+						if tlsConfig != nil {
+							mgmtPort = node.Addresses.SSLPorts.Mgmt
+						}
+
+						if mgmtPort > 0 {
 							mgmtEndpointsList = append(mgmtEndpointsList,
-								fmt.Sprintf("%s:%d", node.Addresses.Hostname, node.Addresses.NonSSLPorts.Mgmt))
+								joinHostPort(node.Addresses.Hostname, mgmtPort))
 						}
 					}
 
-					err := cbAuthAuthenticator.Reconfigure(auth.CbAuthAuthenticatorReconfigureOptions{
-						Addresses:   mgmtEndpointsList,
-						Username:    config.Username,
-						Password:    config.Password,
-						ClusterUUID: clusterUUID,
-					})
-					if err != nil {
-						config.Logger.Warn("failed to reconfigure cbauth",
-							zap.Error(err))
+					cbAuthAddresses = mgmtEndpointsList
+					reconfigureCbAuth()
+
+					// the cluster's users, roles or certificate settings may
+					// have changed along with its configuration, configs we
+					// have already seen are ignored to avoid needlessly
+					// discarding cached results.
+					if cfg.RevEpoch > lastRevEpoch ||
+						(cfg.RevEpoch == lastRevEpoch && cfg.RevID > lastRevID) {
+						lastRevEpoch, lastRevID = cfg.RevEpoch, cfg.RevID
+						if authCache != nil {
+							authCache.Invalidate()
+						}
 					}
 				}
 			}
@@ -398,15 +855,40 @@ func (g *Gateway) Run(ctx context.Context) error {
 	}
 
 	startInstance := func(ctx context.Context, instanceIdx int) error {
-		var rateLimiter ratelimiting.RateLimiter
+		var rateLimiters []ratelimiting.RateLimiter
 		if config.RateLimit > 0 {
-			rateLimiterImpl := ratelimiting.NewGlobalRateLimiter(uint64(config.RateLimit), time.Second)
+			rateLimiterImpl := ratelimiting.NewGlobalRateLimiter(uint64(config.RateLimit), time.Second, rateLimitCosts)
 
 			g.reconfigureLock.Lock()
 			g.rateLimiters = append(g.rateLimiters, rateLimiterImpl)
 			g.reconfigureLock.Unlock()
 
-			rateLimiter = rateLimiterImpl
+			if config.SharedRateLimit != nil {
+				syncCtx, cancelSync := context.WithCancel(context.Background())
+				go func() {
+					<-g.shutdownSig
+					cancelSync()
+				}()
+
+				go rateLimiterImpl.SyncShared(syncCtx, &ratelimiting.SharedStateOptions{
+					Logger: config.Logger.Named("rate-limiter"),
+					Counters: &kvSharedCounters{
+						agentMgr:       agentMgr,
+						bucketName:     config.SharedRateLimit.BucketName,
+						scopeName:      config.SharedRateLimit.ScopeName,
+						collectionName: config.SharedRateLimit.CollectionName,
+					},
+					Name:         "stg-rate-limit-global",
+					SyncInterval: config.SharedRateLimit.SyncInterval,
+				})
+			}
+
+			rateLimiters = append(rateLimiters, rateLimiterImpl)
+		}
+		rateLimiters = append(rateLimiters, keyedRateLimiter)
+		if config.ConcurrencyLimiter != nil {
+			// requests rejected by the rate limiters never occupy a slot
+			rateLimiters = append(rateLimiters, config.ConcurrencyLimiter)
 		}
 
 		dataImpl := dataimpl.New(&dataimpl.NewOptions{
@@ -415,8 +897,11 @@ func (g *Gateway) Run(ctx context.Context) error {
 			CbClient:         agentMgr,
 			Mgmt:             mgmt,
 			Authenticator:    authenticator,
+			TxnManager:       txnManager,
+			ConfigWatcher:    configWatcher,
 			LocalhostConnstr: strings.Contains(mgmtHostPort, "localhost") || config.BoostrapNodeIsLocal,
 			BootstrapNode:    bootstrapNodeAddr,
+			ServerGroup:      serverGroup,
 		})
 
 		dapiImpl := dapiimpl.New(&dapiimpl.NewOptions{
@@ -426,34 +911,25 @@ func (g *Gateway) Run(ctx context.Context) error {
 			Authenticator:   authenticator,
 			ProxyServices:   proxyServices,
 			ProxyBlockAdmin: config.ProxyBlockAdmin,
-			Username:        config.Username,
-			Password:        config.Password,
+			Credentials:     g.creds,
+			ApiKeys:         config.ApiKeyStore,
+			ApiKeyAdmin:     apiKeyAdmin,
 		})
 
 		config.Logger.Info("initializing protostellar system")
 		gatewaySys, err := system.NewSystem(&system.SystemOptions{
-			Logger:      config.Logger.Named("gateway-system"),
-			DataImpl:    dataImpl,
-			DapiImpl:    dapiImpl,
-			Metrics:     metrics.GetSnMetrics(),
-			RateLimiter: rateLimiter,
-			GrpcTlsConfig: &tls.Config{
-				ClientCAs:  config.ClientCaCert,
-				ClientAuth: tls.VerifyClientCertIfGiven,
-				GetCertificate: func(chi *tls.ClientHelloInfo) (*tls.Certificate, error) {
-					return g.atomicGrpcCert.Load(), nil
-				},
-			},
-			DapiTlsConfig: &tls.Config{
-				GetCertificate: func(chi *tls.ClientHelloInfo) (*tls.Certificate, error) {
-					return g.atomicDapiCert.Load(), nil
-				},
-				ClientCAs:  config.ClientCaCert,
-				ClientAuth: tls.VerifyClientCertIfGiven,
-			},
+			Logger:          config.Logger.Named("gateway-system"),
+			DataImpl:        dataImpl,
+			DapiImpl:        dapiImpl,
+			Metrics:         metrics.GetSnMetrics(),
+			RateLimiters:    rateLimiters,
+			GrpcTlsConfig:   g.newServerTlsConfig(&g.atomicGrpcCert, []string{"h2"}),
+			DapiTlsConfig:   g.newServerTlsConfig(&g.atomicDapiCert, []string{"h2", "http/1.1"}),
 			ShutdownTimeout: config.ShutdownTimeout,
 			AlphaEndpoints:  config.AlphaEndpoints,
 			Debug:           config.Debug,
+			ScramService:    scramService,
+			ApiKeyService:   apiKeyService,
 		})
 		if err != nil {
 			config.Logger.Error("error creating legacy proxy")
@@ -562,17 +1038,33 @@ func (g *Gateway) Run(ctx context.Context) error {
 }
 
 type ReconfigureOptions struct {
-	RateLimit int
+	RateLimit       int
+	KeyedRateLimits []ratelimiting.KeyedRule
+	RateLimitCosts  ratelimiting.Costs
 }
 
 func (g *Gateway) Reconfigure(opts *ReconfigureOptions) error {
 	g.reconfigureLock.Lock()
 	defer g.reconfigureLock.Unlock()
 
-	if len(g.rateLimiters) == 0 {
+	if opts.RateLimit > 0 && len(g.rateLimiters) == 0 {
 		return errors.New("cannot enable rate limiting when rate limiting was initially disabled")
 	}
 
+	if g.rateLimitCosts != nil {
+		err := g.rateLimitCosts.Update(opts.RateLimitCosts)
+		if err != nil {
+			return errors.Wrap(err, "failed to update rate limit costs")
+		}
+	}
+
+	if g.keyedRateLimiter != nil {
+		err := g.keyedRateLimiter.UpdateRules(opts.KeyedRateLimits)
+		if err != nil {
+			return errors.Wrap(err, "failed to update keyed rate limits")
+		}
+	}
+
 	for _, rateLimiter := range g.rateLimiters {
 		rateLimiter.ResetAndUpdateRateLimit(uint64(opts.RateLimit), time.Second)
 	}
@@ -580,13 +1072,91 @@ func (g *Gateway) Reconfigure(opts *ReconfigureOptions) error {
 	return nil
 }
 
+// newServerTlsConfig builds a TLS config which picks up the current
+// certificate and client CAs on every handshake, allowing them to be
+// replaced without restarting the listeners.  Because the per-connection
+// config replaces the one the servers would normally configure, the ALPN
+// protocols must be specified explicitly.
+func (g *Gateway) newServerTlsConfig(certs *atomic.Pointer[certselector.Selector], nextProtos []string) *tls.Config {
+	getCertificate := func(chi *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return certs.Load().Select(chi.ServerName), nil
+	}
+
+	var verifyPeerCertificate func([][]byte, [][]*x509.Certificate) error
+	if g.config.ClientCrl != nil {
+		verifyPeerCertificate = g.config.ClientCrl.VerifyPeerCertificate
+	}
+
+	return &tls.Config{
+		ClientCAs:             g.atomicClientCa.Load(),
+		ClientAuth:            tls.VerifyClientCertIfGiven,
+		GetCertificate:        getCertificate,
+		VerifyPeerCertificate: verifyPeerCertificate,
+		NextProtos:            nextProtos,
+		GetConfigForClient: func(chi *tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				ClientCAs:             g.atomicClientCa.Load(),
+				ClientAuth:            tls.VerifyClientCertIfGiven,
+				GetCertificate:        getCertificate,
+				VerifyPeerCertificate: verifyPeerCertificate,
+				NextProtos:            nextProtos,
+			}, nil
+		},
+	}
+}
+
+// UpdateGrpcCertificates replaces the certificates presented to new GRPC
+// connections.
+func (g *Gateway) UpdateGrpcCertificates(defaultCert tls.Certificate, sniCerts []tls.Certificate) error {
+	selector, err := certselector.New(defaultCert, sniCerts)
+	if err != nil {
+		return err
+	}
+
+	g.atomicGrpcCert.Store(selector)
+	return nil
+}
+
+// UpdateDapiCertificates replaces the certificates presented to new Data API
+// connections.
+func (g *Gateway) UpdateDapiCertificates(defaultCert tls.Certificate, sniCerts []tls.Certificate) error {
+	selector, err := certselector.New(defaultCert, sniCerts)
+	if err != nil {
+		return err
+	}
+
+	g.atomicDapiCert.Store(selector)
+	return nil
+}
+
+// UpdateCredentials replaces the credentials used to communicate with the
+// cluster.  Existing connections remain open, new connections and requests
+// will use the new credentials.
+func (g *Gateway) UpdateCredentials(username, password string) {
+	if g.creds.Update(username, password) {
+		g.config.Logger.Info("updated cluster credentials", zap.String("user", username))
+	}
+}
+
+// UpdateClusterClientCertificate replaces the certificate presented to the
+// cluster by new connections.
+func (g *Gateway) UpdateClusterClientCertificate(cert tls.Certificate) {
+	g.creds.SetClientCertificate(&cert)
+}
+
+// UpdateClientCaCert replaces the CAs used to verify client certificates on
+// new connections.
+func (g *Gateway) UpdateClientCaCert(pool *x509.CertPool) {
+	g.atomicClientCa.Store(pool)
+}
+
 func (g *Gateway) Shutdown() {
 	if g.isShutdown.CompareAndSwap(false, true) {
 		close(g.shutdownSig)
 	}
 }
 
-func initStartupMgmt(tlsConfig *tls.Config, mgmtHostPort, username, password string) *cbmgmtx.Management {
+func initStartupMgmt(tlsConfig *tls.Config, mgmtHostPort string, creds *credentials.Provider) *cbmgmtx.Management {
 	var endpoint string
 	if tlsConfig != nil {
 		endpoint = "https://" + mgmtHostPort
@@ -604,8 +1174,11 @@ func initStartupMgmt(tlsConfig *tls.Config, mgmtHostPort, username, password str
 		TLSClientConfig:       tlsConfig,
 	}
 
+	// the transport replaces the basic auth on every request with the current
+	// credentials, so that rotating them does not require a new client.
+	username, password := creds.Get()
 	return &cbmgmtx.Management{
-		Transport: transport,
+		Transport: creds.WrapTransport(transport),
 		UserAgent: "cloud-native-gateway-startup",
 		Endpoint:  endpoint,
 		Auth: &cbhttpx.BasicAuth{
@@ -614,3 +1187,55 @@ func initStartupMgmt(tlsConfig *tls.Config, mgmtHostPort, username, password str
 		},
 	}
 }
+
+// agentAuthenticator provides the current cluster credentials and client
+// certificate to the agents, so that any new connections they establish use
+// the latest credentials.
+type agentAuthenticator struct {
+	creds *credentials.Provider
+}
+
+var _ gocbcorex.Authenticator = (*agentAuthenticator)(nil)
+
+func (a *agentAuthenticator) GetClientCertificate(service gocbcorex.ServiceType, hostPort string) (*tls.Certificate, error) {
+	return a.creds.ClientCertificate(), nil
+}
+
+func (a *agentAuthenticator) GetCredentials(service gocbcorex.ServiceType, hostPort string) (string, string, error) {
+	username, password := a.creds.Get()
+	return username, password, nil
+}
+
+// kvSharedCounters stores the counters shared by gateway instances as counter
+// documents in a collection of the cluster.
+type kvSharedCounters struct {
+	agentMgr       *gocbcorex.BucketsTrackingAgentManager
+	bucketName     string
+	scopeName      string
+	collectionName string
+}
+
+var _ ratelimiting.SharedCounters = (*kvSharedCounters)(nil)
+
+func (c *kvSharedCounters) Add(ctx context.Context, name string, delta uint64, expiry time.Duration) (uint64, error) {
+	agent, err := c.agentMgr.GetBucketAgent(ctx, c.bucketName)
+	if err != nil {
+		return 0, err
+	}
+
+	// the expiry of a counter is only applied when it is created, which is
+	// when it takes its initial value rather than the delta.
+	result, err := agent.Increment(ctx, &gocbcorex.IncrementOptions{
+		Key:            []byte(name),
+		ScopeName:      c.scopeName,
+		CollectionName: c.collectionName,
+		Initial:        delta,
+		Delta:          delta,
+		Expiry:         uint32(max(expiry/time.Second, 1)),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Value, nil
+}
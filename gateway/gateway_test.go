@@ -0,0 +1,133 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnStrToMgmtHostPortsAndScheme(t *testing.T) {
+	origLookupSRV := lookupSRV
+	t.Cleanup(func() {
+		lookupSRV = origLookupSRV
+	})
+
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		switch name {
+		case "_couchbase._tcp.cluster.example.com", "_couchbases._tcp.cluster.example.com":
+			return "", []*net.SRV{
+				{Target: "node1.example.com.", Port: 11210},
+				{Target: "node2.example.com.", Port: 11210},
+			}, nil
+		}
+		return "", nil, errors.New("no such host")
+	}
+
+	testCases := []struct {
+		name      string
+		connStr   string
+		hostPorts []string
+		scheme    string
+	}{
+		{
+			name:      "NoScheme",
+			connStr:   "10.0.0.1",
+			hostPorts: []string{"10.0.0.1:8091"},
+			scheme:    "http",
+		},
+		{
+			name:      "DefaultPort",
+			connStr:   "couchbase://10.0.0.1",
+			hostPorts: []string{"10.0.0.1:8091"},
+			scheme:    "couchbase",
+		},
+		{
+			name:      "DefaultTlsPort",
+			connStr:   "couchbases://10.0.0.1",
+			hostPorts: []string{"10.0.0.1:18091"},
+			scheme:    "couchbases",
+		},
+		{
+			name:      "ExplicitPort",
+			connStr:   "couchbase://10.0.0.1:9000",
+			hostPorts: []string{"10.0.0.1:9000"},
+			scheme:    "couchbase",
+		},
+		{
+			name:      "MultiHost",
+			connStr:   "couchbase://10.0.0.1,10.0.0.2:9000,node3.example.com",
+			hostPorts: []string{"10.0.0.1:8091", "10.0.0.2:9000", "node3.example.com:8091"},
+			scheme:    "couchbase",
+		},
+		{
+			name:      "Ipv6",
+			connStr:   "couchbase://[::1]",
+			hostPorts: []string{"[::1]:8091"},
+			scheme:    "couchbase",
+		},
+		{
+			name:      "Ipv6MultiHost",
+			connStr:   "couchbases://[fd00::1]:18091,[fd00::2]",
+			hostPorts: []string{"[fd00::1]:18091", "[fd00::2]:18091"},
+			scheme:    "couchbases",
+		},
+		{
+			name:      "Srv",
+			connStr:   "couchbase://cluster.example.com",
+			hostPorts: []string{"node1.example.com:8091", "node2.example.com:8091"},
+			scheme:    "couchbase",
+		},
+		{
+			name:      "SrvTls",
+			connStr:   "couchbases://cluster.example.com",
+			hostPorts: []string{"node1.example.com:18091", "node2.example.com:18091"},
+			scheme:    "couchbases",
+		},
+		{
+			name:      "SrvFallback",
+			connStr:   "couchbase://node1.example.com",
+			hostPorts: []string{"node1.example.com:8091"},
+			scheme:    "couchbase",
+		},
+		{
+			// SRV records are only used for a single host without a port
+			name:      "SrvIgnoredWithPort",
+			connStr:   "couchbase://cluster.example.com:8091",
+			hostPorts: []string{"cluster.example.com:8091"},
+			scheme:    "couchbase",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hostPorts, scheme, err := connStrToMgmtHostPortsAndScheme(context.Background(), tc.connStr)
+			require.NoError(t, err)
+			assert.Equal(t, tc.hostPorts, hostPorts)
+			assert.Equal(t, tc.scheme, scheme)
+		})
+	}
+
+	_, _, err := connStrToMgmtHostPortsAndScheme(context.Background(), "couchbase://")
+	assert.Error(t, err)
+}
+
+func TestMgmtHostPortToAuthHostPort(t *testing.T) {
+	testCases := []struct {
+		mgmtHostPort string
+		authHostPort string
+	}{
+		{mgmtHostPort: "10.0.0.1:8091", authHostPort: "10.0.0.1:8091"},
+		{mgmtHostPort: "10.0.0.1:18091", authHostPort: "10.0.0.1:8091"},
+		{mgmtHostPort: "[::1]:18091", authHostPort: "[::1]:8091"},
+	}
+
+	for _, tc := range testCases {
+		authHostPort, err := mgmtHostPortToAuthHostPort(tc.mgmtHostPort)
+		require.NoError(t, err)
+		assert.Equal(t, tc.authHostPort, authHostPort)
+	}
+}
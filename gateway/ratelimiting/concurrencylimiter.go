@@ -0,0 +1,494 @@
+package ratelimiting
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Priority orders requests waiting for the concurrency limiter, and decides
+// which are shed first when it is overloaded.
+type Priority int
+
+const (
+	// PriorityLow is for expensive requests such as queries, which are
+	// shed before anything else.
+	PriorityLow Priority = iota
+
+	// PriorityNormal is for data operations.
+	PriorityNormal
+
+	// PriorityCritical is for health checks and administration, which are
+	// never limited so that an overloaded gateway remains manageable.
+	PriorityCritical
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityCritical:
+		return "critical"
+	}
+	return "unknown"
+}
+
+// ClassifyGrpcMethod returns the priority of a gRPC method.
+func ClassifyGrpcMethod(fullMethod string) Priority {
+	switch {
+	case strings.HasPrefix(fullMethod, "/grpc.health."),
+		strings.HasPrefix(fullMethod, "/couchbase.admin."):
+		return PriorityCritical
+	case strings.HasPrefix(fullMethod, "/couchbase.query."),
+		strings.HasPrefix(fullMethod, "/couchbase.search."),
+		strings.HasPrefix(fullMethod, "/couchbase.analytics."):
+		return PriorityLow
+	}
+	return PriorityNormal
+}
+
+// isWatchMethod returns whether a gRPC method is a watch stream, which stays
+// open for as long as the client is connected.
+func isWatchMethod(fullMethod string) bool {
+	methodName := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	return strings.HasPrefix(methodName, "Watch")
+}
+
+// ClassifyHttpRequest returns the priority of a Data API request.
+func ClassifyHttpRequest(r *http.Request) Priority {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/admin/"),
+		strings.HasPrefix(r.URL.Path, "/_p/mgmt/"):
+		return PriorityCritical
+	case strings.HasPrefix(r.URL.Path, "/_p/query/"),
+		strings.HasPrefix(r.URL.Path, "/_p/fts/"),
+		strings.HasPrefix(r.URL.Path, "/_p/cbas/"):
+		return PriorityLow
+	}
+	return PriorityNormal
+}
+
+// ErrOverloaded is returned when a request is shed by the concurrency limiter.
+var ErrOverloaded = errors.New("server is overloaded")
+
+// OverloadedError indicates a request was shed, and how long the client
+// should wait before retrying it.
+type OverloadedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *OverloadedError) Error() string {
+	return ErrOverloaded.Error()
+}
+
+func (e *OverloadedError) Unwrap() error {
+	return ErrOverloaded
+}
+
+const (
+	// longRttAlpha and shortRttAlpha smooth latency samples over roughly 600
+	// and 10 requests respectively.
+	longRttAlpha  = 2.0 / 601
+	shortRttAlpha = 2.0 / 11
+
+	// rttTolerance is how much latency may rise above its long term average
+	// before the limit is reduced.
+	rttTolerance = 1.5
+
+	// limitSmoothing is how quickly the limit moves towards its new value.
+	limitSmoothing = 0.2
+)
+
+type ConcurrencyLimiterOptions struct {
+	Logger *zap.Logger
+
+	// InitialLimit is the number of concurrent requests permitted before any
+	// latency has been observed.  Defaults to 64, within MinLimit and
+	// MaxLimit.
+	InitialLimit int
+
+	// MinLimit and MaxLimit bound the number of concurrent requests.  They
+	// default to 8 and 1024.
+	MinLimit int
+	MaxLimit int
+
+	// MaxQueue is the number of requests which may wait for the limit, after
+	// which further requests are shed.  Defaults to 128.
+	MaxQueue int
+
+	// MaxWait is how long a request may wait before being shed.  Defaults to
+	// 500ms.
+	MaxWait time.Duration
+}
+
+// ConcurrencyLimiter limits the number of requests in flight, adjusting the
+// limit from the latency it observes: the limit grows while latency is
+// stable, and shrinks when latency rises above its long term average as the
+// cluster slows down.  Requests beyond the limit wait in a bounded queue,
+// ordered by priority.
+type ConcurrencyLimiter struct {
+	logger   *zap.Logger
+	minLimit float64
+	maxLimit float64
+	maxQueue int
+	maxWait  time.Duration
+
+	lock     sync.Mutex
+	limit    float64
+	inflight int
+	longRtt  float64
+	shortRtt float64
+	queues   [PriorityCritical][]*concurrencyWaiter
+	queued   int
+
+	numShed metric.Int64Counter
+}
+
+var _ RateLimiter = (*ConcurrencyLimiter)(nil)
+
+type concurrencyWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+func NewConcurrencyLimiter(opts *ConcurrencyLimiterOptions) *ConcurrencyLimiter {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	minLimit := opts.MinLimit
+	if minLimit <= 0 {
+		minLimit = 8
+	}
+
+	maxLimit := opts.MaxLimit
+	if maxLimit <= 0 {
+		maxLimit = 1024
+	}
+	maxLimit = max(maxLimit, minLimit)
+
+	initialLimit := opts.InitialLimit
+	if initialLimit <= 0 {
+		initialLimit = 64
+	}
+	initialLimit = min(max(initialLimit, minLimit), maxLimit)
+
+	maxQueue := opts.MaxQueue
+	if maxQueue <= 0 {
+		maxQueue = 128
+	}
+
+	maxWait := opts.MaxWait
+	if maxWait <= 0 {
+		maxWait = 500 * time.Millisecond
+	}
+
+	l := &ConcurrencyLimiter{
+		logger:   logger,
+		minLimit: float64(minLimit),
+		maxLimit: float64(maxLimit),
+		maxQueue: maxQueue,
+		maxWait:  maxWait,
+		limit:    float64(initialLimit),
+	}
+
+	var err error
+	l.numShed, err = meter.Int64Counter("concurrency_limit_shed")
+	if err != nil {
+		logger.Warn("failed to initialize concurrency shed counter", zap.Error(err))
+	}
+
+	_, err = meter.Int64ObservableGauge("concurrency_limit",
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(l.Limit()))
+			return nil
+		}))
+	if err != nil {
+		logger.Warn("failed to initialize concurrency limit gauge", zap.Error(err))
+	}
+
+	return l
+}
+
+// Limit returns the current number of concurrent requests permitted.
+func (l *ConcurrencyLimiter) Limit() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return int(l.limit)
+}
+
+// acquire waits until the request may proceed, returning a function which
+// must be called once it completes.
+func (l *ConcurrencyLimiter) acquire(ctx context.Context, priority Priority) (func(), error) {
+	if priority >= PriorityCritical {
+		return func() {}, nil
+	}
+
+	l.lock.Lock()
+
+	if l.queued == 0 && float64(l.inflight) < l.limit {
+		l.inflight++
+		l.lock.Unlock()
+		return l.releaser(), nil
+	}
+
+	if l.queued >= l.maxQueue && !l.shedLowerLocked(priority) {
+		retryAfter := l.retryAfterLocked()
+		l.lock.Unlock()
+		return nil, l.shed(ctx, priority, retryAfter)
+	}
+
+	w := &concurrencyWaiter{ready: make(chan struct{})}
+	l.queues[priority] = append(l.queues[priority], w)
+	l.queued++
+	l.lock.Unlock()
+
+	timer := time.NewTimer(l.maxWait)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	l.lock.Lock()
+	if w.granted {
+		l.lock.Unlock()
+		return l.releaser(), nil
+	}
+
+	l.removeLocked(priority, w)
+	retryAfter := l.retryAfterLocked()
+	l.lock.Unlock()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return nil, l.shed(ctx, priority, retryAfter)
+}
+
+func (l *ConcurrencyLimiter) shed(ctx context.Context, priority Priority, retryAfter time.Duration) error {
+	if l.numShed != nil {
+		l.numShed.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("priority", priority.String())))
+	}
+
+	return &OverloadedError{RetryAfter: retryAfter}
+}
+
+// shedLowerLocked makes room in the queue for a request by shedding the most
+// recently queued request of a lower priority, if there is one.
+func (l *ConcurrencyLimiter) shedLowerLocked(priority Priority) bool {
+	for p := PriorityLow; p < priority; p++ {
+		queue := l.queues[p]
+		if len(queue) == 0 {
+			continue
+		}
+
+		// the waiter sees it was not granted once woken, and sheds itself
+		w := queue[len(queue)-1]
+		l.queues[p] = queue[:len(queue)-1]
+		l.queued--
+		close(w.ready)
+		return true
+	}
+
+	return false
+}
+
+func (l *ConcurrencyLimiter) removeLocked(priority Priority, w *concurrencyWaiter) {
+	queue := l.queues[priority]
+	for i, queued := range queue {
+		if queued == w {
+			l.queues[priority] = append(queue[:i], queue[i+1:]...)
+			l.queued--
+			return
+		}
+	}
+}
+
+// retryAfterLocked estimates how long it will take for the requests which are
+// in flight and queued to complete.
+func (l *ConcurrencyLimiter) retryAfterLocked() time.Duration {
+	rtt := l.longRtt
+	if rtt <= 0 {
+		rtt = float64(l.maxWait)
+	}
+
+	retryAfter := time.Duration(rtt * float64(l.inflight+l.queued+1) / l.limit)
+	return max(retryAfter, 10*time.Millisecond)
+}
+
+func (l *ConcurrencyLimiter) releaser() func() {
+	stime := time.Now()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.release(time.Since(stime))
+		})
+	}
+}
+
+func (l *ConcurrencyLimiter) release(rtt time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.updateLimitLocked(rtt, l.inflight)
+	l.inflight--
+
+	for float64(l.inflight) < l.limit && l.queued > 0 {
+		for p := PriorityCritical - 1; p >= PriorityLow; p-- {
+			queue := l.queues[p]
+			if len(queue) == 0 {
+				continue
+			}
+
+			w := queue[0]
+			l.queues[p] = queue[1:]
+			l.queued--
+			l.inflight++
+			w.granted = true
+			close(w.ready)
+			break
+		}
+	}
+}
+
+// updateLimitLocked adjusts the limit from the latency of a request which
+// completed while inflight requests were running.  While latency remains
+// near its long term average the limit grows by roughly its square root,
+// and it shrinks in proportion to how far latency rises above it.
+func (l *ConcurrencyLimiter) updateLimitLocked(rtt time.Duration, inflight int) {
+	sample := float64(rtt)
+	if sample <= 0 {
+		return
+	}
+
+	if l.longRtt == 0 {
+		l.longRtt = sample
+		l.shortRtt = sample
+	} else {
+		l.longRtt += (sample - l.longRtt) * longRttAlpha
+		l.shortRtt += (sample - l.shortRtt) * shortRttAlpha
+	}
+
+	// once latency has recovered from a slowdown, the long term average is
+	// pulled down faster so that the limit can grow again.
+	if l.longRtt/l.shortRtt > 2 {
+		l.longRtt *= 0.95
+	}
+
+	// the limit can only be learned while there is enough load to reach it
+	if float64(inflight) < l.limit/2 {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1, rttTolerance*l.longRtt/l.shortRtt))
+	newLimit := l.limit*gradient + math.Sqrt(l.limit)
+	newLimit = l.limit*(1-limitSmoothing) + newLimit*limitSmoothing
+
+	l.limit = math.Max(l.minLimit, math.Min(l.maxLimit, newLimit))
+}
+
+func overloadedStatus(err error) error {
+	var overloadedErr *OverloadedError
+	if !errors.As(err, &overloadedErr) {
+		return status.FromContextError(err).Err()
+	}
+
+	st := status.New(codes.ResourceExhausted, "The server is overloaded, please retry later.")
+	if detailedSt, detailErr := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(overloadedErr.RetryAfter),
+	}); detailErr == nil {
+		st = detailedSt
+	}
+
+	return st.Err()
+}
+
+func (l *ConcurrencyLimiter) GrpcUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		release, err := l.acquire(ctx, ClassifyGrpcMethod(info.FullMethod))
+		if err != nil {
+			return nil, overloadedStatus(err)
+		}
+		defer release()
+
+		return handler(ctx, req)
+	}
+}
+
+// GrpcStreamInterceptor limits streams until they send their first message,
+// so that long-lived streams do not hold on to the limit, and latency is
+// sampled as the time to the first message rather than the stream's
+// lifetime.  Watch streams are not limited.
+func (l *ConcurrencyLimiter) GrpcStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if isWatchMethod(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		release, err := l.acquire(ss.Context(), ClassifyGrpcMethod(info.FullMethod))
+		if err != nil {
+			return overloadedStatus(err)
+		}
+		defer release()
+
+		return handler(srv, &firstMessageServerStream{
+			ServerStream: ss,
+			release:      release,
+		})
+	}
+}
+
+// firstMessageServerStream releases the concurrency limit once the first
+// message of a stream is sent.
+type firstMessageServerStream struct {
+	grpc.ServerStream
+	release func()
+}
+
+func (s *firstMessageServerStream) SendMsg(m interface{}) error {
+	s.release()
+	return s.ServerStream.SendMsg(m)
+}
+
+func (l *ConcurrencyLimiter) HttpMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		release, err := l.acquire(r.Context(), ClassifyHttpRequest(r))
+		if err != nil {
+			var overloadedErr *OverloadedError
+			if errors.As(err, &overloadedErr) {
+				retryAfterSecs := int(math.Ceil(overloadedErr.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(max(retryAfterSecs, 1)))
+			}
+
+			http.Error(w, "server is overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+
+		next.ServeHTTP(w, r)
+	})
+}
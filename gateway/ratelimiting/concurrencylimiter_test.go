@@ -0,0 +1,193 @@
+package ratelimiting
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConcurrencyLimiterGradient(t *testing.T) {
+	limiter := NewConcurrencyLimiter(&ConcurrencyLimiterOptions{
+		InitialLimit: 20,
+		MinLimit:     5,
+		MaxLimit:     100,
+	})
+
+	// stable latency under load grows the limit
+	for i := 0; i < 50; i++ {
+		limiter.lock.Lock()
+		limiter.updateLimitLocked(10*time.Millisecond, int(limiter.limit))
+		limiter.lock.Unlock()
+	}
+	grown := limiter.Limit()
+	assert.Greater(t, grown, 20)
+	assert.LessOrEqual(t, grown, 100)
+
+	// but not without the load to justify it
+	limiter.lock.Lock()
+	limiter.updateLimitLocked(10*time.Millisecond, 1)
+	limiter.lock.Unlock()
+	assert.Equal(t, grown, limiter.Limit())
+
+	// rising latency shrinks the limit, down to the minimum
+	for i := 0; i < 200; i++ {
+		limiter.lock.Lock()
+		limiter.updateLimitLocked(100*time.Millisecond, int(limiter.limit))
+		limiter.lock.Unlock()
+	}
+	assert.Less(t, limiter.Limit(), grown)
+	assert.GreaterOrEqual(t, limiter.Limit(), 5)
+}
+
+func TestConcurrencyLimiterQueue(t *testing.T) {
+	limiter := NewConcurrencyLimiter(&ConcurrencyLimiterOptions{
+		MinLimit: 1,
+		MaxLimit: 1,
+		MaxQueue: 1,
+		MaxWait:  5 * time.Second,
+	})
+	ctx := context.Background()
+
+	release, err := limiter.acquire(ctx, PriorityNormal)
+	require.NoError(t, err)
+
+	// critical requests are never limited
+	releaseCritical, err := limiter.acquire(ctx, PriorityCritical)
+	require.NoError(t, err)
+	releaseCritical()
+
+	lowResult := make(chan error, 1)
+	go func() {
+		release, err := limiter.acquire(ctx, PriorityLow)
+		if err == nil {
+			release()
+		}
+		lowResult <- err
+	}()
+	require.Eventually(t, func() bool {
+		limiter.lock.Lock()
+		defer limiter.lock.Unlock()
+		return limiter.queued == 1
+	}, time.Second, time.Millisecond)
+
+	// the queue is full, so a request of the same priority is shed...
+	_, err = limiter.acquire(ctx, PriorityLow)
+	var overloadedErr *OverloadedError
+	require.ErrorAs(t, err, &overloadedErr)
+	assert.Positive(t, overloadedErr.RetryAfter)
+
+	// ...while a higher priority request takes the place of the queued one
+	normalResult := make(chan error, 1)
+	go func() {
+		release, err := limiter.acquire(ctx, PriorityNormal)
+		if err == nil {
+			release()
+		}
+		normalResult <- err
+	}()
+	assert.ErrorIs(t, <-lowResult, ErrOverloaded)
+
+	require.Eventually(t, func() bool {
+		limiter.lock.Lock()
+		defer limiter.lock.Unlock()
+		return limiter.queued == 1
+	}, time.Second, time.Millisecond)
+
+	release()
+	assert.NoError(t, <-normalResult)
+
+	limiter.lock.Lock()
+	assert.Equal(t, 0, limiter.inflight)
+	assert.Equal(t, 0, limiter.queued)
+	limiter.lock.Unlock()
+}
+
+func TestConcurrencyLimiterMaxWait(t *testing.T) {
+	limiter := NewConcurrencyLimiter(&ConcurrencyLimiterOptions{
+		MinLimit: 1,
+		MaxLimit: 1,
+		MaxWait:  10 * time.Millisecond,
+	})
+
+	release, err := limiter.acquire(context.Background(), PriorityNormal)
+	require.NoError(t, err)
+	defer release()
+
+	_, err = limiter.acquire(context.Background(), PriorityNormal)
+	st := status.Convert(overloadedStatus(err))
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Len(t, st.Details(), 1)
+	assert.IsType(t, &errdetails.RetryInfo{}, st.Details()[0])
+
+	handler := limiter.HttpMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/callerIdentity", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/admin/apiKeys", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testServerStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func TestConcurrencyLimiterStreams(t *testing.T) {
+	limiter := NewConcurrencyLimiter(&ConcurrencyLimiterOptions{
+		InitialLimit: 1,
+		MinLimit:     1,
+		MaxLimit:     1,
+		MaxWait:      10 * time.Millisecond,
+	})
+
+	interceptor := limiter.GrpcStreamInterceptor()
+	ss := &testServerStream{ctx: context.Background()}
+	streamInfo := func(fullMethod string) *grpc.StreamServerInfo {
+		return &grpc.StreamServerInfo{FullMethod: fullMethod, IsServerStream: true}
+	}
+	inflight := func() int {
+		limiter.lock.Lock()
+		defer limiter.lock.Unlock()
+		return limiter.inflight
+	}
+
+	// streams hold the limit only until their first message is sent
+	err := interceptor(nil, ss, streamInfo("/couchbase.kv.v1.KvService/RangeScan"), func(srv interface{}, stream grpc.ServerStream) error {
+		assert.Equal(t, 1, inflight())
+		require.NoError(t, stream.SendMsg(nil))
+		assert.Equal(t, 0, inflight())
+		return stream.SendMsg(nil)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, inflight())
+
+	// and watch streams are never limited
+	err = interceptor(nil, ss, streamInfo("/couchbase.routing.v1.RoutingService/WatchRouting"), func(srv interface{}, stream grpc.ServerStream) error {
+		assert.Equal(t, 0, inflight())
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,319 @@
+package ratelimiting
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/oapi-codegen/runtime/strictmiddleware/nethttp"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// Costs assigns the number of tokens each request consumes from the rate
+// limiters, so that expensive operations can be budgeted accordingly.
+type Costs struct {
+	// Default is the cost of operations without a specific cost.  Defaults
+	// to 1.
+	Default float64
+
+	// Operations specifies the cost of particular operations, identified by
+	// their full gRPC method name (/couchbase.query.v1.QueryService/Query),
+	// their Data API operation id (GetDocument) or the name of the service
+	// they are proxied to (proxy:query).
+	Operations map[string]float64
+
+	// RequestBytesPerToken and ResponseBytesPerToken additionally charge a
+	// token for each multiple of the given number of bytes in the request
+	// and response, once the request completes.  Zero disables charging for
+	// bytes.
+	RequestBytesPerToken  int
+	ResponseBytesPerToken int
+}
+
+func validateCosts(costs Costs) error {
+	if costs.Default < 0 {
+		return fmt.Errorf("invalid default rate limit cost")
+	}
+
+	for operation, cost := range costs.Operations {
+		if cost < 0 {
+			return fmt.Errorf("invalid rate limit cost for `%s`", operation)
+		}
+	}
+
+	if costs.RequestBytesPerToken < 0 || costs.ResponseBytesPerToken < 0 {
+		return fmt.Errorf("invalid rate limit bytes per token")
+	}
+
+	return nil
+}
+
+// CostTable holds the costs which are shared by the rate limiters, allowing
+// them to be updated at runtime.  A nil CostTable charges 1 per request.
+type CostTable struct {
+	costs atomic.Pointer[Costs]
+}
+
+func NewCostTable(costs Costs) (*CostTable, error) {
+	t := &CostTable{}
+
+	err := t.Update(costs)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *CostTable) Update(costs Costs) error {
+	err := validateCosts(costs)
+	if err != nil {
+		return err
+	}
+
+	t.costs.Store(&costs)
+	return nil
+}
+
+// OperationCost returns the cost of an operation.
+func (t *CostTable) OperationCost(operation string) float64 {
+	if t == nil {
+		return 1
+	}
+
+	costs := t.costs.Load()
+	if cost, ok := costs.Operations[operation]; ok {
+		return cost
+	}
+	if costs.Default > 0 {
+		return costs.Default
+	}
+	return 1
+}
+
+// BytesCost returns the additional cost of a request for the bytes it
+// transferred.
+func (t *CostTable) BytesCost(requestBytes, responseBytes int64) float64 {
+	if t == nil {
+		return 0
+	}
+
+	costs := t.costs.Load()
+
+	var cost float64
+	if costs.RequestBytesPerToken > 0 {
+		cost += float64(requestBytes) / float64(costs.RequestBytesPerToken)
+	}
+	if costs.ResponseBytesPerToken > 0 {
+		cost += float64(responseBytes) / float64(costs.ResponseBytesPerToken)
+	}
+	return cost
+}
+
+// ChargesBytes returns whether requests are charged for their bytes, so that
+// they need not be counted otherwise.
+func (t *CostTable) ChargesBytes() bool {
+	if t == nil {
+		return false
+	}
+
+	costs := t.costs.Load()
+	return costs.RequestBytesPerToken > 0 || costs.ResponseBytesPerToken > 0
+}
+
+// pendingAdmission is a Data API request which is yet to be admitted by a
+// rate limiter, as the operation it performs is only known once it has been
+// routed.
+type pendingAdmission struct {
+	costs  *CostTable
+	admit  func(ctx context.Context, cost float64) (*Quota, bool)
+	done   bool
+	result bool
+
+	// rejectedBy is the quota which rejected the request, which may belong to
+	// another limiter the request passed through.
+	rejectedBy *Quota
+}
+
+type ctxKeyPendingAdmissions struct{}
+
+func withPendingAdmission(ctx context.Context, admission *pendingAdmission) context.Context {
+	existing, _ := ctx.Value(ctxKeyPendingAdmissions{}).([]*pendingAdmission)
+	admissions := append(existing[:len(existing):len(existing)], admission)
+	return context.WithValue(ctx, ctxKeyPendingAdmissions{}, admissions)
+}
+
+// AdmitHttpOperation charges an HTTP request to the rate limiters it passed
+// through according to the cost of its operation.  It returns the quota with
+// the fewest requests remaining, if any limits apply, or a RateLimitedError
+// if any of the limiters rejects the request.
+func AdmitHttpOperation(ctx context.Context, operation string) (*Quota, error) {
+	var tightest *Quota
+
+	admissions, _ := ctx.Value(ctxKeyPendingAdmissions{}).([]*pendingAdmission)
+	for i, admission := range admissions {
+		if admission.done {
+			if !admission.result {
+				return nil, &RateLimitedError{Quota: *admission.rejectedBy}
+			}
+			continue
+		}
+
+		quota, ok := admission.admit(ctx, admission.costs.OperationCost(operation))
+		admission.done = true
+		admission.result = ok
+		if !ok {
+			admission.rejectedBy = quota
+
+			// the remaining limiters are not charged for a rejected request
+			for _, remaining := range admissions[i+1:] {
+				remaining.done = true
+				remaining.rejectedBy = quota
+			}
+			return nil, &RateLimitedError{Quota: *quota}
+		}
+
+		tightest = tightest.tighter(quota)
+	}
+
+	return tightest, nil
+}
+
+// NewStrictHttpMiddleware admits Data API requests to the rate limiters once
+// their operation is known.
+func NewStrictHttpMiddleware() nethttp.StrictHTTPMiddlewareFunc {
+	return func(f nethttp.StrictHTTPHandlerFunc, operationID string) nethttp.StrictHTTPHandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+			quota, err := AdmitHttpOperation(ctx, operationID)
+			if err != nil {
+				WriteRateLimited(w, err)
+				return nil, nil
+			}
+			SetQuotaHeaders(w.Header(), quota)
+
+			return f(ctx, w, r, request)
+		}
+	}
+}
+
+// messageSize returns the encoded size of a gRPC message.
+func messageSize(m interface{}) int64 {
+	if msg, ok := m.(proto.Message); ok {
+		return int64(proto.Size(msg))
+	}
+	return 0
+}
+
+// countingServerStream counts the bytes of the messages sent and received
+// over a stream.
+type countingServerStream struct {
+	grpc.ServerStream
+	recvBytes int64
+	sentBytes int64
+}
+
+func (s *countingServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.recvBytes += messageSize(m)
+	}
+	return err
+}
+
+func (s *countingServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sentBytes += messageSize(m)
+	}
+	return err
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+type countingResponseWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *countingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// serveHttpWithCosts serves a request which is admitted once its operation is
+// known, see AdmitHttpOperation.  Requests which are never admitted, such as
+// those for unknown paths, are charged the default cost once they complete,
+// along with the cost of the bytes they transferred.
+func serveHttpWithCosts(
+	w http.ResponseWriter,
+	r *http.Request,
+	next http.Handler,
+	costs *CostTable,
+	admit func(ctx context.Context, cost float64) (*Quota, bool),
+	charge func(ctx context.Context, cost float64),
+) {
+	admission := &pendingAdmission{
+		costs: costs,
+		admit: admit,
+	}
+	ctx := withPendingAdmission(r.Context(), admission)
+	r = r.WithContext(ctx)
+
+	if !costs.ChargesBytes() {
+		next.ServeHTTP(w, r)
+
+		if !admission.done {
+			charge(ctx, costs.OperationCost(""))
+		}
+		return
+	}
+
+	var body *countingReadCloser
+	if r.Body != nil {
+		body = &countingReadCloser{ReadCloser: r.Body}
+		r.Body = body
+	}
+	countingW := &countingResponseWriter{ResponseWriter: w}
+
+	next.ServeHTTP(countingW, r)
+
+	if admission.done && !admission.result {
+		return
+	}
+
+	var cost float64
+	if !admission.done {
+		cost += costs.OperationCost("")
+	}
+
+	var requestBytes int64
+	if body != nil {
+		requestBytes = body.n
+	}
+	cost += costs.BytesCost(requestBytes, countingW.n)
+
+	charge(ctx, cost)
+}
@@ -0,0 +1,164 @@
+package ratelimiting
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestCostTable(t *testing.T) {
+	var nilCosts *CostTable
+	assert.Equal(t, 1.0, nilCosts.OperationCost("/couchbase.kv.v1.KvService/Get"))
+	assert.False(t, nilCosts.ChargesBytes())
+
+	costs, err := NewCostTable(Costs{
+		Operations: map[string]float64{
+			"/couchbase.query.v1.QueryService/Query": 10,
+			"proxy:query":                            5,
+		},
+		ResponseBytesPerToken: 1024,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 10.0, costs.OperationCost("/couchbase.query.v1.QueryService/Query"))
+	assert.Equal(t, 5.0, costs.OperationCost("proxy:query"))
+	assert.Equal(t, 1.0, costs.OperationCost("GetDocument"))
+	assert.True(t, costs.ChargesBytes())
+	assert.Equal(t, 2.0, costs.BytesCost(4096, 2048))
+
+	assert.Error(t, costs.Update(Costs{Default: -1}))
+	assert.Error(t, costs.Update(Costs{Operations: map[string]float64{"GetDocument": -1}}))
+	assert.Equal(t, 10.0, costs.OperationCost("/couchbase.query.v1.QueryService/Query"))
+}
+
+func TestTokenBucketCostAboveBurst(t *testing.T) {
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Now()
+	bucket := newTokenBucket(now, limit)
+
+	// a request costing more than the burst is permitted from a full bucket,
+	// and must then be repaid before further requests.
+	ok, _ := bucket.take(now, limit, 5)
+	assert.True(t, ok)
+
+	ok, wait := bucket.take(now, limit, 1)
+	assert.False(t, ok)
+	assert.Equal(t, 4*time.Second, wait)
+
+	ok, _ = bucket.take(now.Add(4*time.Second), limit, 1)
+	assert.True(t, ok)
+}
+
+func TestGlobalRateLimiterFractionalCosts(t *testing.T) {
+	limiter := NewGlobalRateLimiter(1, time.Hour, nil)
+
+	// costs below half a request still add up to whole requests
+	for i := 0; i < 4; i++ {
+		_, ok := limiter.checkAllowed(0.25)
+		require.True(t, ok)
+	}
+	limiter.charge(0.4)
+
+	_, ok := limiter.checkAllowed(0.25)
+	assert.False(t, ok)
+}
+
+func TestKeyedRateLimiterOperationCosts(t *testing.T) {
+	costs, err := NewCostTable(Costs{
+		Operations: map[string]float64{
+			"/couchbase.query.v1.QueryService/Query": 5,
+		},
+	})
+	require.NoError(t, err)
+
+	limiter, err := NewKeyedRateLimiter(&KeyedRateLimiterOptions{
+		Rules: []KeyedRule{
+			{KeyType: KeyTypeIP, Default: Limit{Rate: 0.001, Burst: 6}},
+		},
+		Costs: costs,
+	})
+	require.NoError(t, err)
+
+	ctx := reqctx.WithClientIP(context.Background(), "10.0.0.1")
+	interceptor := limiter.GrpcUnaryInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	call := func(method string) error {
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	require.NoError(t, call("/couchbase.query.v1.QueryService/Query"))
+	require.NoError(t, call("/couchbase.kv.v1.KvService/Get"))
+	assert.Error(t, call("/couchbase.kv.v1.KvService/Get"))
+}
+
+func TestHttpBytesCosts(t *testing.T) {
+	costs, err := NewCostTable(Costs{
+		RequestBytesPerToken:  10,
+		ResponseBytesPerToken: 10,
+	})
+	require.NoError(t, err)
+
+	limiter := NewGlobalRateLimiter(10, time.Hour, costs)
+
+	handler := limiter.HttpMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := AdmitHttpOperation(r.Context(), "CreateDocument"); err != nil {
+			WriteRateLimited(w, err)
+			return
+		}
+
+		_, _ = io.ReadAll(r.Body)
+		_, _ = w.Write([]byte(strings.Repeat("x", 30)))
+	}))
+
+	// the first request costs 1 up front, then 2 for its request body and 3
+	// for its response, leaving 4 requests.
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/", strings.NewReader(strings.Repeat("x", 20))))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+	assert.Equal(t, uint64(6*requestUnits), limiter.getState().Requests.Load())
+	assert.Equal(t, http.StatusOK, serve())
+	assert.Equal(t, http.StatusTooManyRequests, serve())
+}
+
+func TestHttpRejectedAdmission(t *testing.T) {
+	limiter := NewGlobalRateLimiter(1, time.Hour, nil)
+
+	var errs []error
+	handler := limiter.HttpMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := AdmitHttpOperation(r.Context(), "GetDocument")
+		errs = append(errs, err)
+		if err != nil {
+			// requests which were already rejected report the same quota
+			_, err = AdmitHttpOperation(r.Context(), "GetDocument")
+			errs = append(errs, err)
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/", nil))
+
+	require.Len(t, errs, 3)
+	assert.NoError(t, errs[0])
+	for _, err := range errs[1:] {
+		var rateLimitedErr *RateLimitedError
+		require.ErrorAs(t, err, &rateLimitedErr)
+		assert.Equal(t, "global", rateLimitedErr.Quota.Subject)
+		assert.Equal(t, 1, rateLimitedErr.Quota.Limit)
+	}
+}
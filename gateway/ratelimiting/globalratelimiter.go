@@ -2,27 +2,48 @@ package ratelimiting
 
 import (
 	"context"
+	"math"
 	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
+// requestUnits is the number of units each request is counted in, so that
+// fractional costs accumulate rather than being rounded away.
+const requestUnits = 1000
+
+// costUnits converts a cost in requests to the units which are counted.
+func costUnits(cost float64) uint64 {
+	if cost <= 0 {
+		return 0
+	}
+	return uint64(math.Round(cost * requestUnits))
+}
+
 type globalRateState struct {
 	MaximumRequests uint64
 	Period          time.Duration
 
 	ResetTime time.Time
-	Requests  atomic.Uint64
+
+	// Requests counts the cost of the requests made in this period, in
+	// thousandths of a request, see requestUnits.
+	Requests atomic.Uint64
+
+	// Shared counts the requests made to other instances in this period, and
+	// Synced the requests made to this one which have been added to the
+	// shared counters, see SyncShared.
+	Shared atomic.Uint64
+	Synced atomic.Uint64
 }
 
 type GlobalRateLimiter struct {
 	lock  sync.Mutex
 	state atomic.Pointer[globalRateState]
+	costs *CostTable
 }
 
 var _ RateLimiter = (*GlobalRateLimiter)(nil)
@@ -37,7 +58,10 @@ func calculateAlignedResetTime(now time.Time, period time.Duration) time.Time {
 	return resetTime
 }
 
-func NewGlobalRateLimiter(maximumRequests uint64, period time.Duration) *GlobalRateLimiter {
+// NewGlobalRateLimiter creates a limiter which permits maximumRequests per
+// period across all requests, charging each according to costs.  Costs are
+// counted to a thousandth of a request, so fractional costs add up.
+func NewGlobalRateLimiter(maximumRequests uint64, period time.Duration, costs *CostTable) *GlobalRateLimiter {
 	now := time.Now()
 	resetTime := calculateAlignedResetTime(now, period)
 
@@ -47,7 +71,9 @@ func NewGlobalRateLimiter(maximumRequests uint64, period time.Duration) *GlobalR
 		ResetTime:       resetTime,
 	}
 
-	limiter := &GlobalRateLimiter{}
+	limiter := &GlobalRateLimiter{
+		costs: costs,
+	}
 	limiter.state.Store(state)
 
 	return limiter
@@ -83,16 +109,53 @@ func (l *GlobalRateLimiter) getState() *globalRateState {
 	return state
 }
 
-func (l *GlobalRateLimiter) checkAllowed() bool {
+// checkAllowed counts a request against the limit, returning the quota which
+// remains in the current period, or nil if requests are not limited.
+func (l *GlobalRateLimiter) checkAllowed(cost float64) (*Quota, bool) {
 	state := l.getState()
-	reqNum := state.Requests.Add(1)
+	units := costUnits(cost)
+
+	var reqUnits uint64
+	if units > 0 {
+		reqUnits = state.Requests.Add(units)
+	} else {
+		reqUnits = state.Requests.Load()
+	}
+	reqUnits += state.Shared.Load()
 
 	if state.MaximumRequests == 0 {
-		return true
+		return nil, true
 	}
 
-	// we use <= rather than < here because reqNum is 1-based
-	return reqNum <= uint64(state.MaximumRequests)
+	maximumUnits := state.MaximumRequests * requestUnits
+
+	reset := time.Until(state.ResetTime)
+	quota := &Quota{
+		Subject: "global",
+		Limit:   int(state.MaximumRequests),
+		Reset:   reset,
+	}
+	if reqUnits < maximumUnits {
+		quota.Remaining = int((maximumUnits - reqUnits) / requestUnits)
+	}
+
+	// we use <= rather than < here because the request is already counted
+	if reqUnits <= maximumUnits || units == 0 {
+		return quota, true
+	}
+
+	quota.RetryAfter = reset
+	return quota, false
+}
+
+// charge counts requests which have already been performed.
+func (l *GlobalRateLimiter) charge(cost float64) {
+	units := costUnits(cost)
+	if units == 0 {
+		return
+	}
+
+	l.getState().Requests.Add(units)
 }
 
 // SetRateLimit updates the rate limit for this limiter.  Note that this resets
@@ -113,31 +176,48 @@ func (l *GlobalRateLimiter) ResetAndUpdateRateLimit(maximumRequests uint64, peri
 
 func (l *GlobalRateLimiter) GrpcUnaryInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		if !l.checkAllowed() {
-			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded")
+		if quota, ok := l.checkAllowed(l.costs.OperationCost(info.FullMethod)); !ok {
+			return nil, rateLimitedStatus(*quota)
 		}
 
-		return handler(ctx, req)
+		resp, err = handler(ctx, req)
+
+		if l.costs.ChargesBytes() {
+			l.charge(l.costs.BytesCost(messageSize(req), messageSize(resp)))
+		}
+
+		return resp, err
 	}
 }
 
 func (l *GlobalRateLimiter) GrpcStreamInterceptor() grpc.StreamServerInterceptor {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		if !l.checkAllowed() {
-			return status.Errorf(codes.ResourceExhausted, "rate limit exceeded")
+		if quota, ok := l.checkAllowed(l.costs.OperationCost(info.FullMethod)); !ok {
+			return rateLimitedStatus(*quota)
+		}
+
+		if !l.costs.ChargesBytes() {
+			return handler(srv, ss)
 		}
 
-		return handler(srv, ss)
+		stream := &countingServerStream{ServerStream: ss}
+		err := handler(srv, stream)
+
+		l.charge(l.costs.BytesCost(stream.recvBytes, stream.sentBytes))
+
+		return err
 	}
 }
 
 func (l *GlobalRateLimiter) HttpMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !l.checkAllowed() {
-			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
-			return
+		admit := func(ctx context.Context, cost float64) (*Quota, bool) {
+			return l.checkAllowed(cost)
+		}
+		charge := func(ctx context.Context, cost float64) {
+			l.charge(cost)
 		}
 
-		next.ServeHTTP(w, r)
+		serveHttpWithCosts(w, r, next, l.costs, admit, charge)
 	})
 }
@@ -0,0 +1,574 @@
+package ratelimiting
+
+import (
+	"container/list"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+var meter = otel.Meter("github.com/couchbase/stellar-gateway/gateway/ratelimiting")
+
+type KeyType string
+
+const (
+	// KeyTypeUser limits each authenticated user requests are performed on
+	// behalf of.  These limits are applied once the handler of a request has
+	// authenticated it, see reqctx.Authenticated.
+	KeyTypeUser KeyType = "user"
+
+	// KeyTypeCert limits each verified client certificate, identified by its
+	// subject common name.
+	KeyTypeCert KeyType = "cert"
+
+	// KeyTypeBucket limits each bucket targeted by requests.
+	KeyTypeBucket KeyType = "bucket"
+
+	// KeyTypeIP limits each source address.
+	KeyTypeIP KeyType = "ip"
+)
+
+// KeyedRule limits requests separately for each key of a particular type.
+type KeyedRule struct {
+	KeyType KeyType
+
+	// Default is the limit of keys without an override.
+	Default Limit
+
+	// Overrides specifies the limits of particular keys.
+	Overrides map[string]Limit
+}
+
+func (r *KeyedRule) limitFor(key string) Limit {
+	if limit, ok := r.Overrides[key]; ok {
+		return limit
+	}
+	return r.Default
+}
+
+func validateRules(rules []KeyedRule) error {
+	seen := make(map[KeyType]bool)
+	for _, rule := range rules {
+		switch rule.KeyType {
+		case KeyTypeUser, KeyTypeCert, KeyTypeBucket, KeyTypeIP:
+		default:
+			return fmt.Errorf("unknown rate limit key type `%s`", rule.KeyType)
+		}
+
+		if seen[rule.KeyType] {
+			return fmt.Errorf("duplicate rate limit for key type `%s`", rule.KeyType)
+		}
+		seen[rule.KeyType] = true
+
+		if rule.Default.Rate < 0 || rule.Default.Burst < 0 {
+			return fmt.Errorf("invalid default rate limit for key type `%s`", rule.KeyType)
+		}
+		for key, limit := range rule.Overrides {
+			if limit.Rate < 0 || limit.Burst < 0 {
+				return fmt.Errorf("invalid rate limit for %s `%s`", rule.KeyType, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+const numShards = 16
+
+// sweepInterval is how often each shard removes the buckets of idle keys.
+const sweepInterval = 10 * time.Second
+
+type keyEntry struct {
+	key    string
+	bucket tokenBucket
+}
+
+// shard holds the buckets of a subset of keys, ordered by when they were
+// last used so that the least recently used keys can be evicted.
+type shard struct {
+	lock      sync.Mutex
+	entries   map[string]*list.Element
+	lru       *list.List
+	lastSweep time.Time
+}
+
+type ruleState struct {
+	rule   KeyedRule
+	shards [numShards]*shard
+}
+
+func newRuleState(rule KeyedRule) *ruleState {
+	rs := &ruleState{rule: rule}
+	for i := range rs.shards {
+		rs.shards[i] = &shard{
+			entries: make(map[string]*list.Element),
+			lru:     list.New(),
+		}
+	}
+	return rs
+}
+
+func (rs *ruleState) shardFor(key string) *shard {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return rs.shards[hash.Sum32()%numShards]
+}
+
+type KeyedRateLimiterOptions struct {
+	Logger *zap.Logger
+	Rules  []KeyedRule
+
+	// GatewayUser returns the user which requests acting as the gateway
+	// itself are limited as, when they did not authenticate with a username.
+	GatewayUser func() string
+
+	// MaxKeys bounds the number of keys tracked by each rule.  Defaults to
+	// 100000.
+	MaxKeys int
+
+	// Costs specifies how many tokens each request consumes.
+	Costs *CostTable
+}
+
+// KeyedRateLimiter limits requests using a token bucket per user, client
+// certificate, bucket or source address.  Keys whose buckets have refilled
+// are forgotten, and the least recently used keys are evicted when there are
+// too many.
+type KeyedRateLimiter struct {
+	logger          *zap.Logger
+	gatewayUser     func() string
+	maxKeysPerShard int
+	costs           *CostTable
+
+	updateLock sync.Mutex
+	rules      atomic.Pointer[[]*ruleState]
+
+	rejected metric.Int64Counter
+}
+
+var _ RateLimiter = (*KeyedRateLimiter)(nil)
+
+func NewKeyedRateLimiter(opts *KeyedRateLimiterOptions) (*KeyedRateLimiter, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	maxKeys := opts.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = 100000
+	}
+
+	rejected, err := meter.Int64Counter("rate_limit_rejected")
+	if err != nil {
+		logger.Warn("failed to initialize rate limit rejected counter", zap.Error(err))
+	}
+
+	l := &KeyedRateLimiter{
+		logger:          logger,
+		gatewayUser:     opts.GatewayUser,
+		maxKeysPerShard: max(1, maxKeys/numShards),
+		costs:           opts.Costs,
+		rejected:        rejected,
+	}
+
+	err = l.UpdateRules(opts.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// UpdateRules replaces the rules of the limiter.  The buckets of key types
+// which remain limited are kept, and refill according to their new limits.
+func (l *KeyedRateLimiter) UpdateRules(rules []KeyedRule) error {
+	err := validateRules(rules)
+	if err != nil {
+		return err
+	}
+
+	l.updateLock.Lock()
+	defer l.updateLock.Unlock()
+
+	existing := make(map[KeyType]*ruleState)
+	if oldStates := l.rules.Load(); oldStates != nil {
+		for _, rs := range *oldStates {
+			existing[rs.rule.KeyType] = rs
+		}
+	}
+
+	states := make([]*ruleState, 0, len(rules))
+	for _, rule := range rules {
+		rs := newRuleState(rule)
+		if oldRs, ok := existing[rule.KeyType]; ok {
+			rs.shards = oldRs.shards
+		}
+		states = append(states, rs)
+	}
+
+	l.rules.Store(&states)
+	return nil
+}
+
+type requestInfo struct {
+	connState *tls.ConnectionState
+
+	// user is only known once the request has been authenticated, and
+	// rejected indicates that its user limits then rejected it.
+	user     string
+	rejected bool
+}
+
+func (l *KeyedRateLimiter) keyFor(ctx context.Context, keyType KeyType, info *requestInfo) string {
+	switch keyType {
+	case KeyTypeUser:
+		return info.user
+	case KeyTypeCert:
+		if info.connState == nil || len(info.connState.VerifiedChains) == 0 {
+			return ""
+		}
+		return info.connState.VerifiedChains[0][0].Subject.CommonName
+	case KeyTypeBucket:
+		return reqctx.ResourceFromContext(ctx).Bucket
+	case KeyTypeIP:
+		return reqctx.ClientIPFromContext(ctx)
+	}
+
+	return ""
+}
+
+type takenTokens struct {
+	shard *shard
+	key   string
+	limit Limit
+}
+
+// check takes cost tokens from the bucket of each key of the request, for the
+// rules which match filter, returning the quota with the fewest requests
+// remaining.  If any bucket is empty, the tokens taken from the others are
+// returned along with its quota.
+func (l *KeyedRateLimiter) check(ctx context.Context, info *requestInfo, filter func(KeyType) bool, cost float64) (*Quota, bool) {
+	now := time.Now()
+
+	var tightest *Quota
+	var taken []takenTokens
+	for _, rs := range *l.rules.Load() {
+		if !filter(rs.rule.KeyType) {
+			continue
+		}
+
+		key := l.keyFor(ctx, rs.rule.KeyType, info)
+		if key == "" {
+			continue
+		}
+
+		limit := rs.rule.limitFor(key)
+		if limit.Unlimited() {
+			continue
+		}
+
+		s := rs.shardFor(key)
+		ok, quota := l.take(s, &rs.rule, now, key, limit, cost)
+		quota.Subject = string(rs.rule.KeyType) + ":" + key
+		if !ok {
+			for _, t := range taken {
+				t.shard.giveBack(t.key, t.limit, cost)
+			}
+
+			if l.rejected != nil {
+				l.rejected.Add(ctx, 1, metric.WithAttributes(
+					attribute.String("key_type", string(rs.rule.KeyType))))
+			}
+
+			return &quota, false
+		}
+
+		tightest = tightest.tighter(&quota)
+		taken = append(taken, takenTokens{shard: s, key: key, limit: limit})
+	}
+
+	return tightest, true
+}
+
+// charge removes cost tokens from the bucket of each key of a request which
+// has already been performed, for the rules which match filter.
+func (l *KeyedRateLimiter) charge(ctx context.Context, info *requestInfo, filter func(KeyType) bool, cost float64) {
+	if cost <= 0 || info.rejected {
+		return
+	}
+
+	now := time.Now()
+
+	for _, rs := range *l.rules.Load() {
+		if !filter(rs.rule.KeyType) {
+			continue
+		}
+
+		key := l.keyFor(ctx, rs.rule.KeyType, info)
+		if key == "" {
+			continue
+		}
+
+		limit := rs.rule.limitFor(key)
+		if limit.Unlimited() {
+			continue
+		}
+
+		s := rs.shardFor(key)
+		s.lock.Lock()
+		l.entryFor(s, &rs.rule, now, key, limit).bucket.charge(now, limit, cost)
+		s.lock.Unlock()
+	}
+}
+
+func (l *KeyedRateLimiter) take(s *shard, rule *KeyedRule, now time.Time, key string, limit Limit, cost float64) (bool, Quota) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	bucket := &l.entryFor(s, rule, now, key, limit).bucket
+	ok, wait := bucket.take(now, limit, cost)
+
+	quota := bucket.quota(limit)
+	quota.RetryAfter = wait
+	return ok, quota
+}
+
+// entryFor returns the bucket of a key, creating it and evicting the least
+// recently used keys as needed.  Must be called with the lock held.
+func (l *KeyedRateLimiter) entryFor(s *shard, rule *KeyedRule, now time.Time, key string, limit Limit) *keyEntry {
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now, rule)
+	}
+
+	if elem, ok := s.entries[key]; ok {
+		s.lru.MoveToFront(elem)
+		return elem.Value.(*keyEntry)
+	}
+
+	for len(s.entries) >= l.maxKeysPerShard {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*keyEntry).key)
+	}
+
+	entry := &keyEntry{
+		key:    key,
+		bucket: newTokenBucket(now, limit),
+	}
+	s.entries[key] = s.lru.PushFront(entry)
+
+	return entry
+}
+
+// sweep removes the least recently used buckets which have refilled, stopping
+// at the first which has not.  Must be called with the lock held.
+func (s *shard) sweep(now time.Time, rule *KeyedRule) {
+	s.lastSweep = now
+
+	for elem := s.lru.Back(); elem != nil; {
+		entry := elem.Value.(*keyEntry)
+		if !entry.bucket.full(now, rule.limitFor(entry.key)) {
+			return
+		}
+
+		prev := elem.Prev()
+		s.lru.Remove(elem)
+		delete(s.entries, entry.key)
+		elem = prev
+	}
+}
+
+func (s *shard) giveBack(key string, limit Limit, cost float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		elem.Value.(*keyEntry).bucket.giveBack(limit, cost)
+	}
+}
+
+func allKeyTypes(KeyType) bool {
+	return true
+}
+
+func exceptUser(keyType KeyType) bool {
+	return keyType != KeyTypeUser
+}
+
+func onlyUser(keyType KeyType) bool {
+	return keyType == KeyTypeUser
+}
+
+// userCheck returns the function which applies the user limits of a request
+// once its handler has authenticated it, taking the tokens returned by cost.
+// Only the first authentication of a request is checked, and rejections are
+// described to the client by the error returned by rejected.
+func (l *KeyedRateLimiter) userCheck(
+	info *requestInfo,
+	cost func() float64,
+	rejected func(quota Quota) error,
+) reqctx.AuthenticatedFunc {
+	var once sync.Once
+	var err error
+	return func(ctx context.Context, user string) error {
+		once.Do(func() {
+			if user == "" && l.gatewayUser != nil {
+				user = l.gatewayUser()
+			}
+			info.user = user
+
+			if quota, ok := l.check(ctx, info, onlyUser, cost()); !ok {
+				info.rejected = true
+				err = rejected(*quota)
+			}
+		})
+		return err
+	}
+}
+
+func grpcRequestInfo(ctx context.Context) *requestInfo {
+	info := &requestInfo{}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			info.connState = &tlsInfo.State
+		}
+	}
+
+	return info
+}
+
+func (l *KeyedRateLimiter) GrpcUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		reqInfo := grpcRequestInfo(ctx)
+		cost := l.costs.OperationCost(info.FullMethod)
+
+		if quota, ok := l.check(ctx, reqInfo, exceptUser, cost); !ok {
+			return nil, rateLimitedStatus(*quota)
+		}
+
+		ctx = reqctx.WithAuthenticatedFunc(ctx, l.userCheck(reqInfo, func() float64 {
+			return cost
+		}, rateLimitedStatus))
+
+		resp, err = handler(ctx, req)
+
+		if l.costs.ChargesBytes() {
+			l.charge(ctx, reqInfo, allKeyTypes, l.costs.BytesCost(messageSize(req), messageSize(resp)))
+		}
+
+		return resp, err
+	}
+}
+
+// keyedServerStream checks the bucket limit once the first message of a
+// stream has been received, as the bucket is only known from its messages,
+// and the user limit once the stream has been authenticated.
+type keyedServerStream struct {
+	countingServerStream
+	limiter   *KeyedRateLimiter
+	info      *requestInfo
+	cost      float64
+	checked   bool
+	userCheck reqctx.AuthenticatedFunc
+}
+
+func (s *keyedServerStream) Context() context.Context {
+	return reqctx.WithAuthenticatedFunc(s.countingServerStream.Context(), s.userCheck)
+}
+
+func (s *keyedServerStream) RecvMsg(m interface{}) error {
+	err := s.countingServerStream.RecvMsg(m)
+	if err != nil || s.checked {
+		return err
+	}
+	s.checked = true
+
+	onlyBucket := func(keyType KeyType) bool {
+		return keyType == KeyTypeBucket
+	}
+	if quota, ok := s.limiter.check(s.Context(), s.info, onlyBucket, s.cost); !ok {
+		return rateLimitedStatus(*quota)
+	}
+
+	return nil
+}
+
+func (l *KeyedRateLimiter) GrpcStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		reqInfo := grpcRequestInfo(ctx)
+		cost := l.costs.OperationCost(info.FullMethod)
+
+		exceptBucketAndUser := func(keyType KeyType) bool {
+			return keyType != KeyTypeBucket && keyType != KeyTypeUser
+		}
+		if quota, ok := l.check(ctx, reqInfo, exceptBucketAndUser, cost); !ok {
+			return rateLimitedStatus(*quota)
+		}
+
+		stream := &keyedServerStream{
+			countingServerStream: countingServerStream{ServerStream: ss},
+			limiter:              l,
+			info:                 reqInfo,
+			cost:                 cost,
+			userCheck: l.userCheck(reqInfo, func() float64 {
+				return cost
+			}, rateLimitedStatus),
+		}
+		err := handler(srv, stream)
+
+		if l.costs.ChargesBytes() {
+			l.charge(stream.Context(), reqInfo, allKeyTypes,
+				l.costs.BytesCost(stream.recvBytes, stream.sentBytes))
+		}
+
+		return err
+	}
+}
+
+func (l *KeyedRateLimiter) HttpMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := &requestInfo{
+			connState: r.TLS,
+		}
+
+		// requests which authenticate before they are admitted, such as
+		// those which are proxied, are only rejected by their user limits
+		// once those are in debt, and are charged when they complete.
+		var admittedCost float64
+		admit := func(ctx context.Context, cost float64) (*Quota, bool) {
+			admittedCost = cost
+			return l.check(ctx, info, exceptUser, cost)
+		}
+		charge := func(ctx context.Context, cost float64) {
+			l.charge(ctx, info, allKeyTypes, cost)
+		}
+
+		userCheck := l.userCheck(info, func() float64 {
+			return admittedCost
+		}, func(quota Quota) error {
+			err := &RateLimitedError{Quota: quota}
+			SetRateLimitedHeaders(w.Header(), err)
+			return err
+		})
+		r = r.WithContext(reqctx.WithAuthenticatedFunc(r.Context(), userCheck))
+
+		serveHttpWithCosts(w, r, next, l.costs, admit, charge)
+	})
+}
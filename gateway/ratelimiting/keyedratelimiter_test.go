@@ -0,0 +1,282 @@
+package ratelimiting
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTokenBucket(t *testing.T) {
+	limit := Limit{Rate: 10, Burst: 2}
+	now := time.Now()
+	bucket := newTokenBucket(now, limit)
+
+	ok, _ := bucket.take(now, limit, 1)
+	assert.True(t, ok)
+	ok, _ = bucket.take(now, limit, 1)
+	assert.True(t, ok)
+
+	ok, wait := bucket.take(now, limit, 1)
+	assert.False(t, ok)
+	assert.Equal(t, 100*time.Millisecond, wait)
+
+	// tokens refill continuously rather than at a window boundary
+	ok, _ = bucket.take(now.Add(50*time.Millisecond), limit, 1)
+	assert.False(t, ok)
+	ok, _ = bucket.take(now.Add(100*time.Millisecond), limit, 1)
+	assert.True(t, ok)
+
+	// and never beyond the burst size
+	assert.True(t, bucket.full(now.Add(time.Hour), limit))
+	bucket.refill(now.Add(time.Hour), limit)
+	assert.Equal(t, 2.0, bucket.tokens)
+}
+
+func TestKeyedRateLimiterUsers(t *testing.T) {
+	limiter, err := NewKeyedRateLimiter(&KeyedRateLimiterOptions{
+		Rules: []KeyedRule{{
+			KeyType: KeyTypeUser,
+			Default: Limit{Rate: 0.001, Burst: 2},
+			Overrides: map[string]Limit{
+				"batch":   {Rate: 0.001, Burst: 1},
+				"service": {},
+			},
+		}},
+		GatewayUser: func() string {
+			return "gateway"
+		},
+	})
+	require.NoError(t, err)
+
+	check := func(user string) bool {
+		ctx := reqctx.WithAuthenticatedFunc(context.Background(),
+			limiter.userCheck(&requestInfo{}, func() float64 {
+				return 1
+			}, rateLimitedStatus))
+		return reqctx.Authenticated(ctx, user) == nil
+	}
+
+	assert.True(t, check("alice"))
+	assert.True(t, check("alice"))
+	assert.False(t, check("alice"))
+	assert.True(t, check("bob"))
+
+	assert.True(t, check("batch"))
+	assert.False(t, check("batch"))
+
+	for i := 0; i < 10; i++ {
+		assert.True(t, check("service"))
+	}
+
+	// requests acting as the gateway without a username use its user
+	assert.True(t, check(""))
+	assert.True(t, check("gateway"))
+	assert.False(t, check(""))
+
+	// user limits are only applied once requests are authenticated
+	_, ok := limiter.check(context.Background(), &requestInfo{}, allKeyTypes, 1)
+	assert.True(t, ok)
+}
+
+func TestKeyedRateLimiterUserCheckedOnce(t *testing.T) {
+	limiter, err := NewKeyedRateLimiter(&KeyedRateLimiterOptions{
+		Rules: []KeyedRule{{
+			KeyType: KeyTypeUser,
+			Default: Limit{Rate: 0.001, Burst: 1},
+		}},
+	})
+	require.NoError(t, err)
+
+	newCtx := func() context.Context {
+		return reqctx.WithAuthenticatedFunc(context.Background(),
+			limiter.userCheck(&requestInfo{}, func() float64 {
+				return 1
+			}, rateLimitedStatus))
+	}
+
+	// handlers may authenticate a request more than once
+	ctx := newCtx()
+	assert.NoError(t, reqctx.Authenticated(ctx, "alice"))
+	assert.NoError(t, reqctx.Authenticated(ctx, "alice"))
+
+	ctx = newCtx()
+	err = reqctx.Authenticated(ctx, "alice")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(reqctx.Authenticated(ctx, "alice")))
+}
+
+func TestKeyedRateLimiterMultipleRules(t *testing.T) {
+	limiter, err := NewKeyedRateLimiter(&KeyedRateLimiterOptions{
+		Rules: []KeyedRule{
+			{KeyType: KeyTypeIP, Default: Limit{Rate: 0.001, Burst: 3}},
+			{KeyType: KeyTypeCert, Default: Limit{Rate: 0.001, Burst: 1}},
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := reqctx.WithClientIP(context.Background(), "10.0.0.1")
+	withCert := &requestInfo{
+		connState: &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{
+				{Subject: pkix.Name{CommonName: "client"}},
+			}},
+		},
+	}
+
+	_, ok := limiter.check(ctx, withCert, allKeyTypes, 1)
+	assert.True(t, ok)
+	_, ok = limiter.check(ctx, withCert, allKeyTypes, 1)
+	assert.False(t, ok)
+
+	// a rejection by one rule does not consume tokens from the others
+	_, ok = limiter.check(ctx, &requestInfo{}, allKeyTypes, 1)
+	assert.True(t, ok)
+	_, ok = limiter.check(ctx, &requestInfo{}, allKeyTypes, 1)
+	assert.True(t, ok)
+	_, ok = limiter.check(ctx, &requestInfo{}, allKeyTypes, 1)
+	assert.False(t, ok)
+}
+
+func TestKeyedRateLimiterEviction(t *testing.T) {
+	limiter, err := NewKeyedRateLimiter(&KeyedRateLimiterOptions{
+		Rules: []KeyedRule{
+			{KeyType: KeyTypeIP, Default: Limit{Rate: 0.001, Burst: 1}},
+		},
+		MaxKeys: numShards,
+	})
+	require.NoError(t, err)
+
+	rs := (*limiter.rules.Load())[0]
+	for i := 0; i < 1000; i++ {
+		ctx := reqctx.WithClientIP(context.Background(), fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+		_, _ = limiter.check(ctx, &requestInfo{}, allKeyTypes, 1)
+	}
+
+	for _, s := range rs.shards {
+		assert.Len(t, s.entries, 1)
+		assert.Equal(t, 1, s.lru.Len())
+	}
+}
+
+func TestKeyedRateLimiterSweep(t *testing.T) {
+	limiter, err := NewKeyedRateLimiter(&KeyedRateLimiterOptions{
+		Rules: []KeyedRule{
+			{KeyType: KeyTypeIP, Default: Limit{Rate: 1, Burst: 1}},
+		},
+	})
+	require.NoError(t, err)
+
+	rs := (*limiter.rules.Load())[0]
+	s := rs.shardFor("10.0.0.1")
+	now := time.Now()
+
+	_, _ = limiter.take(s, &rs.rule, now, "10.0.0.1", rs.rule.Default, 1)
+	_, _ = limiter.take(s, &rs.rule, now.Add(sweepInterval), "10.0.0.2", rs.rule.Default, 1)
+	assert.Len(t, s.entries, 1)
+	assert.Contains(t, s.entries, "10.0.0.2")
+
+	// and sweeps are performed at most once per interval
+	_, _ = limiter.take(s, &rs.rule, now.Add(sweepInterval+time.Millisecond), "10.0.0.3", rs.rule.Default, 1)
+	assert.Len(t, s.entries, 2)
+}
+
+func TestKeyedRateLimiterUpdateRules(t *testing.T) {
+	limiter, err := NewKeyedRateLimiter(&KeyedRateLimiterOptions{
+		Rules: []KeyedRule{
+			{KeyType: KeyTypeIP, Default: Limit{Rate: 0.001, Burst: 1}},
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := reqctx.WithClientIP(context.Background(), "10.0.0.1")
+	_, ok := limiter.check(ctx, &requestInfo{}, allKeyTypes, 1)
+	assert.True(t, ok)
+	_, ok = limiter.check(ctx, &requestInfo{}, allKeyTypes, 1)
+	assert.False(t, ok)
+
+	// the existing bucket is kept, so a raised limit is not a fresh burst
+	require.NoError(t, limiter.UpdateRules([]KeyedRule{
+		{KeyType: KeyTypeIP, Default: Limit{Rate: 0.001, Burst: 5}},
+	}))
+	_, ok = limiter.check(ctx, &requestInfo{}, allKeyTypes, 1)
+	assert.False(t, ok)
+
+	require.NoError(t, limiter.UpdateRules(nil))
+	_, ok = limiter.check(ctx, &requestInfo{}, allKeyTypes, 1)
+	assert.True(t, ok)
+
+	assert.Error(t, limiter.UpdateRules([]KeyedRule{{KeyType: "tenant"}}))
+	assert.Error(t, limiter.UpdateRules([]KeyedRule{{KeyType: KeyTypeIP}, {KeyType: KeyTypeIP}}))
+	assert.Error(t, limiter.UpdateRules([]KeyedRule{{KeyType: KeyTypeIP, Default: Limit{Rate: -1}}}))
+	assert.NoError(t, limiter.UpdateRules([]KeyedRule{{KeyType: KeyTypeUser}}))
+}
+
+func TestKeyedRateLimiterHttpMiddleware(t *testing.T) {
+	limiter, err := NewKeyedRateLimiter(&KeyedRateLimiterOptions{
+		Rules: []KeyedRule{
+			{KeyType: KeyTypeIP, Default: Limit{Rate: 0.001, Burst: 1}},
+		},
+	})
+	require.NoError(t, err)
+
+	handler := reqctx.ClientIPHttpMiddleware(limiter.HttpMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := AdmitHttpOperation(r.Context(), "GetDocument"); err != nil {
+			WriteRateLimited(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
+
+func TestKeyedRateLimiterHttpUsers(t *testing.T) {
+	limiter, err := NewKeyedRateLimiter(&KeyedRateLimiterOptions{
+		Rules: []KeyedRule{
+			{KeyType: KeyTypeUser, Default: Limit{Rate: 0.001, Burst: 1}},
+		},
+	})
+	require.NoError(t, err)
+
+	handler := limiter.HttpMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := AdmitHttpOperation(r.Context(), "GetDocument"); err != nil {
+			WriteRateLimited(w, err)
+			return
+		}
+		if err := reqctx.Authenticated(r.Context(), "alice"); err != nil {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+}
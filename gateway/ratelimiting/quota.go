@@ -0,0 +1,118 @@
+package ratelimiting
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Quota describes the state of a rate limit once a request has been checked
+// against it, so that clients can pace themselves.
+type Quota struct {
+	// Subject identifies the limit, such as `global` or `user:alice`.
+	Subject string
+
+	// Limit is the number of requests which may be made at once, and
+	// Remaining is how many of those are left.
+	Limit     int
+	Remaining int
+
+	// Reset is how long until the limit has fully replenished.
+	Reset time.Duration
+
+	// RetryAfter is how long until a rejected request would be permitted.
+	RetryAfter time.Duration
+}
+
+// tighter returns whichever quota has the fewest requests remaining.
+func (q *Quota) tighter(other *Quota) *Quota {
+	if q == nil {
+		return other
+	}
+	if other == nil || q.Remaining <= other.Remaining {
+		return q
+	}
+	return other
+}
+
+// ErrRateLimited is returned when a request is rejected by a rate limiter.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitedError describes the limit which rejected a request.
+type RateLimitedError struct {
+	Quota Quota
+}
+
+func (e *RateLimitedError) Error() string {
+	return ErrRateLimited.Error() + ": " + e.Quota.Subject
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// rateLimitedStatus builds the status returned to gRPC clients when they are
+// rate limited, describing when to retry and which limit was exceeded.
+func rateLimitedStatus(quota Quota) error {
+	st := status.New(codes.ResourceExhausted, "rate limit exceeded")
+
+	detailedSt, err := st.WithDetails(
+		&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(quota.RetryAfter),
+		},
+		&errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{{
+				Subject:     quota.Subject,
+				Description: "The request rate limit for " + quota.Subject + " was exceeded.",
+			}},
+		})
+	if err == nil {
+		st = detailedSt
+	}
+
+	return st.Err()
+}
+
+// durationSeconds rounds a duration up to whole seconds, as used by HTTP
+// headers.
+func durationSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// SetQuotaHeaders describes a quota in the RateLimit headers of a response.
+func SetQuotaHeaders(h http.Header, quota *Quota) {
+	if quota == nil {
+		return
+	}
+
+	h.Set("RateLimit-Limit", strconv.Itoa(quota.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(quota.Remaining))
+	h.Set("RateLimit-Reset", durationSeconds(quota.Reset))
+}
+
+// SetRateLimitedHeaders describes when a rate limited request may be retried
+// in the headers of its response, for handlers which write their own body.
+func SetRateLimitedHeaders(h http.Header, err error) {
+	var rateLimitedErr *RateLimitedError
+	if errors.As(err, &rateLimitedErr) {
+		SetQuotaHeaders(h, &rateLimitedErr.Quota)
+
+		// clients are told to wait at least a second, the smallest delay the
+		// header can express.
+		h.Set("Retry-After", durationSeconds(max(rateLimitedErr.Quota.RetryAfter, time.Second)))
+	}
+}
+
+// WriteRateLimited writes the response to a request which was rate limited,
+// which tells the client when to retry it.
+func WriteRateLimited(w http.ResponseWriter, err error) {
+	SetRateLimitedHeaders(w.Header(), err)
+	http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+}
@@ -0,0 +1,85 @@
+package ratelimiting
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimitedGrpcDetails(t *testing.T) {
+	limiter, err := NewKeyedRateLimiter(&KeyedRateLimiterOptions{
+		Rules: []KeyedRule{
+			{KeyType: KeyTypeIP, Default: Limit{Rate: 0.5, Burst: 1}},
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := reqctx.WithClientIP(context.Background(), "10.0.0.1")
+	interceptor := limiter.GrpcUnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/couchbase.kv.v1.KvService/Get"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+
+	_, err = interceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+
+	_, err = interceptor(ctx, nil, info, handler)
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Len(t, st.Details(), 2)
+
+	retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.InDelta(t, 2*time.Second, retryInfo.RetryDelay.AsDuration(), float64(100*time.Millisecond))
+
+	quotaFailure, ok := st.Details()[1].(*errdetails.QuotaFailure)
+	require.True(t, ok)
+	require.Len(t, quotaFailure.Violations, 1)
+	assert.Equal(t, "ip:10.0.0.1", quotaFailure.Violations[0].Subject)
+}
+
+func TestRateLimitedHttpHeaders(t *testing.T) {
+	limiter := NewGlobalRateLimiter(2, time.Hour, nil)
+
+	handler := limiter.HttpMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		quota, err := AdmitHttpOperation(r.Context(), "GetDocument")
+		if err != nil {
+			WriteRateLimited(w, err)
+			return
+		}
+		SetQuotaHeaders(w.Header(), quota)
+	}))
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/", nil))
+		return rec
+	}
+
+	rec := serve()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, rec.Header().Get("RateLimit-Reset"))
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+
+	rec = serve()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	rec = serve()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+}
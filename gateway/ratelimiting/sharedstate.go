@@ -0,0 +1,113 @@
+package ratelimiting
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SharedCounters stores counters which are shared by every gateway instance,
+// such as counter documents in a collection of the cluster.
+type SharedCounters interface {
+	// Add adds delta to the named counter, creating it with the given expiry
+	// if it does not exist, and returns its new value.
+	Add(ctx context.Context, name string, delta uint64, expiry time.Duration) (uint64, error)
+}
+
+const (
+	// sharedSyncTimeout bounds how long a sync may take before the limiter
+	// falls back to its local count.
+	sharedSyncTimeout = time.Second
+
+	// sharedCounterGrace is how long counters are kept once their period has
+	// ended, allowing for clock skew between instances.
+	sharedCounterGrace = 10 * time.Second
+)
+
+// SharedStateOptions configures sharing the usage of a GlobalRateLimiter with
+// other gateway instances.
+type SharedStateOptions struct {
+	Logger   *zap.Logger
+	Counters SharedCounters
+
+	// Name identifies the limit, limiters with the same name count their
+	// requests against the same counters.  Requests are counted in
+	// thousandths of a request, so that fractional costs are shared too.
+	Name string
+
+	// SyncInterval is how often usage is synced.  Between syncs, each instance
+	// only sees its own requests, so the limit may be exceeded by up to the
+	// requests every instance permits in one interval.  Defaults to 100ms.
+	SyncInterval time.Duration
+}
+
+// SyncShared periodically adds the requests counted by the limiter to the
+// shared counters, and counts the requests made by other instances against
+// the limit, until ctx is cancelled.  Whilst the counters are unreachable,
+// only the requests made to this instance are counted.
+func (l *GlobalRateLimiter) SyncShared(ctx context.Context, opts *SharedStateOptions) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	syncInterval := opts.SyncInterval
+	if syncInterval <= 0 {
+		syncInterval = 100 * time.Millisecond
+	}
+
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := l.syncShared(ctx, opts)
+		if err != nil {
+			if !failing && ctx.Err() == nil {
+				logger.Warn("failed to sync shared rate limit, falling back to local rate limit",
+					zap.String("name", opts.Name),
+					zap.Error(err))
+			}
+			failing = true
+		} else if failing {
+			logger.Info("resumed syncing shared rate limit",
+				zap.String("name", opts.Name))
+			failing = false
+		}
+	}
+}
+
+func (l *GlobalRateLimiter) syncShared(ctx context.Context, opts *SharedStateOptions) error {
+	ctx, cancel := context.WithTimeout(ctx, sharedSyncTimeout)
+	defer cancel()
+
+	state := l.getState()
+
+	// each period has its own counter, so that instances need only agree on
+	// the time rather than on when to reset.
+	name := fmt.Sprintf("%s-%d", opts.Name, state.ResetTime.UnixMilli())
+	delta := state.Requests.Load() - state.Synced.Load()
+
+	total, err := opts.Counters.Add(ctx, name, delta, state.Period+sharedCounterGrace)
+	if err != nil {
+		state.Shared.Store(0)
+		return err
+	}
+
+	synced := state.Synced.Add(delta)
+	if total > synced {
+		state.Shared.Store(total - synced)
+	} else {
+		state.Shared.Store(0)
+	}
+
+	return nil
+}
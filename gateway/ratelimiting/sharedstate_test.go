@@ -0,0 +1,72 @@
+package ratelimiting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSharedCounters struct {
+	lock     sync.Mutex
+	counters map[string]uint64
+	err      error
+}
+
+func (c *testSharedCounters) Add(ctx context.Context, name string, delta uint64, expiry time.Duration) (uint64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	c.counters[name] += delta
+	return c.counters[name], nil
+}
+
+func TestGlobalRateLimiterSyncShared(t *testing.T) {
+	counters := &testSharedCounters{counters: make(map[string]uint64)}
+	opts := &SharedStateOptions{
+		Counters: counters,
+		Name:     "global",
+	}
+
+	limiterA := NewGlobalRateLimiter(10, time.Hour, nil)
+	limiterB := NewGlobalRateLimiter(10, time.Hour, nil)
+
+	for i := 0; i < 8; i++ {
+		_, ok := limiterA.checkAllowed(1)
+		require.True(t, ok)
+	}
+
+	ctx := context.Background()
+	require.NoError(t, limiterA.syncShared(ctx, opts))
+	require.NoError(t, limiterB.syncShared(ctx, opts))
+
+	// B now counts the requests made to A against the limit
+	quota, ok := limiterB.checkAllowed(1)
+	require.True(t, ok)
+	assert.Equal(t, 1, quota.Remaining)
+	_, ok = limiterB.checkAllowed(1)
+	assert.True(t, ok)
+	_, ok = limiterB.checkAllowed(1)
+	assert.False(t, ok)
+
+	// and A sees B's once they are synced, without counting its own twice
+	require.NoError(t, limiterB.syncShared(ctx, opts))
+	require.NoError(t, limiterA.syncShared(ctx, opts))
+	assert.Equal(t, uint64(3*requestUnits), limiterA.getState().Shared.Load())
+	counterName := fmt.Sprintf("global-%d", limiterA.getState().ResetTime.UnixMilli())
+	assert.Equal(t, uint64(11*requestUnits), counters.counters[counterName])
+
+	// when the counters are unreachable, only local requests are counted
+	counters.err = errors.New("unreachable")
+	assert.Error(t, limiterB.syncShared(ctx, opts))
+	assert.Equal(t, uint64(0), limiterB.getState().Shared.Load())
+}
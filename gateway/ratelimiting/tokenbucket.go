@@ -0,0 +1,106 @@
+package ratelimiting
+
+import (
+	"math"
+	"time"
+)
+
+// Limit describes a token bucket.
+type Limit struct {
+	// Rate is the number of requests permitted per second, zero permits an
+	// unlimited number of requests.
+	Rate float64
+
+	// Burst is the number of requests which may be made at once.  Defaults
+	// to Rate, rounded up.
+	Burst int
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0
+}
+
+func (l Limit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, math.Ceil(l.Rate))
+}
+
+// tokenBucket refills continuously at the rate of its limit, up to its burst
+// size.  Unlike a fixed window, this never admits a double burst either side
+// of a window boundary.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(now time.Time, limit Limit) tokenBucket {
+	return tokenBucket{
+		tokens: limit.burst(),
+		last:   now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time, limit Limit) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	b.tokens = math.Min(limit.burst(), b.tokens+elapsed*limit.Rate)
+	b.last = now
+}
+
+// take removes cost tokens from the bucket if they are available, otherwise
+// it returns how long it will be until they are.  Requests which cost more
+// than the burst size are permitted once the bucket is full, leaving it in
+// debt until it has refilled.
+func (b *tokenBucket) take(now time.Time, limit Limit, cost float64) (bool, time.Duration) {
+	b.refill(now, limit)
+
+	available := math.Min(cost, limit.burst())
+	if b.tokens >= available {
+		b.tokens -= cost
+		return true, 0
+	}
+
+	missing := available - b.tokens
+	return false, time.Duration(missing / limit.Rate * float64(time.Second))
+}
+
+// charge unconditionally removes tokens for work which has already been
+// performed, which may leave the bucket in debt.
+func (b *tokenBucket) charge(now time.Time, limit Limit, cost float64) {
+	b.refill(now, limit)
+	b.tokens -= cost
+}
+
+// giveBack returns tokens which were taken for a request which was then
+// rejected for another reason.
+func (b *tokenBucket) giveBack(limit Limit, cost float64) {
+	b.tokens = math.Min(limit.burst(), b.tokens+cost)
+}
+
+// full returns whether the bucket has refilled completely, in which case it
+// is indistinguishable from a new bucket and need not be kept.
+func (b *tokenBucket) full(now time.Time, limit Limit) bool {
+	elapsed := now.Sub(b.last).Seconds()
+	return b.tokens+elapsed*limit.Rate >= limit.burst()
+}
+
+// quota describes the bucket as of its last refill.
+func (b *tokenBucket) quota(limit Limit) Quota {
+	burst := limit.burst()
+
+	var reset time.Duration
+	if b.tokens < burst {
+		reset = time.Duration((burst - b.tokens) / limit.Rate * float64(time.Second))
+	}
+
+	return Quota{
+		Limit:     int(burst),
+		Remaining: int(math.Max(0, math.Floor(b.tokens))),
+		Reset:     reset,
+	}
+}
@@ -0,0 +1,40 @@
+package reqctx
+
+import "context"
+
+// AuthenticatedFunc is invoked with the user a request is performed on behalf
+// of once it has been authenticated, and may reject the request by returning
+// an error.  The user is empty for requests which act as the gateway itself
+// without having presented a username.
+type AuthenticatedFunc func(ctx context.Context, user string) error
+
+type ctxKeyAuthenticated struct{}
+
+// WithAuthenticatedFunc registers a function to be invoked once a request has
+// been authenticated, after any which were previously registered.
+func WithAuthenticatedFunc(ctx context.Context, fn AuthenticatedFunc) context.Context {
+	if prev, ok := ctx.Value(ctxKeyAuthenticated{}).(AuthenticatedFunc); ok {
+		next := fn
+		fn = func(ctx context.Context, user string) error {
+			err := prev(ctx, user)
+			if err != nil {
+				return err
+			}
+			return next(ctx, user)
+		}
+	}
+
+	return context.WithValue(ctx, ctxKeyAuthenticated{}, fn)
+}
+
+// Authenticated is called by request handlers once they have authenticated
+// the user of a request.  If an error is returned, the request must not be
+// performed and the error is returned to the client.
+func Authenticated(ctx context.Context, user string) error {
+	fn, ok := ctx.Value(ctxKeyAuthenticated{}).(AuthenticatedFunc)
+	if !ok {
+		return nil
+	}
+
+	return fn(ctx, user)
+}
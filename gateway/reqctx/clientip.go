@@ -0,0 +1,50 @@
+// Package reqctx holds the values which the gateway's middleware attaches to
+// the context of each request, so that the packages which record them and
+// the packages which use them need not depend on one another.
+package reqctx
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"google.golang.org/grpc/peer"
+)
+
+type ctxKeyClientIP struct{}
+
+// WithClientIP records the address a request was received from.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ctxKeyClientIP{}, ip)
+}
+
+// ClientIPFromContext returns the address a request was received from, using
+// either the address recorded by WithClientIP or the grpc peer.
+func ClientIPFromContext(ctx context.Context) string {
+	if ip, ok := ctx.Value(ctxKeyClientIP{}).(string); ok {
+		return ip
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return hostFromAddr(p.Addr.String())
+	}
+
+	return ""
+}
+
+func hostFromAddr(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// ClientIPHttpMiddleware records the address of each request.  Forwarding
+// headers are intentionally ignored as they can be set by the client.
+func ClientIPHttpMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithClientIP(r.Context(), hostFromAddr(r.RemoteAddr))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,22 @@
+package reqctx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIPHttpMiddleware(t *testing.T) {
+	var ip string
+	handler := ClientIPHttpMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip = ClientIPFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:51234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "10.0.0.1", ip)
+}
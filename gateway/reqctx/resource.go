@@ -0,0 +1,27 @@
+package reqctx
+
+import "context"
+
+// Resource is the bucket and scope targeted by a request.
+type Resource struct {
+	Bucket string
+	Scope  string
+
+	// Unbounded indicates that the request may access buckets other than the
+	// one it names, such as a query statement.
+	Unbounded bool
+}
+
+type ctxKeyResource struct{}
+
+// WithResource records the resource targeted by a request.
+func WithResource(ctx context.Context, res Resource) context.Context {
+	return context.WithValue(ctx, ctxKeyResource{}, res)
+}
+
+// ResourceFromContext returns the resource targeted by a request, whose bucket
+// and scope are empty if the request does not target one.
+func ResourceFromContext(ctx context.Context) Resource {
+	res, _ := ctx.Value(ctxKeyResource{}).(Resource)
+	return res
+}
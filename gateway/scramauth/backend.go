@@ -0,0 +1,215 @@
+package scramauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"hash"
+	"sync"
+
+	"github.com/couchbase/stellar-gateway/contrib/scramserver"
+)
+
+var (
+	// ErrInvalidMessage indicates a client sent a malformed SCRAM message.
+	ErrInvalidMessage = errors.New("invalid scram message")
+
+	// ErrAuthenticationFailed indicates a client's proof was incorrect, or
+	// that the user is not known.
+	ErrAuthenticationFailed = errors.New("scram authentication failed")
+)
+
+// Backend performs the server side of SCRAM handshakes for the users it
+// knows of.
+type Backend interface {
+	// Start begins a handshake from the client-first-message, returning the
+	// server-first-message.
+	Start(ctx context.Context, mechanism string, clientFirst []byte) (Conversation, []byte, error)
+}
+
+// Conversation is a handshake which has been started by a Backend.
+type Conversation interface {
+	// Finish verifies the client-final-message, returning the
+	// server-final-message and the identity that sessions act as.
+	Finish(ctx context.Context, clientFinal []byte) ([]byte, Identity, error)
+}
+
+// defaultIterations is the iteration count used for users whose password is
+// known, rather than their SCRAM credentials.
+const defaultIterations = 4096
+
+// mechanismHashes maps the SCRAM mechanisms to their hash functions.
+var mechanismHashes = map[string]func() hash.Hash{
+	"SCRAM-SHA-256": sha256.New,
+	"SCRAM-SHA-512": sha512.New,
+}
+
+// LocalUser is a user known to the gateway.  Either their plaintext password
+// or their SCRAM credentials for the mechanism must be known.
+type LocalUser struct {
+	Password    string
+	Credentials *scramserver.Credentials
+	Identity    Identity
+}
+
+// LocalUserLookup returns a user, if they are able to authenticate using the
+// mechanism.
+type LocalUserLookup func(username, mechanism string) (*LocalUser, bool)
+
+// LocalBackend performs handshakes for users known to the gateway, such as
+// those in the users file.
+type LocalBackend struct {
+	lookup LocalUserLookup
+	secret []byte
+
+	// derived caches the credentials derived from the passwords of users,
+	// so that each handshake does not repeat the key derivation.
+	derivedLock sync.Mutex
+	derived     map[derivedKey]*derivedCredentials
+}
+
+type derivedKey struct {
+	username  string
+	mechanism string
+}
+
+type derivedCredentials struct {
+	password string
+	creds    *scramserver.Credentials
+}
+
+var _ Backend = (*LocalBackend)(nil)
+
+func NewLocalBackend(lookup LocalUserLookup) (*LocalBackend, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalBackend{
+		lookup:  lookup,
+		secret:  secret,
+		derived: make(map[derivedKey]*derivedCredentials),
+	}, nil
+}
+
+// mac returns an HMAC of the values keyed by the backend's secret.
+func (b *LocalBackend) mac(hashFn func() hash.Hash, values ...string) []byte {
+	mac := hmac.New(hashFn, b.secret)
+	for _, value := range values {
+		mac.Write([]byte(value))
+		mac.Write([]byte{0})
+	}
+	return mac.Sum(nil)
+}
+
+// salt returns the salt for users without stored credentials.  It is stable
+// for each user, so that unknown users cannot be distinguished by their salt
+// changing between handshakes.
+func (b *LocalBackend) salt(username string) []byte {
+	return b.mac(sha256.New, "salt", username)[:16]
+}
+
+// unknownCredentials returns credentials for users who are not known.  These
+// are derived cheaply from the backend's secret rather than from a password,
+// so that handshakes for unknown users cannot be used to force expensive key
+// derivations, while being indistinguishable from those of real users.
+func (b *LocalBackend) unknownCredentials(username, mechanism string) *scramserver.Credentials {
+	hashFn := mechanismHashes[mechanism]
+	return &scramserver.Credentials{
+		Salt:       b.salt(username),
+		Iterations: defaultIterations,
+		StoredKey:  b.mac(hashFn, "stored-key", mechanism, username),
+		ServerKey:  b.mac(hashFn, "server-key", mechanism, username),
+	}
+}
+
+// passwordCredentials derives the credentials for a user whose password is
+// known, reusing those derived previously if the password has not changed.
+func (b *LocalBackend) passwordCredentials(username, mechanism, password string) (*scramserver.Credentials, error) {
+	key := derivedKey{username: username, mechanism: mechanism}
+
+	b.derivedLock.Lock()
+	derived := b.derived[key]
+	b.derivedLock.Unlock()
+
+	if derived != nil && subtle.ConstantTimeCompare([]byte(derived.password), []byte(password)) == 1 {
+		return derived.creds, nil
+	}
+
+	creds, err := scramserver.NewCredentials(mechanisms[mechanism], password, b.salt(username), defaultIterations)
+	if err != nil {
+		return nil, err
+	}
+
+	b.derivedLock.Lock()
+	b.derived[key] = &derivedCredentials{
+		password: password,
+		creds:    creds,
+	}
+	b.derivedLock.Unlock()
+
+	return creds, nil
+}
+
+func (b *LocalBackend) credentials(username, mechanism string) (*scramserver.Credentials, Identity, bool, error) {
+	user, known := b.lookup(username, mechanism)
+	if !known {
+		return b.unknownCredentials(username, mechanism), Identity{}, false, nil
+	}
+
+	if user.Credentials != nil {
+		return user.Credentials, user.Identity, true, nil
+	}
+
+	creds, err := b.passwordCredentials(username, mechanism, user.Password)
+	if err != nil {
+		return nil, Identity{}, false, err
+	}
+
+	return creds, user.Identity, true, nil
+}
+
+func (b *LocalBackend) Start(ctx context.Context, mechanism string, clientFirst []byte) (Conversation, []byte, error) {
+	conv := &localConversation{}
+
+	var lookupErr error
+	serverFirst, err := conv.srv.StartWithCredentials(clientFirst, mechanisms[mechanism],
+		func(username string) (*scramserver.Credentials, error) {
+			var creds *scramserver.Credentials
+			creds, conv.identity, conv.known, lookupErr = b.credentials(username, mechanism)
+			return creds, lookupErr
+		})
+	if lookupErr != nil {
+		return nil, nil, lookupErr
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidMessage, err.Error())
+	}
+
+	return conv, serverFirst, nil
+}
+
+type localConversation struct {
+	srv      scramserver.ScramServer
+	identity Identity
+	known    bool
+}
+
+func (c *localConversation) Finish(ctx context.Context, clientFinal []byte) ([]byte, Identity, error) {
+	serverFinal, err := c.srv.Step(clientFinal)
+	if err != nil {
+		return nil, Identity{}, fmt.Errorf("%w: %s", ErrAuthenticationFailed, err.Error())
+	}
+
+	if !c.known {
+		return nil, Identity{}, ErrAuthenticationFailed
+	}
+
+	return serverFinal, c.identity, nil
+}
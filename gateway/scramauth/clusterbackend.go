@@ -0,0 +1,190 @@
+package scramauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+)
+
+// ClusterBackendOptions configures a ClusterBackend.
+type ClusterBackendOptions struct {
+	// HttpClient is used to contact the cluster.  It must not present a
+	// client certificate, as the cluster could then authenticate requests as
+	// the user of the certificate rather than the one performing SCRAM.
+	HttpClient *http.Client
+
+	// Endpoints are the base URLs of the cluster's management service, such
+	// as https://10.0.0.1:18091.
+	Endpoints []string
+}
+
+// ClusterBackend performs handshakes for the users of the cluster, by relaying
+// them to the cluster's management service using HTTP SCRAM (RFC 7804).
+// Sessions act as the user the cluster authenticated.
+type ClusterBackend struct {
+	httpClient *http.Client
+	endpoints  []string
+}
+
+var _ Backend = (*ClusterBackend)(nil)
+
+func NewClusterBackend(opts *ClusterBackendOptions) (*ClusterBackend, error) {
+	if len(opts.Endpoints) == 0 {
+		return nil, errors.New("at least one cluster endpoint must be specified")
+	}
+
+	httpClient := opts.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &ClusterBackend{
+		httpClient: httpClient,
+		endpoints:  opts.Endpoints,
+	}, nil
+}
+
+// parseScramAuthParams parses the sid and data parameters of a SCRAM
+// challenge or Authentication-Info header, such as `sid=abc, data=ZGF0YQ==`.
+func parseScramAuthParams(header string) (string, []byte, error) {
+	var sid, data string
+	for _, param := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			continue
+		}
+
+		value = strings.Trim(value, `"`)
+		switch key {
+		case "sid":
+			sid = value
+		case "data":
+			data = value
+		}
+	}
+
+	if sid == "" || data == "" {
+		return "", nil, errors.New("missing sid or data")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return sid, decoded, nil
+}
+
+func (b *ClusterBackend) whoami(ctx context.Context, endpoint, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/whoami", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authorization)
+
+	return b.httpClient.Do(req)
+}
+
+func (b *ClusterBackend) Start(ctx context.Context, mechanism string, clientFirst []byte) (Conversation, []byte, error) {
+	// the session of a handshake only exists on the node which started it, so
+	// it must be finished on the same node.
+	endpoint := b.endpoints[rand.Intn(len(b.endpoints))]
+
+	resp, err := b.whoami(ctx, endpoint,
+		mechanism+" data="+base64.StdEncoding.EncodeToString(clientFirst))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+	case http.StatusBadRequest:
+		return nil, nil, ErrInvalidMessage
+	default:
+		return nil, nil, fmt.Errorf("unexpected status code from cluster: %d", resp.StatusCode)
+	}
+
+	challenge, found := strings.CutPrefix(resp.Header.Get("WWW-Authenticate"), mechanism+" ")
+	if !found {
+		// the cluster does not offer a challenge to users it rejects outright
+		return nil, nil, ErrAuthenticationFailed
+	}
+
+	sid, serverFirst, err := parseScramAuthParams(challenge)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid scram challenge from cluster: %w", err)
+	}
+
+	return &clusterConversation{
+		backend:   b,
+		endpoint:  endpoint,
+		mechanism: mechanism,
+		sid:       sid,
+	}, serverFirst, nil
+}
+
+type clusterConversation struct {
+	backend   *ClusterBackend
+	endpoint  string
+	mechanism string
+	sid       string
+}
+
+type whoamiJson struct {
+	Id     string `json:"id"`
+	Domain string `json:"domain"`
+}
+
+func (c *clusterConversation) Finish(ctx context.Context, clientFinal []byte) ([]byte, Identity, error) {
+	resp, err := c.backend.whoami(ctx, c.endpoint,
+		c.mechanism+" sid="+c.sid+", data="+base64.StdEncoding.EncodeToString(clientFinal))
+	if err != nil {
+		return nil, Identity{}, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, Identity{}, ErrAuthenticationFailed
+	case http.StatusBadRequest:
+		return nil, Identity{}, ErrInvalidMessage
+	default:
+		return nil, Identity{}, fmt.Errorf("unexpected status code from cluster: %d", resp.StatusCode)
+	}
+
+	_, serverFinal, err := parseScramAuthParams(resp.Header.Get("Authentication-Info"))
+	if err != nil {
+		return nil, Identity{}, fmt.Errorf("invalid scram authentication info from cluster: %w", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, Identity{}, err
+	}
+
+	var whoami whoamiJson
+	err = json.Unmarshal(body, &whoami)
+	if err != nil {
+		return nil, Identity{}, fmt.Errorf("failed to parse whoami response from cluster: %w", err)
+	}
+	if whoami.Id == "" {
+		return nil, Identity{}, errors.New("cluster did not identify the authenticated user")
+	}
+
+	return serverFinal, Identity{
+		User:   whoami.Id,
+		Domain: whoami.Domain,
+	}, nil
+}
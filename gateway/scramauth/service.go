@@ -0,0 +1,280 @@
+package scramauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/gateway/auth/lockout"
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+	"github.com/couchbase/stellar-gateway/genproto/scram_v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mechanisms maps the SCRAM mechanism names clients use to those used by
+// the scram server.
+var mechanisms = map[string]string{
+	"SCRAM-SHA-256": "SCRAM-SHA256",
+	"SCRAM-SHA-512": "SCRAM-SHA512",
+}
+
+type ServiceOptions struct {
+	Logger   *zap.Logger
+	Sessions *SessionStore
+	Backend  Backend
+
+	// Lockout optionally throttles users and addresses after repeated
+	// failed handshakes.  Handshakes which are started but not finished
+	// before they expire also count as failures, so that clients cannot
+	// repeatedly start handshakes without being throttled.
+	Lockout *lockout.Tracker
+
+	// HandshakeTimeout is how long a client has to finish a handshake after
+	// starting it.  Defaults to 30 seconds.
+	HandshakeTimeout time.Duration
+
+	// MaxPendingHandshakes bounds the number of started but unfinished
+	// handshakes.  Defaults to 10000.
+	MaxPendingHandshakes int
+}
+
+type conversation struct {
+	conv      Conversation
+	username  string
+	clientIp  string
+	expiresAt time.Time
+}
+
+// Service implements the ScramService, which establishes sessions via SCRAM
+// handshakes.
+type Service struct {
+	scram_v1.UnimplementedScramServiceServer
+
+	logger           *zap.Logger
+	sessions         *SessionStore
+	backend          Backend
+	lockout          *lockout.Tracker
+	handshakeTimeout time.Duration
+	maxPending       int
+
+	lock          sync.Mutex
+	conversations map[string]*conversation
+	lastSweep     time.Time
+}
+
+func NewService(opts *ServiceOptions) (*Service, error) {
+	if opts.Sessions == nil || opts.Backend == nil {
+		return nil, errors.New("sessions and a backend must be specified")
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	handshakeTimeout := opts.HandshakeTimeout
+	if handshakeTimeout <= 0 {
+		handshakeTimeout = 30 * time.Second
+	}
+
+	maxPending := opts.MaxPendingHandshakes
+	if maxPending <= 0 {
+		maxPending = 10000
+	}
+
+	return &Service{
+		logger:           logger,
+		sessions:         opts.Sessions,
+		backend:          opts.Backend,
+		lockout:          opts.Lockout,
+		handshakeTimeout: handshakeTimeout,
+		maxPending:       maxPending,
+		conversations:    make(map[string]*conversation),
+	}, nil
+}
+
+func randomString(numBytes int) (string, error) {
+	buf := make([]byte, numBytes)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// clientFirstUsername returns the username of a client-first-message, such as
+// `n,,n=user,r=nonce`.
+func clientFirstUsername(clientFirst []byte) (string, bool) {
+	fields := bytes.Split(clientFirst, []byte(","))
+	if len(fields) < 3 || !bytes.HasPrefix(fields[2], []byte("n=")) || len(fields[2]) == 2 {
+		return "", false
+	}
+
+	return string(fields[2][2:]), true
+}
+
+// sweepLocked removes the conversations which have expired, returning them.
+func (s *Service) sweepLocked(now time.Time) []*conversation {
+	s.lastSweep = now
+
+	var expired []*conversation
+	for id, conv := range s.conversations {
+		if !now.Before(conv.expiresAt) {
+			delete(s.conversations, id)
+			expired = append(expired, conv)
+		}
+	}
+	return expired
+}
+
+// recordAbandoned records handshakes which were never finished as failures.
+func (s *Service) recordAbandoned(ctx context.Context, convs []*conversation) {
+	if s.lockout == nil {
+		return
+	}
+
+	for _, conv := range convs {
+		s.lockout.RecordFailure(ctx, conv.username, conv.clientIp)
+	}
+}
+
+func (s *Service) StartScram(
+	ctx context.Context, in *scram_v1.StartScramRequest,
+) (*scram_v1.StartScramResponse, error) {
+	mechanism := in.Mechanism
+	if _, ok := mechanisms[mechanism]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"Unsupported SCRAM mechanism, must be one of SCRAM-SHA-256 or SCRAM-SHA-512.")
+	}
+
+	clientFirst := in.ClientFirstMessage
+	username, ok := clientFirstUsername(clientFirst)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid SCRAM client-first-message.")
+	}
+
+	clientIp := reqctx.ClientIPFromContext(ctx)
+	if s.lockout != nil {
+		err := s.lockout.Check(ctx, username, clientIp)
+		if err != nil {
+			return nil, status.Errorf(codes.ResourceExhausted,
+				"Too many failed authentication attempts, try again later.")
+		}
+	}
+
+	conv, serverFirst, err := s.backend.Start(ctx, mechanism, clientFirst)
+	if err != nil {
+		if errors.Is(err, ErrInvalidMessage) {
+			s.logger.Debug("received an invalid scram client-first-message", zap.Error(err))
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid SCRAM client-first-message.")
+		} else if errors.Is(err, ErrAuthenticationFailed) {
+			if s.lockout != nil {
+				s.lockout.RecordFailure(ctx, username, clientIp)
+			}
+			return nil, status.Errorf(codes.Unauthenticated, "SCRAM authentication failed.")
+		}
+
+		s.logger.Warn("failed to start scram handshake", zap.Error(err))
+		return nil, status.Errorf(codes.Unavailable, "Failed to start SCRAM handshake.")
+	}
+
+	conversationId, err := randomString(16)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to start SCRAM handshake.")
+	}
+
+	now := time.Now()
+
+	s.lock.Lock()
+	var abandoned []*conversation
+	if len(s.conversations) >= s.maxPending || now.Sub(s.lastSweep) >= time.Second {
+		abandoned = s.sweepLocked(now)
+	}
+	if len(s.conversations) >= s.maxPending {
+		s.lock.Unlock()
+		s.recordAbandoned(ctx, abandoned)
+		return nil, status.Errorf(codes.ResourceExhausted, "Too many pending SCRAM handshakes.")
+	}
+	s.conversations[conversationId] = &conversation{
+		conv:      conv,
+		username:  username,
+		clientIp:  clientIp,
+		expiresAt: now.Add(s.handshakeTimeout),
+	}
+	s.lock.Unlock()
+
+	s.recordAbandoned(ctx, abandoned)
+
+	return &scram_v1.StartScramResponse{
+		ConversationId:     conversationId,
+		ServerFirstMessage: serverFirst,
+	}, nil
+}
+
+func (s *Service) FinishScram(
+	ctx context.Context, in *scram_v1.FinishScramRequest,
+) (*scram_v1.FinishScramResponse, error) {
+	conversationId := in.ConversationId
+
+	// conversations may only be finished once, regardless of the outcome
+	s.lock.Lock()
+	conv, ok := s.conversations[conversationId]
+	delete(s.conversations, conversationId)
+	s.lock.Unlock()
+
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "Unknown or expired SCRAM conversation.")
+	} else if !time.Now().Before(conv.expiresAt) {
+		s.recordAbandoned(ctx, []*conversation{conv})
+		return nil, status.Errorf(codes.Unauthenticated, "Unknown or expired SCRAM conversation.")
+	}
+
+	clientIp := reqctx.ClientIPFromContext(ctx)
+
+	serverFinal, identity, err := conv.conv.Finish(ctx, in.ClientFinalMessage)
+	if err != nil {
+		if !errors.Is(err, ErrAuthenticationFailed) && !errors.Is(err, ErrInvalidMessage) {
+			s.logger.Warn("failed to finish scram handshake", zap.Error(err))
+			return nil, status.Errorf(codes.Unavailable, "Failed to finish SCRAM handshake.")
+		}
+
+		s.logger.Debug("scram handshake failed",
+			zap.String("username", conv.username),
+			zap.Error(err))
+
+		if s.lockout != nil {
+			s.lockout.RecordFailure(ctx, conv.username, clientIp)
+		}
+
+		return nil, status.Errorf(codes.Unauthenticated, "SCRAM authentication failed.")
+	}
+
+	if s.lockout != nil {
+		s.lockout.RecordSuccess(ctx, conv.username, clientIp)
+	}
+
+	token, expiresAt, err := s.sessions.Issue(identity)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to create session.")
+	}
+
+	return &scram_v1.FinishScramResponse{
+		ServerFinalMessage: serverFinal,
+		SessionToken:       token,
+		ExpiresInSecs:      uint32(time.Until(expiresAt).Seconds()),
+	}, nil
+}
+
+func (s *Service) RevokeSession(
+	ctx context.Context, in *scram_v1.RevokeSessionRequest,
+) (*scram_v1.RevokeSessionResponse, error) {
+	s.sessions.Revoke(in.SessionToken)
+
+	return &scram_v1.RevokeSessionResponse{}, nil
+}
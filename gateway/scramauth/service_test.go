@@ -0,0 +1,365 @@
+package scramauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/contrib/scramserver"
+	"github.com/couchbase/stellar-gateway/gateway/auth/lockout"
+	"github.com/couchbase/stellar-gateway/genproto/scram_v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testClient struct {
+	scram_v1.ScramServiceClient
+}
+
+func newTestClient(t *testing.T, svc *Service) *testClient {
+	lis := bufconn.Listen(1024 * 1024)
+
+	srv := grpc.NewServer()
+	scram_v1.RegisterScramServiceServer(srv, svc)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return &testClient{scram_v1.NewScramServiceClient(conn)}
+}
+
+func hmacSha256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// authenticate performs a SCRAM-SHA-256 handshake, returning the session token.
+func (c *testClient) authenticate(t *testing.T, username, password string) (string, error) {
+	clientNonce := "clientNONCE123"
+	clientFirstBare := "n=" + username + ",r=" + clientNonce
+
+	startResp, err := c.StartScram(context.Background(), &scram_v1.StartScramRequest{
+		Mechanism:          "SCRAM-SHA-256",
+		ClientFirstMessage: []byte("n,," + clientFirstBare),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	serverFirst := string(startResp.ServerFirstMessage)
+	var nonce, salt string
+	var iterations int
+	for _, field := range strings.Split(serverFirst, ",") {
+		switch {
+		case strings.HasPrefix(field, "r="):
+			nonce = field[2:]
+		case strings.HasPrefix(field, "s="):
+			salt = field[2:]
+		case strings.HasPrefix(field, "i="):
+			iterations, err = strconv.Atoi(field[2:])
+			require.NoError(t, err)
+		}
+	}
+	require.True(t, strings.HasPrefix(nonce, clientNonce))
+
+	rawSalt, err := base64.StdEncoding.DecodeString(salt)
+	require.NoError(t, err)
+
+	ui := hmacSha256([]byte(password), append(rawSalt, 0, 0, 0, 1))
+	saltedPassword := bytes.Clone(ui)
+	for i := 1; i < iterations; i++ {
+		ui = hmacSha256([]byte(password), ui)
+		for j := range saltedPassword {
+			saltedPassword[j] ^= ui[j]
+		}
+	}
+
+	clientFinalWithoutProof := "c=biws,r=" + nonce
+	authMessage := clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof
+
+	clientKey := hmacSha256(saltedPassword, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	proof := hmacSha256(storedKey[:], []byte(authMessage))
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+
+	finishResp, err := c.FinishScram(context.Background(), &scram_v1.FinishScramRequest{
+		ConversationId:     startResp.ConversationId,
+		ClientFinalMessage: []byte(clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	serverKey := hmacSha256(saltedPassword, []byte("Server Key"))
+	serverSignature := hmacSha256(serverKey, []byte(authMessage))
+	assert.Equal(t, "v="+base64.StdEncoding.EncodeToString(serverSignature),
+		string(finishResp.ServerFinalMessage))
+
+	return finishResp.SessionToken, nil
+}
+
+func TestServiceHandshake(t *testing.T) {
+	userCreds, err := scramserver.NewCredentials("SCRAM-SHA256", "user-pass", []byte("0123456789abcdef"), 4096)
+	require.NoError(t, err)
+
+	backend, err := NewLocalBackend(func(username, mechanism string) (*LocalUser, bool) {
+		switch username {
+		case "gateway":
+			return &LocalUser{Password: "s3cret"}, true
+		case "user":
+			if mechanism != "SCRAM-SHA-256" {
+				return nil, false
+			}
+			return &LocalUser{
+				Credentials: userCreds,
+				Identity:    Identity{User: "app-user", Domain: "local"},
+			}, true
+		}
+		return nil, false
+	})
+	require.NoError(t, err)
+
+	sessions := NewSessionStore(0)
+	svc, err := NewService(&ServiceOptions{
+		Sessions: sessions,
+		Backend:  backend,
+	})
+	require.NoError(t, err)
+
+	client := newTestClient(t, svc)
+
+	token, err := client.authenticate(t, "gateway", "s3cret")
+	require.NoError(t, err)
+	assert.True(t, IsSessionToken(token))
+
+	identity, err := sessions.Validate(token)
+	require.NoError(t, err)
+	assert.Equal(t, Identity{}, identity)
+
+	// users with stored credentials act as their own identity
+	userToken, err := client.authenticate(t, "user", "user-pass")
+	require.NoError(t, err)
+
+	identity, err = sessions.Validate(userToken)
+	require.NoError(t, err)
+	assert.Equal(t, Identity{User: "app-user", Domain: "local"}, identity)
+
+	_, err = client.authenticate(t, "gateway", "wrong")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.authenticate(t, "someone", "s3cret")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.StartScram(context.Background(), &scram_v1.StartScramRequest{
+		Mechanism:          "SCRAM-SHA-1",
+		ClientFirstMessage: []byte("n,,n=gateway,r=clientNONCE123"),
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.RevokeSession(context.Background(), &scram_v1.RevokeSessionRequest{
+		SessionToken: token,
+	})
+	require.NoError(t, err)
+
+	_, err = sessions.Validate(token)
+	assert.ErrorIs(t, err, ErrInvalidSession)
+}
+
+// testClusterHandler emulates the HTTP SCRAM support of the cluster's
+// management service, for a single user.
+func testClusterHandler(t *testing.T) http.Handler {
+	var lock sync.Mutex
+	conversations := make(map[string]*scramserver.ScramServer)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/whoami", r.URL.Path)
+
+		mechanism, params, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		var sid, data string
+		for _, param := range strings.Split(params, ", ") {
+			key, value, _ := strings.Cut(param, "=")
+			switch key {
+			case "sid":
+				sid = value
+			case "data":
+				data = value
+			}
+		}
+
+		msg, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		if sid == "" {
+			srv := &scramserver.ScramServer{}
+			serverFirst, err := srv.Start(msg, mechanisms[mechanism])
+			if err != nil || srv.Username() != "alice" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Couchbase Server Admin / REST"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			require.NoError(t, srv.SetPassword("alice-pass"))
+
+			sid = strconv.Itoa(len(conversations) + 1)
+			conversations[sid] = srv
+			w.Header().Set("WWW-Authenticate",
+				mechanism+" sid="+sid+", data="+base64.StdEncoding.EncodeToString(serverFirst))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		srv := conversations[sid]
+		delete(conversations, sid)
+		if srv == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		serverFinal, err := srv.Step(msg)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Authentication-Info",
+			"sid="+sid+", data="+base64.StdEncoding.EncodeToString(serverFinal))
+		_, _ = w.Write([]byte(`{"id":"alice","domain":"local","roles":[]}`))
+	})
+}
+
+func TestServiceClusterBackend(t *testing.T) {
+	cluster := httptest.NewServer(testClusterHandler(t))
+	defer cluster.Close()
+
+	backend, err := NewClusterBackend(&ClusterBackendOptions{
+		Endpoints: []string{cluster.URL},
+	})
+	require.NoError(t, err)
+
+	sessions := NewSessionStore(0)
+	svc, err := NewService(&ServiceOptions{
+		Sessions: sessions,
+		Backend:  backend,
+	})
+	require.NoError(t, err)
+
+	client := newTestClient(t, svc)
+
+	token, err := client.authenticate(t, "alice", "alice-pass")
+	require.NoError(t, err)
+
+	identity, err := sessions.Validate(token)
+	require.NoError(t, err)
+	assert.Equal(t, Identity{User: "alice", Domain: "local"}, identity)
+
+	_, err = client.authenticate(t, "alice", "wrong")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.authenticate(t, "bob", "alice-pass")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestServiceAbandonedHandshakes(t *testing.T) {
+	backend, err := NewLocalBackend(func(username, mechanism string) (*LocalUser, bool) {
+		if username == "gateway" {
+			return &LocalUser{Password: "s3cret"}, true
+		}
+		return nil, false
+	})
+	require.NoError(t, err)
+
+	svc, err := NewService(&ServiceOptions{
+		Sessions: NewSessionStore(0),
+		Backend:  backend,
+		Lockout: lockout.NewTracker(&lockout.Options{
+			MaxFailures: 2,
+			BaseDelay:   -1,
+		}),
+		HandshakeTimeout: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	client := newTestClient(t, svc)
+
+	startReq := &scram_v1.StartScramRequest{
+		Mechanism:          "SCRAM-SHA-256",
+		ClientFirstMessage: []byte("n,,n=gateway,r=clientNONCE123"),
+	}
+
+	var conversationIds []string
+	for i := 0; i < 2; i++ {
+		startResp, err := client.StartScram(context.Background(), startReq)
+		require.NoError(t, err)
+		conversationIds = append(conversationIds, startResp.ConversationId)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	for _, conversationId := range conversationIds {
+		_, err = client.FinishScram(context.Background(), &scram_v1.FinishScramRequest{
+			ConversationId:     conversationId,
+			ClientFinalMessage: []byte("c=biws,r=clientNONCE123,p=AAAA"),
+		})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+
+	// handshakes which were never finished count towards the lockout
+	_, err = client.authenticate(t, "gateway", "s3cret")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestSessionStoreExpiry(t *testing.T) {
+	sessions := NewSessionStore(1)
+
+	token, _, err := sessions.Issue(Identity{User: "user", Domain: "local"})
+	require.NoError(t, err)
+
+	_, err = sessions.Validate(token)
+	assert.ErrorIs(t, err, ErrInvalidSession)
+
+	sessions = NewSessionStore(0)
+	token, _, err = sessions.Issue(Identity{User: "user", Domain: "local"})
+	require.NoError(t, err)
+
+	identity, err := sessions.Validate(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user", identity.User)
+
+	sessions.RevokeAll()
+	_, err = sessions.Validate(token)
+	assert.ErrorIs(t, err, ErrInvalidSession)
+}
@@ -0,0 +1,149 @@
+package scramauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionTokenPrefix identifies bearer tokens which are SCRAM session tokens.
+const SessionTokenPrefix = "cbs_"
+
+var ErrInvalidSession = errors.New("invalid or expired session")
+
+// Identity is who a session acts as.  An empty User indicates the gateway's
+// own credentials were used, so requests are not performed on behalf of
+// another user.
+type Identity struct {
+	User   string
+	Domain string
+}
+
+type session struct {
+	identity  Identity
+	expiresAt time.Time
+}
+
+// SessionStore holds the sessions established by SCRAM handshakes.  Sessions
+// are held in memory only, and are keyed by a hash of their token.
+type SessionStore struct {
+	ttl time.Duration
+
+	lock      sync.Mutex
+	sessions  map[string]*session
+	lastPrune time.Time
+}
+
+// NewSessionStore creates a store whose sessions expire after ttl, which
+// defaults to 1 hour.
+func NewSessionStore(ttl time.Duration) *SessionStore {
+	if ttl <= 0 {
+		ttl = 1 * time.Hour
+	}
+
+	return &SessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]*session),
+	}
+}
+
+// IsSessionToken returns whether a bearer token is in the format of a session
+// token.
+func IsSessionToken(token string) bool {
+	return strings.HasPrefix(token, SessionTokenPrefix)
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Issue creates a new session for an identity, returning its token.
+func (s *SessionStore) Issue(identity Identity) (string, time.Time, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token := SessionTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now()
+	expiresAt := now.Add(s.ttl)
+
+	s.lock.Lock()
+	s.maybePrune(now)
+	s.sessions[hashToken(token)] = &session{
+		identity:  identity,
+		expiresAt: expiresAt,
+	}
+	s.lock.Unlock()
+
+	return token, expiresAt, nil
+}
+
+// Validate returns the identity of an unexpired session.
+func (s *SessionStore) Validate(token string) (Identity, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := hashToken(token)
+	sess, ok := s.sessions[key]
+	if !ok {
+		return Identity{}, ErrInvalidSession
+	}
+
+	if !time.Now().Before(sess.expiresAt) {
+		delete(s.sessions, key)
+		return Identity{}, ErrInvalidSession
+	}
+
+	return sess.identity, nil
+}
+
+// Revoke ends a session, returning whether it existed.
+func (s *SessionStore) Revoke(token string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := hashToken(token)
+	_, ok := s.sessions[key]
+	delete(s.sessions, key)
+	return ok
+}
+
+// RevokeAll ends every session, used when the credentials they were
+// established with may no longer be valid.
+func (s *SessionStore) RevokeAll() {
+	s.lock.Lock()
+	s.sessions = make(map[string]*session)
+	s.lock.Unlock()
+}
+
+// Len returns the number of sessions, including any which have expired but
+// are yet to be removed.
+func (s *SessionStore) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.sessions)
+}
+
+// maybePrune removes expired sessions, at most once per ttl.  Must be called
+// with the lock held.
+func (s *SessionStore) maybePrune(now time.Time) {
+	if now.Sub(s.lastPrune) < s.ttl {
+		return
+	}
+	s.lastPrune = now
+
+	for key, sess := range s.sessions {
+		if !now.Before(sess.expiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+}
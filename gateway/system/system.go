@@ -25,19 +25,26 @@ import (
 	"github.com/couchbase/goprotostellar/genproto/admin_collection_v1"
 	"github.com/couchbase/goprotostellar/genproto/admin_query_v1"
 	"github.com/couchbase/goprotostellar/genproto/admin_search_v1"
+	"github.com/couchbase/goprotostellar/genproto/analytics_v1"
 	"github.com/couchbase/goprotostellar/genproto/internal_hooks_v1"
 	"github.com/couchbase/goprotostellar/genproto/internal_xdcr_v1"
 	"github.com/couchbase/goprotostellar/genproto/kv_v1"
 	"github.com/couchbase/goprotostellar/genproto/query_v1"
 	"github.com/couchbase/goprotostellar/genproto/routing_v2"
 	"github.com/couchbase/goprotostellar/genproto/search_v1"
+	"github.com/couchbase/goprotostellar/genproto/transactions_v1"
 	"github.com/couchbase/stellar-gateway/contrib/oapimetrics"
 	"github.com/couchbase/stellar-gateway/dataapiv1"
+	"github.com/couchbase/stellar-gateway/gateway/apikeys"
 	"github.com/couchbase/stellar-gateway/gateway/apiversion"
 	"github.com/couchbase/stellar-gateway/gateway/dapiimpl"
 	"github.com/couchbase/stellar-gateway/gateway/dataimpl"
 	"github.com/couchbase/stellar-gateway/gateway/hooks"
 	"github.com/couchbase/stellar-gateway/gateway/ratelimiting"
+	"github.com/couchbase/stellar-gateway/gateway/reqctx"
+	"github.com/couchbase/stellar-gateway/gateway/scramauth"
+	"github.com/couchbase/stellar-gateway/genproto/admin_apikey_v1"
+	"github.com/couchbase/stellar-gateway/genproto/scram_v1"
 	"github.com/couchbase/stellar-gateway/pkg/interceptors"
 	"github.com/couchbase/stellar-gateway/pkg/metrics"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
@@ -55,13 +62,21 @@ type SystemOptions struct {
 	DapiImpl *dapiimpl.Servers
 	Metrics  *metrics.SnMetrics
 
-	RateLimiter    ratelimiting.RateLimiter
+	RateLimiters   []ratelimiting.RateLimiter
 	GrpcTlsConfig  *tls.Config
 	DapiTlsConfig  *tls.Config
 	AlphaEndpoints bool
 	Debug          bool
 
 	ShutdownTimeout time.Duration
+
+	// ScramService is optionally registered on the data server, allowing
+	// clients to establish sessions via a SCRAM handshake.
+	ScramService *scramauth.Service
+
+	// ApiKeyService is optionally registered on the data server, allowing
+	// api keys to be managed via gRPC.
+	ApiKeyService *apikeys.Service
 }
 
 type System struct {
@@ -93,10 +108,11 @@ func NewSystem(opts *SystemOptions) (*System, error) {
 		unaryInterceptors = append(unaryInterceptors, debugInterceptor.UnaryInterceptor())
 		unaryInterceptors = append(unaryInterceptors, hooksManager.UnaryInterceptor())
 	}
-	if opts.RateLimiter != nil {
-		unaryInterceptors = append(unaryInterceptors, opts.RateLimiter.GrpcUnaryInterceptor())
-	}
 	unaryInterceptors = append(unaryInterceptors, apiversion.GrpcUnaryInterceptor(opts.Logger))
+	unaryInterceptors = append(unaryInterceptors, apikeys.GrpcUnaryInterceptor())
+	for _, rateLimiter := range opts.RateLimiters {
+		unaryInterceptors = append(unaryInterceptors, rateLimiter.GrpcUnaryInterceptor())
+	}
 	unaryInterceptors = append(unaryInterceptors, recovery.UnaryServerInterceptor(
 		recovery.WithRecoveryHandler(recoveryHandler),
 	))
@@ -106,10 +122,11 @@ func NewSystem(opts *SystemOptions) (*System, error) {
 	if opts.Debug {
 		streamInterceptors = append(streamInterceptors, debugInterceptor.StreamInterceptor())
 	}
-	if opts.RateLimiter != nil {
-		streamInterceptors = append(streamInterceptors, opts.RateLimiter.GrpcStreamInterceptor())
-	}
 	streamInterceptors = append(streamInterceptors, apiversion.GrpcStreamInterceptor(opts.Logger))
+	streamInterceptors = append(streamInterceptors, apikeys.GrpcStreamInterceptor())
+	for _, rateLimiter := range opts.RateLimiters {
+		streamInterceptors = append(streamInterceptors, rateLimiter.GrpcStreamInterceptor())
+	}
 	streamInterceptors = append(streamInterceptors, recovery.StreamServerInterceptor(
 		recovery.WithRecoveryHandler(recoveryHandler),
 	))
@@ -136,12 +153,20 @@ func NewSystem(opts *SystemOptions) (*System, error) {
 	kv_v1.RegisterKvServiceServer(dataSrv, dataImpl.KvV1Server)
 	query_v1.RegisterQueryServiceServer(dataSrv, dataImpl.QueryV1Server)
 	search_v1.RegisterSearchServiceServer(dataSrv, dataImpl.SearchV1Server)
+	analytics_v1.RegisterAnalyticsServiceServer(dataSrv, dataImpl.AnalyticsV1Server)
 	admin_bucket_v1.RegisterBucketAdminServiceServer(dataSrv, dataImpl.AdminBucketV1Server)
 	admin_collection_v1.RegisterCollectionAdminServiceServer(dataSrv, dataImpl.AdminCollectionV1Server)
 	admin_query_v1.RegisterQueryAdminServiceServer(dataSrv, dataImpl.AdminQueryIndexV1Server)
 	admin_search_v1.RegisterSearchAdminServiceServer(dataSrv, dataImpl.AdminSearchIndexV1Server)
+	transactions_v1.RegisterTransactionsServiceServer(dataSrv, dataImpl.TransactionsV1Server)
 	internal_xdcr_v1.RegisterXdcrServiceServer(dataSrv, dataImpl.XdcrV1Server)
 	routing_v2.RegisterRoutingServiceServer(dataSrv, dataImpl.RoutingServer)
+	if opts.ScramService != nil {
+		scram_v1.RegisterScramServiceServer(dataSrv, opts.ScramService)
+	}
+	if opts.ApiKeyService != nil {
+		admin_apikey_v1.RegisterApiKeyAdminServiceServer(dataSrv, opts.ApiKeyService)
+	}
 
 	// health check
 	healthServer := health.NewServer()
@@ -167,6 +192,7 @@ func NewSystem(opts *SystemOptions) (*System, error) {
 		dapiimpl.NewOtelTracingHandler(),
 		dapiimpl.NewUserAgentMetricsHandler(),
 		oapimetrics.NewStatsHandler(opts.Logger),
+		ratelimiting.NewStrictHttpMiddleware(),
 	}, dataapiv1.StrictHTTPServerOptions{
 		RequestErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
 			opts.Logger.Debug("handling unexpected data api strict error during request",
@@ -231,13 +257,17 @@ func NewSystem(opts *SystemOptions) (*System, error) {
 		Debug:            opts.Debug,
 	})
 
+	// rate limiters are applied after the request resource and client address
+	// have been recorded, so that they can be limited by them.
 	var httpHandler http.Handler = mux
+	for i := len(opts.RateLimiters) - 1; i >= 0; i-- {
+		httpHandler = opts.RateLimiters[i].HttpMiddleware(httpHandler)
+	}
+	httpHandler = apikeys.HttpMiddleware(httpHandler)
+	httpHandler = reqctx.ClientIPHttpMiddleware(httpHandler)
 	if opts.Debug {
 		httpHandler = hooksManager.HTTPMiddleware()(httpHandler)
 	}
-	if opts.RateLimiter != nil {
-		httpHandler = opts.RateLimiter.HttpMiddleware(httpHandler)
-	}
 	httpHandler = c.Handler(httpHandler)
 
 	dapiSrv := &http.Server{
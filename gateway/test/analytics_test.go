@@ -0,0 +1,142 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/couchbase/goprotostellar/genproto/analytics_v1"
+	"github.com/stretchr/testify/assert"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (s *GatewayOpsTestSuite) TestAnalyticsQuery() {
+	if !s.SupportsFeature(TestFeatureAnalytics) {
+		s.T().Skip()
+	}
+	analyticsClient := analytics_v1.NewAnalyticsServiceClient(s.gatewayConn)
+
+	readAnalyticsStream := func(
+		client analytics_v1.AnalyticsService_AnalyticsQueryClient,
+	) ([][]byte, *analytics_v1.AnalyticsQueryResponse_MetaData, error) {
+		var rows [][]byte
+		var md *analytics_v1.AnalyticsQueryResponse_MetaData
+
+		for {
+			resp, err := client.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				return rows, md, err
+			}
+
+			if len(resp.Rows) > 0 {
+				rows = append(rows, resp.Rows...)
+			}
+			if resp.MetaData != nil {
+				md = resp.MetaData
+			}
+		}
+
+		return rows, md, nil
+	}
+
+	s.Run("Basic", func() {
+		client, err := analyticsClient.AnalyticsQuery(context.Background(), &analytics_v1.AnalyticsQueryRequest{
+			Statement: "SELECT 1=1 AS result",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		rows, md, err := readAnalyticsStream(client)
+		assertRpcStatus(s.T(), err, codes.OK)
+
+		assert.Len(s.T(), rows, 1)
+		assert.JSONEq(s.T(), `{"result":true}`, string(rows[0]))
+		if assert.NotNil(s.T(), md) {
+			assert.NotEmpty(s.T(), md.RequestId)
+			assert.NotEmpty(s.T(), md.ClientContextId)
+			assert.NotNil(s.T(), md.Metrics)
+		}
+	})
+
+	s.Run("PositionalParameters", func() {
+		client, err := analyticsClient.AnalyticsQuery(context.Background(), &analytics_v1.AnalyticsQueryRequest{
+			Statement:            "SELECT $1 AS result",
+			PositionalParameters: [][]byte{[]byte(`"hello"`)},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		rows, _, err := readAnalyticsStream(client)
+		assertRpcStatus(s.T(), err, codes.OK)
+
+		assert.Len(s.T(), rows, 1)
+		assert.JSONEq(s.T(), `{"result":"hello"}`, string(rows[0]))
+	})
+
+	s.Run("NamedParameters", func() {
+		client, err := analyticsClient.AnalyticsQuery(context.Background(), &analytics_v1.AnalyticsQueryRequest{
+			Statement: "SELECT $greeting AS result",
+			NamedParameters: map[string][]byte{
+				"greeting": []byte(`"hello"`),
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		rows, _, err := readAnalyticsStream(client)
+		assertRpcStatus(s.T(), err, codes.OK)
+
+		assert.Len(s.T(), rows, 1)
+		assert.JSONEq(s.T(), `{"result":"hello"}`, string(rows[0]))
+	})
+
+	s.Run("MixedParameters", func() {
+		client, err := analyticsClient.AnalyticsQuery(context.Background(), &analytics_v1.AnalyticsQueryRequest{
+			Statement:            "SELECT $1 AS result",
+			PositionalParameters: [][]byte{[]byte(`"hello"`)},
+			NamedParameters: map[string][]byte{
+				"greeting": []byte(`"hello"`),
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, _, err = readAnalyticsStream(client)
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("InvalidStatement", func() {
+		client, err := analyticsClient.AnalyticsQuery(context.Background(), &analytics_v1.AnalyticsQueryRequest{
+			Statement: "FINAGLE * FROM missing",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, _, err = readAnalyticsStream(client)
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("BadCredentials", func() {
+		client, err := analyticsClient.AnalyticsQuery(context.Background(), &analytics_v1.AnalyticsQueryRequest{
+			Statement: "SELECT 1=1 AS result",
+		}, grpc.PerRPCCredentials(s.badRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, _, err = readAnalyticsStream(client)
+		assertRpcStatus(s.T(), err, codes.PermissionDenied)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), "user", d.ResourceType)
+		})
+	})
+
+	s.Run("Unauthenticated", func() {
+		client, err := analyticsClient.AnalyticsQuery(context.Background(), &analytics_v1.AnalyticsQueryRequest{
+			Statement: "SELECT 1=1 AS result",
+		})
+		requireRpcSuccess(s.T(), client, err)
+
+		_, _, err = readAnalyticsStream(client)
+		assertRpcStatus(s.T(), err, codes.Unauthenticated)
+	})
+}
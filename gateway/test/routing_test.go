@@ -68,8 +68,42 @@ func (s *GatewayOpsTestSuite) TestWatchRouting() {
 		)
 		require.NoError(s.T(), err)
 
+		resp, err := sClient.Recv()
+		require.NoError(s.T(), err)
+
+		// The test gateway treats the node it was bootstrapped against as
+		// local, so exactly one server should be reported as local.
+		require.NotNil(s.T(), resp.ServerRouting)
+		require.Equal(s.T(), uint32(1), resp.ServerRouting.NumLocalServers)
+
+		require.NotNil(s.T(), resp.ViewsRouting)
+		require.NotNil(s.T(), resp.QueryRouting)
+		require.NotNil(s.T(), resp.SearchRouting)
+		require.NotNil(s.T(), resp.AnalyticsRouting)
+		require.LessOrEqual(s.T(), resp.QueryRouting.NumLocalServers, uint32(1))
+		require.Nil(s.T(), resp.VbucketDataRouting)
+	})
+
+	s.Run("ServerRoutingUnauthenticated", func() {
+		sClient, err := client.WatchRouting(
+			context.Background(),
+			&routing_v2.WatchRoutingRequest{},
+		)
+		require.NoError(s.T(), err)
+
+		_, err = sClient.Recv()
+		assertRpcStatus(s.T(), err, codes.Unauthenticated)
+	})
+
+	s.Run("ServerRoutingBadCredentials", func() {
+		sClient, err := client.WatchRouting(
+			context.Background(),
+			&routing_v2.WatchRoutingRequest{},
+			grpc.PerRPCCredentials(s.badRpcCreds),
+		)
+		require.NoError(s.T(), err)
+
 		_, err = sClient.Recv()
-		// ING-1357 (support non-kv optimised routing)
-		assertRpcStatus(s.T(), err, codes.Unimplemented)
+		assertRpcStatus(s.T(), err, codes.PermissionDenied)
 	})
 }
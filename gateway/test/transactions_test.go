@@ -0,0 +1,249 @@
+package test
+
+import (
+	"context"
+
+	"github.com/couchbase/goprotostellar/genproto/kv_v1"
+	"github.com/couchbase/goprotostellar/genproto/transactions_v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (s *GatewayOpsTestSuite) TestTransactions() {
+	txnClient := transactions_v1.NewTransactionsServiceClient(s.gatewayConn)
+
+	beginTxn := func() (string, string) {
+		resp, err := txnClient.TransactionBeginAttempt(context.Background(), &transactions_v1.TransactionBeginAttemptRequest{
+			BucketName: s.bucketName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+		require.NotEmpty(s.T(), resp.TransactionId)
+		require.NotEmpty(s.T(), resp.AttemptId)
+
+		return resp.TransactionId, resp.AttemptId
+	}
+
+	s.Run("ReplaceAndCommit", func() {
+		docId := s.testDocId()
+		txnId, attemptId := beginTxn()
+
+		getResp, err := txnClient.TransactionGet(context.Background(), &transactions_v1.TransactionGetRequest{
+			BucketName:     s.bucketName,
+			TransactionId:  txnId,
+			AttemptId:      attemptId,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), getResp, err)
+		assertValidCas(s.T(), getResp.Cas)
+		assert.JSONEq(s.T(), string(TEST_CONTENT), string(getResp.Value))
+
+		replaceResp, err := txnClient.TransactionReplace(context.Background(), &transactions_v1.TransactionReplaceRequest{
+			BucketName:     s.bucketName,
+			TransactionId:  txnId,
+			AttemptId:      attemptId,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+			Cas:            getResp.Cas,
+			Value:          []byte(`{"txn":"replaced"}`),
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), replaceResp, err)
+
+		commitResp, err := txnClient.TransactionCommit(context.Background(), &transactions_v1.TransactionCommitRequest{
+			BucketName:    s.bucketName,
+			TransactionId: txnId,
+			AttemptId:     attemptId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), commitResp, err)
+
+		s.checkDocument(s.T(), checkDocumentOptions{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			DocId:          docId,
+			Content:        []byte(`{"txn":"replaced"}`),
+			ContentFlags:   TEST_CONTENT_FLAGS,
+			CheckAsJson:    true,
+		})
+
+		// the transaction should no longer exist once committed
+		_, err = txnClient.TransactionCommit(context.Background(), &transactions_v1.TransactionCommitRequest{
+			BucketName:    s.bucketName,
+			TransactionId: txnId,
+			AttemptId:     attemptId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+	})
+
+	s.Run("InsertAndRollback", func() {
+		docId := s.randomDocId()
+		txnId, attemptId := beginTxn()
+
+		insertResp, err := txnClient.TransactionInsert(context.Background(), &transactions_v1.TransactionInsertRequest{
+			BucketName:     s.bucketName,
+			TransactionId:  txnId,
+			AttemptId:      attemptId,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+			Value:          TEST_CONTENT,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), insertResp, err)
+
+		rollbackResp, err := txnClient.TransactionRollback(context.Background(), &transactions_v1.TransactionRollbackRequest{
+			BucketName:    s.bucketName,
+			TransactionId: txnId,
+			AttemptId:     attemptId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), rollbackResp, err)
+
+		s.checkDocument(s.T(), checkDocumentOptions{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			DocId:          docId,
+			Content:        nil,
+		})
+	})
+
+	s.Run("NewAttemptRollsBackPrevious", func() {
+		docId := s.testDocId()
+		txnId, attemptId := beginTxn()
+
+		getResp, err := txnClient.TransactionGet(context.Background(), &transactions_v1.TransactionGetRequest{
+			BucketName:     s.bucketName,
+			TransactionId:  txnId,
+			AttemptId:      attemptId,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), getResp, err)
+
+		replaceResp, err := txnClient.TransactionReplace(context.Background(), &transactions_v1.TransactionReplaceRequest{
+			BucketName:     s.bucketName,
+			TransactionId:  txnId,
+			AttemptId:      attemptId,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+			Cas:            getResp.Cas,
+			Value:          []byte(`{"txn":"replaced"}`),
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), replaceResp, err)
+
+		// beginning a new attempt without rolling back the previous one
+		// should still remove the mutation it staged.
+		beginResp, err := txnClient.TransactionBeginAttempt(context.Background(), &transactions_v1.TransactionBeginAttemptRequest{
+			BucketName:    s.bucketName,
+			TransactionId: &txnId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), beginResp, err)
+		assert.Equal(s.T(), txnId, beginResp.TransactionId)
+		assert.NotEqual(s.T(), attemptId, beginResp.AttemptId)
+
+		trueBool := true
+		kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
+		lookupResp, err := kvClient.LookupIn(context.Background(), &kv_v1.LookupInRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+			Specs: []*kv_v1.LookupInRequest_Spec{
+				{
+					Operation: kv_v1.LookupInRequest_Spec_OPERATION_EXISTS,
+					Path:      "txn",
+					Flags: &kv_v1.LookupInRequest_Spec_Flags{
+						Xattr: &trueBool,
+					},
+				},
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), lookupResp, err)
+		require.Len(s.T(), lookupResp.Specs, 1)
+		assert.Equal(s.T(), []byte(`false`), lookupResp.Specs[0].Content)
+
+		s.checkDocument(s.T(), checkDocumentOptions{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			DocId:          docId,
+			Content:        TEST_CONTENT,
+			ContentFlags:   TEST_CONTENT_FLAGS,
+			CheckAsJson:    true,
+		})
+	})
+
+	s.Run("ReplaceWithoutGet", func() {
+		docId := s.testDocId()
+		txnId, attemptId := beginTxn()
+
+		_, err := txnClient.TransactionReplace(context.Background(), &transactions_v1.TransactionReplaceRequest{
+			BucketName:     s.bucketName,
+			TransactionId:  txnId,
+			AttemptId:      attemptId,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+			Value:          TEST_CONTENT,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.FailedPrecondition)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.PreconditionFailure) {
+			assert.Len(s.T(), d.Violations, 1)
+			assert.Equal(s.T(), "DOC_NOT_READ", d.Violations[0].Type)
+		})
+	})
+
+	s.Run("AttemptMismatch", func() {
+		txnId, _ := beginTxn()
+
+		_, err := txnClient.TransactionCommit(context.Background(), &transactions_v1.TransactionCommitRequest{
+			BucketName:    s.bucketName,
+			TransactionId: txnId,
+			AttemptId:     "invalid-attempt",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.FailedPrecondition)
+	})
+
+	s.Run("TransactionMissing", func() {
+		_, err := txnClient.TransactionCommit(context.Background(), &transactions_v1.TransactionCommitRequest{
+			BucketName:    s.bucketName,
+			TransactionId: "missing-transaction",
+			AttemptId:     "missing-attempt",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), "transaction", d.ResourceType)
+		})
+	})
+
+	s.Run("DifferentUser", func() {
+		txnId, attemptId := beginTxn()
+
+		_, err := txnClient.TransactionCommit(context.Background(), &transactions_v1.TransactionCommitRequest{
+			BucketName:    s.bucketName,
+			TransactionId: txnId,
+			AttemptId:     attemptId,
+		}, grpc.PerRPCCredentials(s.getReadOnlyRpcCredentials()))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+	})
+
+	s.Run("BadCredentials", func() {
+		_, err := txnClient.TransactionBeginAttempt(context.Background(), &transactions_v1.TransactionBeginAttemptRequest{
+			BucketName: s.bucketName,
+		}, grpc.PerRPCCredentials(s.badRpcCreds))
+		assertRpcStatus(s.T(), err, codes.PermissionDenied)
+	})
+
+	s.Run("Unauthenticated", func() {
+		_, err := txnClient.TransactionBeginAttempt(context.Background(), &transactions_v1.TransactionBeginAttemptRequest{
+			BucketName: s.bucketName,
+		})
+		assertRpcStatus(s.T(), err, codes.Unauthenticated)
+	})
+}
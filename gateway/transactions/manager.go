@@ -0,0 +1,329 @@
+package transactions
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/transactionsx"
+	"go.uber.org/zap"
+)
+
+const defaultLeaseTimeout = 15 * time.Second
+const defaultTransactionTimeout = 15 * time.Second
+const defaultCleanupInterval = 1 * time.Second
+const cleanupRollbackTimeout = 10 * time.Second
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrAttemptMismatch     = errors.New("transaction attempt does not match the active attempt")
+	ErrBucketMismatch      = errors.New("transaction does not belong to the specified bucket")
+	ErrDocumentNotRead     = errors.New("document was not read within this transaction")
+	ErrCasMismatch         = errors.New("document cas does not match the version read within this transaction")
+	ErrManagerClosed       = errors.New("transactions manager is closed")
+)
+
+type ManagerOptions struct {
+	Logger   *zap.Logger
+	CbClient *gocbcorex.BucketsTrackingAgentManager
+
+	// LeaseTimeout specifies how long a transaction is held by the gateway
+	// without any operations being performed against it before the gateway
+	// considers it abandoned and rolls it back.
+	LeaseTimeout time.Duration
+
+	// TransactionTimeout specifies the maximum length of time a single
+	// transaction is allowed to run for before it expires.
+	TransactionTimeout time.Duration
+
+	CleanupInterval time.Duration
+}
+
+// Manager tracks the transactions which have been started by clients of
+// this gateway instance, keyed by their transaction id.
+//
+// Transactions are only tracked in memory, and the manager does not perform
+// lost transaction cleanup.  If the gateway stops without closing the
+// manager, such as when it crashes, the ATR entries and staged documents of
+// its transactions are left behind until they are cleaned up by another
+// client of the cluster which performs lost transaction cleanup.  Once such
+// transactions expire, other transactions may overwrite their staged
+// documents.
+type Manager struct {
+	logger          *zap.Logger
+	txnsMgr         *transactionsx.TransactionsManager
+	leaseTimeout    time.Duration
+	cleanupInterval time.Duration
+
+	lock   sync.Mutex
+	txns   map[string]*Transaction
+	closed bool
+
+	closeSig    chan struct{}
+	closeOnce   sync.Once
+	cleanupDone chan struct{}
+}
+
+func NewManager(opts *ManagerOptions) (*Manager, error) {
+	leaseTimeout := opts.LeaseTimeout
+	if leaseTimeout <= 0 {
+		leaseTimeout = defaultLeaseTimeout
+	}
+
+	transactionTimeout := opts.TransactionTimeout
+	if transactionTimeout <= 0 {
+		transactionTimeout = defaultTransactionTimeout
+	}
+
+	cleanupInterval := opts.CleanupInterval
+	if cleanupInterval <= 0 {
+		cleanupInterval = defaultCleanupInterval
+	}
+
+	cbClient := opts.CbClient
+	txnsMgr, err := transactionsx.InitTransactions(&transactionsx.TransactionsConfig{
+		Logger:         opts.Logger.Named("transactionsx"),
+		ExpirationTime: transactionTimeout,
+		BucketAgentProvider: func(ctx context.Context, bucketName string) (*gocbcorex.Agent, string, error) {
+			agent, err := cbClient.GetBucketAgent(ctx, bucketName)
+			if err != nil {
+				return nil, "", err
+			}
+
+			return agent, "", nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		logger:          opts.Logger,
+		txnsMgr:         txnsMgr,
+		leaseTimeout:    leaseTimeout,
+		cleanupInterval: cleanupInterval,
+		txns:            make(map[string]*Transaction),
+		closeSig:        make(chan struct{}),
+		cleanupDone:     make(chan struct{}),
+	}
+
+	go m.cleanupThread()
+
+	return m, nil
+}
+
+// Begin starts a new attempt for a transaction.  If txnID is nil, a brand
+// new transaction is created, otherwise a new attempt is started for the
+// existing transaction (which must belong to the same user and bucket), and
+// the current attempt is rolled back if it has not already been.  The returned transaction is acquired and must be released by the caller.
+func (m *Manager) Begin(
+	ctx context.Context,
+	bucketName string,
+	oboUser string,
+	txnID *string,
+) (*Transaction, error) {
+	if txnID != nil {
+		txn, err := m.acquire(*txnID, bucketName, oboUser)
+		if err != nil {
+			return nil, err
+		}
+
+		// any mutations staged by the previous attempt would otherwise be
+		// left behind, as only the latest attempt is ever rolled back.
+		if !txn.attemptDone {
+			m.rollback(txn.id, txn.txn)
+		}
+
+		err = txn.txn.NewAttempt(ctx)
+		if err != nil {
+			txn.Release()
+			return nil, err
+		}
+
+		txn.attemptID = txn.txn.Attempt().ID
+		txn.attemptDone = false
+		txn.docs = make(map[docKey]*transactionsx.TransactionGetResult)
+
+		return txn, nil
+	}
+
+	coreTxn, err := m.txnsMgr.BeginTransaction(&transactionsx.TransactionOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = coreTxn.NewAttempt(ctx)
+	if err != nil {
+		// the transaction is not yet tracked, so nothing else will roll back
+		// whatever part of the attempt was started.
+		m.rollback(coreTxn.ID(), coreTxn)
+		return nil, err
+	}
+
+	txn := &Transaction{
+		manager:     m,
+		id:          coreTxn.ID(),
+		bucketName:  bucketName,
+		oboUser:     oboUser,
+		txn:         coreTxn,
+		attemptID:   coreTxn.Attempt().ID,
+		leaseExpiry: time.Now().Add(m.leaseTimeout),
+		docs:        make(map[docKey]*transactionsx.TransactionGetResult),
+	}
+	txn.lock.Lock()
+
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		m.rollback(txn.id, coreTxn)
+		return nil, ErrManagerClosed
+	}
+	m.txns[txn.id] = txn
+	m.lock.Unlock()
+
+	m.logger.Debug("began new transaction",
+		zap.String("transactionId", txn.id),
+		zap.String("attemptId", txn.attemptID))
+
+	return txn, nil
+}
+
+// Acquire locks the transaction for exclusive use by a single operation and
+// extends its lease.  The caller must Release the transaction when done.
+func (m *Manager) Acquire(
+	txnID string,
+	attemptID string,
+	bucketName string,
+	oboUser string,
+) (*Transaction, error) {
+	txn, err := m.acquire(txnID, bucketName, oboUser)
+	if err != nil {
+		return nil, err
+	}
+
+	if txn.attemptID != attemptID {
+		txn.Release()
+		return nil, ErrAttemptMismatch
+	}
+
+	return txn, nil
+}
+
+func (m *Manager) acquire(txnID string, bucketName string, oboUser string) (*Transaction, error) {
+	m.lock.Lock()
+	txn := m.txns[txnID]
+	m.lock.Unlock()
+
+	// we intentionally do not differentiate a transaction belonging to another
+	// user from a missing one to avoid leaking the existence of transactions.
+	if txn == nil || txn.oboUser != oboUser {
+		return nil, ErrTransactionNotFound
+	}
+
+	txn.lock.Lock()
+
+	if txn.removed {
+		txn.lock.Unlock()
+		return nil, ErrTransactionNotFound
+	}
+
+	if txn.bucketName != bucketName {
+		txn.lock.Unlock()
+		return nil, ErrBucketMismatch
+	}
+
+	txn.leaseExpiry = time.Now().Add(m.leaseTimeout)
+
+	return txn, nil
+}
+
+func (m *Manager) remove(txn *Transaction) {
+	txn.removed = true
+
+	m.lock.Lock()
+	delete(m.txns, txn.id)
+	m.lock.Unlock()
+}
+
+// rollback rolls back a transaction which is being abandoned.
+func (m *Manager) rollback(txnID string, coreTxn *transactionsx.Transaction) {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupRollbackTimeout)
+	err := coreTxn.Rollback(ctx)
+	cancel()
+	if err != nil {
+		m.logger.Debug("failed to rollback abandoned transaction",
+			zap.Error(err),
+			zap.String("transactionId", txnID))
+	}
+}
+
+func (m *Manager) cleanupThread() {
+	defer close(m.cleanupDone)
+
+	ticker := time.NewTicker(m.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.closeSig:
+			m.cleanupTransactions(true)
+			return
+		case <-ticker.C:
+			m.cleanupTransactions(false)
+		}
+	}
+}
+
+// cleanupTransactions rolls back any transactions owned by this gateway whose
+// lease has expired (or all of them if force is set).
+func (m *Manager) cleanupTransactions(force bool) {
+	now := time.Now()
+
+	m.lock.Lock()
+	txns := make([]*Transaction, 0, len(m.txns))
+	for _, txn := range m.txns {
+		txns = append(txns, txn)
+	}
+	m.lock.Unlock()
+
+	for _, txn := range txns {
+		// if the transaction is currently in use by an operation, its lease
+		// is about to be extended, so there is nothing to clean up.  When
+		// closing, we instead wait for the operation to finish.
+		if force {
+			txn.lock.Lock()
+		} else if !txn.lock.TryLock() {
+			continue
+		}
+
+		if txn.removed || (!force && now.Before(txn.leaseExpiry)) {
+			txn.lock.Unlock()
+			continue
+		}
+
+		m.logger.Debug("cleaning up expired transaction",
+			zap.String("transactionId", txn.id),
+			zap.String("attemptId", txn.attemptID))
+
+		m.rollback(txn.id, txn.txn)
+		m.remove(txn)
+		txn.lock.Unlock()
+	}
+}
+
+// Close rolls back all outstanding transactions, waiting for any operations
+// using them to finish, and stops the cleanup thread.  Transactions can no
+// longer be started once the manager is closed.
+func (m *Manager) Close() {
+	m.closeOnce.Do(func() {
+		m.lock.Lock()
+		m.closed = true
+		m.lock.Unlock()
+
+		close(m.closeSig)
+	})
+
+	<-m.cleanupDone
+}
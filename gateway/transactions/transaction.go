@@ -0,0 +1,182 @@
+package transactions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/transactionsx"
+)
+
+type docKey struct {
+	ScopeName      string
+	CollectionName string
+	Key            string
+}
+
+// Transaction represents a transaction held by the gateway on behalf of a
+// client.  All methods other than ID and AttemptID require the transaction
+// to be acquired from the Manager first.
+type Transaction struct {
+	manager *Manager
+
+	lock        sync.Mutex
+	id          string
+	bucketName  string
+	oboUser     string
+	txn         *transactionsx.Transaction
+	attemptID   string
+	attemptDone bool
+	leaseExpiry time.Time
+	removed     bool
+
+	// docs tracks the latest version of each document which has been seen
+	// within the current attempt so that it can be passed back into the
+	// transaction for subsequent replace or remove operations.
+	docs map[docKey]*transactionsx.TransactionGetResult
+}
+
+func (t *Transaction) ID() string {
+	return t.id
+}
+
+func (t *Transaction) AttemptID() string {
+	return t.attemptID
+}
+
+// Release returns the transaction to the manager so that other operations
+// may make use of it.
+func (t *Transaction) Release() {
+	t.lock.Unlock()
+}
+
+func (t *Transaction) Get(
+	ctx context.Context,
+	agent *gocbcorex.Agent,
+	scopeName, collectionName, key string,
+) (*transactionsx.TransactionGetResult, error) {
+	res, err := t.txn.Get(ctx, &transactionsx.TransactionGetOptions{
+		Agent:          agent,
+		OboUser:        t.oboUser,
+		ScopeName:      scopeName,
+		CollectionName: collectionName,
+		Key:            []byte(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.docs[docKey{scopeName, collectionName, key}] = res
+	return res, nil
+}
+
+func (t *Transaction) Insert(
+	ctx context.Context,
+	agent *gocbcorex.Agent,
+	scopeName, collectionName, key string,
+	value []byte,
+) (*transactionsx.TransactionGetResult, error) {
+	res, err := t.txn.Insert(ctx, &transactionsx.TransactionInsertOptions{
+		Agent:          agent,
+		OboUser:        t.oboUser,
+		ScopeName:      scopeName,
+		CollectionName: collectionName,
+		Key:            []byte(key),
+		Value:          value,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.docs[docKey{scopeName, collectionName, key}] = res
+	return res, nil
+}
+
+// lookupDoc returns the previously seen version of a document.  A cas of 0
+// indicates the caller does not care which version was seen.
+func (t *Transaction) lookupDoc(
+	scopeName, collectionName, key string,
+	cas uint64,
+) (*transactionsx.TransactionGetResult, error) {
+	doc := t.docs[docKey{scopeName, collectionName, key}]
+	if doc == nil {
+		return nil, ErrDocumentNotRead
+	}
+
+	if cas != 0 && uint64(doc.Cas) != cas {
+		return nil, ErrCasMismatch
+	}
+
+	return doc, nil
+}
+
+func (t *Transaction) Replace(
+	ctx context.Context,
+	scopeName, collectionName, key string,
+	cas uint64,
+	value []byte,
+) (*transactionsx.TransactionGetResult, error) {
+	doc, err := t.lookupDoc(scopeName, collectionName, key, cas)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.txn.Replace(ctx, &transactionsx.TransactionReplaceOptions{
+		Document: doc,
+		Value:    value,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.docs[docKey{scopeName, collectionName, key}] = res
+	return res, nil
+}
+
+func (t *Transaction) Remove(
+	ctx context.Context,
+	scopeName, collectionName, key string,
+	cas uint64,
+) (*transactionsx.TransactionGetResult, error) {
+	doc, err := t.lookupDoc(scopeName, collectionName, key, cas)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.txn.Remove(ctx, &transactionsx.TransactionRemoveOptions{
+		Document: doc,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.docs[docKey{scopeName, collectionName, key}] = res
+	return res, nil
+}
+
+// Commit commits the current attempt.  On success the transaction is removed
+// from the manager, on failure it is retained so that the client can decide
+// to begin a new attempt.
+func (t *Transaction) Commit(ctx context.Context) error {
+	err := t.txn.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.manager.remove(t)
+	return nil
+}
+
+// Rollback rolls back the current attempt.  The transaction is retained so
+// that a new attempt can be started, and is otherwise cleaned up once its
+// lease expires.
+func (t *Transaction) Rollback(ctx context.Context) error {
+	err := t.txn.Rollback(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.attemptDone = true
+	return nil
+}
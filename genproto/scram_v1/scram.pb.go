@@ -0,0 +1,405 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: couchbase/gateway/scram/v1/scram.proto
+
+package scram_v1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type StartScramRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One of SCRAM-SHA-256 or SCRAM-SHA-512.
+	Mechanism          string `protobuf:"bytes,1,opt,name=mechanism,proto3" json:"mechanism,omitempty"`
+	ClientFirstMessage []byte `protobuf:"bytes,2,opt,name=client_first_message,json=clientFirstMessage,proto3" json:"client_first_message,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *StartScramRequest) Reset() {
+	*x = StartScramRequest{}
+	mi := &file_couchbase_gateway_scram_v1_scram_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartScramRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartScramRequest) ProtoMessage() {}
+
+func (x *StartScramRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_couchbase_gateway_scram_v1_scram_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartScramRequest.ProtoReflect.Descriptor instead.
+func (*StartScramRequest) Descriptor() ([]byte, []int) {
+	return file_couchbase_gateway_scram_v1_scram_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StartScramRequest) GetMechanism() string {
+	if x != nil {
+		return x.Mechanism
+	}
+	return ""
+}
+
+func (x *StartScramRequest) GetClientFirstMessage() []byte {
+	if x != nil {
+		return x.ClientFirstMessage
+	}
+	return nil
+}
+
+type StartScramResponse struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId     string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	ServerFirstMessage []byte                 `protobuf:"bytes,2,opt,name=server_first_message,json=serverFirstMessage,proto3" json:"server_first_message,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *StartScramResponse) Reset() {
+	*x = StartScramResponse{}
+	mi := &file_couchbase_gateway_scram_v1_scram_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartScramResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartScramResponse) ProtoMessage() {}
+
+func (x *StartScramResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_couchbase_gateway_scram_v1_scram_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartScramResponse.ProtoReflect.Descriptor instead.
+func (*StartScramResponse) Descriptor() ([]byte, []int) {
+	return file_couchbase_gateway_scram_v1_scram_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StartScramResponse) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *StartScramResponse) GetServerFirstMessage() []byte {
+	if x != nil {
+		return x.ServerFirstMessage
+	}
+	return nil
+}
+
+type FinishScramRequest struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId     string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	ClientFinalMessage []byte                 `protobuf:"bytes,2,opt,name=client_final_message,json=clientFinalMessage,proto3" json:"client_final_message,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *FinishScramRequest) Reset() {
+	*x = FinishScramRequest{}
+	mi := &file_couchbase_gateway_scram_v1_scram_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FinishScramRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FinishScramRequest) ProtoMessage() {}
+
+func (x *FinishScramRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_couchbase_gateway_scram_v1_scram_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FinishScramRequest.ProtoReflect.Descriptor instead.
+func (*FinishScramRequest) Descriptor() ([]byte, []int) {
+	return file_couchbase_gateway_scram_v1_scram_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *FinishScramRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *FinishScramRequest) GetClientFinalMessage() []byte {
+	if x != nil {
+		return x.ClientFinalMessage
+	}
+	return nil
+}
+
+type FinishScramResponse struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	ServerFinalMessage []byte                 `protobuf:"bytes,1,opt,name=server_final_message,json=serverFinalMessage,proto3" json:"server_final_message,omitempty"`
+	SessionToken       string                 `protobuf:"bytes,2,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+	ExpiresInSecs      uint32                 `protobuf:"varint,3,opt,name=expires_in_secs,json=expiresInSecs,proto3" json:"expires_in_secs,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *FinishScramResponse) Reset() {
+	*x = FinishScramResponse{}
+	mi := &file_couchbase_gateway_scram_v1_scram_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FinishScramResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FinishScramResponse) ProtoMessage() {}
+
+func (x *FinishScramResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_couchbase_gateway_scram_v1_scram_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FinishScramResponse.ProtoReflect.Descriptor instead.
+func (*FinishScramResponse) Descriptor() ([]byte, []int) {
+	return file_couchbase_gateway_scram_v1_scram_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *FinishScramResponse) GetServerFinalMessage() []byte {
+	if x != nil {
+		return x.ServerFinalMessage
+	}
+	return nil
+}
+
+func (x *FinishScramResponse) GetSessionToken() string {
+	if x != nil {
+		return x.SessionToken
+	}
+	return ""
+}
+
+func (x *FinishScramResponse) GetExpiresInSecs() uint32 {
+	if x != nil {
+		return x.ExpiresInSecs
+	}
+	return 0
+}
+
+type RevokeSessionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionToken  string                 `protobuf:"bytes,1,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevokeSessionRequest) Reset() {
+	*x = RevokeSessionRequest{}
+	mi := &file_couchbase_gateway_scram_v1_scram_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevokeSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevokeSessionRequest) ProtoMessage() {}
+
+func (x *RevokeSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_couchbase_gateway_scram_v1_scram_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevokeSessionRequest.ProtoReflect.Descriptor instead.
+func (*RevokeSessionRequest) Descriptor() ([]byte, []int) {
+	return file_couchbase_gateway_scram_v1_scram_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RevokeSessionRequest) GetSessionToken() string {
+	if x != nil {
+		return x.SessionToken
+	}
+	return ""
+}
+
+type RevokeSessionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevokeSessionResponse) Reset() {
+	*x = RevokeSessionResponse{}
+	mi := &file_couchbase_gateway_scram_v1_scram_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevokeSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevokeSessionResponse) ProtoMessage() {}
+
+func (x *RevokeSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_couchbase_gateway_scram_v1_scram_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevokeSessionResponse.ProtoReflect.Descriptor instead.
+func (*RevokeSessionResponse) Descriptor() ([]byte, []int) {
+	return file_couchbase_gateway_scram_v1_scram_proto_rawDescGZIP(), []int{5}
+}
+
+var File_couchbase_gateway_scram_v1_scram_proto protoreflect.FileDescriptor
+
+const file_couchbase_gateway_scram_v1_scram_proto_rawDesc = "" +
+	"\n" +
+	"&couchbase/gateway/scram/v1/scram.proto\x12\x1acouchbase.gateway.scram.v1\"c\n" +
+	"\x11StartScramRequest\x12\x1c\n" +
+	"\tmechanism\x18\x01 \x01(\tR\tmechanism\x120\n" +
+	"\x14client_first_message\x18\x02 \x01(\fR\x12clientFirstMessage\"o\n" +
+	"\x12StartScramResponse\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\x120\n" +
+	"\x14server_first_message\x18\x02 \x01(\fR\x12serverFirstMessage\"o\n" +
+	"\x12FinishScramRequest\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\x120\n" +
+	"\x14client_final_message\x18\x02 \x01(\fR\x12clientFinalMessage\"\x94\x01\n" +
+	"\x13FinishScramResponse\x120\n" +
+	"\x14server_final_message\x18\x01 \x01(\fR\x12serverFinalMessage\x12#\n" +
+	"\rsession_token\x18\x02 \x01(\tR\fsessionToken\x12&\n" +
+	"\x0fexpires_in_secs\x18\x03 \x01(\rR\rexpiresInSecs\";\n" +
+	"\x14RevokeSessionRequest\x12#\n" +
+	"\rsession_token\x18\x01 \x01(\tR\fsessionToken\"\x17\n" +
+	"\x15RevokeSessionResponse2\xe1\x02\n" +
+	"\fScramService\x12k\n" +
+	"\n" +
+	"StartScram\x12-.couchbase.gateway.scram.v1.StartScramRequest\x1a..couchbase.gateway.scram.v1.StartScramResponse\x12n\n" +
+	"\vFinishScram\x12..couchbase.gateway.scram.v1.FinishScramRequest\x1a/.couchbase.gateway.scram.v1.FinishScramResponse\x12t\n" +
+	"\rRevokeSession\x120.couchbase.gateway.scram.v1.RevokeSessionRequest\x1a1.couchbase.gateway.scram.v1.RevokeSessionResponseBAZ?github.com/couchbase/stellar-gateway/genproto/scram_v1;scram_v1b\x06proto3"
+
+var (
+	file_couchbase_gateway_scram_v1_scram_proto_rawDescOnce sync.Once
+	file_couchbase_gateway_scram_v1_scram_proto_rawDescData []byte
+)
+
+func file_couchbase_gateway_scram_v1_scram_proto_rawDescGZIP() []byte {
+	file_couchbase_gateway_scram_v1_scram_proto_rawDescOnce.Do(func() {
+		file_couchbase_gateway_scram_v1_scram_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_couchbase_gateway_scram_v1_scram_proto_rawDesc), len(file_couchbase_gateway_scram_v1_scram_proto_rawDesc)))
+	})
+	return file_couchbase_gateway_scram_v1_scram_proto_rawDescData
+}
+
+var file_couchbase_gateway_scram_v1_scram_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_couchbase_gateway_scram_v1_scram_proto_goTypes = []any{
+	(*StartScramRequest)(nil),     // 0: couchbase.gateway.scram.v1.StartScramRequest
+	(*StartScramResponse)(nil),    // 1: couchbase.gateway.scram.v1.StartScramResponse
+	(*FinishScramRequest)(nil),    // 2: couchbase.gateway.scram.v1.FinishScramRequest
+	(*FinishScramResponse)(nil),   // 3: couchbase.gateway.scram.v1.FinishScramResponse
+	(*RevokeSessionRequest)(nil),  // 4: couchbase.gateway.scram.v1.RevokeSessionRequest
+	(*RevokeSessionResponse)(nil), // 5: couchbase.gateway.scram.v1.RevokeSessionResponse
+}
+var file_couchbase_gateway_scram_v1_scram_proto_depIdxs = []int32{
+	0, // 0: couchbase.gateway.scram.v1.ScramService.StartScram:input_type -> couchbase.gateway.scram.v1.StartScramRequest
+	2, // 1: couchbase.gateway.scram.v1.ScramService.FinishScram:input_type -> couchbase.gateway.scram.v1.FinishScramRequest
+	4, // 2: couchbase.gateway.scram.v1.ScramService.RevokeSession:input_type -> couchbase.gateway.scram.v1.RevokeSessionRequest
+	1, // 3: couchbase.gateway.scram.v1.ScramService.StartScram:output_type -> couchbase.gateway.scram.v1.StartScramResponse
+	3, // 4: couchbase.gateway.scram.v1.ScramService.FinishScram:output_type -> couchbase.gateway.scram.v1.FinishScramResponse
+	5, // 5: couchbase.gateway.scram.v1.ScramService.RevokeSession:output_type -> couchbase.gateway.scram.v1.RevokeSessionResponse
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_couchbase_gateway_scram_v1_scram_proto_init() }
+func file_couchbase_gateway_scram_v1_scram_proto_init() {
+	if File_couchbase_gateway_scram_v1_scram_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_couchbase_gateway_scram_v1_scram_proto_rawDesc), len(file_couchbase_gateway_scram_v1_scram_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_couchbase_gateway_scram_v1_scram_proto_goTypes,
+		DependencyIndexes: file_couchbase_gateway_scram_v1_scram_proto_depIdxs,
+		MessageInfos:      file_couchbase_gateway_scram_v1_scram_proto_msgTypes,
+	}.Build()
+	File_couchbase_gateway_scram_v1_scram_proto = out.File
+	file_couchbase_gateway_scram_v1_scram_proto_goTypes = nil
+	file_couchbase_gateway_scram_v1_scram_proto_depIdxs = nil
+}
@@ -0,0 +1,205 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: couchbase/gateway/scram/v1/scram.proto
+
+package scram_v1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ScramService_StartScram_FullMethodName    = "/couchbase.gateway.scram.v1.ScramService/StartScram"
+	ScramService_FinishScram_FullMethodName   = "/couchbase.gateway.scram.v1.ScramService/FinishScram"
+	ScramService_RevokeSession_FullMethodName = "/couchbase.gateway.scram.v1.ScramService/RevokeSession"
+)
+
+// ScramServiceClient is the client API for ScramService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ScramService allows clients to authenticate using SCRAM rather than sending
+// their password on every request.  A successful handshake produces a session
+// token, which is then presented as a bearer token on later requests.
+type ScramServiceClient interface {
+	StartScram(ctx context.Context, in *StartScramRequest, opts ...grpc.CallOption) (*StartScramResponse, error)
+	FinishScram(ctx context.Context, in *FinishScramRequest, opts ...grpc.CallOption) (*FinishScramResponse, error)
+	RevokeSession(ctx context.Context, in *RevokeSessionRequest, opts ...grpc.CallOption) (*RevokeSessionResponse, error)
+}
+
+type scramServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewScramServiceClient(cc grpc.ClientConnInterface) ScramServiceClient {
+	return &scramServiceClient{cc}
+}
+
+func (c *scramServiceClient) StartScram(ctx context.Context, in *StartScramRequest, opts ...grpc.CallOption) (*StartScramResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartScramResponse)
+	err := c.cc.Invoke(ctx, ScramService_StartScram_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *scramServiceClient) FinishScram(ctx context.Context, in *FinishScramRequest, opts ...grpc.CallOption) (*FinishScramResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FinishScramResponse)
+	err := c.cc.Invoke(ctx, ScramService_FinishScram_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *scramServiceClient) RevokeSession(ctx context.Context, in *RevokeSessionRequest, opts ...grpc.CallOption) (*RevokeSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RevokeSessionResponse)
+	err := c.cc.Invoke(ctx, ScramService_RevokeSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ScramServiceServer is the server API for ScramService service.
+// All implementations must embed UnimplementedScramServiceServer
+// for forward compatibility.
+//
+// ScramService allows clients to authenticate using SCRAM rather than sending
+// their password on every request.  A successful handshake produces a session
+// token, which is then presented as a bearer token on later requests.
+type ScramServiceServer interface {
+	StartScram(context.Context, *StartScramRequest) (*StartScramResponse, error)
+	FinishScram(context.Context, *FinishScramRequest) (*FinishScramResponse, error)
+	RevokeSession(context.Context, *RevokeSessionRequest) (*RevokeSessionResponse, error)
+	mustEmbedUnimplementedScramServiceServer()
+}
+
+// UnimplementedScramServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedScramServiceServer struct{}
+
+func (UnimplementedScramServiceServer) StartScram(context.Context, *StartScramRequest) (*StartScramResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartScram not implemented")
+}
+func (UnimplementedScramServiceServer) FinishScram(context.Context, *FinishScramRequest) (*FinishScramResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FinishScram not implemented")
+}
+func (UnimplementedScramServiceServer) RevokeSession(context.Context, *RevokeSessionRequest) (*RevokeSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeSession not implemented")
+}
+func (UnimplementedScramServiceServer) mustEmbedUnimplementedScramServiceServer() {}
+func (UnimplementedScramServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeScramServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ScramServiceServer will
+// result in compilation errors.
+type UnsafeScramServiceServer interface {
+	mustEmbedUnimplementedScramServiceServer()
+}
+
+func RegisterScramServiceServer(s grpc.ServiceRegistrar, srv ScramServiceServer) {
+	// If the following call pancis, it indicates UnimplementedScramServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ScramService_ServiceDesc, srv)
+}
+
+func _ScramService_StartScram_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartScramRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScramServiceServer).StartScram(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ScramService_StartScram_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScramServiceServer).StartScram(ctx, req.(*StartScramRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ScramService_FinishScram_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishScramRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScramServiceServer).FinishScram(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ScramService_FinishScram_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScramServiceServer).FinishScram(ctx, req.(*FinishScramRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ScramService_RevokeSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScramServiceServer).RevokeSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ScramService_RevokeSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScramServiceServer).RevokeSession(ctx, req.(*RevokeSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ScramService_ServiceDesc is the grpc.ServiceDesc for ScramService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ScramService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "couchbase.gateway.scram.v1.ScramService",
+	HandlerType: (*ScramServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartScram",
+			Handler:    _ScramService_StartScram_Handler,
+		},
+		{
+			MethodName: "FinishScram",
+			Handler:    _ScramService_FinishScram_Handler,
+		},
+		{
+			MethodName: "RevokeSession",
+			Handler:    _ScramService_RevokeSession_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "couchbase/gateway/scram/v1/scram.proto",
+}
@@ -12,6 +12,7 @@ type SnMetrics struct {
 	NewConnections    metric.Float64Counter
 	ActiveConnections metric.Float64UpDownCounter
 	ClientNames       metric.Int64Counter
+	CertificateExpiry metric.Float64Gauge
 }
 
 var (
@@ -43,10 +44,12 @@ func newSnMetrics() *SnMetrics {
 	newConnections, _ := meter.Float64Counter("grpc_connections_total")
 	activeConnections, _ := meter.Float64UpDownCounter("grpc_connections")
 	clientNames, _ := meter.Int64Counter("grpc_client_names")
+	certificateExpiry, _ := meter.Float64Gauge("tls_certificate_expiry_timestamp_seconds")
 
 	return &SnMetrics{
 		NewConnections:    newConnections,
 		ActiveConnections: activeConnections,
 		ClientNames:       clientNames,
+		CertificateExpiry: certificateExpiry,
 	}
 }
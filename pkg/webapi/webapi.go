@@ -19,6 +19,9 @@ type WebServerOptions struct {
 	LogLevel      *zap.AtomicLevel
 	ListenAddress string
 	EnablePprof   bool
+
+	// AuthLockouts serves the state of failed authentication lockouts.
+	AuthLockouts http.Handler
 }
 
 type WebServer struct {
@@ -28,6 +31,7 @@ type WebServer struct {
 	httpServer    *http.Server
 	isHealthy     atomic.Bool
 	enablePprof   bool
+	authLockouts  http.Handler
 }
 
 func newWebServer(opts WebServerOptions) *WebServer {
@@ -36,6 +40,7 @@ func newWebServer(opts WebServerOptions) *WebServer {
 		logLevel:      opts.LogLevel,
 		listenAddress: opts.ListenAddress,
 		enablePprof:   opts.EnablePprof,
+		authLockouts:  opts.AuthLockouts,
 	}
 }
 
@@ -82,6 +87,10 @@ func (w *WebServer) ListenAndServe() error {
 	r.HandleFunc("/ready", w.handleReady)
 	r.HandleFunc("/", w.handleRoot)
 
+	if w.authLockouts != nil {
+		r.Handle("/auth/lockouts", w.authLockouts).Methods(http.MethodGet)
+	}
+
 	if w.enablePprof {
 		r.HandleFunc("/debug/pprof/", pprof.Index)
 		r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
@@ -7,6 +7,9 @@ import (
 
 func DecodeBasicAuth(hdr string) (string, string, bool) {
 	auth := []byte(hdr)
+	if len(auth) < 6 {
+		return "", "", false
+	}
 
 	if auth[0] != 'b' && auth[0] != 'B' {
 		return "", "", false
@@ -55,3 +58,18 @@ func DecodeBasicAuth(hdr string) (string, string, bool) {
 
 	return username, password, true
 }
+
+// DecodeBearerAuth extracts the token from a bearer authorization header.
+func DecodeBearerAuth(hdr string) (string, bool) {
+	const prefix = "Bearer "
+	if len(hdr) < len(prefix) || !strings.EqualFold(hdr[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	token := strings.TrimSpace(hdr[len(prefix):])
+	if token == "" {
+		return "", false
+	}
+
+	return token, true
+}
@@ -32,6 +32,28 @@ func TestBasic(t *testing.T) {
 	}
 }
 
+func TestBearer(t *testing.T) {
+	token, ok := authhdr.DecodeBearerAuth("Bearer abc.def.ghi")
+	if !ok || token != "abc.def.ghi" {
+		t.Fatalf("Failed to decode bearer header: %s", token)
+	}
+
+	token, ok = authhdr.DecodeBearerAuth("bearer abc.def.ghi")
+	if !ok || token != "abc.def.ghi" {
+		t.Fatalf("Failed to decode lowercase bearer header: %s", token)
+	}
+
+	_, ok = authhdr.DecodeBearerAuth(TEST_HEADER)
+	if ok {
+		t.Fatalf("Unexpectedly decoded basic header as bearer")
+	}
+
+	_, ok = authhdr.DecodeBearerAuth("Bearer ")
+	if ok {
+		t.Fatalf("Unexpectedly decoded empty bearer header")
+	}
+}
+
 func BenchmarkHttp(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		r := http.Request{
@@ -54,3 +76,11 @@ func BenchmarkLib(b *testing.B) {
 		}
 	}
 }
+
+func TestBasicShort(t *testing.T) {
+	for _, hdr := range []string{"", "Bas", "Basic"} {
+		if _, _, ok := authhdr.DecodeBasicAuth(hdr); ok {
+			t.Fatalf("Decoded invalid header: %s", hdr)
+		}
+	}
+}
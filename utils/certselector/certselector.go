@@ -0,0 +1,105 @@
+package certselector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"strings"
+)
+
+// Selector picks the certificate to present to a client based on the server
+// name it requested via SNI, falling back to a default certificate when the
+// client did not send a server name or no certificate matches it.
+type Selector struct {
+	defaultCert *tls.Certificate
+	exact       map[string]*tls.Certificate
+	wildcard    map[string]*tls.Certificate
+}
+
+// New builds a selector from a default certificate and a list of additional
+// certificates which are matched against the DNS names they are issued for.
+// Where multiple certificates cover the same name, the earliest one wins.
+func New(defaultCert tls.Certificate, sniCerts []tls.Certificate) (*Selector, error) {
+	s := &Selector{
+		defaultCert: &defaultCert,
+		exact:       make(map[string]*tls.Certificate),
+		wildcard:    make(map[string]*tls.Certificate),
+	}
+
+	for i := range sniCerts {
+		cert := &sniCerts[i]
+
+		names, err := certificateNames(cert)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range names {
+			name = normalizeName(name)
+
+			if suffix, ok := strings.CutPrefix(name, "*."); ok {
+				if _, exists := s.wildcard[suffix]; !exists {
+					s.wildcard[suffix] = cert
+				}
+			} else {
+				if _, exists := s.exact[name]; !exists {
+					s.exact[name] = cert
+				}
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// Select returns the certificate to use for a particular server name.  Exact
+// matches take priority over wildcard matches, and wildcards only match a
+// single label, as per RFC 6125.
+func (s *Selector) Select(serverName string) *tls.Certificate {
+	if serverName == "" {
+		return s.defaultCert
+	}
+
+	name := normalizeName(serverName)
+	if cert := s.exact[name]; cert != nil {
+		return cert
+	}
+
+	if _, suffix, ok := strings.Cut(name, "."); ok {
+		if cert := s.wildcard[suffix]; cert != nil {
+			return cert
+		}
+	}
+
+	return s.defaultCert
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func certificateNames(cert *tls.Certificate) ([]string, error) {
+	leaf := cert.Leaf
+	if leaf == nil {
+		if len(cert.Certificate) == 0 {
+			return nil, errors.New("certificate is empty")
+		}
+
+		parsedLeaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+
+		leaf = parsedLeaf
+	}
+
+	if len(leaf.DNSNames) > 0 {
+		return leaf.DNSNames, nil
+	}
+
+	if leaf.Subject.CommonName != "" {
+		return []string{leaf.Subject.CommonName}, nil
+	}
+
+	return nil, errors.New("certificate does not specify any dns names")
+}
@@ -0,0 +1,83 @@
+package certselector
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateCert(t *testing.T, dnsNames ...string) tls.Certificate {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+
+	return tls.Certificate{
+		Certificate: [][]byte{certBytes},
+		PrivateKey:  priv,
+	}
+}
+
+func TestSelector(t *testing.T) {
+	defaultCert := generateCert(t, "default.example.com")
+	internalCert := generateCert(t, "gateway.internal.example.com")
+	partnerCert := generateCert(t, "*.partner.example.com", "partner.example.com")
+	legacyCert := generateCert(t, "gateway.legacy.net")
+
+	selector, err := New(defaultCert, []tls.Certificate{internalCert, partnerCert, legacyCert})
+	require.NoError(t, err)
+
+	tests := []struct {
+		serverName string
+		expected   *tls.Certificate
+	}{
+		{"", &defaultCert},
+		{"gateway.internal.example.com", &internalCert},
+		{"GATEWAY.Internal.Example.com.", &internalCert},
+		{"partner.example.com", &partnerCert},
+		{"eu.partner.example.com", &partnerCert},
+		{"a.eu.partner.example.com", &defaultCert},
+		{"gateway.legacy.net", &legacyCert},
+		{"unknown.example.com", &defaultCert},
+	}
+
+	for _, test := range tests {
+		t.Run(test.serverName, func(t *testing.T) {
+			cert := selector.Select(test.serverName)
+			assert.Equal(t, test.expected.Certificate, cert.Certificate)
+		})
+	}
+}
+
+func TestSelectorExactBeatsWildcard(t *testing.T) {
+	defaultCert := generateCert(t, "default.example.com")
+	wildcardCert := generateCert(t, "*.example.com")
+	exactCert := generateCert(t, "api.example.com")
+
+	selector, err := New(defaultCert, []tls.Certificate{wildcardCert, exactCert})
+	require.NoError(t, err)
+
+	assert.Equal(t, exactCert.Certificate, selector.Select("api.example.com").Certificate)
+	assert.Equal(t, wildcardCert.Certificate, selector.Select("web.example.com").Certificate)
+}
+
+func TestSelectorRejectsUnnamedCert(t *testing.T) {
+	_, err := New(generateCert(t), []tls.Certificate{generateCert(t)})
+	assert.Error(t, err)
+}
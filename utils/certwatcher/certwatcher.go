@@ -0,0 +1,185 @@
+package certwatcher
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+const defaultDebounce = 500 * time.Millisecond
+
+// kubernetesDataDir is the symlink which kubernetes atomically swaps when the
+// contents of a mounted secret change.  The watched files themselves are
+// symlinks through this, so their own names never appear in the events.
+const kubernetesDataDir = "..data"
+
+type Options struct {
+	Logger *zap.Logger
+
+	// Paths is the list of files to watch for changes.
+	Paths []string
+
+	// Debounce specifies how long to wait for further changes before invoking
+	// OnChange, so that a cert and key written separately are reloaded once.
+	Debounce time.Duration
+
+	// OnChange is invoked whenever any of the watched files changes.
+	OnChange func()
+}
+
+// Watcher watches a set of files (typically certificates and their keys) and
+// notifies when any of them are modified, replaced or re-linked.
+type Watcher struct {
+	logger   *zap.Logger
+	debounce time.Duration
+	onChange func()
+	watcher  *fsnotify.Watcher
+
+	// files maps each watched directory to the names within it we care about.
+	files map[string]map[string]struct{}
+
+	lock   sync.Mutex
+	timer  *time.Timer
+	closed bool
+	doneCh chan struct{}
+}
+
+func New(opts *Options) (*Watcher, error) {
+	debounce := opts.Debounce
+	if debounce <= 0 {
+		debounce = defaultDebounce
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		logger:   opts.Logger,
+		debounce: debounce,
+		onChange: opts.OnChange,
+		watcher:  fsWatcher,
+		files:    make(map[string]map[string]struct{}),
+		doneCh:   make(chan struct{}),
+	}
+
+	// we watch the parent directories rather than the files themselves, as
+	// most tooling replaces files via rename, which would otherwise silently
+	// end the watch on the original file.
+	for _, path := range opts.Paths {
+		if path == "" {
+			continue
+		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			_ = fsWatcher.Close()
+			return nil, err
+		}
+
+		dir, name := filepath.Split(absPath)
+		dir = filepath.Clean(dir)
+
+		if w.files[dir] == nil {
+			err := fsWatcher.Add(dir)
+			if err != nil {
+				_ = fsWatcher.Close()
+				return nil, err
+			}
+
+			w.files[dir] = map[string]struct{}{
+				kubernetesDataDir: {},
+			}
+		}
+
+		w.files[dir][name] = struct{}{}
+	}
+
+	go w.watchThread()
+
+	return w, nil
+}
+
+func (w *Watcher) isWatched(path string) bool {
+	dir, name := filepath.Split(filepath.Clean(path))
+	names := w.files[filepath.Clean(dir)]
+	if names == nil {
+		return false
+	}
+
+	_, ok := names[name]
+	return ok
+}
+
+func (w *Watcher) watchThread() {
+	defer close(w.doneCh)
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Has(fsnotify.Chmod) || !w.isWatched(event.Name) {
+				continue
+			}
+
+			w.logger.Debug("watched file changed",
+				zap.String("path", event.Name),
+				zap.String("op", event.Op.String()))
+
+			w.scheduleChange()
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+
+			w.logger.Warn("error watching files", zap.Error(err))
+		}
+	}
+}
+
+func (w *Watcher) scheduleChange() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	if w.timer != nil {
+		w.timer.Reset(w.debounce)
+		return
+	}
+
+	w.timer = time.AfterFunc(w.debounce, func() {
+		w.lock.Lock()
+		w.timer = nil
+		closed := w.closed
+		w.lock.Unlock()
+
+		if !closed {
+			w.onChange()
+		}
+	})
+}
+
+// Close stops watching for changes.
+func (w *Watcher) Close() error {
+	w.lock.Lock()
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.lock.Unlock()
+
+	err := w.watcher.Close()
+	<-w.doneCh
+	return err
+}
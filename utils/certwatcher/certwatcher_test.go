@@ -0,0 +1,60 @@
+package certwatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func waitForChange(t *testing.T, changeCh chan struct{}) {
+	select {
+	case <-changeCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change notification")
+	}
+}
+
+func TestWatcherDetectsChanges(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "tls.crt")
+	keyPath := filepath.Join(dir, "tls.key")
+	otherPath := filepath.Join(dir, "other.txt")
+
+	require.NoError(t, os.WriteFile(certPath, []byte("cert-1"), 0600))
+	require.NoError(t, os.WriteFile(keyPath, []byte("key-1"), 0600))
+
+	changeCh := make(chan struct{}, 10)
+	w, err := New(&Options{
+		Logger:   zap.NewNop(),
+		Paths:    []string{certPath, keyPath},
+		Debounce: 50 * time.Millisecond,
+		OnChange: func() {
+			changeCh <- struct{}{}
+		},
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	// writing both files in quick succession should produce a single change
+	require.NoError(t, os.WriteFile(certPath, []byte("cert-2"), 0600))
+	require.NoError(t, os.WriteFile(keyPath, []byte("key-2"), 0600))
+	waitForChange(t, changeCh)
+
+	// replacing a file via rename should also be detected
+	tmpPath := filepath.Join(dir, "tls.crt.tmp")
+	require.NoError(t, os.WriteFile(tmpPath, []byte("cert-3"), 0600))
+	require.NoError(t, os.Rename(tmpPath, certPath))
+	waitForChange(t, changeCh)
+
+	// unrelated files in the same directory should be ignored
+	require.NoError(t, os.WriteFile(otherPath, []byte("other"), 0600))
+	select {
+	case <-changeCh:
+		t.Fatal("unexpected change notification for unwatched file")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
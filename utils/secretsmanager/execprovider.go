@@ -0,0 +1,65 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const defaultExecTimeout = 30 * time.Second
+
+type execProvider struct {
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+// newExecProvider handles `exec:///<command>?arg=<arg>&timeout=<duration>`.
+// The command is run each time the secret is needed, and must write the
+// secret to stdout.
+func newExecProvider(uri *url.URL) (SecretProvider, error) {
+	command := uriTarget(uri)
+	if command == "" {
+		return nil, fmt.Errorf("must specify a command when fetching secrets from a plugin")
+	}
+
+	query := uri.Query()
+
+	timeout := defaultExecTimeout
+	if timeoutStr := query.Get("timeout"); timeoutStr != "" {
+		parsedTimeout, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exec plugin timeout: %w", err)
+		}
+
+		timeout = parsedTimeout
+	}
+
+	return &execProvider{
+		command: command,
+		args:    query["arg"],
+		timeout: timeout,
+	}, nil
+}
+
+func (p *execProvider) FetchSecret(ctx context.Context) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.command, p.args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("secret plugin failed: %w (stderr: %s)",
+			err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,72 @@
+package secretsmanager
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type fileProvider struct {
+	path string
+	key  string
+}
+
+// newFileProvider handles `file:///<path>[?key=<name>]`.  The path may either
+// be a single file, or a directory laid out like a mounted Kubernetes secret
+// with one file per key, in which case credentials are read from the
+// `username` and `password` keys.
+func newFileProvider(uri *url.URL) (SecretProvider, error) {
+	path := uriTarget(uri)
+	if path == "" {
+		return nil, fmt.Errorf("must specify a path when reading secrets from files")
+	}
+
+	return &fileProvider{
+		path: path,
+		key:  uri.Query().Get("key"),
+	}, nil
+}
+
+func (p *fileProvider) readKey(key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(p.path, key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	return data, nil
+}
+
+func (p *fileProvider) FetchSecret(ctx context.Context) ([]byte, error) {
+	return p.readKey(p.key)
+}
+
+func (p *fileProvider) FetchCredentials(ctx context.Context) (string, string, error) {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	if !info.IsDir() || p.key != "" {
+		secret, err := p.FetchSecret(ctx)
+		if err != nil {
+			return "", "", err
+		}
+
+		return credsFromSecret(strings.TrimSpace(string(secret)))
+	}
+
+	username, err := p.readKey("username")
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err := p.readKey("password")
+	if err != nil {
+		return "", "", err
+	}
+
+	return strings.TrimSpace(string(username)), strings.TrimSpace(string(password)), nil
+}
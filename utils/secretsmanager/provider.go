@@ -0,0 +1,217 @@
+package secretsmanager
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SecretProvider fetches a secret from a secret store.  A new value is
+// fetched on every call, allowing secrets to be rotated.
+type SecretProvider interface {
+	FetchSecret(ctx context.Context) ([]byte, error)
+}
+
+// CredentialsProvider is implemented by providers whose secrets natively
+// store a username and password separately, rather than as a single
+// `username:password` value.
+type CredentialsProvider interface {
+	FetchCredentials(ctx context.Context) (string, string, error)
+}
+
+// ProviderFactory creates a provider from a secret reference URI.
+type ProviderFactory func(uri *url.URL) (SecretProvider, error)
+
+var (
+	providers     = make(map[string]ProviderFactory)
+	providersLock sync.Mutex
+)
+
+// RegisterProvider registers a provider for secret references using the
+// specified URI scheme.
+func RegisterProvider(scheme string, factory ProviderFactory) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+
+	providers[scheme] = factory
+}
+
+func init() {
+	RegisterProvider("aws", newAwsProvider)
+	RegisterProvider("azure", newAzureProvider)
+	RegisterProvider("gcp", newGcpProvider)
+	RegisterProvider("file", newFileProvider)
+	RegisterProvider("exec", newExecProvider)
+	RegisterProvider("vault", newVaultProvider)
+	RegisterProvider("vault+http", newVaultProvider)
+}
+
+func lookupProvider(scheme string) ProviderFactory {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+
+	return providers[scheme]
+}
+
+// NewProvider creates a provider from a secret reference, such as
+// `aws://my-secret?region=us-east-1` or `file:///etc/couchbase/creds`.
+func NewProvider(ref string) (SecretProvider, error) {
+	uri, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret reference: %w", err)
+	}
+
+	factory := lookupProvider(uri.Scheme)
+	if factory == nil {
+		providersLock.Lock()
+		schemes := make([]string, 0, len(providers))
+		for scheme := range providers {
+			schemes = append(schemes, scheme)
+		}
+		providersLock.Unlock()
+		sort.Strings(schemes)
+
+		return nil, fmt.Errorf("unsupported secret source `%s`, expected one of: %s",
+			uri.Scheme, strings.Join(schemes, ", "))
+	}
+
+	return factory(uri)
+}
+
+// IsSecretReference returns whether a string refers to a secret from one of
+// the registered providers, rather than being a plain file path.
+func IsSecretReference(ref string) bool {
+	scheme, _, found := strings.Cut(ref, ":")
+	if !found {
+		return false
+	}
+
+	// single letter schemes are windows drive letters
+	if len(scheme) <= 1 {
+		return false
+	}
+
+	return lookupProvider(scheme) != nil
+}
+
+// FetchCredentials fetches a username and password from a provider.
+func FetchCredentials(ctx context.Context, provider SecretProvider) (string, string, error) {
+	if credsProvider, ok := provider.(CredentialsProvider); ok {
+		return credsProvider.FetchCredentials(ctx)
+	}
+
+	secret, err := provider.FetchSecret(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	return credsFromSecret(strings.TrimSpace(string(secret)))
+}
+
+// uriTarget returns the portion of a reference identifying the secret, this
+// supports both `scheme://target` and `scheme:target` forms, the latter being
+// required for identifiers such as ARNs.
+func uriTarget(uri *url.URL) string {
+	if uri.Opaque != "" {
+		return uri.Opaque
+	}
+
+	return uri.Host + uri.Path
+}
+
+type awsProvider struct {
+	secretId string
+	region   string
+}
+
+// newAwsProvider handles `aws://<secret-id>?region=<region>`.
+func newAwsProvider(uri *url.URL) (SecretProvider, error) {
+	secretId := uriTarget(uri)
+	region := uri.Query().Get("region")
+	if secretId == "" || region == "" {
+		return nil, fmt.Errorf("must specify region and id when fetching secrets from aws")
+	}
+
+	return NewAwsProvider(secretId, region), nil
+}
+
+func NewAwsProvider(secretId, region string) SecretProvider {
+	return &awsProvider{
+		secretId: secretId,
+		region:   region,
+	}
+}
+
+func (p *awsProvider) FetchSecret(ctx context.Context) ([]byte, error) {
+	secret, err := fetchAWSSecretString(ctx, p.secretId, p.region)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(secret), nil
+}
+
+type azureProvider struct {
+	secretId     string
+	keyVaultName string
+}
+
+// newAzureProvider handles `azure://<key-vault-name>/<secret-id>`.
+func newAzureProvider(uri *url.URL) (SecretProvider, error) {
+	keyVaultName, secretId, _ := strings.Cut(uriTarget(uri), "/")
+	if keyVaultName == "" || secretId == "" {
+		return nil, fmt.Errorf("must specify key vault name and id when fetching secrets from azure")
+	}
+
+	return NewAzureProvider(secretId, keyVaultName), nil
+}
+
+func NewAzureProvider(secretId, keyVaultName string) SecretProvider {
+	return &azureProvider{
+		secretId:     secretId,
+		keyVaultName: keyVaultName,
+	}
+}
+
+func (p *azureProvider) FetchSecret(ctx context.Context) ([]byte, error) {
+	secret, err := fetchAzureSecretString(ctx, p.secretId, p.keyVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(secret), nil
+}
+
+type gcpProvider struct {
+	secretId  string
+	projectId string
+}
+
+// newGcpProvider handles `gcp://<project-id>/<secret-id>`.
+func newGcpProvider(uri *url.URL) (SecretProvider, error) {
+	projectId, secretId, _ := strings.Cut(uriTarget(uri), "/")
+	if projectId == "" || secretId == "" {
+		return nil, fmt.Errorf("must specify project and secret ids when fetching secrets from gcp")
+	}
+
+	return NewGcpProvider(secretId, projectId), nil
+}
+
+func NewGcpProvider(secretId, projectId string) SecretProvider {
+	return &gcpProvider{
+		secretId:  secretId,
+		projectId: projectId,
+	}
+}
+
+func (p *gcpProvider) FetchSecret(ctx context.Context) ([]byte, error) {
+	secret, err := fetchGcpSecretString(ctx, p.secretId, p.projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(secret), nil
+}
@@ -0,0 +1,92 @@
+package secretsmanager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProvider(t *testing.T) {
+	_, err := NewProvider("aws://my-secret?region=us-east-1")
+	assert.NoError(t, err)
+
+	_, err = NewProvider("aws:arn:aws:secretsmanager:us-east-1:123456789012:secret:creds?region=us-east-1")
+	assert.NoError(t, err)
+
+	_, err = NewProvider("aws://my-secret")
+	assert.Error(t, err)
+
+	_, err = NewProvider("azure://my-vault/my-secret")
+	assert.NoError(t, err)
+
+	_, err = NewProvider("gcp://my-project")
+	assert.Error(t, err)
+
+	_, err = NewProvider("unknown://something")
+	assert.ErrorContains(t, err, "unsupported secret source")
+}
+
+func TestIsSecretReference(t *testing.T) {
+	assert.True(t, IsSecretReference("file:///etc/secrets/tls"))
+	assert.True(t, IsSecretReference("vault://vault:8200/secret/tls?key=tls.key"))
+	assert.False(t, IsSecretReference("/etc/secrets/tls.key"))
+	assert.False(t, IsSecretReference(`C:\secrets\tls.key`))
+	assert.False(t, IsSecretReference("unknown://something"))
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "username"), []byte("user\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "password"), []byte("pass\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tls.key"), []byte("key-data"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "creds"), []byte("user2:pass2"), 0600))
+
+	t.Run("Directory", func(t *testing.T) {
+		provider, err := NewProvider("file://" + dir)
+		require.NoError(t, err)
+
+		username, password, err := FetchCredentials(context.Background(), provider)
+		require.NoError(t, err)
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+	})
+
+	t.Run("SingleFile", func(t *testing.T) {
+		provider, err := NewProvider("file://" + filepath.Join(dir, "creds"))
+		require.NoError(t, err)
+
+		username, password, err := FetchCredentials(context.Background(), provider)
+		require.NoError(t, err)
+		assert.Equal(t, "user2", username)
+		assert.Equal(t, "pass2", password)
+	})
+
+	t.Run("Key", func(t *testing.T) {
+		provider, err := NewProvider("file://" + dir + "?key=tls.key")
+		require.NoError(t, err)
+
+		secret, err := provider.FetchSecret(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "key-data", string(secret))
+	})
+}
+
+func TestExecProvider(t *testing.T) {
+	provider, err := NewProvider("exec:///bin/sh?arg=-c&arg=echo+user:pass")
+	require.NoError(t, err)
+
+	username, password, err := FetchCredentials(context.Background(), provider)
+	require.NoError(t, err)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "pass", password)
+
+	provider, err = NewProvider("exec:///bin/sh?arg=-c&arg=echo+oops+>%262%3B+exit+1")
+	require.NoError(t, err)
+
+	_, err = provider.FetchSecret(context.Background())
+	assert.ErrorContains(t, err, "oops")
+}
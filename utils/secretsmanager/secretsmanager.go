@@ -14,55 +14,81 @@ import (
 )
 
 func FetchAWSSecret(secretId string, region string) (string, string, error) {
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+	secret, err := fetchAWSSecretString(context.Background(), secretId, region)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to load default aws config: %w", err)
+		return "", "", err
+	}
+
+	return credsFromSecret(secret)
+}
+
+func fetchAWSSecretString(ctx context.Context, secretId string, region string) (string, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return "", fmt.Errorf("failed to load default aws config: %w", err)
 	}
 
 	secrets := secretsmanager.NewFromConfig(cfg)
 	res, err := secrets.GetSecretValue(
-		context.Background(),
+		ctx,
 		&secretsmanager.GetSecretValueInput{SecretId: &secretId},
 	)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get aws secret: %w", err)
+		return "", fmt.Errorf("failed to get aws secret: %w", err)
 	}
 	if res.SecretString == nil {
-		return "", "", fmt.Errorf("aws secret %s not a string", secretId)
+		return "", fmt.Errorf("aws secret %s not a string", secretId)
 	}
 
-	return credsFromSecret(*res.SecretString)
+	return *res.SecretString, nil
 }
 
 func FetchAzureSecret(secretId string, keyVaultName string) (string, string, error) {
+	secret, err := fetchAzureSecretString(context.Background(), secretId, keyVaultName)
+	if err != nil {
+		return "", "", err
+	}
+
+	return credsFromSecret(secret)
+}
+
+func fetchAzureSecretString(ctx context.Context, secretId string, keyVaultName string) (string, error) {
 	vaultURI := fmt.Sprintf("https://%s.vault.azure.net/", keyVaultName)
 
 	// Create a credential using the NewDefaultAzureCredential type.
 	cred, err := azidentity.NewDefaultAzureCredential(nil)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to obtain azure credential: %w", err)
+		return "", fmt.Errorf("failed to obtain azure credential: %w", err)
 	}
 
 	client, err := azsecrets.NewClient(vaultURI, cred, nil)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create azure client: %w", err)
+		return "", fmt.Errorf("failed to create azure client: %w", err)
 	}
 
 	//  An empty string version gets the latest version of the secret.
 	version := ""
-	resp, err := client.GetSecret(context.Background(), secretId, version, nil)
+	resp, err := client.GetSecret(ctx, secretId, version, nil)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get azure secret: %w", err)
+		return "", fmt.Errorf("failed to get azure secret: %w", err)
 	}
 
-	return credsFromSecret(*resp.Value)
+	return *resp.Value, nil
 }
 
 func FetchGcpSecret(secretId string, projectId string) (string, string, error) {
-	ctx := context.Background()
+	secret, err := fetchGcpSecretString(context.Background(), secretId, projectId)
+	if err != nil {
+		return "", "", err
+	}
+
+	return credsFromSecret(secret)
+}
+
+func fetchGcpSecretString(ctx context.Context, secretId string, projectId string) (string, error) {
 	client, err := gcpsecretmanager.NewClient(ctx)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create gcp secretmanager client: %w", err)
+		return "", fmt.Errorf("failed to create gcp secretmanager client: %w", err)
 	}
 	defer func() {
 		_ = client.Close()
@@ -74,10 +100,10 @@ func FetchGcpSecret(secretId string, projectId string) (string, string, error) {
 
 	result, err := client.AccessSecretVersion(ctx, req)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get gcp secret: %w", err)
+		return "", fmt.Errorf("failed to get gcp secret: %w", err)
 	}
 
-	return credsFromSecret(string(result.Payload.Data[:]))
+	return string(result.Payload.Data[:]), nil
 }
 
 func credsFromSecret(secret string) (string, string, error) {
@@ -0,0 +1,332 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errVaultForbidden = errors.New("vault denied access to the secret")
+
+// vaultProvider reads secrets from a HashiCorp Vault KV v2 secrets engine.
+type vaultProvider struct {
+	httpClient *http.Client
+	address    string
+	namespace  string
+	mount      string
+	path       string
+	key        string
+
+	// token authentication
+	token     string
+	tokenFile string
+
+	// approle authentication
+	roleId       string
+	secretId     string
+	secretIdFile string
+	approleMount string
+
+	lock        sync.Mutex
+	loginToken  string
+	loginExpiry time.Time
+}
+
+// newVaultProvider handles `vault://<host>[:port]/<mount>/<path>`, with the
+// following optional query parameters:
+//
+//	key             the field of the secret to use, for non-credential secrets
+//	mount           the KV mount, if it contains slashes
+//	namespace       the vault enterprise namespace
+//	token_file      a file containing the vault token (otherwise VAULT_TOKEN)
+//	role_id         the AppRole role id (otherwise VAULT_ROLE_ID)
+//	secret_id_file  a file containing the AppRole secret id (otherwise VAULT_SECRET_ID)
+//	approle_mount   the mount of the AppRole auth method, defaults to approle
+//
+// The `vault+http` scheme may be used to connect without TLS.
+func newVaultProvider(uri *url.URL) (SecretProvider, error) {
+	query := uri.Query()
+
+	scheme := "https"
+	if uri.Scheme == "vault+http" {
+		scheme = "http"
+	}
+
+	if uri.Host == "" {
+		return nil, errors.New("must specify the vault server address")
+	}
+
+	fullPath := strings.Trim(uri.Path, "/")
+	mount := strings.Trim(query.Get("mount"), "/")
+	var secretPath string
+	if mount != "" {
+		secretPath = strings.TrimPrefix(strings.TrimPrefix(fullPath, mount), "/")
+	} else {
+		mount, secretPath, _ = strings.Cut(fullPath, "/")
+	}
+	if mount == "" || secretPath == "" {
+		return nil, errors.New("must specify both the mount and path of the vault secret")
+	}
+
+	p := &vaultProvider{
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		address:      scheme + "://" + uri.Host,
+		namespace:    query.Get("namespace"),
+		mount:        mount,
+		path:         secretPath,
+		key:          query.Get("key"),
+		token:        os.Getenv("VAULT_TOKEN"),
+		tokenFile:    query.Get("token_file"),
+		roleId:       query.Get("role_id"),
+		secretId:     os.Getenv("VAULT_SECRET_ID"),
+		secretIdFile: query.Get("secret_id_file"),
+		approleMount: query.Get("approle_mount"),
+	}
+
+	if p.namespace == "" {
+		p.namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if p.roleId == "" {
+		p.roleId = os.Getenv("VAULT_ROLE_ID")
+	}
+	if p.approleMount == "" {
+		p.approleMount = "approle"
+	}
+
+	if p.roleId == "" && p.token == "" && p.tokenFile == "" {
+		return nil, errors.New("must specify either a vault token or an approle role id")
+	}
+
+	return p, nil
+}
+
+func (p *vaultProvider) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, p.address+"/v1/"+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	return req, nil
+}
+
+func readFileTrimmed(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// getToken returns the token to authenticate with, logging in via AppRole
+// if necessary.  Tokens from AppRole are cached until they expire.
+func (p *vaultProvider) getToken(ctx context.Context) (string, error) {
+	if p.roleId == "" {
+		if p.tokenFile != "" {
+			// the token file is re-read every time, as it is typically
+			// maintained by a vault agent which renews it.
+			token, err := readFileTrimmed(p.tokenFile)
+			if err != nil {
+				return "", fmt.Errorf("failed to read vault token file: %w", err)
+			}
+
+			return token, nil
+		}
+
+		return p.token, nil
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.loginToken != "" && time.Now().Before(p.loginExpiry) {
+		return p.loginToken, nil
+	}
+
+	secretId := p.secretId
+	if p.secretIdFile != "" {
+		fileSecretId, err := readFileTrimmed(p.secretIdFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read vault secret id file: %w", err)
+		}
+
+		secretId = fileSecretId
+	}
+
+	loginBody, err := json.Marshal(map[string]string{
+		"role_id":   p.roleId,
+		"secret_id": secretId,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := p.newRequest(ctx, http.MethodPost, "auth/"+p.approleMount+"/login", bytes.NewReader(loginBody))
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to login to vault: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to login to vault: unexpected status code %d", resp.StatusCode)
+	}
+
+	var loginResp struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&loginResp)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse vault login response: %w", err)
+	}
+
+	if loginResp.Auth.ClientToken == "" {
+		return "", errors.New("vault login response did not contain a token")
+	}
+
+	// we stop using the token a little before it expires to avoid racing
+	// with its expiry on the server.
+	leaseDuration := time.Duration(loginResp.Auth.LeaseDuration) * time.Second
+	p.loginToken = loginResp.Auth.ClientToken
+	p.loginExpiry = time.Now().Add(leaseDuration * 9 / 10)
+
+	return p.loginToken, nil
+}
+
+func (p *vaultProvider) clearToken() {
+	p.lock.Lock()
+	p.loginToken = ""
+	p.lock.Unlock()
+}
+
+func (p *vaultProvider) readSecretOnce(ctx context.Context) (map[string]interface{}, error) {
+	token, err := p.getToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := p.newRequest(ctx, http.MethodGet, p.mount+"/data/"+p.path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault secret: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusForbidden {
+		return nil, errVaultForbidden
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read vault secret: unexpected status code %d", resp.StatusCode)
+	}
+
+	var secretResp struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&secretResp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vault secret: %w", err)
+	}
+
+	return secretResp.Data.Data, nil
+}
+
+func (p *vaultProvider) readSecret(ctx context.Context) (map[string]interface{}, error) {
+	data, err := p.readSecretOnce(ctx)
+	if errors.Is(err, errVaultForbidden) && p.roleId != "" {
+		// our cached token may have been revoked, so login again
+		p.clearToken()
+		data, err = p.readSecretOnce(ctx)
+	}
+
+	return data, err
+}
+
+func vaultField(data map[string]interface{}, key string) (string, error) {
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("vault secret does not contain the field `%s`", key)
+	}
+
+	strValue, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret field `%s` is not a string", key)
+	}
+
+	return strValue, nil
+}
+
+func (p *vaultProvider) FetchSecret(ctx context.Context) ([]byte, error) {
+	if p.key == "" {
+		return nil, errors.New("must specify the key of the vault secret to use")
+	}
+
+	data, err := p.readSecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := vaultField(data, p.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(value), nil
+}
+
+func (p *vaultProvider) FetchCredentials(ctx context.Context) (string, string, error) {
+	if p.key != "" {
+		secret, err := p.FetchSecret(ctx)
+		if err != nil {
+			return "", "", err
+		}
+
+		return credsFromSecret(string(secret))
+	}
+
+	data, err := p.readSecret(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	username, err := vaultField(data, "username")
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err := vaultField(data, "password")
+	if err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
@@ -0,0 +1,133 @@
+package secretsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newVaultStandIn starts a minimal stand-in for the vault KV v2 and AppRole
+// APIs, serving a single secret at secret/couchbase.
+func newVaultStandIn(t *testing.T, numLogins *atomic.Int32) *httptest.Server {
+	var currentToken atomic.Value
+	currentToken.Store("static-token")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			var req map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req["role_id"] != "my-role" || req["secret_id"] != "my-secret-id" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			token := "approle-token-" + string(rune('a'+numLogins.Add(1)))
+			currentToken.Store(token)
+
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"auth": map[string]interface{}{
+					"client_token":   token,
+					"lease_duration": 3600,
+				},
+			})
+		case "/v1/secret/data/couchbase":
+			if r.Header.Get("X-Vault-Token") != currentToken.Load().(string) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data": map[string]interface{}{
+						"username": "vault-user",
+						"password": "vault-pass",
+						"tls.key":  "key-data",
+					},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func vaultRef(srv *httptest.Server, query string) string {
+	return "vault+http://" + strings.TrimPrefix(srv.URL, "http://") + "/secret/couchbase" + query
+}
+
+func TestVaultProviderToken(t *testing.T) {
+	var numLogins atomic.Int32
+	srv := newVaultStandIn(t, &numLogins)
+
+	t.Setenv("VAULT_TOKEN", "static-token")
+
+	provider, err := NewProvider(vaultRef(srv, ""))
+	require.NoError(t, err)
+
+	username, password, err := FetchCredentials(context.Background(), provider)
+	require.NoError(t, err)
+	assert.Equal(t, "vault-user", username)
+	assert.Equal(t, "vault-pass", password)
+
+	keyProvider, err := NewProvider(vaultRef(srv, "?key=tls.key"))
+	require.NoError(t, err)
+
+	secret, err := keyProvider.FetchSecret(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "key-data", string(secret))
+
+	missingProvider, err := NewProvider(vaultRef(srv, "?key=missing"))
+	require.NoError(t, err)
+
+	_, err = missingProvider.FetchSecret(context.Background())
+	assert.Error(t, err)
+}
+
+func TestVaultProviderAppRole(t *testing.T) {
+	var numLogins atomic.Int32
+	srv := newVaultStandIn(t, &numLogins)
+
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("VAULT_SECRET_ID", "my-secret-id")
+
+	provider, err := NewProvider(vaultRef(srv, "?role_id=my-role"))
+	require.NoError(t, err)
+
+	username, _, err := FetchCredentials(context.Background(), provider)
+	require.NoError(t, err)
+	assert.Equal(t, "vault-user", username)
+
+	// the login token should be reused for subsequent fetches
+	_, _, err = FetchCredentials(context.Background(), provider)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), numLogins.Load())
+
+	// if the token is revoked, we should login again
+	vp := provider.(*vaultProvider)
+	vp.lock.Lock()
+	vp.loginToken = "revoked-token"
+	vp.lock.Unlock()
+
+	_, _, err = FetchCredentials(context.Background(), provider)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), numLogins.Load())
+}
+
+func TestVaultProviderRequiresAuth(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("VAULT_ROLE_ID", "")
+
+	_, err := NewProvider("vault://vault:8200/secret/couchbase")
+	assert.Error(t, err)
+}